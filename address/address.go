@@ -0,0 +1,370 @@
+package address
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dimuls/swan/entity"
+)
+
+// Address is a parsed postal address of the flat or the building.
+type Address struct {
+	City       string
+	StreetType string
+	Street     string
+	House      string
+	Block      string
+	Structure  string
+	Flat       string
+}
+
+// streetTypes maps street type spellings to the canonical abbreviations.
+var streetTypes = map[string]string{
+	"ул":         "ул.",
+	"улица":      "ул.",
+	"пр":         "пр-т",
+	"пр-т":       "пр-т",
+	"просп":      "пр-т",
+	"проспект":   "пр-т",
+	"пер":        "пер.",
+	"переулок":   "пер.",
+	"б-р":        "б-р",
+	"бул":        "б-р",
+	"бульвар":    "б-р",
+	"ш":          "ш.",
+	"шоссе":      "ш.",
+	"пл":         "пл.",
+	"площадь":    "пл.",
+	"наб":        "наб.",
+	"набережная": "наб.",
+	"проезд":     "проезд",
+	"пр-д":       "проезд",
+	"туп":        "туп.",
+	"тупик":      "туп.",
+	"мкр":        "мкр.",
+	"микрорайон": "мкр.",
+}
+
+var (
+	cityMarkers      = map[string]bool{"г": true, "город": true}
+	houseMarkers     = map[string]bool{"д": true, "дом": true}
+	blockMarkers     = map[string]bool{"к": true, "корп": true, "корпус": true}
+	structureMarkers = map[string]bool{"стр": true, "строение": true}
+	flatMarkers      = map[string]bool{
+		"кв": true, "квартира": true, "оф": true, "офис": true,
+	}
+)
+
+// tokenRegexp matches words with optional trailing dot, house numbers with
+// optional letter and fraction, and commas.
+var tokenRegexp = regexp.MustCompile(
+	`[\p{L}][\p{L}-]*\.?|\d+(?:/\d+)?(?:[\p{L}](?:[^\p{L}\d]|$))?|,`)
+
+type token struct {
+	text   string
+	word   string
+	number bool
+	comma  bool
+}
+
+func tokenize(s string) []token {
+	var ts []token
+	for _, m := range tokenRegexp.FindAllString(s, -1) {
+		if m == "," {
+			ts = append(ts, token{text: m, comma: true})
+			continue
+		}
+		m = strings.TrimRightFunc(m, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+		})
+		switch {
+		case unicode.IsDigit([]rune(m)[0]):
+			ts = append(ts, token{text: strings.ToLower(m), number: true})
+		default:
+			ts = append(ts, token{text: m,
+				word: strings.ToLower(strings.TrimSuffix(m, "."))})
+		}
+	}
+	return ts
+}
+
+// Parse parses free text address like "ул. Ленина 5 кв 3" or
+// "Ленина, д.5, 3". Street and house are required. Street without type is
+// a street, so both examples are the same building. Number right after the
+// street type is a part of the street name like in "ул. 8 Марта, 12".
+// Number following the house without marker is the flat number. Comma
+// separated part right before the street is the city, regions before it
+// are ignored.
+func Parse(s string) (Address, error) {
+	var (
+		a       Address
+		parts   [][]string
+		words   []string
+		pending string
+
+		// numbered is true right after the street type preceding the
+		// street name.
+		numbered bool
+	)
+
+	flush := func() {
+		if len(words) > 0 {
+			parts = append(parts, words)
+			words = nil
+		}
+	}
+
+	for _, t := range tokenize(s) {
+		streetNumber := numbered
+		numbered = false
+
+		switch {
+		case t.number && streetNumber:
+			words = append(words, t.text)
+		case t.comma:
+			if a.House == "" {
+				flush()
+				if pending == "city" {
+					pending = ""
+				}
+			}
+		case t.number:
+			switch {
+			case a.House == "":
+				if a.Street == "" && len(words) == 0 && len(parts) > 0 {
+					words = parts[len(parts)-1]
+					parts = parts[:len(parts)-1]
+				}
+				if len(words) > 0 {
+					a.Street = strings.Join(words, " ")
+					words = nil
+				}
+				a.House = t.text
+			case pending == "block":
+				a.Block = t.text
+			case pending == "structure":
+				a.Structure = t.text
+			default:
+				a.Flat = t.text
+			}
+			pending = ""
+		case cityMarkers[t.word] && a.House == "":
+			flush()
+			pending = "city"
+		case houseMarkers[t.word]:
+		case blockMarkers[t.word] && a.House != "":
+			pending = "block"
+		case structureMarkers[t.word] && a.House != "":
+			pending = "structure"
+		case flatMarkers[t.word]:
+			pending = "flat"
+		case streetTypes[t.word] != "" && a.House == "":
+			numbered = len(words) == 0
+			flush()
+			a.StreetType = streetTypes[t.word]
+			pending = ""
+		case a.House == "":
+			if pending == "city" {
+				if a.City != "" {
+					a.City += " "
+				}
+				a.City += title(t.word)
+				continue
+			}
+			words = append(words, title(t.word))
+		}
+	}
+
+	if a.City == "" && len(parts) > 0 {
+		a.City = strings.Join(parts[len(parts)-1], " ")
+	}
+
+	if a.Street == "" {
+		return a, errors.New("street required")
+	}
+
+	if a.House == "" {
+		return a, errors.New("house required")
+	}
+
+	if a.StreetType == "" {
+		a.StreetType = streetTypes["ул"]
+	}
+
+	return a, nil
+}
+
+// parseHouse parses house number with optional block and structure like
+// "10к2" or "5 стр. 1".
+func parseHouse(s string) (house, block, structure string) {
+	pending := ""
+	for _, t := range tokenize(s) {
+		switch {
+		case t.number:
+			switch {
+			case pending == "block":
+				block = t.text
+			case pending == "structure":
+				structure = t.text
+			case house == "":
+				house = t.text
+			}
+			pending = ""
+		case blockMarkers[t.word]:
+			pending = "block"
+		case structureMarkers[t.word]:
+			pending = "structure"
+		}
+	}
+	return
+}
+
+func title(w string) string {
+	rs := []rune(w)
+	for i, r := range rs {
+		if i == 0 || rs[i-1] == '-' {
+			rs[i] = unicode.ToUpper(r)
+		}
+	}
+	return string(rs)
+}
+
+// Building returns canonical address of the building like
+// "г. Город, ул. Ленина, д. 5, корп. 2".
+func (a Address) Building() string {
+	var ps []string
+	if a.City != "" {
+		ps = append(ps, "г. "+a.City)
+	}
+	if a.StreetType != "" {
+		ps = append(ps, a.StreetType+" "+a.Street)
+	} else {
+		ps = append(ps, a.Street)
+	}
+	ps = append(ps, "д. "+a.House)
+	if a.Block != "" {
+		ps = append(ps, "корп. "+a.Block)
+	}
+	if a.Structure != "" {
+		ps = append(ps, "стр. "+a.Structure)
+	}
+	return strings.Join(ps, ", ")
+}
+
+// String returns canonical address of the flat, or of the building if flat
+// is not set.
+func (a Address) String() string {
+	if a.Flat == "" {
+		return a.Building()
+	}
+	return a.Building() + ", кв. " + a.Flat
+}
+
+// Key returns building address key independent of city, street type and
+// spelling, so different spellings of the same building have equal keys.
+func (a Address) Key() string {
+	street := strings.Join(strings.Fields(strings.ToLower(a.Street)), " ")
+	k := strings.Replace(street, "ё", "е", -1) + " " + a.House
+	if a.Block != "" {
+		k += " к" + a.Block
+	}
+	if a.Structure != "" {
+		k += " с" + a.Structure
+	}
+	return k
+}
+
+// SearchKey returns key prefix of the partially entered address for the
+// address suggestions.
+func SearchKey(s string) string {
+	var ws []string
+	for _, t := range tokenize(s) {
+		switch {
+		case t.comma, cityMarkers[t.word], houseMarkers[t.word],
+			streetTypes[t.word] != "":
+		case t.number:
+			ws = append(ws, t.text)
+		default:
+			ws = append(ws, t.word)
+		}
+	}
+	return strings.Replace(strings.Join(ws, " "), "ё", "е", -1)
+}
+
+// RegistryReader reads addresses registry in CSV format exported from
+// FIAS/GAR: semicolon separated guid, city, street type, street and house
+// columns with a header row. Addresses are read one by one, so the registry
+// is never loaded into memory entirely.
+type RegistryReader struct {
+	cr   *csv.Reader
+	line int
+}
+
+// NewRegistryReader creates registry reader and reads the header row.
+func NewRegistryReader(r io.Reader) (*RegistryReader, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.FieldsPerRecord = 5
+	cr.ReuseRecord = true
+
+	_, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("failed to read header: " + err.Error())
+	}
+
+	return &RegistryReader{cr: cr, line: 1}, nil
+}
+
+// Read reads the next registry address. It returns io.EOF when there are no
+// more addresses.
+func (rr *RegistryReader) Read() (entity.RegistryAddress, error) {
+	rec, err := rr.cr.Read()
+	if err == io.EOF {
+		return entity.RegistryAddress{}, err
+	}
+	if err != nil {
+		return entity.RegistryAddress{},
+			errors.New("failed to read record: " + err.Error())
+	}
+
+	rr.line++
+
+	a := Address{
+		City:   strings.TrimSpace(rec[1]),
+		Street: strings.TrimSpace(rec[3]),
+	}
+
+	for _, m := range []string{"г.", "г ", "город "} {
+		a.City = strings.TrimSpace(strings.TrimPrefix(a.City, m))
+	}
+
+	st := strings.ToLower(strings.TrimSuffix(strings.TrimSpace(rec[2]), "."))
+	a.StreetType = streetTypes[st]
+	if a.StreetType == "" {
+		a.StreetType = strings.TrimSpace(rec[2])
+	}
+
+	a.House, a.Block, a.Structure = parseHouse(rec[4])
+
+	if a.Street == "" || a.House == "" {
+		return entity.RegistryAddress{}, errors.New(
+			"street and house required at line " + strconv.Itoa(rr.line))
+	}
+
+	return entity.RegistryAddress{
+		GUID:       strings.TrimSpace(rec[0]),
+		City:       a.City,
+		StreetType: a.StreetType,
+		Street:     a.Street,
+		House:      a.House,
+		Block:      a.Block,
+		Structure:  a.Structure,
+		Address:    a.Building(),
+		Key:        a.Key(),
+	}, nil
+}
@@ -0,0 +1,159 @@
+package address
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		address  string
+		building string
+		flat     string
+		err      bool
+	}{
+		{
+			address:  "ул. Ленина 5 кв 3",
+			building: "ул. Ленина, д. 5",
+			flat:     "3",
+		},
+		{
+			address:  "Ленина, д.5, 3",
+			building: "ул. Ленина, д. 5",
+			flat:     "3",
+		},
+		{
+			address:  "улица Ленина, дом 5, квартира 3",
+			building: "ул. Ленина, д. 5",
+			flat:     "3",
+		},
+		{
+			address:  "ул. 8 Марта, 12",
+			building: "ул. 8 Марта, д. 12",
+		},
+		{
+			address:  "г. Москва, ул. 8 Марта, 12, кв. 4",
+			building: "г. Москва, ул. 8 Марта, д. 12",
+			flat:     "4",
+		},
+		{
+			address:  "Ленина ул., д. 5",
+			building: "ул. Ленина, д. 5",
+		},
+		{
+			address:  "Московская обл., г. Химки, пр-т Мира, д. 10к2, кв. 7",
+			building: "г. Химки, пр-т Мира, д. 10, корп. 2",
+			flat:     "7",
+		},
+		{
+			address:  "Казань, проспект Победы 100 стр. 1",
+			building: "г. Казань, пр-т Победы, д. 100, стр. 1",
+		},
+		{
+			address:  "пер. Лесной, 7а",
+			building: "пер. Лесной, д. 7а",
+		},
+		{
+			address: "ул. Ленина",
+			err:     true,
+		},
+		{
+			address: "5",
+			err:     true,
+		},
+	}
+
+	for _, test := range tests {
+		a, err := Parse(test.address)
+		if test.err {
+			if err == nil {
+				t.Errorf("Parse(%q): expected error, got %q", test.address,
+					a.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", test.address, err)
+			continue
+		}
+		if a.Building() != test.building {
+			t.Errorf("Parse(%q).Building() = %q, expected %q", test.address,
+				a.Building(), test.building)
+		}
+		if a.Flat != test.flat {
+			t.Errorf("Parse(%q).Flat = %q, expected %q", test.address,
+				a.Flat, test.flat)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a string
+		b string
+	}{
+		{a: "ул. Ленина 5 кв 3", b: "Ленина, д.5, 3"},
+		{a: "г. Москва, ул. Ленина, 5", b: "Ленина ул., д. 5"},
+		{a: "пр-т Мира 10 корп. 2", b: "проспект Мира, д. 10к2"},
+		{a: "ул. Артёма, 1", b: "ул. Артема, 1"},
+	}
+
+	for _, test := range tests {
+		a, err := Parse(test.a)
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", test.a, err)
+		}
+		b, err := Parse(test.b)
+		if err != nil {
+			t.Fatalf("Parse(%q): unexpected error: %v", test.b, err)
+		}
+		if a.Key() != b.Key() {
+			t.Errorf("Key of %q = %q, key of %q = %q, expected equal keys",
+				test.a, a.Key(), test.b, b.Key())
+		}
+	}
+}
+
+func TestRegistryReader(t *testing.T) {
+	rr, err := NewRegistryReader(strings.NewReader(
+		"guid;city;street_type;street;house\n" +
+			"a1;г. Москва;ул;8 Марта;12к2\n" +
+			"a2;Москва;проспект;Мира;5\n"))
+	if err != nil {
+		t.Fatalf("NewRegistryReader: unexpected error: %v", err)
+	}
+
+	var addresses []string
+
+	for {
+		ra, err := rr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: unexpected error: %v", err)
+		}
+		addresses = append(addresses, ra.Address)
+	}
+
+	expected := []string{
+		"г. Москва, ул. 8 Марта, д. 12, корп. 2",
+		"г. Москва, пр-т Мира, д. 5",
+	}
+
+	if strings.Join(addresses, "; ") != strings.Join(expected, "; ") {
+		t.Errorf("read addresses %q, expected %q", addresses, expected)
+	}
+
+	rr, err = NewRegistryReader(strings.NewReader(
+		"guid;city;street_type;street;house\na1;Москва;ул;;12\n"))
+	if err != nil {
+		t.Fatalf("NewRegistryReader: unexpected error: %v", err)
+	}
+
+	_, err = rr.Read()
+	if err == nil || err == io.EOF {
+		t.Errorf("Read: expected street required error, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package alarm
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/swan/entity"
+)
+
+type Storage interface {
+	UnacknowledgedEmergencyRequests(notifiedBefore time.Time) (
+		[]entity.RequestExtended, error)
+	SetRequestNotifiedAt(requestID int, notifiedAt time.Time) error
+}
+
+type SMSSender interface {
+	SendSMS(phone string, msg string) error
+}
+
+const checkPeriod = 30 * time.Second
+
+// Alarm repeatedly notifies operators about emergency requests until they
+// acknowledge them.
+type Alarm struct {
+	storage        Storage
+	smsSender      SMSSender
+	repeatInterval time.Duration
+
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+
+	log *logrus.Entry
+}
+
+func NewAlarm(s Storage, ss SMSSender, repeatInterval time.Duration) *Alarm {
+	return &Alarm{
+		storage:        s,
+		smsSender:      ss,
+		repeatInterval: repeatInterval,
+
+		log: logrus.WithField("subsystem", "alarm"),
+	}
+}
+
+func (a *Alarm) Start() error {
+	a.stop = make(chan struct{})
+
+	a.waitGroup.Add(1)
+	go func() {
+		defer a.waitGroup.Done()
+
+		t := time.NewTicker(checkPeriod)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-t.C:
+				a.notify()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (a *Alarm) Stop() {
+	close(a.stop)
+	a.waitGroup.Wait()
+}
+
+func (a *Alarm) notify() {
+	rs, err := a.storage.UnacknowledgedEmergencyRequests(
+		time.Now().Add(-a.repeatInterval))
+	if err != nil {
+		a.log.WithError(err).Error(
+			"failed to get unacknowledged emergency requests from storage")
+		return
+	}
+
+	for _, r := range rs {
+		if r.OperatorPhone == nil {
+			continue
+		}
+
+		err = a.smsSender.SendSMS(*r.OperatorPhone, fmt.Sprintf(
+			"Аварийное обращение №%d не подтверждено: %s", r.ID, r.Text))
+		if err != nil {
+			a.log.WithError(err).WithField("request_id", r.ID).Error(
+				"failed to send emergency SMS")
+			continue
+		}
+
+		err = a.storage.SetRequestNotifiedAt(r.ID, time.Now())
+		if err != nil {
+			a.log.WithError(err).WithField("request_id", r.ID).Error(
+				"failed to set request notified at in storage")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package announcer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/swan/entity"
+)
+
+type Storage interface {
+	UnpushedAnnouncements(publishedBefore time.Time) (
+		[]entity.Announcement, error)
+	AnnouncementOwners(announcementID int) ([]entity.Owner, error)
+	SetAnnouncementPushedAt(announcementID int, pushedAt time.Time) error
+}
+
+type SMSSender interface {
+	SendSMS(phone string, msg string) error
+}
+
+type EmailSender interface {
+	SendEmail(email string, msg string) error
+}
+
+const checkPeriod = time.Minute
+
+// Announcer pushes published announcements to the residents by SMS and
+// email.
+type Announcer struct {
+	storage     Storage
+	smsSender   SMSSender
+	emailSender EmailSender
+
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+
+	log *logrus.Entry
+}
+
+func NewAnnouncer(s Storage, ss SMSSender, es EmailSender) *Announcer {
+	return &Announcer{
+		storage:     s,
+		smsSender:   ss,
+		emailSender: es,
+
+		log: logrus.WithField("subsystem", "announcer"),
+	}
+}
+
+func (a *Announcer) Start() error {
+	a.stop = make(chan struct{})
+
+	a.waitGroup.Add(1)
+	go func() {
+		defer a.waitGroup.Done()
+
+		t := time.NewTicker(checkPeriod)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-a.stop:
+				return
+			case <-t.C:
+				a.push()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (a *Announcer) Stop() {
+	close(a.stop)
+	a.waitGroup.Wait()
+}
+
+func (a *Announcer) push() {
+	as, err := a.storage.UnpushedAnnouncements(time.Now())
+	if err != nil {
+		a.log.WithError(err).Error(
+			"failed to get unpushed announcements from storage")
+		return
+	}
+
+	for _, an := range as {
+		l := a.log.WithField("announcement_id", an.ID)
+
+		os, err := a.storage.AnnouncementOwners(an.ID)
+		if err != nil {
+			l.WithError(err).Error(
+				"failed to get announcement owners from storage")
+			continue
+		}
+
+		msg := an.Title + ": " + an.Text
+
+		for _, o := range os {
+			if an.PushSMS {
+				err = a.smsSender.SendSMS(o.Phone, msg)
+				if err != nil {
+					l.WithError(err).WithField("owner_id", o.ID).
+						Error("failed to send announcement SMS")
+				}
+			}
+
+			if an.PushEmail && o.Email != "" {
+				err = a.emailSender.SendEmail(o.Email, msg)
+				if err != nil {
+					l.WithError(err).WithField("owner_id", o.ID).
+						Error("failed to send announcement email")
+				}
+			}
+		}
+
+		err = a.storage.SetAnnouncementPushedAt(an.ID, time.Now())
+		if err != nil {
+			l.WithError(err).Error(
+				"failed to set announcement pushed at in storage")
+		}
+	}
+}
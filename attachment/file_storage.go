@@ -0,0 +1,111 @@
+package attachment
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// keySize is the size of the random attachment key in bytes.
+const keySize = 16
+
+// FileStorage keeps attachments as files of the directory. Attachments are
+// addressed by the random keys and spread over subdirectories by the key
+// prefix.
+type FileStorage struct {
+	dir string
+}
+
+func NewFileStorage(dir string) (*FileStorage, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileStorage{dir: dir}, nil
+}
+
+func (s *FileStorage) path(key string) (string, error) {
+	k, err := hex.DecodeString(key)
+	if err != nil || len(k) != keySize {
+		return "", errors.New("invalid attachment key")
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+// Put stores the attachment and returns its key and size. Attachment is
+// written to the temporary file first, so partially written attachments
+// are never visible.
+func (s *FileStorage) Put(r io.Reader) (string, int64, error) {
+	k := make([]byte, keySize)
+
+	_, err := rand.Read(k)
+	if err != nil {
+		return "", 0, errors.New("failed to generate key: " + err.Error())
+	}
+
+	key := hex.EncodeToString(k)
+
+	p, err := s.path(key)
+	if err != nil {
+		return "", 0, err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return "", 0, errors.New("failed to create directory: " +
+			err.Error())
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(p), key+".tmp")
+	if err != nil {
+		return "", 0, errors.New("failed to create file: " + err.Error())
+	}
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", 0, errors.New("failed to write file: " + err.Error())
+	}
+
+	err = f.Close()
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, errors.New("failed to close file: " + err.Error())
+	}
+
+	err = os.Rename(f.Name(), p)
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, errors.New("failed to rename file: " + err.Error())
+	}
+
+	return key, size, nil
+}
+
+// Open opens the attachment by the key.
+func (s *FileStorage) Open(key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+// Remove removes the attachment by the key. Missing attachment is not an
+// error.
+func (s *FileStorage) Remove(key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
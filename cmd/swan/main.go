@@ -16,7 +16,12 @@ func main() {
 		os.Getenv("POSTGRES_STORAGE_URI"),
 		os.Getenv("CLASSIFIER_API_URI"),
 		os.Getenv("WEB_SERVER_BIND_ADDR"),
-		os.Getenv("WEB_SERVER_DEBUG") == "1")
+		os.Getenv("WEB_SERVER_DEBUG") == "1",
+		os.Getenv("WEB_SERVER_PUBLIC_BASE_URL"),
+		os.Getenv("PROTOCOL_SIGNING_KEY"),
+		os.Getenv("PAYMENT_STUB") == "1",
+		os.Getenv("PAYMENT_STUB_KEY"),
+		os.Getenv("ATTACHMENTS_DIR"))
 	if err != nil {
 		logrus.WithError(err).Fatal("failed to create swan service")
 	}
@@ -0,0 +1,59 @@
+package dedup
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Similarity returns Dice coefficient of the texts trigram sets. Trigrams
+// are taken from every word padded with spaces, as pg_trgm does. Result is
+// in [0, 1] range where 1 means the texts have the same trigrams.
+func Similarity(a, b string) float64 {
+	ta := trigrams(a)
+	tb := trigrams(b)
+
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	common := 0
+	for t := range ta {
+		if _, ok := tb[t]; ok {
+			common++
+		}
+	}
+
+	return 2 * float64(common) / float64(len(ta)+len(tb))
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func trigrams(text string) map[string]struct{} {
+	ts := map[string]struct{}{}
+	for _, w := range words(text) {
+		rs := []rune("  " + w + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			ts[string(rs[i:i+3])] = struct{}{}
+		}
+	}
+	return ts
+}
+
+var flatRegexp = regexp.MustCompile(
+	`(?i)[\s,]*(кв\.?|квартира|офис|оф\.)\s*\S+\s*$`)
+
+// BuildingAddress strips flat part from the address and normalizes it, so
+// addresses of flats in the same building are equal.
+func BuildingAddress(address string) string {
+	return strings.Join(words(StripFlat(address)), " ")
+}
+
+// StripFlat strips flat part from the address keeping the rest as is.
+func StripFlat(address string) string {
+	return strings.TrimSpace(flatRegexp.ReplaceAllString(address, ""))
+}
@@ -0,0 +1,20 @@
+package area
+
+import "errors"
+
+const (
+	Stairwell = "stairwell"
+	Elevator  = "elevator"
+	Basement  = "basement"
+	Roof      = "roof"
+	Yard      = "yard"
+	Other     = "other"
+)
+
+func Validate(area string) error {
+	switch area {
+	case Stairwell, Elevator, Basement, Roof, Yard, Other:
+		return nil
+	}
+	return errors.New("invalid common area")
+}
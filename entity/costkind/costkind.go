@@ -0,0 +1,16 @@
+package costkind
+
+import "errors"
+
+const (
+	Material = "material"
+	Labor    = "labor"
+)
+
+func Validate(kind string) error {
+	switch kind {
+	case Material, Labor:
+		return nil
+	}
+	return errors.New("invalid cost kind")
+}
@@ -0,0 +1,216 @@
+// Package decimal provides fixed point numbers for money and quantities.
+// They are stored as scaled integers, so sums and products are exact unlike
+// float64 ones.
+package decimal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of money in kopecks.
+type Money int64
+
+// Quantity is a quantity in thousandths.
+type Quantity int64
+
+const (
+	moneyScale    = 2
+	quantityScale = 3
+)
+
+// maxIntDigits limits integer part of the numbers, so they fit into int64
+// after scaling.
+const maxIntDigits = 15
+
+// ParseMoney parses money in rubles such as "1250.50" or "1250,5".
+func ParseMoney(s string) (Money, error) {
+	v, err := parse(s, moneyScale)
+	return Money(v), err
+}
+
+func (m Money) String() string {
+	return format(int64(m), moneyScale)
+}
+
+// Mul returns the price multiplied by the quantity rounded to kopecks.
+func (m Money) Mul(q Quantity) Money {
+	p := int64(m) * int64(q)
+	d := pow10(quantityScale)
+	if p < 0 {
+		return Money((p - d/2) / d)
+	}
+	return Money((p + d/2) / d)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := parse(unquote(b), moneyScale)
+	if err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	v, err := scan(src, moneyScale)
+	if err != nil {
+		return err
+	}
+	*m = Money(v)
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+// ParseQuantity parses quantity such as "1.5" or "1,5".
+func ParseQuantity(s string) (Quantity, error) {
+	v, err := parse(s, quantityScale)
+	return Quantity(v), err
+}
+
+// String formats quantity without trailing zeros.
+func (q Quantity) String() string {
+	s := format(int64(q), quantityScale)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	v, err := parse(unquote(b), quantityScale)
+	if err != nil {
+		return err
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+func (q *Quantity) Scan(src interface{}) error {
+	v, err := scan(src, quantityScale)
+	if err != nil {
+		return err
+	}
+	*q = Quantity(v)
+	return nil
+}
+
+func (q Quantity) Value() (driver.Value, error) {
+	return q.String(), nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
+
+// parse parses decimal number into integer scaled by 10^scale. Both point
+// and comma are accepted as decimal separator.
+func parse(s string, scale int) (int64, error) {
+	s = strings.TrimSpace(s)
+
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg = true
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+
+	s = strings.Replace(s, ",", ".", 1)
+
+	intPart, fracPart := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		intPart, fracPart = s[:i], s[i+1:]
+	}
+
+	if intPart == "" && fracPart == "" {
+		return 0, errors.New("invalid decimal number")
+	}
+
+	if len(intPart) > maxIntDigits {
+		return 0, errors.New("decimal number is too large")
+	}
+
+	if len(fracPart) > scale {
+		return 0, errors.New("decimal number has too many fraction digits")
+	}
+
+	fracPart += strings.Repeat("0", scale-len(fracPart))
+
+	digits := intPart + fracPart
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, errors.New("invalid decimal number")
+		}
+	}
+
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, errors.New("invalid decimal number")
+	}
+
+	if neg {
+		v = -v
+	}
+
+	return v, nil
+}
+
+// format formats integer scaled by 10^scale as decimal number.
+func format(v int64, scale int) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	d := pow10(scale)
+
+	frac := strconv.FormatInt(v%d, 10)
+	frac = strings.Repeat("0", scale-len(frac)) + frac
+
+	return sign + strconv.FormatInt(v/d, 10) + "." + frac
+}
+
+func unquote(b []byte) string {
+	return strings.Trim(string(b), `"`)
+}
+
+// scan scans NUMERIC column value into integer scaled by 10^scale.
+func scan(src interface{}, scale int) (int64, error) {
+	switch v := src.(type) {
+	case nil:
+		return 0, nil
+	case []byte:
+		return parse(string(v), scale)
+	case string:
+		return parse(v, scale)
+	case int64:
+		return v * pow10(scale), nil
+	case float64:
+		return parse(strconv.FormatFloat(v, 'f', scale, 64), scale)
+	}
+	return 0, errors.New("unsupported decimal number source")
+}
@@ -1,9 +1,24 @@
 package entity
 
 import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/dimuls/swan/entity/area"
+	"github.com/dimuls/swan/entity/costkind"
+	"github.com/dimuls/swan/entity/decimal"
+	"github.com/dimuls/swan/entity/event"
+	"github.com/dimuls/swan/entity/fieldtype"
+	"github.com/dimuls/swan/entity/household"
+	"github.com/dimuls/swan/entity/meter"
+	"github.com/dimuls/swan/entity/priority"
+	"github.com/dimuls/swan/entity/provider"
 	"github.com/dimuls/swan/entity/status"
+	"github.com/dimuls/swan/entity/weighting"
+	"github.com/dimuls/swan/entity/workstatus"
 )
 
 type PasswordCode struct {
@@ -39,12 +54,47 @@ func (cs CategorySample) Validate() error {
 	return nil
 }
 
+// PriorityRule raises priority of the request which text matches Pattern
+// and which category is CategoryID if it is set.
+type PriorityRule struct {
+	ID         int    `db:"id" json:"id" form:"id"`
+	Pattern    string `db:"pattern" json:"pattern" form:"pattern"`
+	CategoryID *int   `db:"category_id" json:"category_id" form:"category_id"`
+	Priority   string `db:"priority" json:"priority" form:"priority"`
+}
+
+func (pr PriorityRule) Validate() error {
+	if pr.Pattern == "" && pr.CategoryID == nil {
+		return errors.New("pattern or category ID required")
+	}
+	_, err := regexp.Compile(pr.Pattern)
+	if err != nil {
+		return errors.New("invalid pattern: " + err.Error())
+	}
+	return priority.Validate(pr.Priority)
+}
+
+func (pr PriorityRule) Match(text string, categoryID *int) (bool, error) {
+	if pr.CategoryID != nil &&
+		(categoryID == nil || *pr.CategoryID != *categoryID) {
+		return false, nil
+	}
+	if pr.Pattern == "" {
+		return true, nil
+	}
+	return regexp.MatchString("(?i)"+pr.Pattern, text)
+}
+
+// Organization is a housing management company. FlatsCount is a number of
+// flats in the organization buildings. Removed organization is kept with
+// DeletedAt set, so its requests history is not lost.
 type Organization struct {
-	ID           int    `db:"id" json:"id" form:"id"`
-	Name         string `db:"name" json:"name" form:"name"`
-	Email        string `db:"email" json:"email" form:"email"`
-	FlatsCount   int    `db:"flats_count" json:"flats_count" form:"flats_count"`
-	PasswordHash []byte `db:"password_hash" json:"-" form:"-"`
+	ID           int        `db:"id" json:"id" form:"id"`
+	Name         string     `db:"name" json:"name" form:"name"`
+	Email        string     `db:"email" json:"email" form:"email"`
+	FlatsCount   int        `db:"flats_count" json:"flats_count" form:"-"`
+	PasswordHash []byte     `db:"password_hash" json:"-" form:"-"`
+	DeletedAt    *time.Time `db:"deleted_at" json:"deleted_at" form:"-"`
 }
 
 func (o Organization) Validate() error {
@@ -52,14 +102,18 @@ func (o Organization) Validate() error {
 	return nil
 }
 
+// Operator handles requests of the organization. Removed operator is kept
+// with DeletedAt set.
 type Operator struct {
-	ID                       int    `db:"id" json:"id" form:"id"`
-	OrganizationID           int    `db:"organization_id" json:"organization_id" form:"organization_id"`
-	Phone                    string `db:"phone" json:"phone" form:"phone"`
-	PasswordHash             []byte `db:"password_hash" json:"-" form:"-"`
-	Name                     string `db:"name" json:"name" form:"name"`
-	ResponsibleCategoriesStr string `db:"-" json:"-" form:"responsible_categories"`
-	ResponsibleCategories    []int  `db:"responsible_categories" json:"responsible_categories" form:"-"`
+	ID                       int        `db:"id" json:"id" form:"id"`
+	OrganizationID           int        `db:"organization_id" json:"organization_id" form:"organization_id"`
+	Phone                    string     `db:"phone" json:"phone" form:"phone"`
+	PasswordHash             []byte     `db:"password_hash" json:"-" form:"-"`
+	Name                     string     `db:"name" json:"name" form:"name"`
+	ResponsibleCategoriesStr string     `db:"-" json:"-" form:"responsible_categories"`
+	ResponsibleCategories    []int      `db:"responsible_categories" json:"responsible_categories" form:"-"`
+	OnDuty                   bool       `db:"on_duty" json:"on_duty" form:"on_duty"`
+	DeletedAt                *time.Time `db:"deleted_at" json:"deleted_at" form:"-"`
 }
 
 func (o Operator) Validate() error {
@@ -67,35 +121,761 @@ func (o Operator) Validate() error {
 	return nil
 }
 
+// Owner is a resident of the organization flat. Address is the flat address
+// and is empty if owner is not linked to a flat. Residents of the same flat
+// form a household managed by the one with household.Owner role. Share is
+// the part of the flat owned by the owner, owners without share own the rest
+// of the flat equally. Removed owner is kept with DeletedAt set.
 type Owner struct {
-	ID             int    `db:"id" json:"id" form:"id"`
-	OrganizationID int    `db:"organization_id" json:"organization_id" form:"organization_id"`
-	Phone          string `db:"phone" json:"phone" form:"phone"`
-	PasswordHash   []byte `db:"password_hash" json:"-" form:"-"`
-	Name           string `db:"name" json:"name" form:"name"`
-	Address        string `db:"address" json:"address" form:"address"`
+	ID             int        `db:"id" json:"id" form:"id"`
+	OrganizationID int        `db:"organization_id" json:"organization_id" form:"organization_id"`
+	Phone          string     `db:"phone" json:"phone" form:"phone"`
+	PasswordHash   []byte     `db:"password_hash" json:"-" form:"-"`
+	Name           string     `db:"name" json:"name" form:"name"`
+	FlatID         *int       `db:"flat_id" json:"flat_id" form:"flat_id"`
+	HouseholdRole  string     `db:"household_role" json:"household_role" form:"household_role"`
+	Email          string     `db:"email" json:"email" form:"email"`
+	Share          *float64   `db:"share" json:"share" form:"share"`
+	Address        string     `db:"address" json:"address" form:"-"`
+	DeletedAt      *time.Time `db:"deleted_at" json:"deleted_at" form:"-"`
 }
 
 func (u Owner) Validate() error {
 	// TODO: validate user
+	if u.Share != nil && (*u.Share <= 0 || *u.Share > 1) {
+		return errors.New("share must be in (0, 1]")
+	}
+	return household.Validate(u.HouseholdRole)
+}
+
+// PrimaryOwner reports whether owner manages the household of the flat.
+func (u Owner) PrimaryOwner() bool {
+	return u.FlatID != nil && u.HouseholdRole == household.Owner
+}
+
+func (u Owner) HasHouseholdRole(r string) bool {
+	return u.HouseholdRole == r
+}
+
+func (u Owner) LivesIn(flatID int) bool {
+	return u.FlatID != nil && *u.FlatID == flatID
+}
+
+// Building is an apartment building of the organization. FlatsCount is a
+// number of the building flats.
+type Building struct {
+	ID             int    `db:"id" json:"id" form:"id"`
+	OrganizationID int    `db:"organization_id" json:"organization_id" form:"-"`
+	Address        string `db:"address" json:"address" form:"address"`
+	Entrances      int    `db:"entrances" json:"entrances" form:"entrances"`
+	Floors         int    `db:"floors" json:"floors" form:"floors"`
+	FlatsCount     int    `db:"flats_count" json:"flats_count" form:"-"`
+}
+
+func (b Building) Validate() error {
+	if b.Address == "" {
+		return errors.New("address required")
+	}
+	if b.Entrances < 1 {
+		return errors.New("entrances must be positive")
+	}
+	if b.Floors < 1 {
+		return errors.New("floors must be positive")
+	}
 	return nil
 }
 
-type Request struct {
+// Flat is a flat of the building. Flat with empty number is a private house
+// taking the whole building. Non-empty account is unique within the
+// organization.
+type Flat struct {
+	ID              int      `db:"id" json:"id" form:"id"`
+	OrganizationID  int      `db:"organization_id" json:"organization_id" form:"-"`
+	BuildingID      int      `db:"building_id" json:"building_id" form:"building_id"`
+	BuildingAddress string   `db:"building_address" json:"building_address" form:"-"`
+	Number          string   `db:"number" json:"number" form:"number"`
+	Entrance        *int     `db:"entrance" json:"entrance" form:"entrance"`
+	Floor           *int     `db:"floor" json:"floor" form:"floor"`
+	Area            *float64 `db:"area" json:"area" form:"area"`
+	Account         string   `db:"account" json:"account" form:"account"`
+}
+
+func (f Flat) Validate() error {
+	if f.BuildingID == 0 {
+		return errors.New("building required")
+	}
+	if f.Area != nil && *f.Area <= 0 {
+		return errors.New("area must be positive")
+	}
+	return nil
+}
+
+// FitsBuilding checks that the flat entrance and floor are within the
+// building ones.
+func (f Flat) FitsBuilding(b Building) error {
+	if f.Entrance != nil && (*f.Entrance < 1 || *f.Entrance > b.Entrances) {
+		return errors.New("entrance out of building entrances")
+	}
+	if f.Floor != nil && (*f.Floor < 1 || *f.Floor > b.Floors) {
+		return errors.New("floor out of building floors")
+	}
+	return nil
+}
+
+// Address returns full address of the flat.
+func (f Flat) Address() string {
+	if f.Number == "" {
+		return f.BuildingAddress
+	}
+	return f.BuildingAddress + ", кв. " + f.Number
+}
+
+// Meter is a utility meter of the flat. VerificationDate is the date of the
+// next meter verification.
+type Meter struct {
+	ID                  int        `db:"id" json:"id" form:"id"`
+	FlatID              int        `db:"flat_id" json:"flat_id" form:"flat_id"`
+	FlatAddress         string     `db:"flat_address" json:"flat_address" form:"-"`
+	Type                string     `db:"type" json:"type" form:"type"`
+	SerialNumber        string     `db:"serial_number" json:"serial_number" form:"serial_number"`
+	VerificationDateStr string     `db:"-" json:"-" form:"verification_date"`
+	VerificationDate    *time.Time `db:"verification_date" json:"verification_date" form:"-"`
+	LastPeriod          *time.Time `db:"last_period" json:"last_period" form:"-"`
+	LastValue           *float64   `db:"last_value" json:"last_value" form:"-"`
+}
+
+func (m Meter) Validate() error {
+	if m.FlatID == 0 {
+		return errors.New("flat required")
+	}
+	err := meter.Validate(m.Type)
+	if err != nil {
+		return err
+	}
+	if m.SerialNumber == "" {
+		return errors.New("serial number required")
+	}
+	return nil
+}
+
+// Submitted checks that the meter reading of the period is submitted.
+func (m Meter) Submitted(period time.Time) bool {
+	return m.LastPeriod != nil && m.LastPeriod.Equal(period)
+}
+
+// Meter readings are accepted from MeterReadingsFromDay to
+// MeterReadingsToDay day of the month inclusive.
+const (
+	MeterReadingsFromDay = 15
+	MeterReadingsToDay   = 25
+)
+
+// MeterReadingsPeriod returns the month of the meter readings accepted at
+// the time and whether readings are accepted at all.
+func MeterReadingsPeriod(t time.Time) (time.Time, bool) {
+	period := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return period, t.Day() >= MeterReadingsFromDay &&
+		t.Day() <= MeterReadingsToDay
+}
+
+// MeterReading is a monthly meter reading submitted by the resident.
+// Outlier reading has consumption much higher than usual.
+type MeterReading struct {
+	ID          int       `db:"id" json:"id" form:"id"`
+	MeterID     int       `db:"meter_id" json:"meter_id" form:"meter_id"`
+	Period      time.Time `db:"period" json:"period" form:"-"`
+	Value       float64   `db:"value" json:"value" form:"value"`
+	Outlier     bool      `db:"outlier" json:"outlier" form:"-"`
+	OwnerID     *int      `db:"owner_id" json:"owner_id" form:"-"`
+	SubmittedAt time.Time `db:"submitted_at" json:"submitted_at" form:"-"`
+}
+
+func (r MeterReading) Validate() error {
+	if r.Value < 0 {
+		return errors.New("value must not be negative")
+	}
+	return nil
+}
+
+// MeterReadingExtended is a meter reading with the meter and the previous
+// reading for the billing.
+type MeterReadingExtended struct {
+	MeterReading
+	FlatAddress   string   `db:"flat_address" json:"flat_address"`
+	MeterType     string   `db:"meter_type" json:"meter_type"`
+	SerialNumber  string   `db:"serial_number" json:"serial_number"`
+	OwnerName     *string  `db:"owner_name" json:"owner_name"`
+	PreviousValue *float64 `db:"previous_value" json:"previous_value"`
+}
+
+// Consumption returns consumption since the previous reading.
+func (r MeterReadingExtended) Consumption() *float64 {
+	if r.PreviousValue == nil {
+		return nil
+	}
+	c := r.Value - *r.PreviousValue
+	return &c
+}
+
+// Announcement is a news of the organization for the residents of the whole
+// organization, the building or the entrance. Announcement is shown from
+// PublishAt till ExpiresAt and pushed by SMS or email when published.
+type Announcement struct {
+	ID              int        `db:"id" json:"id" form:"id"`
+	OrganizationID  int        `db:"organization_id" json:"organization_id" form:"-"`
+	BuildingID      *int       `db:"building_id" json:"building_id" form:"building_id"`
+	BuildingAddress *string    `db:"building_address" json:"building_address" form:"-"`
+	Entrance        *int       `db:"entrance" json:"entrance" form:"entrance"`
+	Title           string     `db:"title" json:"title" form:"title"`
+	Text            string     `db:"text" json:"text" form:"text"`
+	PublishAtStr    string     `db:"-" json:"-" form:"publish_at"`
+	PublishAt       time.Time  `db:"publish_at" json:"publish_at" form:"-"`
+	ExpiresAtStr    string     `db:"-" json:"-" form:"expires_at"`
+	ExpiresAt       *time.Time `db:"expires_at" json:"expires_at" form:"-"`
+	PushSMS         bool       `db:"push_sms" json:"push_sms" form:"push_sms"`
+	PushEmail       bool       `db:"push_email" json:"push_email" form:"push_email"`
+	PushedAt        *time.Time `db:"pushed_at" json:"pushed_at" form:"-"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at" form:"-"`
+	ReadsCount      int        `db:"reads_count" json:"reads_count" form:"-"`
+	ReadAt          *time.Time `db:"read_at" json:"read_at" form:"-"`
+}
+
+func (a Announcement) Validate() error {
+	if a.Title == "" {
+		return errors.New("title required")
+	}
+	if a.Text == "" {
+		return errors.New("text required")
+	}
+	if a.Entrance != nil && a.BuildingID == nil {
+		return errors.New("entrance requires building")
+	}
+	if a.ExpiresAt != nil && !a.ExpiresAt.After(a.PublishAt) {
+		return errors.New("expiry must be after publishing")
+	}
+	return nil
+}
+
+// Published checks that the announcement is shown at the time.
+func (a Announcement) Published(t time.Time) bool {
+	return !a.PublishAt.After(t) && (a.ExpiresAt == nil || a.ExpiresAt.After(t))
+}
+
+// PublishAtLocal returns publishing time in the form format.
+func (a Announcement) PublishAtLocal() string {
+	return a.PublishAt.Local().Format("2006-01-02T15:04")
+}
+
+// ExpiresAtLocal returns expiry time in the form format.
+func (a Announcement) ExpiresAtLocal() string {
+	if a.ExpiresAt == nil {
+		return ""
+	}
+	return a.ExpiresAt.Local().Format("2006-01-02T15:04")
+}
+
+// TargetedAt checks that the announcement is for the building.
+func (a Announcement) TargetedAt(buildingID int) bool {
+	return a.BuildingID != nil && *a.BuildingID == buildingID
+}
+
+// Poll is a residents poll or an owners general meeting of the building.
+// Owners vote from StartsAt till EndsAt. Votes are weighted by Weighting
+// and poll is valid if voted weight reaches QuorumPercent of the building
+// weight.
+type Poll struct {
+	ID              int       `db:"id" json:"id" form:"id"`
+	OrganizationID  int       `db:"organization_id" json:"organization_id" form:"-"`
+	BuildingID      int       `db:"building_id" json:"building_id" form:"building_id"`
+	BuildingAddress string    `db:"building_address" json:"building_address" form:"-"`
+	Title           string    `db:"title" json:"title" form:"title"`
+	Description     string    `db:"description" json:"description" form:"description"`
+	Weighting       string    `db:"weighting" json:"weighting" form:"weighting"`
+	QuorumPercent   float64   `db:"quorum_percent" json:"quorum_percent" form:"quorum_percent"`
+	StartsAtStr     string    `db:"-" json:"-" form:"starts_at"`
+	StartsAt        time.Time `db:"starts_at" json:"starts_at" form:"-"`
+	EndsAtStr       string    `db:"-" json:"-" form:"ends_at"`
+	EndsAt          time.Time `db:"ends_at" json:"ends_at" form:"-"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at" form:"-"`
+}
+
+func (p Poll) Validate() error {
+	if p.BuildingID == 0 {
+		return errors.New("building required")
+	}
+	if p.Title == "" {
+		return errors.New("title required")
+	}
+	err := weighting.Validate(p.Weighting)
+	if err != nil {
+		return err
+	}
+	if p.QuorumPercent <= 0 || p.QuorumPercent > 100 {
+		return errors.New("quorum percent must be in (0, 100]")
+	}
+	if !p.EndsAt.After(p.StartsAt) {
+		return errors.New("end must be after start")
+	}
+	return nil
+}
+
+// Started checks that voting is started at the time.
+func (p Poll) Started(t time.Time) bool {
+	return !p.StartsAt.After(t)
+}
+
+// Open checks that owners can vote at the time.
+func (p Poll) Open(t time.Time) bool {
+	return p.Started(t) && p.EndsAt.After(t)
+}
+
+// Finished checks that voting is finished at the time.
+func (p Poll) Finished(t time.Time) bool {
+	return !p.EndsAt.After(t)
+}
+
+// PollQuestion is a question of the poll with single or multiple choice
+// options.
+type PollQuestion struct {
+	ID         int      `db:"id" json:"id" form:"id"`
+	PollID     int      `db:"poll_id" json:"poll_id" form:"poll_id"`
+	Position   int      `db:"position" json:"position" form:"-"`
+	Text       string   `db:"text" json:"text" form:"text"`
+	Multiple   bool     `db:"multiple" json:"multiple" form:"multiple"`
+	OptionsStr string   `db:"-" json:"-" form:"options"`
+	Options    []string `db:"options" json:"options" form:"-"`
+}
+
+func (q PollQuestion) Validate() error {
+	if q.PollID == 0 {
+		return errors.New("poll required")
+	}
+	if q.Text == "" {
+		return errors.New("text required")
+	}
+	if len(q.Options) < 2 {
+		return errors.New("at least two options required")
+	}
+	return nil
+}
+
+// ValidateChoice checks that the chosen options are of the question.
+func (q PollQuestion) ValidateChoice(options []int) error {
+	if len(options) == 0 {
+		return errors.New("option required")
+	}
+	if !q.Multiple && len(options) > 1 {
+		return errors.New("single option allowed")
+	}
+	chosen := map[int]bool{}
+	for _, o := range options {
+		if o < 0 || o >= len(q.Options) {
+			return errors.New("invalid option")
+		}
+		if chosen[o] {
+			return errors.New("duplicate option")
+		}
+		chosen[o] = true
+	}
+	return nil
+}
+
+// PollVote is the owner choice on the poll question. Weight is the owner
+// weight at the voting time.
+type PollVote struct {
+	QuestionID int       `db:"question_id" json:"question_id" form:"question_id"`
+	OwnerID    int       `db:"owner_id" json:"owner_id" form:"-"`
+	Options    []int     `db:"options" json:"options" form:"options"`
+	Weight     float64   `db:"weight" json:"weight" form:"-"`
+	VotedAt    time.Time `db:"voted_at" json:"voted_at" form:"-"`
+}
+
+// Chose checks that the option is chosen.
+func (v PollVote) Chose(option int) bool {
+	for _, o := range v.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// PollOptionResult is a weight voted for the poll question option and its
+// percent of the voted weight.
+type PollOptionResult struct {
+	Option  string  `json:"option"`
+	Votes   int     `json:"votes"`
+	Weight  float64 `json:"weight"`
+	Percent float64 `json:"percent"`
+}
+
+// PollQuestionResult is a result of the poll question. Number is the
+// question number in the poll agenda.
+type PollQuestionResult struct {
+	PollQuestion
+	Number  int                `json:"number"`
+	Votes   []PollVote         `json:"-"`
+	Results []PollOptionResult `json:"results"`
+}
+
+// Vote returns the owner vote on the question.
+func (qr PollQuestionResult) Vote(ownerID int) *PollVote {
+	for i := range qr.Votes {
+		if qr.Votes[i].OwnerID == ownerID {
+			return &qr.Votes[i]
+		}
+	}
+	return nil
+}
+
+// PollResults is a poll with the voting results. TotalWeight is the weight
+// of the whole building and VotedWeight is the weight of the owners voted
+// at least on one question.
+type PollResults struct {
+	Poll
+	Questions     []PollQuestionResult `json:"questions"`
+	TotalWeight   float64              `json:"total_weight"`
+	VotedWeight   float64              `json:"voted_weight"`
+	VotersCount   int                  `json:"voters_count"`
+	VotedPercent  float64              `json:"voted_percent"`
+	QuorumReached bool                 `json:"quorum_reached"`
+}
+
+// Charge is a monthly charge of the flat for the service imported from the
+// organization billing. Negative amount is a recalculation.
+type Charge struct {
+	ID          int           `db:"id" json:"id"`
+	FlatID      int           `db:"flat_id" json:"flat_id"`
+	FlatAddress string        `db:"flat_address" json:"flat_address"`
+	Period      time.Time     `db:"period" json:"period"`
+	Service     string        `db:"service" json:"service"`
+	Amount      decimal.Money `db:"amount" json:"amount"`
+	CreatedAt   time.Time     `db:"created_at" json:"created_at"`
+}
+
+func (c Charge) Validate() error {
+	if c.FlatID == 0 {
+		return errors.New("flat required")
+	}
+	if c.Period.IsZero() {
+		return errors.New("period required")
+	}
+	if c.Service == "" {
+		return errors.New("service required")
+	}
+	return nil
+}
+
+// Payment is a payment for the flat. ExternalID is unique per flat and
+// provider, so repeated import or callback records the payment once.
+type Payment struct {
+	ID          int           `db:"id" json:"id"`
+	FlatID      int           `db:"flat_id" json:"flat_id"`
+	FlatAddress string        `db:"flat_address" json:"flat_address"`
+	Amount      decimal.Money `db:"amount" json:"amount"`
+	PaidAt      time.Time     `db:"paid_at" json:"paid_at"`
+	Provider    string        `db:"provider" json:"provider"`
+	ExternalID  string        `db:"external_id" json:"external_id"`
+	CreatedAt   time.Time     `db:"created_at" json:"created_at"`
+}
+
+func (p Payment) Validate() error {
+	if p.FlatID == 0 {
+		return errors.New("flat required")
+	}
+	if p.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if p.PaidAt.IsZero() {
+		return errors.New("payment time required")
+	}
+	if p.ExternalID == "" {
+		return errors.New("external ID required")
+	}
+	return provider.Validate(p.Provider)
+}
+
+// Ledger is the flat charges and payments history. Positive balance is the
+// flat debt and negative one is the overpayment.
+type Ledger struct {
+	FlatID   int           `json:"flat_id"`
+	Charged  decimal.Money `json:"charged"`
+	Paid     decimal.Money `json:"paid"`
+	Balance  decimal.Money `json:"balance"`
+	Charges  []Charge      `json:"charges"`
+	Payments []Payment     `json:"payments"`
+}
+
+// Debt returns the flat debt or zero if there is no debt.
+func (l Ledger) Debt() decimal.Money {
+	if l.Balance > 0 {
+		return l.Balance
+	}
+	return 0
+}
+
+// Overpayment returns the flat overpayment or zero if there is no one.
+func (l Ledger) Overpayment() decimal.Money {
+	if l.Balance < 0 {
+		return -l.Balance
+	}
+	return 0
+}
+
+// Debtor is a flat with charges exceeding payments.
+type Debtor struct {
+	FlatID      int           `db:"flat_id" json:"flat_id"`
+	FlatAddress string        `db:"flat_address" json:"flat_address"`
+	Account     string        `db:"account" json:"account"`
+	Charged     decimal.Money `db:"charged" json:"charged"`
+	Paid        decimal.Money `db:"paid" json:"paid"`
+	Debt        decimal.Money `db:"debt" json:"debt"`
+	LastPaidAt  *time.Time    `db:"last_paid_at" json:"last_paid_at"`
+}
+
+// DocumentFolder groups the organization documents.
+type DocumentFolder struct {
 	ID             int       `db:"id" json:"id" form:"id"`
 	OrganizationID int       `db:"organization_id" json:"organization_id" form:"-"`
-	OwnerID        int       `db:"owner_id" json:"owner_id" form:"-"`
-	OperatorID     *int      `db:"operator_id" json:"operator_id" form:"-"`
-	CategoryID     *int      `db:"category_id" json:"category_id" form:"-"`
-	Text           string    `db:"text" json:"text" form:"text"`
-	Response       *string   `db:"response" json:"response" form:"response"`
-	Status         string    `db:"status" json:"status" form:"status"`
+	Name           string    `db:"name" json:"name" form:"name"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at" form:"-"`
 }
 
+func (f DocumentFolder) Validate() error {
+	if f.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+// Document is a document the organization publishes to the residents. Nil
+// building means the document is for all buildings of the organization.
+// Version fields are of the latest document version, DownloadsCount and
+// DownloadersCount are of all versions.
+type Document struct {
+	ID               int       `db:"id" json:"id" form:"id"`
+	OrganizationID   int       `db:"organization_id" json:"organization_id" form:"-"`
+	FolderID         *int      `db:"folder_id" json:"folder_id" form:"folder_id"`
+	FolderName       *string   `db:"folder_name" json:"folder_name" form:"-"`
+	BuildingID       *int      `db:"building_id" json:"building_id" form:"building_id"`
+	BuildingAddress  *string   `db:"building_address" json:"building_address" form:"-"`
+	Title            string    `db:"title" json:"title" form:"title"`
+	Description      string    `db:"description" json:"description" form:"description"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at" form:"-"`
+	UpdatedAt        time.Time `db:"updated_at" json:"updated_at" form:"-"`
+	VersionID        int       `db:"version_id" json:"version_id" form:"-"`
+	Version          int       `db:"version" json:"version" form:"-"`
+	FileName         string    `db:"file_name" json:"file_name" form:"-"`
+	ContentType      string    `db:"content_type" json:"content_type" form:"-"`
+	Size             int64     `db:"size" json:"size" form:"-"`
+	DownloadsCount   int       `db:"downloads_count" json:"downloads_count" form:"-"`
+	DownloadersCount int       `db:"downloaders_count" json:"downloaders_count" form:"-"`
+}
+
+func (d Document) Validate() error {
+	if d.Title == "" {
+		return errors.New("title required")
+	}
+	return nil
+}
+
+// InFolder checks that the document is in the folder.
+func (d Document) InFolder(folderID int) bool {
+	return d.FolderID != nil && *d.FolderID == folderID
+}
+
+// TargetedAt checks that the document is for the building only.
+func (d Document) TargetedAt(buildingID int) bool {
+	return d.BuildingID != nil && *d.BuildingID == buildingID
+}
+
+// DocumentVersion is an uploaded file of the document. AttachmentKey is the
+// key of the file in the attachment storage.
+type DocumentVersion struct {
+	ID               int       `db:"id" json:"id"`
+	DocumentID       int       `db:"document_id" json:"document_id"`
+	Version          int       `db:"version" json:"version"`
+	AttachmentKey    string    `db:"attachment_key" json:"-"`
+	FileName         string    `db:"file_name" json:"file_name"`
+	ContentType      string    `db:"content_type" json:"content_type"`
+	Size             int64     `db:"size" json:"size"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+	DownloadsCount   int       `db:"downloads_count" json:"downloads_count"`
+	DownloadersCount int       `db:"downloaders_count" json:"downloaders_count"`
+}
+
+// RegistryAddress is a building address imported from the local addresses
+// registry. Key matches different spellings of the address.
+type RegistryAddress struct {
+	ID         int    `db:"id" json:"id"`
+	GUID       string `db:"guid" json:"guid"`
+	City       string `db:"city" json:"city"`
+	StreetType string `db:"street_type" json:"street_type"`
+	Street     string `db:"street" json:"street"`
+	House      string `db:"house" json:"house"`
+	Block      string `db:"block" json:"block"`
+	Structure  string `db:"structure" json:"structure"`
+	Address    string `db:"address" json:"address"`
+	Key        string `db:"key" json:"-"`
+}
+
+// Contractor is an outside company doing jobs for the organization.
+type Contractor struct {
+	ID             int    `db:"id" json:"id" form:"id"`
+	OrganizationID int    `db:"organization_id" json:"organization_id" form:"-"`
+	Name           string `db:"name" json:"name" form:"name"`
+	Phone          string `db:"phone" json:"phone" form:"phone"`
+	Email          string `db:"email" json:"email" form:"email"`
+}
+
+func (c Contractor) Validate() error {
+	if c.Name == "" {
+		return errors.New("name required")
+	}
+	if c.Phone == "" && c.Email == "" {
+		return errors.New("phone or email required")
+	}
+	return nil
+}
+
+// WorkOrder is a job of the request subcontracted to the contractor.
+// Contractor updates its status by the link with Token. Token is expired
+// when work order becomes final.
+type WorkOrder struct {
+	ID           int        `db:"id" json:"id" form:"id"`
+	RequestID    int        `db:"request_id" json:"request_id" form:"request_id"`
+	ContractorID int        `db:"contractor_id" json:"contractor_id" form:"contractor_id"`
+	Scope        string     `db:"scope" json:"scope" form:"scope"`
+	DueAtStr     string     `db:"-" json:"-" form:"due_at"`
+	DueAt        *time.Time `db:"due_at" json:"due_at" form:"-"`
+	Status       string     `db:"status" json:"status" form:"status"`
+	Comment      *string    `db:"comment" json:"comment" form:"comment"`
+	Token        *string    `db:"token" json:"-" form:"-"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at" form:"-"`
+	CompletedAt  *time.Time `db:"completed_at" json:"completed_at" form:"-"`
+}
+
+func (wo WorkOrder) Validate() error {
+	if wo.Scope == "" {
+		return errors.New("scope required")
+	}
+	return workstatus.Validate(wo.Status)
+}
+
+func (wo WorkOrder) Final() bool {
+	return workstatus.Final(wo.Status)
+}
+
+type WorkOrderExtended struct {
+	WorkOrder `db:",inline"`
+
+	ContractorName  string `db:"contractor_name" json:"contractor_name"`
+	ContractorPhone string `db:"contractor_phone" json:"contractor_phone"`
+	ContractorEmail string `db:"contractor_email" json:"contractor_email"`
+
+	RequestText  string  `db:"request_text" json:"request_text"`
+	OwnerPhone   *string `db:"owner_phone" json:"owner_phone"`
+	OwnerAddress *string `db:"owner_address" json:"owner_address"`
+}
+
+// ContractorWorkOrder is a work order as it is shown to the contractor by
+// the token link. Contractor gets only what is needed to do the job.
+type ContractorWorkOrder struct {
+	ID             int        `json:"id"`
+	RequestID      int        `json:"request_id"`
+	Scope          string     `json:"scope"`
+	DueAt          *time.Time `json:"due_at"`
+	Status         string     `json:"status"`
+	Comment        *string    `json:"comment"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	ContractorName string     `json:"contractor_name"`
+	RequestText    string     `json:"request_text"`
+	Address        *string    `json:"address"`
+}
+
+// ContractorWorkOrder returns the contractor view of the work order.
+func (wo WorkOrderExtended) ContractorWorkOrder() ContractorWorkOrder {
+	return ContractorWorkOrder{
+		ID:             wo.ID,
+		RequestID:      wo.RequestID,
+		Scope:          wo.Scope,
+		DueAt:          wo.DueAt,
+		Status:         wo.Status,
+		Comment:        wo.Comment,
+		CreatedAt:      wo.CreatedAt,
+		CompletedAt:    wo.CompletedAt,
+		ContractorName: wo.ContractorName,
+		RequestText:    wo.RequestText,
+		Address:        wo.OwnerAddress,
+	}
+}
+
+// Incident is a building-wide problem such as water shut off. Owners
+// requests related to the incident are attached to it and resolved with it.
+type Incident struct {
+	ID                      int        `db:"id" json:"id" form:"id"`
+	OrganizationID          int        `db:"organization_id" json:"organization_id" form:"-"`
+	Title                   string     `db:"title" json:"title" form:"title"`
+	Description             string     `db:"description" json:"description" form:"description"`
+	BuildingsStr            string     `db:"-" json:"-" form:"buildings"`
+	Buildings               []string   `db:"buildings" json:"buildings" form:"-"`
+	ExpectedResolutionAtStr string     `db:"-" json:"-" form:"expected_resolution_at"`
+	ExpectedResolutionAt    *time.Time `db:"expected_resolution_at" json:"expected_resolution_at" form:"-"`
+	Response                *string    `db:"response" json:"response" form:"response"`
+	CreatedAt               time.Time  `db:"created_at" json:"created_at" form:"-"`
+	ResolvedAt              *time.Time `db:"resolved_at" json:"resolved_at" form:"-"`
+}
+
+func (i Incident) Validate() error {
+	if i.Title == "" {
+		return errors.New("title required")
+	}
+	if len(i.Buildings) == 0 {
+		return errors.New("buildings required")
+	}
+	return nil
+}
+
+func (i Incident) Resolved() bool {
+	return i.ResolvedAt != nil
+}
+
+type Request struct {
+	ID               int        `db:"id" json:"id" form:"id"`
+	OrganizationID   int        `db:"organization_id" json:"organization_id" form:"-"`
+	OwnerID          *int       `db:"owner_id" json:"owner_id" form:"-"`
+	CreatorRole      string     `db:"creator_role" json:"creator_role" form:"-"`
+	CreatorID        int        `db:"creator_id" json:"creator_id" form:"-"`
+	OperatorID       *int       `db:"operator_id" json:"operator_id" form:"-"`
+	CategoryID       *int       `db:"category_id" json:"category_id" form:"-"`
+	PrimaryRequestID *int       `db:"primary_request_id" json:"primary_request_id" form:"-"`
+	IncidentID       *int       `db:"incident_id" json:"incident_id" form:"incident_id"`
+	FlatID           *int       `db:"flat_id" json:"flat_id" form:"-"`
+	Building         *string    `db:"building" json:"building" form:"building"`
+	Entrance         *string    `db:"entrance" json:"entrance" form:"entrance"`
+	Floor            *int       `db:"floor" json:"floor" form:"floor"`
+	CommonArea       *string    `db:"common_area" json:"common_area" form:"common_area"`
+	Text             string     `db:"text" json:"text" form:"text"`
+	Response         *string    `db:"response" json:"response" form:"response"`
+	Status           string     `db:"status" json:"status" form:"status"`
+	Priority         string     `db:"priority" json:"priority" form:"priority"`
+	OwnerPriority    string     `db:"owner_priority" json:"-" form:"-"`
+	CreatedAt        time.Time  `db:"created_at" json:"created_at" form:"-"`
+	AcknowledgedAt   *time.Time `db:"acknowledged_at" json:"acknowledged_at" form:"-"`
+	NotifiedAt       *time.Time `db:"notified_at" json:"-" form:"-"`
+	FinishedAt       *time.Time `db:"finished_at" json:"finished_at" form:"-"`
+
+	// ResponseTemplateID is a response template to fill Response with when
+	// request is set by operator.
+	ResponseTemplateID *int `db:"-" json:"response_template_id,omitempty" form:"response_template_id"`
+}
+
 type RequestExtended struct {
 	Request `db:",inline"`
 
+	OrganizationName string `db:"organization_name"`
+
 	CategoryName *string `db:"category_name"`
 
 	OperatorPhone *string `db:"operator_phone"`
@@ -104,13 +884,632 @@ type RequestExtended struct {
 	OwnerPhone   *string `db:"owner_phone"`
 	OwnerName    *string `db:"owner_name"`
 	OwnerAddress *string `db:"owner_address"`
+
+	// SupportsCount is a number of building owners who confirmed that common
+	// area problem concerns them too.
+	SupportsCount int  `db:"supports_count"`
+	Supported     bool `db:"-" json:",omitempty"`
+
+	Duplicates []RequestDuplicate `db:"-"`
+	Events     []RequestEvent     `db:"-"`
+
+	Visit          *VisitSlot  `db:"-"`
+	FreeVisitSlots []VisitSlot `db:"-"`
+
+	WorkOrders []WorkOrderExtended `db:"-"`
+
+	CostItems []CostItem `db:"-"`
+
+	// Notes are internal notes of the organization staff. They are never
+	// filled for owners.
+	Notes []RequestNote `db:"-" json:",omitempty"`
+
+	Labels []Label             `db:"-" json:",omitempty"`
+	Fields []RequestFieldValue `db:"-" json:",omitempty"`
+
+	// Tasks are checklist items of the request. They are never filled for
+	// owners, owners see Progress only.
+	Tasks []RequestTask `db:"-" json:",omitempty"`
+
+	// Progress is a percent of done request tasks. It is nil if request has
+	// no tasks.
+	Progress *int `db:"-" json:",omitempty"`
+}
+
+// Address returns address of the request location. Request without
+// building is located at the owner flat.
+func (r RequestExtended) Address() string {
+	if r.Building != nil {
+		return *r.Building
+	}
+	if r.OwnerAddress != nil {
+		return *r.OwnerAddress
+	}
+	return ""
+}
+
+func (r RequestExtended) HasLabel(labelID int) bool {
+	for _, l := range r.Labels {
+		if l.ID == labelID {
+			return true
+		}
+	}
+	return false
+}
+
+// FieldValue returns value of the custom field or empty string if it is not
+// set.
+func (r RequestExtended) FieldValue(fieldID int) string {
+	for _, fv := range r.Fields {
+		if fv.FieldID == fieldID {
+			return fv.Value
+		}
+	}
+	return ""
+}
+
+func (r RequestExtended) CostTotal() CostTotal {
+	var ct CostTotal
+	for _, ci := range r.CostItems {
+		ct.Add(ci)
+	}
+	return ct
+}
+
+// CommonRequest is an open common area request shown to the building
+// owners. It has no details of the owner who filed it.
+type CommonRequest struct {
+	ID         int       `json:"id"`
+	Building   *string   `json:"building"`
+	Entrance   *string   `json:"entrance"`
+	Floor      *int      `json:"floor"`
+	CommonArea *string   `json:"common_area"`
+	Text       string    `json:"text"`
+	Status     string    `json:"status"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// SupportsCount is a number of building owners who confirmed that common
+	// area problem concerns them too.
+	SupportsCount int `json:"supports_count"`
+
+	// Supported is true if the request is filed or supported by the owner.
+	Supported bool `json:"supported"`
+
+	// Own is true if the request is filed by the owner.
+	Own bool `json:"own"`
+}
+
+// bulkActionMaxRequests is maximum number of requests changed by a single
+// bulk action.
+const bulkActionMaxRequests = 200
+
+// BulkAction is a set of changes applied to each of the selected requests.
+// Nil and empty fields are left unchanged.
+type BulkAction struct {
+	RequestIDs []int   `json:"request_ids" form:"request_ids"`
+	Status     string  `json:"status" form:"status"`
+	OperatorID *int    `json:"operator_id" form:"operator_id"`
+	CategoryID *int    `json:"category_id" form:"category_id"`
+	Response   *string `json:"response" form:"response"`
+
+	// ResponseTemplateID is a response template rendered for each of the
+	// requests and prepended to Response.
+	ResponseTemplateID *int `json:"response_template_id" form:"response_template_id"`
+}
+
+func (ba BulkAction) Validate() error {
+	if len(ba.RequestIDs) == 0 {
+		return errors.New("no requests selected")
+	}
+	if len(ba.RequestIDs) > bulkActionMaxRequests {
+		return errors.New("too many requests selected")
+	}
+	if ba.Status == "" && ba.OperatorID == nil && ba.CategoryID == nil &&
+		ba.Response == nil && ba.ResponseTemplateID == nil {
+		return errors.New("no changes set")
+	}
+	switch ba.Status {
+	case "", status.InProgress, status.Resolved, status.Rejected,
+		status.Irrelevant:
+		return nil
+	}
+	return errors.New("invalid status")
+}
+
+// BulkResult is an outcome of the bulk action for a single request.
+type BulkResult struct {
+	RequestID int    `json:"request_id"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RequestFilter selects requests. Nil and zero fields are not applied. Query
+// is full-text searched in request text and response. HouseholdOf selects
+// requests of the owner and the other residents of the owner flat.
+type RequestFilter struct {
+	OrganizationID *int
+	OwnerID        *int
+	HouseholdOf    *int
+	OperatorID     *int
+	Statuses       []string
+	CategoryID     *int
+	From           *time.Time
+	To             *time.Time
+	OwnerAddress   string
+	Query          string
+	LabelID        *int
+	FieldID        *int
+	FieldValue     string
+	Overdue        bool
+	Sorting        string
+	After          *RequestCursor
+	Limit          int
+}
+
+// Label is a mark of the organization requests.
+type Label struct {
+	ID             int    `db:"id" json:"id" form:"id"`
+	OrganizationID int    `db:"organization_id" json:"organization_id" form:"-"`
+	Name           string `db:"name" json:"name" form:"name"`
+}
+
+func (l Label) Validate() error {
+	if l.Name == "" {
+		return errors.New("name required")
+	}
+	return nil
+}
+
+// CustomField is a typed field of the organization requests. Value of enum
+// field is one of the Options.
+type CustomField struct {
+	ID             int      `db:"id" json:"id" form:"id"`
+	OrganizationID int      `db:"organization_id" json:"organization_id" form:"-"`
+	Name           string   `db:"name" json:"name" form:"name"`
+	Type           string   `db:"type" json:"type" form:"type"`
+	OptionsStr     string   `db:"-" json:"-" form:"options"`
+	Options        []string `db:"options" json:"options" form:"-"`
+}
+
+func (cf CustomField) Validate() error {
+	if cf.Name == "" {
+		return errors.New("name required")
+	}
+	err := fieldtype.Validate(cf.Type)
+	if err != nil {
+		return err
+	}
+	if cf.Type == fieldtype.Enum && len(cf.Options) == 0 {
+		return errors.New("options required")
+	}
+	return nil
+}
+
+func (cf CustomField) IsText() bool {
+	return cf.Type == fieldtype.Text
+}
+
+func (cf CustomField) IsNumber() bool {
+	return cf.Type == fieldtype.Number
+}
+
+func (cf CustomField) IsDate() bool {
+	return cf.Type == fieldtype.Date
+}
+
+func (cf CustomField) IsEnum() bool {
+	return cf.Type == fieldtype.Enum
+}
+
+// ValidateValue checks that the value is of the field type. Date is in
+// 2006-01-02 format.
+func (cf CustomField) ValidateValue(value string) error {
+	switch cf.Type {
+	case fieldtype.Number:
+		_, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.New("invalid number")
+		}
+	case fieldtype.Date:
+		_, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return errors.New("invalid date")
+		}
+	case fieldtype.Enum:
+		for _, o := range cf.Options {
+			if o == value {
+				return nil
+			}
+		}
+		return errors.New("invalid option")
+	}
+	return nil
+}
+
+// RequestFieldValue is a value of the custom field of the request.
+type RequestFieldValue struct {
+	RequestID int    `db:"request_id" json:"-"`
+	FieldID   int    `db:"field_id" json:"field_id"`
+	FieldName string `db:"field_name" json:"field_name"`
+	Value     string `db:"value" json:"value"`
+}
+
+// RequestAttributes are labels and custom field values set to the request.
+// Custom fields with empty value are unset. Forms pass custom fields as
+// parallel FieldIDs and FieldValues lists.
+type RequestAttributes struct {
+	RequestID   int                 `json:"-" form:"request_id"`
+	LabelIDs    []int               `json:"label_ids" form:"label_ids"`
+	Fields      []RequestFieldValue `json:"fields" form:"-"`
+	FieldIDs    []int               `json:"-" form:"field_id"`
+	FieldValues []string            `json:"-" form:"field_value"`
+}
+
+// ChecklistTemplate is a list of tasks added to the organization requests of
+// the category on creation. Form passes items one per line.
+type ChecklistTemplate struct {
+	ID             int      `db:"id" json:"id" form:"id"`
+	OrganizationID int      `db:"organization_id" json:"organization_id" form:"-"`
+	CategoryID     int      `db:"category_id" json:"category_id" form:"category_id"`
+	CategoryName   string   `db:"category_name" json:"category_name" form:"-"`
+	ItemsStr       string   `db:"-" json:"-" form:"items"`
+	Items          []string `db:"items" json:"items" form:"-"`
+}
+
+func (ct ChecklistTemplate) Validate() error {
+	if ct.CategoryID == 0 {
+		return errors.New("category required")
+	}
+	if len(ct.Items) == 0 {
+		return errors.New("items required")
+	}
+	return nil
+}
+
+// RequestTask is an item of the request checklist. Task can be assigned to
+// an operator other than the request operator.
+type RequestTask struct {
+	ID           int        `db:"id" json:"id" form:"id"`
+	RequestID    int        `db:"request_id" json:"request_id" form:"request_id"`
+	Position     int        `db:"position" json:"position" form:"-"`
+	Title        string     `db:"title" json:"title" form:"title"`
+	OperatorID   *int       `db:"operator_id" json:"operator_id" form:"operator_id"`
+	OperatorName *string    `db:"operator_name" json:"operator_name" form:"-"`
+	Done         bool       `db:"done" json:"done" form:"done"`
+	DoneAt       *time.Time `db:"done_at" json:"done_at" form:"-"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at" form:"-"`
+}
+
+func (rt RequestTask) Validate() error {
+	if rt.Title == "" {
+		return errors.New("title required")
+	}
+	return nil
+}
+
+// AssignedTo returns true if the task is assigned to the operator.
+func (rt RequestTask) AssignedTo(operatorID int) bool {
+	return rt.OperatorID != nil && *rt.OperatorID == operatorID
+}
+
+// TasksProgress returns percent of done tasks or nil if there are no tasks.
+func TasksProgress(ts []RequestTask) *int {
+	if len(ts) == 0 {
+		return nil
+	}
+	var done int
+	for _, t := range ts {
+		if t.Done {
+			done++
+		}
+	}
+	p := done * 100 / len(ts)
+	return &p
+}
+
+// RequestCursor is a keyset pagination cursor pointing to the last request
+// of the previous page.
+type RequestCursor struct {
+	PriorityRank int
+	CreatedAt    time.Time
+	ID           int
+}
+
+// RequestEvent is a record of the request history. Role and ActorID
+// identify who made the change.
+type RequestEvent struct {
+	ID        int       `db:"id" json:"id"`
+	RequestID int       `db:"request_id" json:"request_id"`
+	Role      string    `db:"role" json:"role"`
+	ActorID   int       `db:"actor_id" json:"actor_id"`
+	Kind      string    `db:"kind" json:"kind"`
+	Text      *string   `db:"text" json:"text"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func (re RequestEvent) Edited() bool {
+	return re.Kind == event.Edited
+}
+
+func (re RequestEvent) Cancelled() bool {
+	return re.Kind == event.Cancelled
+}
+
+func (re RequestEvent) StatusChanged() bool {
+	return re.Kind == event.StatusChanged
+}
+
+func (re RequestEvent) Reassigned() bool {
+	return re.Kind == event.Reassigned
+}
+
+func (re RequestEvent) CategoryChanged() bool {
+	return re.Kind == event.CategoryChanged
+}
+
+func (re RequestEvent) Responded() bool {
+	return re.Kind == event.Responded
+}
+
+// RequestNote is an internal note of the organization staff on the request.
+// Notes are never shown to owner.
+type RequestNote struct {
+	ID         int       `db:"id" json:"id"`
+	RequestID  int       `db:"request_id" json:"request_id" form:"request_id"`
+	Role       string    `db:"role" json:"role"`
+	AuthorID   int       `db:"author_id" json:"author_id"`
+	AuthorName *string   `db:"author_name" json:"author_name"`
+	Text       string    `db:"text" json:"text" form:"text"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+func (rn RequestNote) Validate() error {
+	if rn.Text == "" {
+		return errors.New("text required")
+	}
+	return nil
+}
+
+// VisitSlot is a time interval when operator is available to visit owner.
+// Slot is booked by the request when RequestID is set.
+type VisitSlot struct {
+	ID          int        `db:"id" json:"id" form:"id"`
+	OperatorID  int        `db:"operator_id" json:"operator_id" form:"-"`
+	StartsAtStr string     `db:"-" json:"-" form:"starts_at"`
+	StartsAt    time.Time  `db:"starts_at" json:"starts_at" form:"-"`
+	EndsAtStr   string     `db:"-" json:"-" form:"ends_at"`
+	EndsAt      time.Time  `db:"ends_at" json:"ends_at" form:"-"`
+	RequestID   *int       `db:"request_id" json:"request_id" form:"-"`
+	RemindedAt  *time.Time `db:"reminded_at" json:"-" form:"-"`
+}
+
+func (vs VisitSlot) Validate() error {
+	if vs.StartsAt.IsZero() || vs.EndsAt.IsZero() {
+		return errors.New("starts at and ends at required")
+	}
+	if !vs.EndsAt.After(vs.StartsAt) {
+		return errors.New("ends at must be after starts at")
+	}
+	return nil
+}
+
+func (vs VisitSlot) Booked() bool {
+	return vs.RequestID != nil
+}
+
+type VisitSlotExtended struct {
+	VisitSlot `db:",inline"`
+
+	RequestText *string `db:"request_text"`
+
+	OperatorPhone *string `db:"operator_phone"`
+	OperatorName  *string `db:"operator_name"`
+
+	OwnerPhone   *string `db:"owner_phone"`
+	OwnerName    *string `db:"owner_name"`
+	OwnerAddress *string `db:"owner_address"`
+}
+
+// ResponseTemplate is a canned response of the organization. Template with
+// CategoryID is offered for requests of that category only. Placeholders of
+// Text are filled from the request by Render.
+type ResponseTemplate struct {
+	ID             int        `db:"id" json:"id" form:"id"`
+	OrganizationID int        `db:"organization_id" json:"organization_id" form:"-"`
+	CategoryID     *int       `db:"category_id" json:"category_id" form:"category_id"`
+	Title          string     `db:"title" json:"title" form:"title"`
+	Text           string     `db:"text" json:"text" form:"text"`
+	UsesCount      int        `db:"uses_count" json:"uses_count" form:"-"`
+	LastUsedAt     *time.Time `db:"last_used_at" json:"last_used_at" form:"-"`
+}
+
+// ResponseTemplatePlaceholders are placeholders available in response
+// template text.
+var ResponseTemplatePlaceholders = []string{
+	"{request_id}",
+	"{request_date}",
+	"{category}",
+	"{owner_name}",
+	"{owner_address}",
+	"{operator_name}",
+	"{date}",
+}
+
+var responseTemplatePlaceholderRegexp = regexp.MustCompile(`\{[a-z_]+\}`)
+
+func (rt ResponseTemplate) Validate() error {
+	if rt.Title == "" {
+		return errors.New("title required")
+	}
+	if rt.Text == "" {
+		return errors.New("text required")
+	}
+	for _, p := range responseTemplatePlaceholderRegexp.FindAllString(
+		rt.Text, -1) {
+		known := false
+		for _, kp := range ResponseTemplatePlaceholders {
+			if p == kp {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return errors.New("unknown placeholder " + p)
+		}
+	}
+	return nil
+}
+
+// Fits returns true if template is offered for requests of the category.
+func (rt ResponseTemplate) Fits(categoryID *int) bool {
+	return rt.CategoryID == nil ||
+		(categoryID != nil && *rt.CategoryID == *categoryID)
+}
+
+func (rt ResponseTemplate) HasCategory(categoryID int) bool {
+	return rt.CategoryID != nil && *rt.CategoryID == categoryID
+}
+
+// Render returns template text with placeholders filled from the request.
+func (rt ResponseTemplate) Render(r RequestExtended, now time.Time) string {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	return strings.NewReplacer(
+		"{request_id}", strconv.Itoa(r.ID),
+		"{request_date}", r.CreatedAt.In(time.Local).Format("02.01.2006"),
+		"{category}", value(r.CategoryName),
+		"{owner_name}", value(r.OwnerName),
+		"{owner_address}", value(r.OwnerAddress),
+		"{operator_name}", value(r.OperatorName),
+		"{date}", now.In(time.Local).Format("02.01.2006"),
+	).Replace(rt.Text)
+}
+
+// ResponseTemplateUse is a record of the response template used to respond
+// to the request. ResponseTemplateID is nil once the template is removed.
+type ResponseTemplateUse struct {
+	ID                 int       `db:"id" json:"id"`
+	ResponseTemplateID *int      `db:"response_template_id" json:"response_template_id"`
+	RequestID          int       `db:"request_id" json:"request_id"`
+	Role               string    `db:"role" json:"role"`
+	ActorID            int       `db:"actor_id" json:"actor_id"`
+	UsedAt             time.Time `db:"used_at" json:"used_at"`
+}
+
+// CostItem is a material or labor spent on the request. Labor Quantity is
+// in hours and UnitPrice is hourly rate. Labor is attributed to OperatorID.
+type CostItem struct {
+	ID           int              `db:"id" json:"id" form:"id"`
+	RequestID    int              `db:"request_id" json:"request_id" form:"request_id"`
+	Kind         string           `db:"kind" json:"kind" form:"kind"`
+	Name         string           `db:"name" json:"name" form:"name"`
+	OperatorID   *int             `db:"operator_id" json:"operator_id" form:"operator_id"`
+	QuantityStr  string           `db:"-" json:"-" form:"quantity"`
+	Quantity     decimal.Quantity `db:"quantity" json:"quantity" form:"-"`
+	UnitPriceStr string           `db:"-" json:"-" form:"unit_price"`
+	UnitPrice    decimal.Money    `db:"unit_price" json:"unit_price" form:"-"`
+	Billable     bool             `db:"billable" json:"billable" form:"billable"`
+	CreatedAt    time.Time        `db:"created_at" json:"created_at" form:"-"`
+}
+
+func (ci CostItem) Validate() error {
+	err := costkind.Validate(ci.Kind)
+	if err != nil {
+		return err
+	}
+	if ci.Kind == costkind.Material && ci.Name == "" {
+		return errors.New("material name required")
+	}
+	if ci.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+	if ci.UnitPrice < 0 {
+		return errors.New("unit price must not be negative")
+	}
+	return nil
+}
+
+func (ci CostItem) Amount() decimal.Money {
+	return ci.UnitPrice.Mul(ci.Quantity)
+}
+
+func (ci CostItem) Labor() bool {
+	return ci.Kind == costkind.Labor
+}
+
+type CostItemExtended struct {
+	CostItem `db:",inline"`
+
+	RequestStatus string  `db:"request_status"`
+	CategoryName  *string `db:"category_name"`
+	OperatorName  *string `db:"operator_name"`
+	OwnerAddress  *string `db:"owner_address"`
+}
+
+// CostTotal is a total of cost items grouped by Name.
+type CostTotal struct {
+	Name     string        `json:"name"`
+	Total    decimal.Money `json:"total"`
+	Billable decimal.Money `json:"billable"`
+}
+
+func (ct *CostTotal) Add(ci CostItem) {
+	ct.Total += ci.Amount()
+	if ci.Billable {
+		ct.Billable += ci.Amount()
+	}
+}
+
+// CostReport is a cost items totals for the period.
+type CostReport struct {
+	From       time.Time   `json:"from"`
+	To         time.Time   `json:"to"`
+	Total      CostTotal   `json:"total"`
+	Requests   []CostTotal `json:"requests"`
+	Categories []CostTotal `json:"categories"`
+	Buildings  []CostTotal `json:"buildings"`
+}
+
+// RequestDuplicate is a likely duplicate of the request. Text, Status and
+// CreatedAt are of the duplicate request.
+type RequestDuplicate struct {
+	RequestID   int       `db:"request_id" json:"request_id"`
+	DuplicateID int       `db:"duplicate_id" json:"duplicate_id"`
+	Similarity  float64   `db:"similarity" json:"similarity"`
+	Text        string    `db:"text" json:"text"`
+	Status      string    `db:"status" json:"status"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+}
+
+func (rd RequestDuplicate) SimilarityPercent() int {
+	return int(rd.Similarity * 100)
 }
 
 func (r Request) Validate() error {
-	// TODO: validate request
+	if r.CommonArea != nil {
+		err := area.Validate(*r.CommonArea)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// HasCommonArea reports whether request is about common area of the
+// building rather than a flat.
+func (r Request) HasCommonArea() bool {
+	return r.CommonArea != nil
+}
+
+// CreatedBy reports whether request was created by the owner.
+func (r Request) CreatedBy(ownerID int) bool {
+	return r.OwnerID != nil && *r.OwnerID == ownerID
+}
+
 func (r Request) HasNewStatus() bool {
 	return r.Status == status.New
 }
@@ -118,3 +1517,87 @@ func (r Request) HasNewStatus() bool {
 func (r Request) HasInProgressStatus() bool {
 	return r.Status == status.InProgress
 }
+
+func (r Request) HasCancelledStatus() bool {
+	return r.Status == status.Cancelled
+}
+
+func (r Request) HasUrgentPriority() bool {
+	return r.Priority == priority.Urgent
+}
+
+func (r Request) HasEmergencyPriority() bool {
+	return r.Priority == priority.Emergency
+}
+
+func (r Request) Acknowledged() bool {
+	return r.AcknowledgedAt != nil
+}
+
+// Deadline returns time by which request should be finished according to
+// its priority SLA.
+func (r Request) Deadline() time.Time {
+	return r.CreatedAt.Add(priority.SLA(r.Priority))
+}
+
+// Age returns time passed since request creation till it is finished. Age
+// of requests finished before finish time was recorded is zero.
+func (r Request) Age() time.Duration {
+	if r.FinishedAt != nil {
+		return r.FinishedAt.Sub(r.CreatedAt)
+	}
+	if status.Final(r.Status) {
+		return 0
+	}
+	return time.Since(r.CreatedAt)
+}
+
+func (r Request) AgeHours() int {
+	return int(r.Age().Hours())
+}
+
+// Overdue reports whether request is finished or still open after the
+// deadline.
+func (r Request) Overdue() bool {
+	return r.CreatedAt.Add(r.Age()).After(r.Deadline())
+}
+
+// OperatorStats is a breakdown of the organization requests by operator.
+// OperatorID is nil for requests without operator.
+type OperatorStats struct {
+	OperatorID         *int     `db:"operator_id" json:"operator_id"`
+	OperatorName       *string  `db:"operator_name" json:"operator_name"`
+	New                int      `db:"new" json:"new"`
+	InProgress         int      `db:"in_progress" json:"in_progress"`
+	Finished           int      `db:"finished" json:"finished"`
+	Overdue            int      `db:"overdue" json:"overdue"`
+	FinishedLate       int      `db:"finished_late" json:"finished_late"`
+	AvgResolutionHours *float64 `db:"avg_resolution_hours" json:"avg_resolution_hours"`
+}
+
+// OrganizationStats is a breakdown of the organization requests for admins.
+// Open requests are new and in progress ones. Recategorized requests are ones
+// whose classifier assigned category was changed by the staff.
+type OrganizationStats struct {
+	OrganizationID     int        `db:"organization_id" json:"organization_id"`
+	OrganizationName   string     `db:"organization_name" json:"organization_name"`
+	FlatsCount         int        `db:"flats_count" json:"flats_count"`
+	Total              int        `db:"total" json:"total"`
+	Open               int        `db:"open" json:"open"`
+	Unassigned         int        `db:"unassigned" json:"unassigned"`
+	Overdue            int        `db:"overdue" json:"overdue"`
+	OldestOpenAt       *time.Time `db:"oldest_open_at" json:"oldest_open_at"`
+	Uncategorized      int        `db:"uncategorized" json:"uncategorized"`
+	Recategorized      int        `db:"recategorized" json:"recategorized"`
+	AvgResolutionHours *float64   `db:"avg_resolution_hours" json:"avg_resolution_hours"`
+}
+
+// CategoryStats is a breakdown of requests by category. Requests without
+// category have nil CategoryID.
+type CategoryStats struct {
+	CategoryID    *int    `db:"category_id" json:"category_id"`
+	CategoryName  *string `db:"category_name" json:"category_name"`
+	Total         int     `db:"total" json:"total"`
+	Open          int     `db:"open" json:"open"`
+	Recategorized int     `db:"recategorized" json:"recategorized"`
+}
@@ -0,0 +1,21 @@
+package event
+
+import "errors"
+
+const (
+	Edited          = "edited"
+	Cancelled       = "cancelled"
+	StatusChanged   = "status_changed"
+	Reassigned      = "reassigned"
+	CategoryChanged = "category_changed"
+	Responded       = "responded"
+)
+
+func Validate(event string) error {
+	switch event {
+	case Edited, Cancelled, StatusChanged, Reassigned, CategoryChanged,
+		Responded:
+		return nil
+	}
+	return errors.New("invalid event")
+}
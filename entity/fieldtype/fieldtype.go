@@ -0,0 +1,18 @@
+package fieldtype
+
+import "errors"
+
+const (
+	Text   = "text"
+	Number = "number"
+	Date   = "date"
+	Enum   = "enum"
+)
+
+func Validate(fieldType string) error {
+	switch fieldType {
+	case Text, Number, Date, Enum:
+		return nil
+	}
+	return errors.New("invalid field type")
+}
@@ -0,0 +1,20 @@
+package household
+
+import "errors"
+
+// Roles of the flat residents. Owner is the primary owner managing the
+// household.
+const (
+	Owner        = "owner"
+	CoOwner      = "co_owner"
+	Tenant       = "tenant"
+	FamilyMember = "family_member"
+)
+
+func Validate(r string) error {
+	switch r {
+	case Owner, CoOwner, Tenant, FamilyMember:
+		return nil
+	}
+	return errors.New("invalid household role")
+}
@@ -0,0 +1,19 @@
+package meter
+
+import "errors"
+
+const (
+	ColdWater   = "cold_water"
+	HotWater    = "hot_water"
+	Heating     = "heating"
+	Electricity = "electricity"
+	Gas         = "gas"
+)
+
+func Validate(t string) error {
+	switch t {
+	case ColdWater, HotWater, Heating, Electricity, Gas:
+		return nil
+	}
+	return errors.New("invalid meter type")
+}
@@ -0,0 +1,53 @@
+package priority
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	Normal    = "normal"
+	Urgent    = "urgent"
+	Emergency = "emergency"
+)
+
+// Rank returns priority rank. Higher priority has greater rank.
+func Rank(priority string) int {
+	switch priority {
+	case Urgent:
+		return 1
+	case Emergency:
+		return 2
+	}
+	return 0
+}
+
+// Max returns the highest of the given priorities.
+func Max(priorities ...string) string {
+	max := Normal
+	for _, p := range priorities {
+		if Rank(p) > Rank(max) {
+			max = p
+		}
+	}
+	return max
+}
+
+// SLA returns time in which request of the priority should be finished.
+func SLA(priority string) time.Duration {
+	switch priority {
+	case Emergency:
+		return 4 * time.Hour
+	case Urgent:
+		return 24 * time.Hour
+	}
+	return 72 * time.Hour
+}
+
+func Validate(priority string) error {
+	switch priority {
+	case Normal, Urgent, Emergency:
+		return nil
+	}
+	return errors.New("invalid priority")
+}
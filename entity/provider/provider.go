@@ -0,0 +1,18 @@
+package provider
+
+import "errors"
+
+// Payments are recorded by the organization import or by the payment
+// provider callback. Stub is the local provider for the development.
+const (
+	Import = "import"
+	Stub   = "stub"
+)
+
+func Validate(p string) error {
+	switch p {
+	case Import, Stub:
+		return nil
+	}
+	return errors.New("invalid payment provider")
+}
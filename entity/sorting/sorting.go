@@ -0,0 +1,17 @@
+package sorting
+
+import "errors"
+
+const (
+	Newest   = "newest"
+	Oldest   = "oldest"
+	Priority = "priority"
+)
+
+func Validate(sorting string) error {
+	switch sorting {
+	case Newest, Oldest, Priority:
+		return nil
+	}
+	return errors.New("invalid sorting")
+}
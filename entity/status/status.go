@@ -8,11 +8,12 @@ const (
 	Resolved   = "resolved"
 	Rejected   = "rejected"
 	Irrelevant = "irrelevant"
+	Cancelled  = "cancelled"
 )
 
 func Final(status string) bool {
 	switch status {
-	case Resolved, Rejected, Irrelevant:
+	case Resolved, Rejected, Irrelevant, Cancelled:
 		return true
 	}
 	return false
@@ -20,7 +21,7 @@ func Final(status string) bool {
 
 func Validate(status string) error {
 	switch status {
-	case New, InProgress, Resolved, Rejected, Irrelevant:
+	case New, InProgress, Resolved, Rejected, Irrelevant, Cancelled:
 		return nil
 	}
 	return errors.New("invalid status")
@@ -0,0 +1,17 @@
+package weighting
+
+import "errors"
+
+// Poll votes are weighted equally per flat or by flat area.
+const (
+	Flat = "flat"
+	Area = "area"
+)
+
+func Validate(w string) error {
+	switch w {
+	case Flat, Area:
+		return nil
+	}
+	return errors.New("invalid weighting")
+}
@@ -0,0 +1,27 @@
+package workstatus
+
+import "errors"
+
+const (
+	Issued     = "issued"
+	Accepted   = "accepted"
+	InProgress = "in_progress"
+	Completed  = "completed"
+	Declined   = "declined"
+)
+
+func Final(status string) bool {
+	switch status {
+	case Completed, Declined:
+		return true
+	}
+	return false
+}
+
+func Validate(status string) error {
+	switch status {
+	case Issued, Accepted, InProgress, Completed, Declined:
+		return nil
+	}
+	return errors.New("invalid work status")
+}
@@ -0,0 +1,101 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/decimal"
+	"github.com/dimuls/swan/entity/provider"
+)
+
+// StubPagePath is the path of the stub payment page served by the web
+// server.
+const StubPagePath = "/payment/stub"
+
+// Stub is the local payment provider for the development. Its payment page
+// confirms payment immediately. Payment fields are signed, so the callback
+// can't be forged without the key.
+type Stub struct {
+	key []byte
+}
+
+func NewStub(key []byte) (*Stub, error) {
+	if len(key) == 0 {
+		return nil, errors.New("key required")
+	}
+	return &Stub{key: key}, nil
+}
+
+var stubFields = []string{"flat_id", "amount", "external_id", "return_url"}
+
+func (s *Stub) sign(v url.Values) []byte {
+	signed := url.Values{}
+	for _, f := range stubFields {
+		signed.Set(f, v.Get(f))
+	}
+
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(signed.Encode()))
+
+	return m.Sum(nil)
+}
+
+// PaymentURL returns the stub payment page URL. Payer is returned to the
+// returnURL after the payment.
+func (s *Stub) PaymentURL(p entity.Payment, returnURL string) (string,
+	error) {
+
+	v := url.Values{}
+	v.Set("flat_id", strconv.Itoa(p.FlatID))
+	v.Set("amount", p.Amount.String())
+	v.Set("external_id", p.ExternalID)
+	v.Set("return_url", returnURL)
+	v.Set("sign", hex.EncodeToString(s.sign(v)))
+
+	return StubPagePath + "?" + v.Encode(), nil
+}
+
+// ParsePaymentCallback parses the payment confirmed on the stub payment
+// page.
+func (s *Stub) ParsePaymentCallback(r *http.Request) (entity.Payment,
+	error) {
+
+	var p entity.Payment
+
+	err := r.ParseForm()
+	if err != nil {
+		return p, errors.New("failed to parse form: " + err.Error())
+	}
+
+	sign, err := hex.DecodeString(r.Form.Get("sign"))
+	if err != nil {
+		return p, errors.New("failed to decode sign: " + err.Error())
+	}
+
+	if !hmac.Equal(sign, s.sign(r.Form)) {
+		return p, errors.New("invalid sign")
+	}
+
+	p.FlatID, err = strconv.Atoi(r.Form.Get("flat_id"))
+	if err != nil {
+		return p, errors.New("failed to parse flat_id: " + err.Error())
+	}
+
+	p.Amount, err = decimal.ParseMoney(r.Form.Get("amount"))
+	if err != nil {
+		return p, errors.New("failed to parse amount: " + err.Error())
+	}
+
+	p.ExternalID = r.Form.Get("external_id")
+	p.PaidAt = time.Now()
+	p.Provider = provider.Stub
+
+	return p, nil
+}
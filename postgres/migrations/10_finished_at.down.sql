@@ -0,0 +1,3 @@
+DROP INDEX requests_organization_id_created_at_idx;
+
+ALTER TABLE requests DROP COLUMN finished_at;
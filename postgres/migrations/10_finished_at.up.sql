@@ -0,0 +1,4 @@
+ALTER TABLE requests ADD COLUMN finished_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX requests_organization_id_created_at_idx
+    ON requests (organization_id, created_at DESC, id DESC);
@@ -0,0 +1,2 @@
+DROP TABLE response_template_uses;
+DROP TABLE response_templates;
@@ -0,0 +1,23 @@
+CREATE TABLE response_templates (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    category_id BIGINT REFERENCES categories (id) ON DELETE SET NULL,
+    title TEXT NOT NULL,
+    text TEXT NOT NULL
+);
+
+CREATE INDEX response_templates_organization_id_idx
+    ON response_templates (organization_id);
+
+CREATE TABLE response_template_uses (
+    id BIGSERIAL PRIMARY KEY,
+    response_template_id BIGINT NOT NULL
+        REFERENCES response_templates (id) ON DELETE CASCADE,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    actor_id BIGINT NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX response_template_uses_response_template_id_idx
+    ON response_template_uses (response_template_id);
@@ -0,0 +1,10 @@
+CREATE TABLE request_notes (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    author_id BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX request_notes_request_id_idx ON request_notes (request_id);
@@ -0,0 +1,4 @@
+DROP TABLE request_field_values;
+DROP TABLE custom_fields;
+DROP TABLE request_labels;
+DROP TABLE labels;
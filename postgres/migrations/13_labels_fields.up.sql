@@ -0,0 +1,37 @@
+CREATE TABLE labels (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE request_labels (
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    label_id BIGINT NOT NULL REFERENCES labels (id) ON DELETE CASCADE,
+
+    PRIMARY KEY (request_id, label_id)
+);
+
+CREATE INDEX request_labels_label_id_idx ON request_labels (label_id);
+
+CREATE TABLE custom_fields (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    options TEXT[] NOT NULL,
+
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE request_field_values (
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    field_id BIGINT NOT NULL REFERENCES custom_fields (id) ON DELETE CASCADE,
+    value TEXT NOT NULL,
+
+    PRIMARY KEY (request_id, field_id)
+);
+
+CREATE INDEX request_field_values_field_id_value_idx
+    ON request_field_values (field_id, value);
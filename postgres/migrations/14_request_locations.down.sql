@@ -0,0 +1,15 @@
+DROP TABLE request_supports;
+
+DROP INDEX requests_common_area_idx;
+
+DELETE FROM requests WHERE owner_id IS NULL;
+
+ALTER TABLE requests
+    DROP COLUMN building,
+    DROP COLUMN entrance,
+    DROP COLUMN floor,
+    DROP COLUMN common_area,
+    DROP COLUMN creator_role,
+    DROP COLUMN creator_id;
+
+ALTER TABLE requests ALTER COLUMN owner_id SET NOT NULL;
@@ -0,0 +1,23 @@
+ALTER TABLE requests ALTER COLUMN owner_id DROP NOT NULL;
+
+ALTER TABLE requests
+    ADD COLUMN building TEXT,
+    ADD COLUMN entrance TEXT,
+    ADD COLUMN floor INTEGER,
+    ADD COLUMN common_area TEXT,
+    ADD COLUMN creator_role TEXT NOT NULL DEFAULT 'owner',
+    ADD COLUMN creator_id BIGINT;
+
+UPDATE requests SET creator_id = owner_id;
+
+ALTER TABLE requests ALTER COLUMN creator_id SET NOT NULL;
+
+CREATE INDEX requests_common_area_idx ON requests (organization_id)
+    WHERE common_area IS NOT NULL AND status IN ('new', 'in_progress');
+
+CREATE TABLE request_supports (
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    owner_id BIGINT NOT NULL REFERENCES owners (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (request_id, owner_id)
+);
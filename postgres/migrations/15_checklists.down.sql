@@ -0,0 +1,2 @@
+DROP TABLE request_tasks;
+DROP TABLE checklist_templates;
@@ -0,0 +1,23 @@
+CREATE TABLE checklist_templates (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL
+        REFERENCES organizations (id) ON DELETE CASCADE,
+    category_id BIGINT NOT NULL REFERENCES categories (id) ON DELETE CASCADE,
+    items TEXT[] NOT NULL,
+    UNIQUE (organization_id, category_id)
+);
+
+CREATE TABLE request_tasks (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    operator_id BIGINT REFERENCES operators (id) ON DELETE SET NULL,
+    done BOOLEAN NOT NULL DEFAULT false,
+    done_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX request_tasks_request_id_idx ON request_tasks (request_id);
+CREATE INDEX request_tasks_operator_id_idx ON request_tasks (operator_id)
+    WHERE NOT done;
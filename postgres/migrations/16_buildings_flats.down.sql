@@ -0,0 +1,27 @@
+ALTER TABLE organizations ADD COLUMN flats_count INT NOT NULL DEFAULT 0;
+
+UPDATE organizations as o SET flats_count = (
+    SELECT count(*) FROM flats as f
+    JOIN buildings as b ON f.building_id = b.id
+    WHERE b.organization_id = o.id
+);
+
+ALTER TABLE organizations ALTER COLUMN flats_count DROP DEFAULT;
+
+ALTER TABLE owners ADD COLUMN address TEXT;
+
+UPDATE owners as ow SET address = b.address ||
+    CASE WHEN f.number = '' THEN '' ELSE ', кв. ' || f.number END
+FROM flats as f
+JOIN buildings as b ON f.building_id = b.id
+WHERE ow.flat_id = f.id;
+
+UPDATE owners SET address = 'owner ' || id WHERE address IS NULL;
+
+ALTER TABLE owners
+    ALTER COLUMN address SET NOT NULL,
+    ADD CONSTRAINT owners_address_key UNIQUE (address),
+    DROP COLUMN flat_id;
+
+DROP TABLE flats;
+DROP TABLE buildings;
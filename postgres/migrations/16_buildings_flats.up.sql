@@ -0,0 +1,50 @@
+CREATE TABLE buildings (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    address TEXT NOT NULL,
+    entrances INTEGER NOT NULL DEFAULT 1,
+    floors INTEGER NOT NULL DEFAULT 1,
+    UNIQUE (organization_id, address)
+);
+
+CREATE TABLE flats (
+    id BIGSERIAL PRIMARY KEY,
+    building_id BIGINT NOT NULL REFERENCES buildings (id) ON DELETE CASCADE,
+    number TEXT NOT NULL,
+    entrance INTEGER,
+    floor INTEGER,
+    area NUMERIC(8, 2),
+    UNIQUE (building_id, number)
+);
+
+ALTER TABLE owners ADD COLUMN flat_id BIGINT REFERENCES flats (id) ON DELETE SET NULL;
+
+-- Free text owner addresses are split to building address and flat number
+-- the same way dedup package strips flat part of the address. Address
+-- without flat part is a private house, its flat number is empty.
+
+INSERT INTO buildings (organization_id, address)
+SELECT DISTINCT organization_id, trim(regexp_replace(address,
+    '[\s,]*(кв\.?|квартира|офис|оф\.)\s*\S+\s*$', '', 'i'))
+FROM owners;
+
+INSERT INTO flats (building_id, number)
+SELECT DISTINCT b.id, coalesce(substring(ow.address from
+    '(?i)(?:кв\.?|квартира|офис|оф\.)\s*(\S+)\s*$'), '')
+FROM owners as ow
+JOIN buildings as b ON b.organization_id = ow.organization_id
+    AND b.address = trim(regexp_replace(ow.address,
+        '[\s,]*(кв\.?|квартира|офис|оф\.)\s*\S+\s*$', '', 'i'));
+
+UPDATE owners as ow SET flat_id = f.id
+FROM flats as f
+JOIN buildings as b ON f.building_id = b.id
+WHERE b.organization_id = ow.organization_id
+    AND b.address = trim(regexp_replace(ow.address,
+        '[\s,]*(кв\.?|квартира|офис|оф\.)\s*\S+\s*$', '', 'i'))
+    AND f.number = coalesce(substring(ow.address from
+        '(?i)(?:кв\.?|квартира|офис|оф\.)\s*(\S+)\s*$'), '');
+
+ALTER TABLE owners DROP COLUMN address;
+
+ALTER TABLE organizations DROP COLUMN flats_count;
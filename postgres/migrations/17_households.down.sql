@@ -0,0 +1,3 @@
+DROP INDEX owners_flat_id_idx;
+
+ALTER TABLE owners DROP COLUMN household_role;
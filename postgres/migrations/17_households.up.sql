@@ -0,0 +1,3 @@
+ALTER TABLE owners ADD COLUMN household_role TEXT NOT NULL DEFAULT 'owner';
+
+CREATE INDEX owners_flat_id_idx ON owners (flat_id);
@@ -0,0 +1,15 @@
+CREATE TABLE address_registry (
+    id BIGSERIAL PRIMARY KEY,
+    guid TEXT NOT NULL UNIQUE,
+    city TEXT NOT NULL,
+    street_type TEXT NOT NULL,
+    street TEXT NOT NULL,
+    house TEXT NOT NULL,
+    block TEXT NOT NULL DEFAULT '',
+    structure TEXT NOT NULL DEFAULT '',
+    address TEXT NOT NULL,
+    key TEXT NOT NULL
+);
+
+CREATE INDEX address_registry_key_idx
+    ON address_registry (key text_pattern_ops);
@@ -0,0 +1,21 @@
+CREATE TABLE meters (
+    id BIGSERIAL PRIMARY KEY,
+    flat_id BIGINT NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    serial_number TEXT NOT NULL,
+    verification_date DATE,
+    UNIQUE (flat_id, serial_number)
+);
+
+CREATE TABLE meter_readings (
+    id BIGSERIAL PRIMARY KEY,
+    meter_id BIGINT NOT NULL REFERENCES meters (id) ON DELETE CASCADE,
+    period DATE NOT NULL,
+    value NUMERIC(12, 3) NOT NULL,
+    outlier BOOLEAN NOT NULL DEFAULT false,
+    owner_id BIGINT REFERENCES owners (id) ON DELETE SET NULL,
+    submitted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (meter_id, period)
+);
+
+CREATE INDEX meter_readings_period_idx ON meter_readings (period);
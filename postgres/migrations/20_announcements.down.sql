@@ -0,0 +1,4 @@
+DROP TABLE announcement_reads;
+DROP TABLE announcements;
+
+ALTER TABLE owners DROP COLUMN email;
@@ -0,0 +1,26 @@
+ALTER TABLE owners ADD COLUMN email TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE announcements (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    building_id BIGINT REFERENCES buildings (id) ON DELETE CASCADE,
+    entrance INTEGER,
+    title TEXT NOT NULL,
+    text TEXT NOT NULL,
+    publish_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    push_sms BOOLEAN NOT NULL DEFAULT false,
+    push_email BOOLEAN NOT NULL DEFAULT false,
+    pushed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX announcements_organization_id_idx
+    ON announcements (organization_id, publish_at);
+
+CREATE TABLE announcement_reads (
+    announcement_id BIGINT NOT NULL REFERENCES announcements (id) ON DELETE CASCADE,
+    owner_id BIGINT NOT NULL REFERENCES owners (id) ON DELETE CASCADE,
+    read_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (announcement_id, owner_id)
+);
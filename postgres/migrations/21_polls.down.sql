@@ -0,0 +1,5 @@
+DROP TABLE poll_votes;
+DROP TABLE poll_questions;
+DROP TABLE polls;
+
+ALTER TABLE owners DROP COLUMN share;
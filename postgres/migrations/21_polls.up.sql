@@ -0,0 +1,36 @@
+ALTER TABLE owners ADD COLUMN share NUMERIC(5, 4);
+
+CREATE TABLE polls (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    building_id BIGINT NOT NULL REFERENCES buildings (id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    weighting TEXT NOT NULL,
+    quorum_percent NUMERIC(5, 2) NOT NULL,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX polls_building_id_idx ON polls (building_id);
+
+CREATE TABLE poll_questions (
+    id BIGSERIAL PRIMARY KEY,
+    poll_id BIGINT NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    multiple BOOLEAN NOT NULL DEFAULT false,
+    options TEXT[] NOT NULL
+);
+
+CREATE INDEX poll_questions_poll_id_idx ON poll_questions (poll_id);
+
+CREATE TABLE poll_votes (
+    question_id BIGINT NOT NULL REFERENCES poll_questions (id) ON DELETE CASCADE,
+    owner_id BIGINT NOT NULL REFERENCES owners (id) ON DELETE CASCADE,
+    options INTEGER[] NOT NULL,
+    weight NUMERIC(12, 4) NOT NULL,
+    voted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    PRIMARY KEY (question_id, owner_id)
+);
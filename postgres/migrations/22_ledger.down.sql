@@ -0,0 +1,4 @@
+DROP TABLE payments;
+DROP TABLE charges;
+
+ALTER TABLE flats DROP COLUMN account;
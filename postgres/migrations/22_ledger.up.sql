@@ -0,0 +1,26 @@
+ALTER TABLE flats ADD COLUMN account TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX flats_account_idx ON flats (account);
+
+CREATE TABLE charges (
+    id BIGSERIAL PRIMARY KEY,
+    flat_id BIGINT NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    period DATE NOT NULL,
+    service TEXT NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (flat_id, period, service)
+);
+
+CREATE TABLE payments (
+    id BIGSERIAL PRIMARY KEY,
+    flat_id BIGINT NOT NULL REFERENCES flats (id) ON DELETE CASCADE,
+    amount NUMERIC(12, 2) NOT NULL,
+    paid_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    provider TEXT NOT NULL,
+    external_id TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (provider, external_id)
+);
+
+CREATE INDEX payments_flat_id_idx ON payments (flat_id);
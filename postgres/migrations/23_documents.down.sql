@@ -0,0 +1,4 @@
+DROP TABLE document_downloads;
+DROP TABLE document_versions;
+DROP TABLE documents;
+DROP TABLE document_folders;
@@ -0,0 +1,41 @@
+CREATE TABLE document_folders (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE documents (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    folder_id BIGINT REFERENCES document_folders (id) ON DELETE SET NULL,
+    building_id BIGINT REFERENCES buildings (id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX documents_organization_id_idx ON documents (organization_id);
+
+CREATE TABLE document_versions (
+    id BIGSERIAL PRIMARY KEY,
+    document_id BIGINT NOT NULL REFERENCES documents (id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    attachment_key TEXT NOT NULL,
+    file_name TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size BIGINT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    UNIQUE (document_id, version)
+);
+
+CREATE TABLE document_downloads (
+    id BIGSERIAL PRIMARY KEY,
+    document_version_id BIGINT NOT NULL REFERENCES document_versions (id) ON DELETE CASCADE,
+    owner_id BIGINT NOT NULL REFERENCES owners (id) ON DELETE CASCADE,
+    downloaded_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX document_downloads_document_version_id_idx ON document_downloads (document_version_id);
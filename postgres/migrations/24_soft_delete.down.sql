@@ -0,0 +1,11 @@
+DROP INDEX owners_phone_idx;
+DROP INDEX operators_phone_idx;
+DROP INDEX organizations_email_idx;
+
+ALTER TABLE owners ADD CONSTRAINT owners_phone_key UNIQUE (phone);
+ALTER TABLE operators ADD CONSTRAINT operators_phone_key UNIQUE (phone);
+ALTER TABLE organizations ADD CONSTRAINT organizations_email_key UNIQUE (email);
+
+ALTER TABLE owners DROP COLUMN deleted_at;
+ALTER TABLE operators DROP COLUMN deleted_at;
+ALTER TABLE organizations DROP COLUMN deleted_at;
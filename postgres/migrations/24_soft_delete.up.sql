@@ -0,0 +1,14 @@
+ALTER TABLE organizations ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE operators ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE owners ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE organizations DROP CONSTRAINT organizations_email_key;
+ALTER TABLE operators DROP CONSTRAINT operators_phone_key;
+ALTER TABLE owners DROP CONSTRAINT owners_phone_key;
+
+CREATE UNIQUE INDEX organizations_email_idx
+    ON organizations (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX operators_phone_idx
+    ON operators (phone) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX owners_phone_idx
+    ON owners (phone) WHERE deleted_at IS NULL;
@@ -0,0 +1 @@
+ALTER TABLE visit_slots DROP CONSTRAINT visit_slots_operator_id_overlap_excl;
@@ -0,0 +1,20 @@
+-- btree_gist is a trusted extension since PostgreSQL 13, so the database
+-- owner can create it. On older servers it has to be created by a superuser
+-- before the migration.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Slots added concurrently before the constraint may overlap. Booked slots
+-- are kept in favor of free ones, otherwise the earlier added slot is kept.
+DELETE FROM visit_slots b
+USING visit_slots a
+WHERE a.operator_id = b.operator_id
+    AND a.id <> b.id
+    AND a.starts_at < b.ends_at AND a.ends_at > b.starts_at
+    AND (
+        (a.request_id IS NOT NULL AND b.request_id IS NULL)
+        OR ((a.request_id IS NULL) = (b.request_id IS NULL) AND a.id < b.id)
+    );
+
+ALTER TABLE visit_slots ADD CONSTRAINT visit_slots_operator_id_overlap_excl
+    EXCLUDE USING gist (operator_id WITH =,
+        tstzrange(starts_at, ends_at) WITH &&);
@@ -0,0 +1,6 @@
+-- Priority chosen on request creation before the priority rules raised it.
+-- Existing requests keep their current priority so nothing is lowered.
+ALTER TABLE requests
+    ADD COLUMN owner_priority TEXT NOT NULL DEFAULT 'normal';
+
+UPDATE requests SET owner_priority = priority;
@@ -0,0 +1,7 @@
+UPDATE operators
+SET calendar_token = md5(random()::text || clock_timestamp()::text)
+WHERE calendar_token IS NULL;
+
+ALTER TABLE operators ALTER COLUMN calendar_token SET NOT NULL;
+ALTER TABLE operators ALTER COLUMN calendar_token
+    SET DEFAULT md5(random()::text || clock_timestamp()::text);
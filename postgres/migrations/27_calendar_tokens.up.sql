@@ -0,0 +1,7 @@
+-- Calendar tokens are generated by the application with crypto/rand now.
+-- Tokens generated by the database were predictable, so they are revoked
+-- and operators publish their calendars again.
+ALTER TABLE operators ALTER COLUMN calendar_token DROP DEFAULT;
+ALTER TABLE operators ALTER COLUMN calendar_token DROP NOT NULL;
+
+UPDATE operators SET calendar_token = NULL;
@@ -0,0 +1,7 @@
+UPDATE work_orders
+SET token = md5(random()::text || clock_timestamp()::text)
+WHERE token IS NULL;
+
+ALTER TABLE work_orders ALTER COLUMN token SET NOT NULL;
+ALTER TABLE work_orders ALTER COLUMN token
+    SET DEFAULT md5(random()::text || clock_timestamp()::text);
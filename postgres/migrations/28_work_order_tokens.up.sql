@@ -0,0 +1,9 @@
+-- Work order tokens are generated by the application with crypto/rand now
+-- and are expired when the work order is completed or declined. Links of
+-- the open work orders were already sent to the contractors, so their
+-- tokens are kept.
+ALTER TABLE work_orders ALTER COLUMN token DROP DEFAULT;
+ALTER TABLE work_orders ALTER COLUMN token DROP NOT NULL;
+
+UPDATE work_orders SET token = NULL
+WHERE status IN ('completed', 'declined');
@@ -0,0 +1,14 @@
+DELETE FROM response_template_uses WHERE response_template_id IS NULL;
+
+ALTER TABLE response_template_uses
+    ALTER COLUMN response_template_id SET NOT NULL,
+    DROP CONSTRAINT response_template_uses_response_template_id_fkey,
+    ADD CONSTRAINT response_template_uses_response_template_id_fkey
+        FOREIGN KEY (response_template_id) REFERENCES response_templates (id)
+        ON DELETE CASCADE;
+
+ALTER TABLE response_templates
+    DROP CONSTRAINT response_templates_category_id_fkey,
+    ADD CONSTRAINT response_templates_category_id_fkey
+        FOREIGN KEY (category_id) REFERENCES categories (id)
+        ON DELETE SET NULL;
@@ -0,0 +1,15 @@
+-- Template of the deleted category should not become a template for all
+-- categories.
+ALTER TABLE response_templates
+    DROP CONSTRAINT response_templates_category_id_fkey,
+    ADD CONSTRAINT response_templates_category_id_fkey
+        FOREIGN KEY (category_id) REFERENCES categories (id)
+        ON DELETE CASCADE;
+
+-- Uses of the deleted template are kept as a history of the responses.
+ALTER TABLE response_template_uses
+    ALTER COLUMN response_template_id DROP NOT NULL,
+    DROP CONSTRAINT response_template_uses_response_template_id_fkey,
+    ADD CONSTRAINT response_template_uses_response_template_id_fkey
+        FOREIGN KEY (response_template_id) REFERENCES response_templates (id)
+        ON DELETE SET NULL;
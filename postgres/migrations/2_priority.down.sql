@@ -0,0 +1,8 @@
+DROP TABLE priority_rules;
+
+ALTER TABLE requests
+    DROP COLUMN priority,
+    DROP COLUMN acknowledged_at,
+    DROP COLUMN notified_at;
+
+ALTER TABLE operators DROP COLUMN on_duty;
@@ -0,0 +1,20 @@
+ALTER TABLE operators ADD COLUMN on_duty BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE requests
+    ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal',
+    ADD COLUMN acknowledged_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN notified_at TIMESTAMP WITH TIME ZONE;
+
+CREATE TABLE priority_rules (
+    id BIGSERIAL PRIMARY KEY,
+    pattern TEXT NOT NULL,
+    category_id BIGINT REFERENCES categories (id) ON DELETE CASCADE,
+    priority TEXT NOT NULL
+);
+
+INSERT INTO priority_rules (pattern, priority) VALUES
+    ('запах газа|пахнет газом|утечк[аи] газа', 'emergency'),
+    ('затоп|потоп|прорвал[оа]|прорыв трубы|теч[её]т с потолка', 'emergency'),
+    ('пожар|задымлен|искрит|горит проводк', 'emergency'),
+    ('застрял[аи]? в лифте', 'emergency'),
+    ('нет воды|нет отопления|нет света|нет электричества', 'urgent');
@@ -0,0 +1,3 @@
+DROP INDEX owners_flat_id_primary_owner_idx;
+
+ALTER TABLE requests DROP COLUMN flat_id;
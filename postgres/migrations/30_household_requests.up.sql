@@ -0,0 +1,27 @@
+-- Requests keep the flat of the owner at the time of creation. Existing
+-- requests get the current flat of their owners.
+ALTER TABLE requests
+    ADD COLUMN flat_id BIGINT REFERENCES flats (id) ON DELETE SET NULL;
+
+UPDATE requests as r SET flat_id = ow.flat_id
+FROM owners as ow
+WHERE r.owner_id = ow.id;
+
+CREATE INDEX requests_flat_id_idx ON requests (flat_id);
+
+-- Members unlinked from the flat had empty household role.
+UPDATE owners SET household_role = 'owner' WHERE household_role = '';
+
+-- Flat has only one primary owner. Later registered primary owners of the
+-- same flat become co-owners.
+UPDATE owners as ow SET household_role = 'co_owner'
+WHERE ow.household_role = 'owner' AND ow.deleted_at IS NULL
+    AND ow.flat_id IS NOT NULL
+    AND EXISTS (
+        SELECT 1 FROM owners as o
+        WHERE o.flat_id = ow.flat_id AND o.id < ow.id
+            AND o.household_role = 'owner' AND o.deleted_at IS NULL
+    );
+
+CREATE UNIQUE INDEX owners_flat_id_primary_owner_idx ON owners (flat_id)
+    WHERE household_role = 'owner' AND deleted_at IS NULL;
@@ -0,0 +1,20 @@
+DROP INDEX flats_organization_id_account_idx;
+
+CREATE INDEX flats_account_idx ON flats (account);
+
+ALTER TABLE flats DROP COLUMN organization_id;
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_flat_id_fkey,
+    ADD CONSTRAINT payments_flat_id_fkey
+        FOREIGN KEY (flat_id) REFERENCES flats (id) ON DELETE CASCADE;
+
+ALTER TABLE charges
+    DROP CONSTRAINT charges_flat_id_fkey,
+    ADD CONSTRAINT charges_flat_id_fkey
+        FOREIGN KEY (flat_id) REFERENCES flats (id) ON DELETE CASCADE;
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_flat_id_provider_external_id_key,
+    ADD CONSTRAINT payments_provider_external_id_key
+        UNIQUE (provider, external_id);
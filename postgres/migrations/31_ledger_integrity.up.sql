@@ -0,0 +1,44 @@
+-- Payment external IDs are unique per flat only. Payments of different
+-- organizations imported with the same external IDs are not skipped.
+ALTER TABLE payments
+    DROP CONSTRAINT payments_provider_external_id_key,
+    ADD CONSTRAINT payments_flat_id_provider_external_id_key
+        UNIQUE (flat_id, provider, external_id);
+
+-- Financial history is kept, so flats with charges or payments can't be
+-- removed.
+ALTER TABLE charges
+    DROP CONSTRAINT charges_flat_id_fkey,
+    ADD CONSTRAINT charges_flat_id_fkey
+        FOREIGN KEY (flat_id) REFERENCES flats (id) ON DELETE RESTRICT;
+
+ALTER TABLE payments
+    DROP CONSTRAINT payments_flat_id_fkey,
+    ADD CONSTRAINT payments_flat_id_fkey
+        FOREIGN KEY (flat_id) REFERENCES flats (id) ON DELETE RESTRICT;
+
+-- Flat account is unique within the organization, so imports find a single
+-- flat by the account. Flat never moves to another organization, so its
+-- organization is stored in the flat. Duplicate accounts of the later flats
+-- are cleared to be set again by the organization.
+ALTER TABLE flats
+    ADD COLUMN organization_id BIGINT REFERENCES organizations (id)
+        ON DELETE CASCADE;
+
+UPDATE flats as f SET organization_id = b.organization_id
+FROM buildings as b
+WHERE f.building_id = b.id;
+
+ALTER TABLE flats ALTER COLUMN organization_id SET NOT NULL;
+
+UPDATE flats as f SET account = ''
+WHERE f.account <> '' AND EXISTS (
+    SELECT 1 FROM flats as o
+    WHERE o.organization_id = f.organization_id AND o.account = f.account
+        AND o.id < f.id
+);
+
+DROP INDEX flats_account_idx;
+
+CREATE UNIQUE INDEX flats_organization_id_account_idx
+    ON flats (organization_id, account) WHERE account <> '';
@@ -0,0 +1,3 @@
+DROP TABLE request_duplicates;
+
+ALTER TABLE requests DROP COLUMN primary_request_id;
@@ -0,0 +1,10 @@
+ALTER TABLE requests ADD COLUMN primary_request_id BIGINT
+    REFERENCES requests (id) ON DELETE SET NULL;
+
+CREATE TABLE request_duplicates (
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    duplicate_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    similarity DOUBLE PRECISION NOT NULL,
+
+    UNIQUE (request_id, duplicate_id)
+);
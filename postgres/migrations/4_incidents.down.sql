@@ -0,0 +1,3 @@
+ALTER TABLE requests DROP COLUMN incident_id;
+
+DROP TABLE incidents;
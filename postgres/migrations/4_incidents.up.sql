@@ -0,0 +1,14 @@
+CREATE TABLE incidents (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    description TEXT NOT NULL,
+    buildings TEXT[] NOT NULL,
+    expected_resolution_at TIMESTAMP WITH TIME ZONE,
+    response TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    resolved_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE requests ADD COLUMN incident_id BIGINT
+    REFERENCES incidents (id) ON DELETE SET NULL;
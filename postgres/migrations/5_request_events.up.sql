@@ -0,0 +1,11 @@
+CREATE TABLE request_events (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    actor_id BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    text TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX request_events_request_id_idx ON request_events (request_id);
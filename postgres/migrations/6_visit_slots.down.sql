@@ -0,0 +1,3 @@
+ALTER TABLE operators DROP COLUMN calendar_token;
+
+DROP TABLE visit_slots;
@@ -0,0 +1,15 @@
+CREATE TABLE visit_slots (
+    id BIGSERIAL PRIMARY KEY,
+    operator_id BIGINT NOT NULL REFERENCES operators (id) ON DELETE CASCADE,
+    starts_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    ends_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    request_id BIGINT UNIQUE REFERENCES requests (id) ON DELETE SET NULL,
+    reminded_at TIMESTAMP WITH TIME ZONE,
+    CHECK (ends_at > starts_at)
+);
+
+CREATE INDEX visit_slots_operator_id_starts_at_idx
+    ON visit_slots (operator_id, starts_at);
+
+ALTER TABLE operators ADD COLUMN calendar_token TEXT NOT NULL UNIQUE
+    DEFAULT md5(random()::text || clock_timestamp()::text);
@@ -0,0 +1,2 @@
+DROP TABLE work_orders;
+DROP TABLE contractors;
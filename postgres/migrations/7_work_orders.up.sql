@@ -0,0 +1,23 @@
+CREATE TABLE contractors (
+    id BIGSERIAL PRIMARY KEY,
+    organization_id BIGINT NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    email TEXT NOT NULL
+);
+
+CREATE TABLE work_orders (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    contractor_id BIGINT NOT NULL REFERENCES contractors (id) ON DELETE CASCADE,
+    scope TEXT NOT NULL,
+    due_at TIMESTAMP WITH TIME ZONE,
+    status TEXT NOT NULL,
+    comment TEXT,
+    token TEXT NOT NULL UNIQUE
+        DEFAULT md5(random()::text || clock_timestamp()::text),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX work_orders_request_id_idx ON work_orders (request_id);
@@ -0,0 +1 @@
+DROP TABLE cost_items;
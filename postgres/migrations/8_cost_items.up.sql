@@ -0,0 +1,13 @@
+CREATE TABLE cost_items (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    operator_id BIGINT REFERENCES operators (id) ON DELETE SET NULL,
+    quantity NUMERIC(12, 3) NOT NULL,
+    unit_price NUMERIC(12, 2) NOT NULL,
+    billable BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX cost_items_request_id_idx ON cost_items (request_id);
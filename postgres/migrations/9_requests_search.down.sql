@@ -0,0 +1,3 @@
+DROP INDEX requests_owner_id_created_at_idx;
+DROP INDEX requests_operator_id_created_at_idx;
+DROP INDEX requests_search_idx;
@@ -0,0 +1,9 @@
+CREATE INDEX requests_search_idx ON requests USING GIN (
+    to_tsvector('russian', text || ' ' || coalesce(response, ''))
+);
+
+CREATE INDEX requests_operator_id_created_at_idx
+    ON requests (operator_id, created_at DESC, id DESC);
+
+CREATE INDEX requests_owner_id_created_at_idx
+    ON requests (owner_id, created_at DESC, id DESC);
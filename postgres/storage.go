@@ -3,7 +3,10 @@ package postgres
 import (
 	"database/sql"
 	"errors"
+	"io"
 	"math/rand"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Boostport/migration"
@@ -13,6 +16,11 @@ import (
 	"github.com/lib/pq"
 
 	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/decimal"
+	"github.com/dimuls/swan/entity/event"
+	"github.com/dimuls/swan/entity/priority"
+	"github.com/dimuls/swan/entity/role"
+	"github.com/dimuls/swan/entity/sorting"
 )
 
 type Storage struct {
@@ -154,40 +162,115 @@ func (s *Storage) SetCategorySamples(css []entity.CategorySample) error {
 	return err
 }
 
+func (s *Storage) PriorityRules() (prs []entity.PriorityRule, err error) {
+	err = s.db.Select(&prs, `SELECT * FROM priority_rules`)
+	return
+}
+
+func (s *Storage) AddPriorityRule(pr entity.PriorityRule) (
+	entity.PriorityRule, error) {
+	err := s.db.QueryRowx(`
+		INSERT INTO priority_rules (pattern, category_id, priority)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, pr.Pattern, pr.CategoryID, pr.Priority).Scan(&pr.ID)
+	return pr, err
+}
+
+func (s *Storage) SetPriorityRule(pr entity.PriorityRule) (
+	entity.PriorityRule, error) {
+	_, err := s.db.Exec(`
+		UPDATE priority_rules SET pattern = $1, category_id = $2, priority = $3
+		WHERE id = $4
+	`, pr.Pattern, pr.CategoryID, pr.Priority, pr.ID)
+	return pr, err
+}
+
+func (s *Storage) RemovePriorityRule(id int) error {
+	_, err := s.db.Exec(`DELETE FROM priority_rules WHERE id = $1`, id)
+	return err
+}
+
+const organizationFlatsCount = `(
+	SELECT count(*) FROM flats as f
+	JOIN buildings as b ON f.building_id = b.id
+	WHERE b.organization_id = o.id
+)`
+
+const organizationsSelect = `
+	SELECT o.*, ` + organizationFlatsCount + ` as flats_count
+	FROM organizations as o
+`
+
 func (s *Storage) Organization(email string) (o entity.Organization, err error) {
-	err = s.db.QueryRowx(`SELECT * FROM organizations WHERE email = $1`,
-		email).StructScan(&o)
+	err = s.db.QueryRowx(organizationsSelect+`
+		WHERE o.email = $1 AND o.deleted_at IS NULL
+	`, email).StructScan(&o)
 	return
 }
 
 func (s *Storage) Organizations() (os []entity.Organization, err error) {
-	err = s.db.Select(&os, `SELECT * FROM organizations`)
+	err = s.db.Select(&os, organizationsSelect+`WHERE o.deleted_at IS NULL`)
+	return
+}
+
+func (s *Storage) DeletedOrganizations() (os []entity.Organization,
+	err error) {
+	err = s.db.Select(&os, organizationsSelect+`
+		WHERE o.deleted_at IS NOT NULL
+		ORDER BY o.deleted_at DESC
+	`)
 	return
 }
 
 func (s *Storage) AddOrganization(o entity.Organization) (entity.Organization, error) {
 	err := s.db.QueryRowx(`
-		INSERT INTO organizations (name, email, flats_count, password_hash)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO organizations (name, email, password_hash)
+		VALUES ($1, $2, $3)
 		RETURNING id
-	`, o.Name, o.Email, o.FlatsCount, o.PasswordHash).Scan(&o.ID)
+	`, o.Name, o.Email, o.PasswordHash).Scan(&o.ID)
 	return o, err
 }
 
 func (s *Storage) SetOrganization(o entity.Organization) (entity.Organization, error) {
 	_, err := s.db.Exec(`
-		UPDATE organizations SET name = $1, email = $2, flats_count = $3,
-			password_hash = $4
-		WHERE id = $5
-	`, o.Name, o.Email, o.FlatsCount, o.PasswordHash, o.ID)
+		UPDATE organizations SET name = $1, email = $2, password_hash = $3
+		WHERE id = $4
+	`, o.Name, o.Email, o.PasswordHash, o.ID)
 	return o, err
 }
 
+// RemoveOrganization marks the organization deleted. Its operators, owners
+// and requests are kept, so the organization can be restored.
 func (s *Storage) RemoveOrganization(id int) error {
-	_, err := s.db.Exec(`DELETE FROM organizations WHERE id = $1`, id)
+	_, err := s.db.Exec(`
+		UPDATE organizations SET deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, time.Now(), id)
 	return err
 }
 
+func (s *Storage) RestoreOrganization(id int) error {
+	res, err := s.db.Exec(`
+		UPDATE organizations SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (s *Storage) SetOrganizationPasswordHash(organizationID int,
 	passwordHash []byte) error {
 	_, err := s.db.Exec(`
@@ -201,10 +284,38 @@ func (s *Storage) Operator(phone string) (o entity.Operator, err error) {
 
 	err = s.db.QueryRow(`
 		SELECT id, organization_id, phone, password_hash, name,
-		       responsible_categories
-		FROM operators WHERE phone = $1
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE phone = $1 AND deleted_at IS NULL
+			AND organization_id IN (
+				SELECT id FROM organizations WHERE deleted_at IS NULL
+			)
 	`, phone).Scan(&o.ID, &o.OrganizationID, &o.Phone, &o.PasswordHash,
-		&o.Name, &rcs64)
+		&o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
+	if err != nil {
+		return o, err
+	}
+
+	for _, rc := range rcs64 {
+		o.ResponsibleCategories = append(o.ResponsibleCategories, int(rc))
+	}
+
+	return
+}
+
+// OperatorByID returns not deleted operator of not deleted organization by
+// its ID.
+func (s *Storage) OperatorByID(operatorID int) (o entity.Operator, err error) {
+	var rcs64 pq.Int64Array
+
+	err = s.db.QueryRow(`
+		SELECT id, organization_id, phone, password_hash, name,
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE id = $1 AND deleted_at IS NULL
+			AND organization_id IN (
+				SELECT id FROM organizations WHERE deleted_at IS NULL
+			)
+	`, operatorID).Scan(&o.ID, &o.OrganizationID, &o.Phone, &o.PasswordHash,
+		&o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
 	if err != nil {
 		return o, err
 	}
@@ -216,13 +327,24 @@ func (s *Storage) Operator(phone string) (o entity.Operator, err error) {
 	return
 }
 
+// OperatorPhoneTaken reports whether the phone is taken by not deleted
+// operator, including operators of deleted organizations.
+func (s *Storage) OperatorPhoneTaken(phone string) (taken bool, err error) {
+	err = s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM operators WHERE phone = $1 AND deleted_at IS NULL
+		)
+	`, phone).Scan(&taken)
+	return
+}
+
 func (s *Storage) OrganizationOperators(organizationID int) (
 	[]entity.Operator, error) {
 
 	rows, err := s.db.Query(`
 		SELECT id, organization_id, phone, password_hash, name,
-		       responsible_categories
-		FROM operators WHERE organization_id = $1
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE organization_id = $1 AND deleted_at IS NULL
 	`, organizationID)
 	if err != nil {
 		return nil, err
@@ -236,7 +358,7 @@ func (s *Storage) OrganizationOperators(organizationID int) (
 		var rcs64 pq.Int64Array
 
 		err = rows.Scan(&o.ID, &o.OrganizationID, &o.Phone, &o.PasswordHash,
-			&o.Name, &rcs64)
+			&o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
 
 		for _, rc := range rcs64 {
 			o.ResponsibleCategories = append(o.ResponsibleCategories, int(rc))
@@ -254,36 +376,126 @@ func (s *Storage) OrganizationOperators(organizationID int) (
 func (s *Storage) AddOperator(o entity.Operator) (entity.Operator, error) {
 	err := s.db.QueryRowx(`
 		INSERT INTO operators (organization_id, phone, name, 
-			responsible_categories)
-		VALUES ($1, $2, $3, $4)
+			responsible_categories, on_duty)
+		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
-	`, o.OrganizationID, o.Phone, o.Name, pq.Array(o.ResponsibleCategories)).
-		Scan(&o.ID)
+	`, o.OrganizationID, o.Phone, o.Name, pq.Array(o.ResponsibleCategories),
+		o.OnDuty).Scan(&o.ID)
 	return o, err
 }
 
 func (s *Storage) SetOperator(o entity.Operator) (entity.Operator, error) {
 	_, err := s.db.Exec(`
-		UPDATE operators SET phone = $1, name = $2, responsible_categories = $3
-		WHERE id = $4
-	`, o.Phone, o.Name, pq.Array(o.ResponsibleCategories), o.ID)
+		UPDATE operators SET phone = $1, name = $2, responsible_categories = $3,
+			on_duty = $4
+		WHERE id = $5
+	`, o.Phone, o.Name, pq.Array(o.ResponsibleCategories), o.OnDuty, o.ID)
 	return o, err
 }
 
+// RemoveOrganizationOperator marks the operator deleted. Not final requests
+// of the operator are unassigned to be assigned by the organization, final
+// ones keep the operator assigned.
 func (s *Storage) RemoveOrganizationOperator(
 	organizationID int, operatorID int) error {
-	_, err := s.db.Exec(`
-		DELETE FROM operators
-		WHERE organization_id = $1 AND id = $2
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE operators SET deleted_at = $1
+		WHERE organization_id = $2 AND id = $3 AND deleted_at IS NULL
+	`, time.Now(), organizationID, operatorID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE requests SET operator_id = NULL, acknowledged_at = NULL,
+			notified_at = NULL
+		WHERE organization_id = $1 AND operator_id = $2
+			AND status IN ('new', 'in_progress')
 	`, organizationID, operatorID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
 	return err
 }
 
+func (s *Storage) OrganizationDeletedOperators(organizationID int) (
+	[]entity.Operator, error) {
+
+	rows, err := s.db.Query(`
+		SELECT id, organization_id, phone, password_hash, name,
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE organization_id = $1 AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC
+	`, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var os []entity.Operator
+
+	for rows.Next() {
+		var o entity.Operator
+		var rcs64 pq.Int64Array
+
+		err = rows.Scan(&o.ID, &o.OrganizationID, &o.Phone, &o.PasswordHash,
+			&o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rc := range rcs64 {
+			o.ResponsibleCategories = append(o.ResponsibleCategories, int(rc))
+		}
+
+		os = append(os, o)
+	}
+
+	return os, rows.Err()
+}
+
+func (s *Storage) RestoreOrganizationOperator(organizationID int,
+	operatorID int) error {
+	res, err := s.db.Exec(`
+		UPDATE operators SET deleted_at = NULL
+		WHERE organization_id = $1 AND id = $2 AND deleted_at IS NOT NULL
+	`, organizationID, operatorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (s *Storage) FindOrganizationOperator(organizationID, categoryID int) (
 	o entity.Operator, err error) {
 
 	rows, err := s.db.Query(`
-		SELECT * FROM operators WHERE organization_id = $1
+		SELECT id, organization_id, phone, password_hash, name,
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE organization_id = $1 AND deleted_at IS NULL
 			AND $2 = ANY(responsible_categories)
 	`, organizationID, categoryID)
 	if err != nil {
@@ -298,7 +510,7 @@ func (s *Storage) FindOrganizationOperator(organizationID, categoryID int) (
 		var rcs64 pq.Int64Array
 
 		err = rows.Scan(&o.ID, &o.OrganizationID, &o.Phone, &o.PasswordHash,
-			&o.Name, &rcs64)
+			&o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
 
 		for _, rc := range rcs64 {
 			o.ResponsibleCategories = append(o.ResponsibleCategories, int(rc))
@@ -317,6 +529,34 @@ func (s *Storage) FindOrganizationOperator(organizationID, categoryID int) (
 	return os[rand.Intn(len(os))], nil
 }
 
+// FindOrganizationOnDutyOperator finds random on duty operator of the
+// organization preferring ones responsible for the category.
+func (s *Storage) FindOrganizationOnDutyOperator(organizationID int,
+	categoryID *int) (o entity.Operator, err error) {
+
+	var rcs64 pq.Int64Array
+
+	err = s.db.QueryRow(`
+		SELECT id, organization_id, phone, password_hash, name,
+		       responsible_categories, on_duty, deleted_at
+		FROM operators WHERE organization_id = $1 AND on_duty
+			AND deleted_at IS NULL
+		ORDER BY COALESCE($2 = ANY(responsible_categories), FALSE) DESC,
+			random()
+		LIMIT 1
+	`, organizationID, categoryID).Scan(&o.ID, &o.OrganizationID, &o.Phone,
+		&o.PasswordHash, &o.Name, &rcs64, &o.OnDuty, &o.DeletedAt)
+	if err != nil {
+		return o, err
+	}
+
+	for _, rc := range rcs64 {
+		o.ResponsibleCategories = append(o.ResponsibleCategories, int(rc))
+	}
+
+	return
+}
+
 func (s *Storage) SetOperatorPasswordHash(operatorID int,
 	passwordHash []byte) error {
 	_, err := s.db.Exec(`
@@ -325,45 +565,239 @@ func (s *Storage) SetOperatorPasswordHash(operatorID int,
 	return err
 }
 
+// ownerFlatJoin joins flat and building of the owner joined as ow.
+const ownerFlatJoin = `
+	LEFT JOIN flats as owf ON ow.flat_id = owf.id
+	LEFT JOIN buildings as owb ON owf.building_id = owb.id
+`
+
+// requestFlatJoin joins flat and building of the request joined as r. They
+// are aliased as the owner ones, so ownerAddress gives the request address.
+const requestFlatJoin = `
+	LEFT JOIN flats as owf ON r.flat_id = owf.id
+	LEFT JOIN buildings as owb ON owf.building_id = owb.id
+`
+
+// ownerAddress is an address of the owner flat joined by ownerFlatJoin or
+// of the request flat joined by requestFlatJoin.
+const ownerAddress = `
+	owb.address || CASE WHEN owf.number = '' THEN ''
+		ELSE ', кв. ' || owf.number END`
+
+const ownersSelect = `
+	SELECT ow.*, coalesce(` + ownerAddress + `, '') as address
+	FROM owners as ow
+` + ownerFlatJoin
+
+// householdOwners selects IDs of the owner given by the placeholder and the
+// other residents of the owner flat.
+func householdOwners(placeholder string) string {
+	return `(
+		SELECT hm.id FROM owners as hm
+		JOIN owners as me ON hm.id = me.id OR hm.flat_id = me.flat_id
+		WHERE me.id = ` + placeholder + `
+	)`
+}
+
+// householdRequest is a condition of the request given by the alias being
+// created by the owner given by the placeholder or for the owner flat.
+// Requests keep the flat of their creation, so residents who left the flat
+// don't see its requests anymore.
+func householdRequest(alias string, placeholder string) string {
+	return `(` + alias + `.owner_id = ` + placeholder + ` OR ` +
+		alias + `.flat_id = (
+			SELECT flat_id FROM owners
+			WHERE id = ` + placeholder + ` AND deleted_at IS NULL
+		))`
+}
+
 func (s *Storage) Owner(phone string) (o entity.Owner, err error) {
-	err = s.db.QueryRowx(`SELECT * FROM owners WHERE phone = $1`,
-		phone).StructScan(&o)
+	err = s.db.QueryRowx(ownersSelect+`
+		WHERE ow.phone = $1 AND ow.deleted_at IS NULL
+			AND ow.organization_id IN (
+				SELECT id FROM organizations WHERE deleted_at IS NULL
+			)
+	`, phone).StructScan(&o)
+	return
+}
+
+// OwnerPhoneTaken reports whether the phone is taken by not deleted owner,
+// including owners of deleted organizations.
+func (s *Storage) OwnerPhoneTaken(phone string) (taken bool, err error) {
+	err = s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM owners WHERE phone = $1 AND deleted_at IS NULL
+		)
+	`, phone).Scan(&taken)
+	return
+}
+
+// ActiveActor reports whether the organization, operator or owner is not
+// deleted and is not of the deleted organization. Other actors are always
+// active.
+func (s *Storage) ActiveActor(actorRole string, actorID int) (
+	active bool, err error) {
+
+	var table string
+
+	switch actorRole {
+	case role.Organization:
+		err = s.db.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM organizations
+				WHERE id = $1 AND deleted_at IS NULL
+			)
+		`, actorID).Scan(&active)
+		return
+	case role.Operator:
+		table = "operators"
+	case role.Owner:
+		table = "owners"
+	default:
+		return true, nil
+	}
+
+	err = s.db.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM `+table+`
+			WHERE id = $1 AND deleted_at IS NULL
+				AND organization_id IN (
+					SELECT id FROM organizations WHERE deleted_at IS NULL
+				)
+		)
+	`, actorID).Scan(&active)
 	return
 }
 
 func (s *Storage) OrganizationOwners(organizationID int) (os []entity.Owner, err error) {
-	err = s.db.Select(&os, `
-		SELECT * FROM owners WHERE organization_id = $1
+	err = s.db.Select(&os, ownersSelect+`
+		WHERE ow.organization_id = $1 AND ow.deleted_at IS NULL
+		ORDER BY address, ow.name
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationDeletedOwners(organizationID int) (
+	os []entity.Owner, err error) {
+	err = s.db.Select(&os, ownersSelect+`
+		WHERE ow.organization_id = $1 AND ow.deleted_at IS NOT NULL
+		ORDER BY ow.deleted_at DESC
 	`, organizationID)
 	return
 }
 
+// Postgres error codes of the constraint violations.
+const (
+	foreignKeyViolation = "23503"
+	uniqueViolation     = "23505"
+)
+
+// AddOwner adds the owner. Returns sql.ErrNoRows if the phone is taken or the
+// owner flat already has primary owner.
 func (s *Storage) AddOwner(o entity.Owner) (entity.Owner, error) {
 	err := s.db.QueryRowx(`
-		INSERT INTO owners
-			(organization_id, phone, password_hash, name, address)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO owners (organization_id, phone, password_hash, name,
+			flat_id, household_role, email, share)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		RETURNING id
-	`, o.OrganizationID, o.Phone, o.PasswordHash, o.Name, o.Address).Scan(&o.ID)
+	`, o.OrganizationID, o.Phone, o.PasswordHash, o.Name, o.FlatID,
+		o.HouseholdRole, o.Email, o.Share).Scan(&o.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return o, sql.ErrNoRows
+	}
 	return o, err
 }
 
+// SetOwner sets the owner. Returns sql.ErrNoRows if the phone is taken or the
+// owner flat already has primary owner.
 func (s *Storage) SetOwner(o entity.Owner) (entity.Owner, error) {
 	_, err := s.db.Exec(`
 		UPDATE owners SET phone = $1, password_hash = $2, name = $3,
-			address = $4 WHERE id = $5
-	`, o.Phone, o.PasswordHash, o.Name, o.Address, o.ID)
+			flat_id = $4, household_role = $5, email = $6, share = $7
+		WHERE organization_id = $8 AND id = $9
+	`, o.Phone, o.PasswordHash, o.Name, o.FlatID, o.HouseholdRole, o.Email,
+		o.Share, o.OrganizationID, o.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return o, sql.ErrNoRows
+	}
 	return o, err
 }
 
+// HouseholdMembers returns the owner and the other residents of the owner
+// flat.
+func (s *Storage) HouseholdMembers(ownerID int) (os []entity.Owner,
+	err error) {
+	err = s.db.Select(&os, ownersSelect+`
+		WHERE ow.id IN `+householdOwners("$1")+` AND ow.deleted_at IS NULL
+		ORDER BY ow.household_role = 'owner' DESC, ow.name
+	`, ownerID)
+	return
+}
+
+// RemoveHouseholdMember unlinks the resident from the flat of the primary
+// owner. The resident account and its requests are kept, only the
+// organization deletes owners. Primary owner is never removed this way.
+// Requests of the flat stay with the flat, so the resident no longer sees
+// them. Unlinked resident becomes primary owner without flat.
+func (s *Storage) RemoveHouseholdMember(primaryOwnerID int,
+	memberID int) error {
+	res, err := s.db.Exec(`
+		UPDATE owners SET flat_id = NULL, household_role = 'owner'
+		WHERE id = $2 AND id <> $1 AND deleted_at IS NULL AND flat_id = (
+			SELECT flat_id FROM owners
+			WHERE id = $1 AND household_role = 'owner'
+				AND deleted_at IS NULL
+		)
+	`, primaryOwnerID, memberID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// RemoveOrganizationOwner marks the owner deleted. Requests of the owner are
+// kept.
 func (s *Storage) RemoveOrganizationOwner(organizationID int,
 	ownerID int) error {
 	_, err := s.db.Exec(`
-		DELETE FROM owners WHERE organization_id = $1 AND id = $2
-	`, organizationID, ownerID)
+		UPDATE owners SET deleted_at = $1
+		WHERE organization_id = $2 AND id = $3 AND deleted_at IS NULL
+	`, time.Now(), organizationID, ownerID)
 	return err
 }
 
+func (s *Storage) RestoreOrganizationOwner(organizationID int,
+	ownerID int) error {
+	res, err := s.db.Exec(`
+		UPDATE owners SET deleted_at = NULL
+		WHERE organization_id = $1 AND id = $2 AND deleted_at IS NOT NULL
+	`, organizationID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
 func (s *Storage) SetOwnerPasswordHash(ownerID int,
 	passwordHash []byte) error {
 	_, err := s.db.Exec(`
@@ -372,6 +806,45 @@ func (s *Storage) SetOwnerPasswordHash(ownerID int,
 	return err
 }
 
+const requestsExtendedSelect = `
+	SELECT
+		r.id as id,
+		r.organization_id as organization_id,
+		r.owner_id as owner_id,
+		r.creator_role as creator_role,
+		r.creator_id as creator_id,
+		r.operator_id as operator_id,
+		r.category_id as category_id,
+		r.primary_request_id as primary_request_id,
+		r.incident_id as incident_id,
+		r.building as building,
+		r.entrance as entrance,
+		r.floor as floor,
+		r.common_area as common_area,
+		r.text as text,
+		r.response as response,
+		r.status as status,
+		r.priority as priority,
+		r.created_at as created_at,
+		r.acknowledged_at as acknowledged_at,
+		r.notified_at as notified_at,
+		r.finished_at as finished_at,
+		o.name as organization_name,
+		c.name as category_name,
+		op.phone as operator_phone,
+		op.name as operator_name,
+		ow.phone as owner_phone,
+		ow.name as owner_name,
+		` + ownerAddress + ` as owner_address,
+		(SELECT count(*) FROM request_supports as rs
+			WHERE rs.request_id = r.id) as supports_count
+	FROM requests as r
+	JOIN organizations as o ON r.organization_id = o.id
+	LEFT JOIN categories as c ON r.category_id = c.id
+	LEFT JOIN operators as op ON r.operator_id = op.id
+	LEFT JOIN owners as ow ON r.owner_id = ow.id
+` + requestFlatJoin
+
 func (s *Storage) OperatorRequest(operatorID int, requestID int) (
 	r entity.Request, err error) {
 	err = s.db.QueryRowx(`
@@ -380,81 +853,2939 @@ func (s *Storage) OperatorRequest(operatorID int, requestID int) (
 	return
 }
 
-func (s *Storage) OperatorRequests(operatorID int) (
+const requestPriorityRank = `
+	CASE r.priority
+		WHEN 'emergency' THEN 2
+		WHEN 'urgent' THEN 1
+		ELSE 0
+	END`
+
+const requestSearchVector = `
+	to_tsvector('russian', r.text || ' ' || coalesce(r.response, ''))`
+
+// Requests returns requests selected by the filter page by page. Requests
+// are sorted by creation time and ID, emergency and urgent requests go
+// first if sorted by priority.
+func (s *Storage) Requests(f entity.RequestFilter) (
 	rs []entity.RequestExtended, err error) {
-	err = s.db.Select(&rs, `
-		SELECT
-			r.id as id,
-			r.organization_id as organization_id,
-			r.owner_id as owner_id,
-			r.operator_id as operator_id,
-			r.category_id as category_id,
-			r.text as text,
-			r.response as response,
-			r.status as status,
-			r.created_at as created_at,
-			c.name as category_name,
-			op.phone as operator_phone,
-		    op.name as operator_name,
-			ow.phone as owner_phone,
-			ow.name as owner_name,
-			ow.address as owner_address
-		FROM requests as r
-		LEFT JOIN categories as c ON r.category_id = c.id
-		LEFT JOIN operators as op ON r.operator_id = op.id
-		LEFT JOIN owners as ow ON r.owner_id = ow.id 
-		WHERE operator_id = $1
-		ORDER BY created_at DESC
-	`, operatorID)
+
+	var (
+		conds []string
+		args  []interface{}
+	)
+
+	arg := func(a interface{}) string {
+		args = append(args, a)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if f.OrganizationID != nil {
+		conds = append(conds, "r.organization_id = "+arg(*f.OrganizationID))
+	}
+	if f.OwnerID != nil {
+		conds = append(conds, "r.owner_id = "+arg(*f.OwnerID))
+	}
+	if f.HouseholdOf != nil {
+		conds = append(conds,
+			householdRequest("r", arg(*f.HouseholdOf)))
+	}
+	if f.OperatorID != nil {
+		conds = append(conds, "r.operator_id = "+arg(*f.OperatorID))
+	}
+	if len(f.Statuses) > 0 {
+		conds = append(conds, "r.status = ANY("+
+			arg(pq.StringArray(f.Statuses))+")")
+	}
+	if f.CategoryID != nil {
+		conds = append(conds, "r.category_id = "+arg(*f.CategoryID))
+	}
+	if f.From != nil {
+		conds = append(conds, "r.created_at >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		conds = append(conds, "r.created_at < "+arg(*f.To))
+	}
+	if f.OwnerAddress != "" {
+		// Wildcards typed by the user are matched literally.
+		conds = append(conds, "coalesce(r.building, "+ownerAddress+
+			") ILIKE '%' || replace(replace(replace("+
+			arg(f.OwnerAddress)+", '\\', '\\\\'), '%', '\\%'), "+
+			"'_', '\\_') || '%'")
+	}
+	if f.Query != "" {
+		conds = append(conds, requestSearchVector+
+			" @@ plainto_tsquery('russian', "+arg(f.Query)+")")
+	}
+	if f.LabelID != nil {
+		conds = append(conds, `EXISTS (
+			SELECT 1 FROM request_labels as rl
+			WHERE rl.request_id = r.id AND rl.label_id = `+
+			arg(*f.LabelID)+")")
+	}
+	if f.FieldID != nil {
+		cond := `EXISTS (
+			SELECT 1 FROM request_field_values as fv
+			WHERE fv.request_id = r.id AND fv.field_id = ` + arg(*f.FieldID)
+		if f.FieldValue != "" {
+			cond += " AND fv.value = " + arg(f.FieldValue)
+		}
+		conds = append(conds, cond+")")
+	}
+	if f.Overdue {
+		conds = append(conds, "r.status IN ('new', 'in_progress') AND "+
+			"r.created_at + CASE r.priority"+
+			" WHEN 'emergency' THEN "+
+			arg(priority.SLA(priority.Emergency).Seconds())+"::float8"+
+			" WHEN 'urgent' THEN "+
+			arg(priority.SLA(priority.Urgent).Seconds())+"::float8"+
+			" ELSE "+arg(priority.SLA(priority.Normal).Seconds())+"::float8"+
+			" END * interval '1 second' < "+arg(time.Now()))
+	}
+
+	var order string
+
+	switch f.Sorting {
+	case sorting.Oldest:
+		order = "r.created_at, r.id"
+		if f.After != nil {
+			conds = append(conds, "(r.created_at, r.id) > ("+
+				arg(f.After.CreatedAt)+", "+arg(f.After.ID)+")")
+		}
+	case sorting.Priority:
+		order = requestPriorityRank + " DESC, r.created_at DESC, r.id DESC"
+		if f.After != nil {
+			conds = append(conds, "("+requestPriorityRank+
+				", r.created_at, r.id) < ("+arg(f.After.PriorityRank)+", "+
+				arg(f.After.CreatedAt)+", "+arg(f.After.ID)+")")
+		}
+	default:
+		order = "r.created_at DESC, r.id DESC"
+		if f.After != nil {
+			conds = append(conds, "(r.created_at, r.id) < ("+
+				arg(f.After.CreatedAt)+", "+arg(f.After.ID)+")")
+		}
+	}
+
+	query := requestsExtendedSelect
+
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	query += " ORDER BY " + order
+
+	if f.Limit > 0 {
+		query += " LIMIT " + arg(f.Limit)
+	}
+
+	err = s.db.Select(&rs, query, args...)
 	return
 }
 
-func (s *Storage) SetOperatorRequest(operatorID int, r entity.Request) (
-	entity.Request, error) {
-	_, err := s.db.Exec(`
-		UPDATE requests SET response = $1, status = $2
-		WHERE operator_id = $3 AND id = $4
-	`, r.Response, r.Status, operatorID, r.ID)
-	return r, err
+func (s *Storage) OperatorRequests(operatorID int, f entity.RequestFilter) (
+	[]entity.RequestExtended, error) {
+	f.OperatorID = &operatorID
+	return s.Requests(f)
 }
 
-func (s *Storage) OwnerRequests(ownerID int) (rs []entity.RequestExtended,
-	err error) {
-	err = s.db.Select(&rs, `
+func (s *Storage) OrganizationRequests(organizationID int,
+	f entity.RequestFilter) ([]entity.RequestExtended, error) {
+	f.OrganizationID = &organizationID
+	return s.Requests(f)
+}
+
+func (s *Storage) OrganizationRequest(organizationID int, requestID int) (
+	r entity.RequestExtended, err error) {
+	err = s.db.QueryRowx(requestsExtendedSelect+`
+		WHERE r.organization_id = $1 AND r.id = $2
+	`, organizationID, requestID).StructScan(&r)
+	return
+}
+
+// OrganizationOperatorsStats returns breakdown of the organization requests
+// by operator. Request is overdue if it is not finished in its priority
+// SLA.
+func (s *Storage) OrganizationOperatorsStats(organizationID int) (
+	oss []entity.OperatorStats, err error) {
+	err = s.db.Select(&oss, `
+		WITH rs AS (
+			SELECT r.*, r.created_at + CASE r.priority
+				WHEN 'emergency' THEN $2::float8
+				WHEN 'urgent' THEN $3::float8
+				ELSE $4::float8
+			END * interval '1 second' as deadline
+			FROM requests as r
+			WHERE r.organization_id = $1
+		)
 		SELECT
-			r.id as id,
-			r.organization_id as organization_id,
-			r.owner_id as owner_id,
-			r.operator_id as operator_id,
-			r.category_id as category_id,
-			r.text as text,
-			r.response as response,
-			r.status as status,
-			r.created_at as created_at,
+			rs.operator_id as operator_id,
+			op.name as operator_name,
+			count(*) FILTER (WHERE rs.status = 'new') as new,
+			count(*) FILTER (WHERE rs.status = 'in_progress') as in_progress,
+			count(*) FILTER (
+				WHERE rs.status NOT IN ('new', 'in_progress')) as finished,
+			count(*) FILTER (
+				WHERE rs.status IN ('new', 'in_progress')
+					AND rs.deadline < $5) as overdue,
+			count(*) FILTER (
+				WHERE rs.finished_at > rs.deadline) as finished_late,
+			round(avg(extract(epoch FROM rs.finished_at - rs.created_at)
+				/ 3600)::numeric, 1)::float8 as avg_resolution_hours
+		FROM rs
+		LEFT JOIN operators as op ON rs.operator_id = op.id
+		GROUP BY rs.operator_id, op.name
+		ORDER BY op.name NULLS FIRST
+	`, organizationID, priority.SLA(priority.Emergency).Seconds(),
+		priority.SLA(priority.Urgent).Seconds(),
+		priority.SLA(priority.Normal).Seconds(), time.Now())
+	return
+}
+
+// OrganizationsStats returns breakdown of requests by organization.
+// Organizations with most overdue and open requests go first.
+func (s *Storage) OrganizationsStats() (oss []entity.OrganizationStats,
+	err error) {
+	err = s.db.Select(&oss, `
+		WITH rs AS (
+			SELECT r.*, r.created_at + CASE r.priority
+				WHEN 'emergency' THEN $1::float8
+				WHEN 'urgent' THEN $2::float8
+				ELSE $3::float8
+			END * interval '1 second' as deadline,
+			EXISTS (
+				SELECT 1 FROM request_events as e
+				WHERE e.request_id = r.id AND e.kind = 'category_changed'
+			) as recategorized
+			FROM requests as r
+		)
+		SELECT
+			o.id as organization_id,
+			o.name as organization_name,
+			`+organizationFlatsCount+` as flats_count,
+			count(rs.id) as total,
+			count(rs.id) FILTER (
+				WHERE rs.status IN ('new', 'in_progress')) as open,
+			count(rs.id) FILTER (
+				WHERE rs.status IN ('new', 'in_progress')
+					AND rs.operator_id IS NULL) as unassigned,
+			count(rs.id) FILTER (
+				WHERE rs.status IN ('new', 'in_progress')
+					AND rs.deadline < $4) as overdue,
+			min(rs.created_at) FILTER (
+				WHERE rs.status IN ('new', 'in_progress')) as oldest_open_at,
+			count(rs.id) FILTER (WHERE rs.category_id IS NULL)
+				as uncategorized,
+			count(rs.id) FILTER (WHERE rs.recategorized) as recategorized,
+			round(avg(extract(epoch FROM rs.finished_at - rs.created_at)
+				/ 3600)::numeric, 1)::float8 as avg_resolution_hours
+		FROM organizations as o
+		LEFT JOIN rs ON rs.organization_id = o.id
+		WHERE o.deleted_at IS NULL
+		GROUP BY o.id, o.name
+		ORDER BY overdue DESC, open DESC, o.name
+	`, priority.SLA(priority.Emergency).Seconds(),
+		priority.SLA(priority.Urgent).Seconds(),
+		priority.SLA(priority.Normal).Seconds(), time.Now())
+	return
+}
+
+// CategoriesStats returns breakdown of requests by category. Requests of all
+// organizations are counted if organizationID is nil.
+func (s *Storage) CategoriesStats(organizationID *int) (
+	css []entity.CategoryStats, err error) {
+	err = s.db.Select(&css, `
+		SELECT
+			r.category_id as category_id,
 			c.name as category_name,
-			op.phone as operator_phone,
-		    op.name as operator_name,
-			ow.phone as owner_phone,
-			ow.name as owner_name,
-			ow.address as owner_address
+			count(*) as total,
+			count(*) FILTER (
+				WHERE r.status IN ('new', 'in_progress')) as open,
+			count(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM request_events as e
+				WHERE e.request_id = r.id AND e.kind = 'category_changed'
+			)) as recategorized
 		FROM requests as r
 		LEFT JOIN categories as c ON r.category_id = c.id
-		LEFT JOIN operators as op ON r.operator_id = op.id
-		LEFT JOIN owners as ow ON r.owner_id = ow.id
-		WHERE owner_id = $1
-		ORDER BY created_at DESC
-	`, ownerID)
+		WHERE $1::bigint IS NULL OR r.organization_id = $1
+		GROUP BY r.category_id, c.name
+		ORDER BY c.name NULLS FIRST
+	`, organizationID)
+	return
+}
+
+func (s *Storage) RequestEvents(requestID int) (
+	res []entity.RequestEvent, err error) {
+	err = s.db.Select(&res, `
+		SELECT * FROM request_events WHERE request_id = $1
+		ORDER BY created_at
+	`, requestID)
+	return
+}
+
+const requestNotesSelect = `
+	SELECT
+		n.*,
+		coalesce(op.name, o.name) as author_name
+	FROM request_notes as n
+	LEFT JOIN operators as op ON n.role = 'operator' AND n.author_id = op.id
+	LEFT JOIN organizations as o
+		ON n.role = 'organization' AND n.author_id = o.id
+`
+
+func (s *Storage) AddRequestNote(n entity.RequestNote) (entity.RequestNote,
+	error) {
+	n.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO request_notes (request_id, role, author_id, text, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, n.RequestID, n.Role, n.AuthorID, n.Text, n.CreatedAt).Scan(&n.ID)
+	return n, err
+}
+
+func (s *Storage) RequestNotes(requestID int) (
+	rns []entity.RequestNote, err error) {
+	err = s.db.Select(&rns, requestNotesSelect+`
+		WHERE n.request_id = $1
+		ORDER BY n.created_at
+	`, requestID)
+	return
+}
+
+func (s *Storage) OperatorRequestsNotes(operatorID int, requestIDs []int) (
+	rns []entity.RequestNote, err error) {
+	err = s.db.Select(&rns, requestNotesSelect+`
+		JOIN requests as r ON n.request_id = r.id
+		WHERE r.operator_id = $1 AND r.id = ANY($2)
+		ORDER BY n.created_at
+	`, operatorID, pq.Array(requestIDs))
+	return
+}
+
+func (s *Storage) AcknowledgeOperatorRequest(operatorID int,
+	requestID int) error {
+	_, err := s.db.Exec(`
+		UPDATE requests SET acknowledged_at = $1
+		WHERE operator_id = $2 AND id = $3 AND acknowledged_at IS NULL
+	`, time.Now(), operatorID, requestID)
+	return err
+}
+
+func (s *Storage) SetRequestNotifiedAt(requestID int,
+	notifiedAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE requests SET notified_at = $1 WHERE id = $2
+	`, notifiedAt, requestID)
+	return err
+}
+
+// UnacknowledgedEmergencyRequests returns not final emergency requests
+// which are not acknowledged by its operators and which operators were
+// not notified since notifiedBefore. Requests of deleted operators and
+// organizations are skipped.
+func (s *Storage) UnacknowledgedEmergencyRequests(notifiedBefore time.Time) (
+	rs []entity.RequestExtended, err error) {
+	err = s.db.Select(&rs, requestsExtendedSelect+`
+		WHERE r.priority = 'emergency'
+			AND r.operator_id IS NOT NULL
+			AND op.deleted_at IS NULL AND o.deleted_at IS NULL
+			AND r.acknowledged_at IS NULL
+			AND r.status IN ('new', 'in_progress')
+			AND (r.notified_at IS NULL OR r.notified_at < $1)
+		ORDER BY r.created_at
+	`, notifiedBefore)
+	return
+}
+
+// SetOperatorRequest sets response and status of the operator request and
+// of the requests linked to it as duplicates.
+func (s *Storage) SetOperatorRequest(operatorID int, r entity.Request) (
+	entity.Request, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return r, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE requests SET response = $1, status = $2,
+			finished_at = CASE WHEN $2 IN ('new', 'in_progress') THEN NULL
+				ELSE coalesce(finished_at, $3) END
+		WHERE operator_id = $4 AND id = $5
+	`, r.Response, r.Status, time.Now(), operatorID, r.ID)
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	if n > 0 {
+		_, err = tx.Exec(`
+			UPDATE requests SET response = $1, status = $2,
+				finished_at = CASE WHEN $2 IN ('new', 'in_progress') THEN NULL
+					ELSE coalesce(finished_at, $3) END
+			WHERE primary_request_id = $4
+		`, r.Response, r.Status, time.Now(), r.ID)
+		if err != nil {
+			tx.Rollback()
+			return r, err
+		}
+
+		status := r.Status
+
+		err = addRequestEvent(tx, entity.RequestEvent{
+			RequestID: r.ID,
+			Role:      role.Operator,
+			ActorID:   operatorID,
+			Kind:      event.StatusChanged,
+			Text:      &status,
+		})
+		if err != nil {
+			tx.Rollback()
+			return r, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return r, err
+}
+
+// SetRequest sets operator, category, status and response of the request
+// and records the events in its history. Operator, status and response are
+// also set to the requests linked to it. Emergency alarm starts over if
+// operator is changed.
+func (s *Storage) SetRequest(r entity.Request, es []entity.RequestEvent) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE requests SET
+			acknowledged_at = CASE WHEN operator_id IS DISTINCT FROM $1
+				THEN NULL ELSE acknowledged_at END,
+			notified_at = CASE WHEN operator_id IS DISTINCT FROM $1
+				THEN NULL ELSE notified_at END,
+			operator_id = $1, status = $2, response = $3,
+			finished_at = CASE WHEN $2 IN ('new', 'in_progress') THEN NULL
+				ELSE coalesce(finished_at, $4) END
+		WHERE id = $5 OR primary_request_id = $5
+	`, r.OperatorID, r.Status, r.Response, time.Now(), r.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE requests SET category_id = $1 WHERE id = $2
+	`, r.CategoryID, r.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, e := range es {
+		err = addRequestEvent(tx, e)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+func (s *Storage) Request(requestID int) (r entity.Request, err error) {
+	err = s.db.QueryRowx(`SELECT * FROM requests WHERE id = $1`, requestID).
+		StructScan(&r)
+	return
+}
+
+// LinkRequest links the request as duplicate to the primary request. Linked
+// request gets operator, status and response of the primary request, and
+// requests linked to it are relinked to the primary request.
+func (s *Storage) LinkRequest(requestID int, primaryRequestID int) error {
+	_, err := s.db.Exec(`
+		UPDATE requests as r SET
+			primary_request_id = p.id,
+			operator_id = p.operator_id,
+			status = p.status,
+			response = p.response,
+			finished_at = p.finished_at
+		FROM requests as p
+		WHERE p.id = $2 AND (r.id = $1 OR r.primary_request_id = $1)
+	`, requestID, primaryRequestID)
+	return err
+}
+
+func (s *Storage) UnlinkRequest(requestID int) error {
+	_, err := s.db.Exec(`
+		UPDATE requests SET primary_request_id = NULL WHERE id = $1
+	`, requestID)
+	return err
+}
+
+// DuplicateCandidates returns organization requests created since the given
+// time which are not final and not linked to other requests. If category ID
+// is set only requests of that category are returned.
+func (s *Storage) DuplicateCandidates(organizationID int, categoryID *int,
+	since time.Time) (rs []entity.RequestExtended, err error) {
+	err = s.db.Select(&rs, requestsExtendedSelect+`
+		WHERE r.organization_id = $1
+			AND ($2::BIGINT IS NULL OR r.category_id = $2)
+			AND r.created_at >= $3
+			AND r.primary_request_id IS NULL
+			AND r.status IN ('new', 'in_progress')
+		ORDER BY r.created_at DESC
+	`, organizationID, categoryID, since)
+	return
+}
+
+// AddRequestDuplicates stores the duplicates in both directions, so both
+// requests operators can see them.
+func (s *Storage) AddRequestDuplicates(rds []entity.RequestDuplicate) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, rd := range rds {
+		_, err = tx.Exec(`
+			INSERT INTO request_duplicates
+				(request_id, duplicate_id, similarity)
+			VALUES ($1, $2, $3), ($2, $1, $3)
+			ON CONFLICT DO NOTHING
+		`, rd.RequestID, rd.DuplicateID, rd.Similarity)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+// OperatorRequestsDuplicates returns not linked duplicates of the operator
+// requests with the given IDs.
+func (s *Storage) OperatorRequestsDuplicates(operatorID int,
+	requestIDs []int) (rds []entity.RequestDuplicate, err error) {
+	err = s.db.Select(&rds, `
+		SELECT
+			rd.request_id as request_id,
+			rd.duplicate_id as duplicate_id,
+			rd.similarity as similarity,
+			d.text as text,
+			d.status as status,
+			d.created_at as created_at
+		FROM request_duplicates as rd
+		JOIN requests as r ON rd.request_id = r.id
+		JOIN requests as d ON rd.duplicate_id = d.id
+		WHERE r.operator_id = $1 AND r.id = ANY($2)
+			AND r.primary_request_id IS NULL
+			AND d.primary_request_id IS NULL
+		ORDER BY rd.similarity DESC
+	`, operatorID, pq.Array(requestIDs))
+	return
+}
+
+func (s *Storage) OwnerRequests(ownerID int, f entity.RequestFilter) (
+	[]entity.RequestExtended, error) {
+	f.HouseholdOf = &ownerID
+	return s.Requests(f)
+}
+
+func (s *Storage) OwnerRequest(ownerID int, requestID int) (
+	r entity.Request, err error) {
+	err = s.db.QueryRowx(`
+		SELECT * FROM requests WHERE owner_id = $1 AND id = $2
+	`, ownerID, requestID).StructScan(&r)
+	return
+}
+
+func addRequestEvent(tx *sql.Tx, e entity.RequestEvent) error {
+	_, err := tx.Exec(`
+		INSERT INTO request_events
+			(request_id, role, actor_id, kind, text, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, e.RequestID, e.Role, e.ActorID, e.Kind, e.Text, time.Now())
+	return err
+}
+
+// EditOwnerRequest sets text, category, operator and priority of the owner
+// request if it is still new and records the event in its history.
+func (s *Storage) EditOwnerRequest(ownerID int, r entity.Request,
+	e entity.RequestEvent) (entity.Request, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return r, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE requests SET text = $1, category_id = $2, operator_id = $3,
+			priority = $4
+		WHERE owner_id = $5 AND id = $6 AND status = 'new'
+	`, r.Text, r.CategoryID, r.OperatorID, r.Priority, ownerID, r.ID)
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	if n == 0 {
+		tx.Rollback()
+		return r, sql.ErrNoRows
+	}
+
+	err = addRequestEvent(tx, e)
+	if err != nil {
+		tx.Rollback()
+		return r, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return r, err
+}
+
+// CancelOwnerRequest cancels not final owner request, unlinks requests
+// linked to it and records the event in its history.
+func (s *Storage) CancelOwnerRequest(ownerID int, requestID int,
+	e entity.RequestEvent) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE requests SET status = 'cancelled', finished_at = $3
+		WHERE owner_id = $1 AND id = $2 AND status IN ('new', 'in_progress')
+	`, ownerID, requestID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if n == 0 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	_, err = tx.Exec(`
+		UPDATE requests SET primary_request_id = NULL
+		WHERE primary_request_id = $1
+	`, requestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE visit_slots SET request_id = NULL, reminded_at = NULL
+		WHERE request_id = $1
+	`, requestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = addRequestEvent(tx, e)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+func (s *Storage) OperatorRequestsEvents(operatorID int, requestIDs []int) (
+	res []entity.RequestEvent, err error) {
+	err = s.db.Select(&res, `
+		SELECT e.* FROM request_events as e
+		JOIN requests as r ON e.request_id = r.id
+		WHERE r.operator_id = $1 AND r.id = ANY($2)
+		ORDER BY e.created_at
+	`, operatorID, pq.Array(requestIDs))
 	return
 }
 
 func (s *Storage) AddRequest(r entity.Request) (entity.Request, error) {
+	if r.OwnerPriority == "" {
+		r.OwnerPriority = r.Priority
+	}
+
 	err := s.db.QueryRowx(`
 		INSERT INTO requests
-			(organization_id, owner_id, operator_id, category_id, text, 
-				status, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+			(organization_id, owner_id, creator_role, creator_id, operator_id,
+				category_id, incident_id, building, entrance, floor,
+				common_area, text, status, priority, owner_priority,
+				flat_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14,
+			$15, $16, $17)
 		RETURNING id
-	`, r.OrganizationID, r.OwnerID, r.OperatorID, r.CategoryID, r.Text,
-		r.Status, time.Now()).Scan(&r.ID)
+	`, r.OrganizationID, r.OwnerID, r.CreatorRole, r.CreatorID, r.OperatorID,
+		r.CategoryID, r.IncidentID, r.Building, r.Entrance, r.Floor,
+		r.CommonArea, r.Text, r.Status, r.Priority, r.OwnerPriority,
+		r.FlatID, time.Now()).Scan(&r.ID)
 	return r, err
 }
+
+// OpenOrganizationCommonRequests returns open common area requests of the
+// organization which are not linked to other requests.
+func (s *Storage) OpenOrganizationCommonRequests(organizationID int) (
+	rs []entity.RequestExtended, err error) {
+	err = s.db.Select(&rs, requestsExtendedSelect+`
+		WHERE r.organization_id = $1
+			AND r.common_area IS NOT NULL
+			AND r.primary_request_id IS NULL
+			AND r.status IN ('new', 'in_progress')
+		ORDER BY r.created_at DESC
+	`, organizationID)
+	return
+}
+
+// AddRequestSupport records that the owner is concerned by the common area
+// request too. Repeated support is ignored.
+func (s *Storage) AddRequestSupport(requestID int, ownerID int) error {
+	_, err := s.db.Exec(`
+		INSERT INTO request_supports (request_id, owner_id, created_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+	`, requestID, ownerID, time.Now())
+	return err
+}
+
+func (s *Storage) OwnerSupportedRequests(ownerID int) (
+	requestIDs []int, err error) {
+	err = s.db.Select(&requestIDs, `
+		SELECT request_id FROM request_supports WHERE owner_id = $1
+	`, ownerID)
+	return
+}
+
+const incidentsSelect = `
+	SELECT id, organization_id, title, description, buildings,
+		expected_resolution_at, response, created_at, resolved_at
+	FROM incidents
+`
+
+func scanIncident(row interface{ Scan(...interface{}) error }) (
+	i entity.Incident, err error) {
+	var bs pq.StringArray
+	err = row.Scan(&i.ID, &i.OrganizationID, &i.Title, &i.Description, &bs,
+		&i.ExpectedResolutionAt, &i.Response, &i.CreatedAt, &i.ResolvedAt)
+	i.Buildings = bs
+	return
+}
+
+func (s *Storage) selectIncidents(query string, args ...interface{}) (
+	[]entity.Incident, error) {
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var is []entity.Incident
+
+	for rows.Next() {
+		i, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		is = append(is, i)
+	}
+
+	return is, rows.Err()
+}
+
+func (s *Storage) OrganizationIncidents(organizationID int) (
+	[]entity.Incident, error) {
+	return s.selectIncidents(incidentsSelect+`
+		WHERE organization_id = $1
+		ORDER BY resolved_at IS NOT NULL, created_at DESC
+	`, organizationID)
+}
+
+func (s *Storage) OpenOrganizationIncidents(organizationID int) (
+	[]entity.Incident, error) {
+	return s.selectIncidents(incidentsSelect+`
+		WHERE organization_id = $1 AND resolved_at IS NULL
+		ORDER BY created_at DESC
+	`, organizationID)
+}
+
+func (s *Storage) OrganizationIncident(organizationID int, incidentID int) (
+	entity.Incident, error) {
+	return scanIncident(s.db.QueryRow(incidentsSelect+`
+		WHERE organization_id = $1 AND id = $2
+	`, organizationID, incidentID))
+}
+
+func (s *Storage) AddIncident(i entity.Incident) (entity.Incident, error) {
+	i.CreatedAt = time.Now()
+	err := s.db.QueryRowx(`
+		INSERT INTO incidents (organization_id, title, description, buildings,
+			expected_resolution_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, i.OrganizationID, i.Title, i.Description, pq.Array(i.Buildings),
+		i.ExpectedResolutionAt, i.CreatedAt).Scan(&i.ID)
+	return i, err
+}
+
+func (s *Storage) SetIncident(i entity.Incident) (entity.Incident, error) {
+	_, err := s.db.Exec(`
+		UPDATE incidents SET title = $1, description = $2, buildings = $3,
+			expected_resolution_at = $4
+		WHERE organization_id = $5 AND id = $6 AND resolved_at IS NULL
+	`, i.Title, i.Description, pq.Array(i.Buildings), i.ExpectedResolutionAt,
+		i.OrganizationID, i.ID)
+	return i, err
+}
+
+// ResolveIncident resolves the incident and all not final requests attached
+// to it with the response. It returns phones of the resolved requests
+// owners.
+func (s *Storage) ResolveIncident(organizationID int, incidentID int,
+	response string) ([]string, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE incidents SET response = $1, resolved_at = $2
+		WHERE organization_id = $3 AND id = $4 AND resolved_at IS NULL
+	`, response, time.Now(), organizationID, incidentID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if n == 0 {
+		tx.Rollback()
+		return nil, sql.ErrNoRows
+	}
+
+	rows, err := tx.Query(`
+		UPDATE requests as r SET status = 'resolved', response = $1,
+			finished_at = $3
+		FROM owners as ow
+		WHERE r.owner_id = ow.id AND r.incident_id = $2
+			AND r.status IN ('new', 'in_progress')
+		RETURNING ow.phone
+	`, response, incidentID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var phones []string
+
+	for rows.Next() {
+		var phone string
+		err = rows.Scan(&phone)
+		if err != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, err
+		}
+		phones = append(phones, phone)
+	}
+
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return phones, nil
+}
+
+const visitSlotsExtendedSelect = `
+	SELECT
+		vs.*,
+		r.text as request_text,
+		op.phone as operator_phone,
+		op.name as operator_name,
+		ow.phone as owner_phone,
+		ow.name as owner_name,
+		` + ownerAddress + ` as owner_address
+	FROM visit_slots as vs
+	JOIN operators as op ON vs.operator_id = op.id
+	LEFT JOIN requests as r ON vs.request_id = r.id
+	LEFT JOIN owners as ow ON r.owner_id = ow.id
+` + requestFlatJoin
+
+func (s *Storage) OperatorVisitSlots(operatorID int, since time.Time) (
+	vss []entity.VisitSlotExtended, err error) {
+	err = s.db.Select(&vss, visitSlotsExtendedSelect+`
+		WHERE vs.operator_id = $1 AND vs.ends_at > $2
+		ORDER BY vs.starts_at
+	`, operatorID, since)
+	return
+}
+
+// exclusionViolation is the postgres error code of the exclusion constraint
+// violation.
+const exclusionViolation = "23P01"
+
+// AddVisitSlot adds the visit slot unless it overlaps other visit slot of
+// the operator, sql.ErrNoRows is returned otherwise. Concurrently added
+// overlapping slots are rejected by the exclusion constraint.
+func (s *Storage) AddVisitSlot(vs entity.VisitSlot) (entity.VisitSlot, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO visit_slots (operator_id, starts_at, ends_at)
+		SELECT $1, $2, $3
+		WHERE NOT EXISTS (
+			SELECT 1 FROM visit_slots
+			WHERE operator_id = $1 AND starts_at < $3 AND ends_at > $2
+		)
+		RETURNING id
+	`, vs.OperatorID, vs.StartsAt, vs.EndsAt).Scan(&vs.ID)
+	if pqErr, ok := err.(*pq.Error); ok &&
+		pqErr.Code == exclusionViolation {
+		return vs, sql.ErrNoRows
+	}
+	return vs, err
+}
+
+func (s *Storage) RemoveOperatorVisitSlot(operatorID int,
+	visitSlotID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM visit_slots
+		WHERE operator_id = $1 AND id = $2 AND request_id IS NULL
+	`, operatorID, visitSlotID)
+	return err
+}
+
+func (s *Storage) OwnerRequestsVisitSlots(ownerID int, requestIDs []int) (
+	vss []entity.VisitSlot, err error) {
+	err = s.db.Select(&vss, `
+		SELECT vs.* FROM visit_slots as vs
+		JOIN requests as r ON vs.request_id = r.id
+		WHERE `+householdRequest("r", "$1")+`
+			AND r.id = ANY($2)
+	`, ownerID, pq.Array(requestIDs))
+	return
+}
+
+// OwnerRequestsFreeVisitSlots returns free visit slots starting after
+// since of operators handling not final requests with the given IDs of the
+// owner household.
+func (s *Storage) OwnerRequestsFreeVisitSlots(ownerID int, requestIDs []int,
+	since time.Time) (vss []entity.VisitSlot, err error) {
+	err = s.db.Select(&vss, `
+		SELECT vs.* FROM visit_slots as vs
+		WHERE vs.request_id IS NULL AND vs.starts_at > $2
+			AND vs.operator_id IN (
+				SELECT operator_id FROM requests
+				WHERE `+householdRequest("requests", "$1")+`
+					AND id = ANY($3)
+					AND status IN ('new', 'in_progress')
+			)
+		ORDER BY vs.starts_at
+	`, ownerID, since, pq.Array(requestIDs))
+	return
+}
+
+// BookVisitSlot books free visit slot of the request operator for the not
+// final request of the owner household. Previously booked slot of the
+// request is released.
+// Returns sql.ErrNoRows if request or free slot is not found.
+func (s *Storage) BookVisitSlot(ownerID int, requestID int,
+	visitSlotID int) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	var operatorID int
+
+	err = tx.QueryRow(`
+		SELECT operator_id FROM requests
+		WHERE `+householdRequest("requests", "$1")+` AND id = $2
+			AND operator_id IS NOT NULL
+			AND status IN ('new', 'in_progress')
+		FOR UPDATE
+	`, ownerID, requestID).Scan(&operatorID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE visit_slots SET request_id = NULL, reminded_at = NULL
+		WHERE request_id = $1
+	`, requestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	res, err := tx.Exec(`
+		UPDATE visit_slots SET request_id = $1
+		WHERE id = $2 AND operator_id = $3 AND request_id IS NULL
+			AND starts_at > $4
+	`, requestID, visitSlotID, operatorID, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if n == 0 {
+		tx.Rollback()
+		return sql.ErrNoRows
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+func (s *Storage) CancelVisit(ownerID int, requestID int) error {
+	_, err := s.db.Exec(`
+		UPDATE visit_slots SET request_id = NULL, reminded_at = NULL
+		FROM requests as r
+		WHERE visit_slots.request_id = r.id AND r.id = $2
+			AND `+householdRequest("r", "$1"), ownerID, requestID)
+	return err
+}
+
+// UnremindedVisits returns booked visit slots starting between now and
+// startsBefore which owners and operators were not reminded about. Visits
+// of deleted operators and organizations are skipped.
+func (s *Storage) UnremindedVisits(startsBefore time.Time) (
+	vss []entity.VisitSlotExtended, err error) {
+	err = s.db.Select(&vss, visitSlotsExtendedSelect+`
+		WHERE vs.request_id IS NOT NULL AND vs.reminded_at IS NULL
+			AND vs.starts_at > $1 AND vs.starts_at <= $2
+			AND op.deleted_at IS NULL AND op.organization_id IN (
+				SELECT id FROM organizations WHERE deleted_at IS NULL
+			)
+	`, time.Now(), startsBefore)
+	return
+}
+
+func (s *Storage) SetVisitSlotRemindedAt(visitSlotID int,
+	remindedAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE visit_slots SET reminded_at = $1 WHERE id = $2
+	`, remindedAt, visitSlotID)
+	return err
+}
+
+// OperatorCalendarToken returns calendar token of the operator, nil if the
+// operator calendar is not published.
+func (s *Storage) OperatorCalendarToken(operatorID int) (token *string,
+	err error) {
+	err = s.db.QueryRow(`
+		SELECT calendar_token FROM operators WHERE id = $1
+	`, operatorID).Scan(&token)
+	return
+}
+
+// SetOperatorCalendarToken replaces calendar token of the operator. Nil token
+// revokes it.
+func (s *Storage) SetOperatorCalendarToken(operatorID int,
+	token *string) error {
+
+	res, err := s.db.Exec(`
+		UPDATE operators SET calendar_token = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, token, operatorID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) CalendarTokenOperatorID(token string) (operatorID int,
+	err error) {
+	err = s.db.QueryRow(`
+		SELECT id FROM operators
+		WHERE calendar_token = $1 AND deleted_at IS NULL
+	`, token).Scan(&operatorID)
+	return
+}
+
+func (s *Storage) OrganizationContractors(organizationID int) (
+	cs []entity.Contractor, err error) {
+	err = s.db.Select(&cs, `
+		SELECT * FROM contractors WHERE organization_id = $1 ORDER BY name
+	`, organizationID)
+	return
+}
+
+func (s *Storage) AddContractor(c entity.Contractor) (entity.Contractor,
+	error) {
+	err := s.db.QueryRow(`
+		INSERT INTO contractors (organization_id, name, phone, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, c.OrganizationID, c.Name, c.Phone, c.Email).Scan(&c.ID)
+	return c, err
+}
+
+func (s *Storage) SetContractor(c entity.Contractor) (entity.Contractor,
+	error) {
+	_, err := s.db.Exec(`
+		UPDATE contractors SET name = $1, phone = $2, email = $3
+		WHERE organization_id = $4 AND id = $5
+	`, c.Name, c.Phone, c.Email, c.OrganizationID, c.ID)
+	return c, err
+}
+
+func (s *Storage) RemoveOrganizationContractor(organizationID int,
+	contractorID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM contractors WHERE organization_id = $1 AND id = $2
+	`, organizationID, contractorID)
+	return err
+}
+
+func (s *Storage) OrganizationBuildings(organizationID int) (
+	bs []entity.Building, err error) {
+	err = s.db.Select(&bs, `
+		SELECT b.*, (
+			SELECT count(*) FROM flats as f WHERE f.building_id = b.id
+		) as flats_count
+		FROM buildings as b
+		WHERE b.organization_id = $1
+		ORDER BY b.address
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationBuilding(organizationID int, buildingID int) (
+	b entity.Building, err error) {
+	err = s.db.QueryRowx(`
+		SELECT b.*, (
+			SELECT count(*) FROM flats as f WHERE f.building_id = b.id
+		) as flats_count
+		FROM buildings as b
+		WHERE b.organization_id = $1 AND b.id = $2
+	`, organizationID, buildingID).StructScan(&b)
+	return
+}
+
+func (s *Storage) AddBuilding(b entity.Building) (entity.Building, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO buildings (organization_id, address, entrances, floors)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, b.OrganizationID, b.Address, b.Entrances, b.Floors).Scan(&b.ID)
+	return b, err
+}
+
+func (s *Storage) SetBuilding(b entity.Building) (entity.Building, error) {
+	_, err := s.db.Exec(`
+		UPDATE buildings SET address = $1, entrances = $2, floors = $3
+		WHERE organization_id = $4 AND id = $5
+	`, b.Address, b.Entrances, b.Floors, b.OrganizationID, b.ID)
+	return b, err
+}
+
+// RemoveOrganizationBuilding removes the building with its flats. Returns
+// sql.ErrNoRows if some flat of the building has charges or payments.
+func (s *Storage) RemoveOrganizationBuilding(organizationID int,
+	buildingID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM buildings WHERE organization_id = $1 AND id = $2
+	`, organizationID, buildingID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+const flatsSelect = `
+	SELECT f.*, b.address as building_address
+	FROM flats as f
+	JOIN buildings as b ON f.building_id = b.id
+`
+
+func (s *Storage) OrganizationFlats(organizationID int) (
+	fs []entity.Flat, err error) {
+	err = s.db.Select(&fs, flatsSelect+`
+		WHERE b.organization_id = $1
+		ORDER BY b.address, length(f.number), f.number
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationFlat(organizationID int, flatID int) (
+	f entity.Flat, err error) {
+	err = s.db.QueryRowx(flatsSelect+`
+		WHERE b.organization_id = $1 AND f.id = $2
+	`, organizationID, flatID).StructScan(&f)
+	return
+}
+
+// AddFlat adds the flat. Returns sql.ErrNoRows if the account is taken by
+// another flat of the organization.
+func (s *Storage) AddFlat(f entity.Flat) (entity.Flat, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO flats (organization_id, building_id, number, entrance,
+			floor, area, account)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, f.OrganizationID, f.BuildingID, f.Number, f.Entrance, f.Floor, f.Area,
+		f.Account).Scan(&f.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return f, sql.ErrNoRows
+	}
+	return f, err
+}
+
+// SetFlat sets the flat of the organization. Flat can be moved to another
+// building of the same organization only. Returns sql.ErrNoRows if the
+// account is taken by another flat of the organization.
+func (s *Storage) SetFlat(organizationID int, f entity.Flat) (entity.Flat,
+	error) {
+	_, err := s.db.Exec(`
+		UPDATE flats SET building_id = $1, number = $2, entrance = $3,
+			floor = $4, area = $5, account = $6
+		WHERE id = $7 AND organization_id = $8 AND building_id IN (
+			SELECT id FROM buildings WHERE organization_id = $8
+		)
+	`, f.BuildingID, f.Number, f.Entrance, f.Floor, f.Area, f.Account,
+		f.ID, organizationID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == uniqueViolation {
+		return f, sql.ErrNoRows
+	}
+	return f, err
+}
+
+// RemoveOrganizationFlat removes the flat. Returns sql.ErrNoRows if the flat
+// has charges or payments.
+func (s *Storage) RemoveOrganizationFlat(organizationID int,
+	flatID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM flats WHERE id = $1 AND organization_id = $2
+	`, flatID, organizationID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == foreignKeyViolation {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+const metersSelect = `
+	SELECT m.*, b.address || CASE WHEN f.number = '' THEN ''
+		ELSE ', кв. ' || f.number END as flat_address, (
+			SELECT period FROM meter_readings WHERE meter_id = m.id
+			ORDER BY period DESC LIMIT 1
+		) as last_period, (
+			SELECT value FROM meter_readings WHERE meter_id = m.id
+			ORDER BY period DESC LIMIT 1
+		) as last_value
+	FROM meters as m
+	JOIN flats as f ON m.flat_id = f.id
+	JOIN buildings as b ON f.building_id = b.id
+`
+
+func (s *Storage) OrganizationMeters(organizationID int) (
+	ms []entity.Meter, err error) {
+	err = s.db.Select(&ms, metersSelect+`
+		WHERE b.organization_id = $1
+		ORDER BY b.address, length(f.number), f.number, m.type
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationMeter(organizationID int, meterID int) (
+	m entity.Meter, err error) {
+	err = s.db.QueryRowx(metersSelect+`
+		WHERE b.organization_id = $1 AND m.id = $2
+	`, organizationID, meterID).StructScan(&m)
+	return
+}
+
+func (s *Storage) FlatMeters(flatID int) (ms []entity.Meter, err error) {
+	err = s.db.Select(&ms, metersSelect+`
+		WHERE m.flat_id = $1
+		ORDER BY m.type, m.serial_number
+	`, flatID)
+	return
+}
+
+func (s *Storage) FlatMeter(flatID int, meterID int) (m entity.Meter,
+	err error) {
+	err = s.db.QueryRowx(metersSelect+`
+		WHERE m.flat_id = $1 AND m.id = $2
+	`, flatID, meterID).StructScan(&m)
+	return
+}
+
+func (s *Storage) AddMeter(m entity.Meter) (entity.Meter, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO meters (flat_id, type, serial_number, verification_date)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, m.FlatID, m.Type, m.SerialNumber, m.VerificationDate).Scan(&m.ID)
+	return m, err
+}
+
+func (s *Storage) SetMeter(organizationID int, m entity.Meter) (entity.Meter,
+	error) {
+	_, err := s.db.Exec(`
+		UPDATE meters SET flat_id = $1, type = $2, serial_number = $3,
+			verification_date = $4
+		WHERE id = $5 AND flat_id IN (
+			SELECT f.id FROM flats as f
+			JOIN buildings as b ON f.building_id = b.id
+			WHERE b.organization_id = $6
+		)
+	`, m.FlatID, m.Type, m.SerialNumber, m.VerificationDate, m.ID,
+		organizationID)
+	return m, err
+}
+
+func (s *Storage) RemoveOrganizationMeter(organizationID int,
+	meterID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM meters WHERE id = $1 AND flat_id IN (
+			SELECT f.id FROM flats as f
+			JOIN buildings as b ON f.building_id = b.id
+			WHERE b.organization_id = $2
+		)
+	`, meterID, organizationID)
+	return err
+}
+
+// PreviousMeterReadings returns the latest meter readings before the period.
+func (s *Storage) PreviousMeterReadings(meterID int, period time.Time,
+	limit int) (rs []entity.MeterReading, err error) {
+	err = s.db.Select(&rs, `
+		SELECT * FROM meter_readings
+		WHERE meter_id = $1 AND period < $2
+		ORDER BY period DESC
+		LIMIT $3
+	`, meterID, period, limit)
+	return
+}
+
+// SetMeterReading adds the meter reading of the period or replaces the
+// already submitted one.
+func (s *Storage) SetMeterReading(r entity.MeterReading) (entity.MeterReading,
+	error) {
+	err := s.db.QueryRow(`
+		INSERT INTO meter_readings (meter_id, period, value, outlier, owner_id,
+			submitted_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (meter_id, period) DO UPDATE SET value = $3,
+			outlier = $4, owner_id = $5, submitted_at = $6
+		RETURNING id
+	`, r.MeterID, r.Period, r.Value, r.Outlier, r.OwnerID,
+		r.SubmittedAt).Scan(&r.ID)
+	return r, err
+}
+
+// OrganizationMeterReadings returns meter readings of the period with the
+// previous readings for the billing.
+func (s *Storage) OrganizationMeterReadings(organizationID int,
+	period time.Time) (rs []entity.MeterReadingExtended, err error) {
+	err = s.db.Select(&rs, `
+		SELECT mr.*, b.address || CASE WHEN f.number = '' THEN ''
+			ELSE ', кв. ' || f.number END as flat_address,
+			m.type as meter_type, m.serial_number, ow.name as owner_name, (
+				SELECT value FROM meter_readings
+				WHERE meter_id = mr.meter_id AND period < mr.period
+				ORDER BY period DESC LIMIT 1
+			) as previous_value
+		FROM meter_readings as mr
+		JOIN meters as m ON mr.meter_id = m.id
+		JOIN flats as f ON m.flat_id = f.id
+		JOIN buildings as b ON f.building_id = b.id
+		LEFT JOIN owners as ow ON mr.owner_id = ow.id
+		WHERE b.organization_id = $1 AND mr.period = $2
+		ORDER BY b.address, length(f.number), f.number, m.type
+	`, organizationID, period)
+	return
+}
+
+const announcementsSelect = `
+	SELECT a.*, ab.address as building_address, (
+		SELECT count(*) FROM announcement_reads as ar
+		WHERE ar.announcement_id = a.id
+	) as reads_count
+	FROM announcements as a
+	LEFT JOIN buildings as ab ON a.building_id = ab.id
+`
+
+// announcementOwners is a condition of the announcement a being for the
+// owner flat joined by ownerFlatJoin.
+const announcementOwners = `
+	a.organization_id = ow.organization_id AND (a.building_id IS NULL OR
+		a.building_id = owf.building_id AND
+		(a.entrance IS NULL OR a.entrance = owf.entrance))
+`
+
+func (s *Storage) OrganizationAnnouncements(organizationID int) (
+	as []entity.Announcement, err error) {
+	err = s.db.Select(&as, announcementsSelect+`
+		WHERE a.organization_id = $1
+		ORDER BY a.publish_at DESC
+	`, organizationID)
+	return
+}
+
+func (s *Storage) AddAnnouncement(a entity.Announcement) (entity.Announcement,
+	error) {
+	a.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO announcements (organization_id, building_id, entrance,
+			title, text, publish_at, expires_at, push_sms, push_email,
+			created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`, a.OrganizationID, a.BuildingID, a.Entrance, a.Title, a.Text,
+		a.PublishAt, a.ExpiresAt, a.PushSMS, a.PushEmail,
+		a.CreatedAt).Scan(&a.ID)
+	return a, err
+}
+
+// SetAnnouncement sets the announcement. Already pushed announcement is not
+// pushed again.
+func (s *Storage) SetAnnouncement(a entity.Announcement) (entity.Announcement,
+	error) {
+	_, err := s.db.Exec(`
+		UPDATE announcements SET building_id = $1, entrance = $2, title = $3,
+			text = $4, publish_at = $5, expires_at = $6, push_sms = $7,
+			push_email = $8
+		WHERE organization_id = $9 AND id = $10
+	`, a.BuildingID, a.Entrance, a.Title, a.Text, a.PublishAt, a.ExpiresAt,
+		a.PushSMS, a.PushEmail, a.OrganizationID, a.ID)
+	return a, err
+}
+
+func (s *Storage) RemoveOrganizationAnnouncement(organizationID int,
+	announcementID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM announcements WHERE organization_id = $1 AND id = $2
+	`, organizationID, announcementID)
+	return err
+}
+
+// OwnerAnnouncements returns announcements for the owner published at the
+// time with the owner read time.
+func (s *Storage) OwnerAnnouncements(ownerID int, publishedAt time.Time) (
+	as []entity.Announcement, err error) {
+	err = s.db.Select(&as, `
+		SELECT a.*, ab.address as building_address, ar.read_at
+		FROM owners as ow
+		`+ownerFlatJoin+`
+		JOIN announcements as a ON `+announcementOwners+`
+		LEFT JOIN buildings as ab ON a.building_id = ab.id
+		LEFT JOIN announcement_reads as ar ON ar.announcement_id = a.id
+			AND ar.owner_id = ow.id
+		WHERE ow.id = $1 AND a.publish_at <= $2
+			AND (a.expires_at IS NULL OR a.expires_at > $2)
+		ORDER BY a.publish_at DESC
+	`, ownerID, publishedAt)
+	return
+}
+
+// ReadAnnouncements marks announcements read by the owner. Announcements not
+// for the owner and already read ones are skipped.
+func (s *Storage) ReadAnnouncements(ownerID int, announcementIDs []int,
+	readAt time.Time) (int, error) {
+	ids := make(pq.Int64Array, 0, len(announcementIDs))
+	for _, id := range announcementIDs {
+		ids = append(ids, int64(id))
+	}
+
+	res, err := s.db.Exec(`
+		INSERT INTO announcement_reads (announcement_id, owner_id, read_at)
+		SELECT a.id, ow.id, $3
+		FROM owners as ow
+		`+ownerFlatJoin+`
+		JOIN announcements as a ON `+announcementOwners+`
+		WHERE ow.id = $1 AND a.id = ANY($2) AND a.publish_at <= $3
+		ON CONFLICT DO NOTHING
+	`, ownerID, ids, readAt)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// UnpushedAnnouncements returns announcements to push published before the
+// time. Announcements of deleted organizations are skipped.
+func (s *Storage) UnpushedAnnouncements(publishedBefore time.Time) (
+	as []entity.Announcement, err error) {
+	err = s.db.Select(&as, announcementsSelect+`
+		WHERE (a.push_sms OR a.push_email) AND a.pushed_at IS NULL
+			AND a.publish_at <= $1
+			AND (a.expires_at IS NULL OR a.expires_at > $1)
+			AND a.organization_id IN (
+				SELECT id FROM organizations WHERE deleted_at IS NULL
+			)
+		ORDER BY a.publish_at
+	`, publishedBefore)
+	return
+}
+
+// AnnouncementOwners returns owners the announcement is for.
+func (s *Storage) AnnouncementOwners(announcementID int) (
+	os []entity.Owner, err error) {
+	err = s.db.Select(&os, ownersSelect+`
+		JOIN announcements as a ON `+announcementOwners+`
+		WHERE a.id = $1 AND ow.deleted_at IS NULL
+		ORDER BY ow.id
+	`, announcementID)
+	return
+}
+
+func (s *Storage) SetAnnouncementPushedAt(announcementID int,
+	pushedAt time.Time) error {
+	_, err := s.db.Exec(`
+		UPDATE announcements SET pushed_at = $1 WHERE id = $2
+	`, pushedAt, announcementID)
+	return err
+}
+
+const pollsSelect = `
+	SELECT p.*, b.address as building_address
+	FROM polls as p
+	JOIN buildings as b ON p.building_id = b.id
+`
+
+func (s *Storage) OrganizationPolls(organizationID int) (
+	ps []entity.Poll, err error) {
+	err = s.db.Select(&ps, pollsSelect+`
+		WHERE p.organization_id = $1
+		ORDER BY p.starts_at DESC
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationPoll(organizationID int, pollID int) (
+	p entity.Poll, err error) {
+	err = s.db.QueryRowx(pollsSelect+`
+		WHERE p.organization_id = $1 AND p.id = $2
+	`, organizationID, pollID).StructScan(&p)
+	return
+}
+
+func (s *Storage) BuildingPolls(buildingID int) (ps []entity.Poll,
+	err error) {
+	err = s.db.Select(&ps, pollsSelect+`
+		WHERE p.building_id = $1
+		ORDER BY p.starts_at DESC
+	`, buildingID)
+	return
+}
+
+func (s *Storage) AddPoll(p entity.Poll) (entity.Poll, error) {
+	p.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO polls (organization_id, building_id, title, description,
+			weighting, quorum_percent, starts_at, ends_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`, p.OrganizationID, p.BuildingID, p.Title, p.Description, p.Weighting,
+		p.QuorumPercent, p.StartsAt, p.EndsAt, p.CreatedAt).Scan(&p.ID)
+	return p, err
+}
+
+func (s *Storage) RemoveOrganizationPoll(organizationID int,
+	pollID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM polls WHERE organization_id = $1 AND id = $2
+	`, organizationID, pollID)
+	return err
+}
+
+func (s *Storage) PollQuestions(pollID int) ([]entity.PollQuestion, error) {
+	rows, err := s.db.Query(`
+		SELECT id, poll_id, position, text, multiple, options
+		FROM poll_questions WHERE poll_id = $1
+		ORDER BY position, id
+	`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var qs []entity.PollQuestion
+
+	for rows.Next() {
+		var q entity.PollQuestion
+		var os pq.StringArray
+
+		err = rows.Scan(&q.ID, &q.PollID, &q.Position, &q.Text, &q.Multiple,
+			&os)
+		if err != nil {
+			return nil, err
+		}
+
+		q.Options = os
+		qs = append(qs, q)
+	}
+
+	return qs, rows.Err()
+}
+
+// AddPollQuestion appends the question to the poll.
+func (s *Storage) AddPollQuestion(q entity.PollQuestion) (entity.PollQuestion,
+	error) {
+	err := s.db.QueryRow(`
+		INSERT INTO poll_questions (poll_id, position, text, multiple, options)
+		VALUES ($1, (
+			SELECT coalesce(max(position), 0) + 1 FROM poll_questions
+			WHERE poll_id = $1
+		), $2, $3, $4)
+		RETURNING id, position
+	`, q.PollID, q.Text, q.Multiple, pq.Array(q.Options)).Scan(&q.ID,
+		&q.Position)
+	return q, err
+}
+
+func (s *Storage) RemovePollQuestion(pollID int, questionID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM poll_questions WHERE poll_id = $1 AND id = $2
+	`, pollID, questionID)
+	return err
+}
+
+func (s *Storage) PollVotes(pollID int) ([]entity.PollVote, error) {
+	rows, err := s.db.Query(`
+		SELECT v.question_id, v.owner_id, v.options, v.weight, v.voted_at
+		FROM poll_votes as v
+		JOIN poll_questions as q ON v.question_id = q.id
+		WHERE q.poll_id = $1
+		ORDER BY v.voted_at
+	`, pollID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vs []entity.PollVote
+
+	for rows.Next() {
+		var v entity.PollVote
+		var os pq.Int64Array
+
+		err = rows.Scan(&v.QuestionID, &v.OwnerID, &os, &v.Weight,
+			&v.VotedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range os {
+			v.Options = append(v.Options, int(o))
+		}
+		vs = append(vs, v)
+	}
+
+	return vs, rows.Err()
+}
+
+// SetPollVote adds the owner vote on the question or replaces the previous
+// one.
+func (s *Storage) SetPollVote(v entity.PollVote) (entity.PollVote, error) {
+	_, err := s.db.Exec(`
+		INSERT INTO poll_votes (question_id, owner_id, options, weight,
+			voted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (question_id, owner_id) DO UPDATE SET options = $3,
+			weight = $4, voted_at = $5
+	`, v.QuestionID, v.OwnerID, pq.Array(v.Options), v.Weight, v.VotedAt)
+	return v, err
+}
+
+// BuildingOwners returns owners linked to the flats of the building.
+func (s *Storage) BuildingOwners(buildingID int) (os []entity.Owner,
+	err error) {
+	err = s.db.Select(&os, ownersSelect+`
+		WHERE owf.building_id = $1 AND ow.deleted_at IS NULL
+		ORDER BY ow.flat_id, ow.id
+	`, buildingID)
+	return
+}
+
+func (s *Storage) BuildingFlats(buildingID int) (fs []entity.Flat,
+	err error) {
+	err = s.db.Select(&fs, flatsSelect+`
+		WHERE f.building_id = $1
+		ORDER BY length(f.number), f.number
+	`, buildingID)
+	return
+}
+
+// SetCharges adds the flat charges. Charge of the same flat, period and
+// service replaces the previous one.
+func (s *Storage) SetCharges(cs []entity.Charge) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cs {
+		_, err = tx.Exec(`
+			INSERT INTO charges (flat_id, period, service, amount, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (flat_id, period, service) DO UPDATE SET
+				amount = $4, created_at = $5
+		`, c.FlatID, c.Period, c.Service, c.Amount, c.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+// AddPayments adds the flat payments and returns the number of the added
+// ones. Already recorded payments are skipped.
+func (s *Storage) AddPayments(ps []entity.Payment) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var added int
+
+	for _, p := range ps {
+		res, err := tx.Exec(`
+			INSERT INTO payments (flat_id, amount, paid_at, provider,
+				external_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (flat_id, provider, external_id) DO NOTHING
+		`, p.FlatID, p.Amount, p.PaidAt, p.Provider, p.ExternalID,
+			p.CreatedAt)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		added += int(n)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return added, nil
+}
+
+func (s *Storage) FlatCharges(flatID int) (cs []entity.Charge, err error) {
+	err = s.db.Select(&cs, `
+		SELECT c.*, b.address || CASE WHEN f.number = '' THEN ''
+			ELSE ', кв. ' || f.number END as flat_address
+		FROM charges as c
+		JOIN flats as f ON c.flat_id = f.id
+		JOIN buildings as b ON f.building_id = b.id
+		WHERE c.flat_id = $1
+		ORDER BY c.period DESC, c.service
+	`, flatID)
+	return
+}
+
+func (s *Storage) FlatPayments(flatID int) (ps []entity.Payment, err error) {
+	err = s.db.Select(&ps, `
+		SELECT p.*, b.address || CASE WHEN f.number = '' THEN ''
+			ELSE ', кв. ' || f.number END as flat_address
+		FROM payments as p
+		JOIN flats as f ON p.flat_id = f.id
+		JOIN buildings as b ON f.building_id = b.id
+		WHERE p.flat_id = $1
+		ORDER BY p.paid_at DESC
+	`, flatID)
+	return
+}
+
+// OrganizationDebtors returns the organization flats with the debt not less
+// than minDebt ordered from the largest debt.
+func (s *Storage) OrganizationDebtors(organizationID int,
+	minDebt decimal.Money) (
+	ds []entity.Debtor, err error) {
+	err = s.db.Select(&ds, `
+		SELECT f.id as flat_id, b.address || CASE WHEN f.number = '' THEN ''
+			ELSE ', кв. ' || f.number END as flat_address, f.account,
+			c.charged, coalesce(p.paid, 0) as paid,
+			c.charged - coalesce(p.paid, 0) as debt, p.last_paid_at
+		FROM flats as f
+		JOIN buildings as b ON f.building_id = b.id
+		JOIN (
+			SELECT flat_id, sum(amount) as charged FROM charges
+			GROUP BY flat_id
+		) as c ON c.flat_id = f.id
+		LEFT JOIN (
+			SELECT flat_id, sum(amount) as paid, max(paid_at) as last_paid_at
+			FROM payments GROUP BY flat_id
+		) as p ON p.flat_id = f.id
+		WHERE b.organization_id = $1
+			AND c.charged - coalesce(p.paid, 0) > 0
+			AND c.charged - coalesce(p.paid, 0) >= $2
+		ORDER BY debt DESC, b.address, length(f.number), f.number
+	`, organizationID, minDebt)
+	return
+}
+
+func (s *Storage) OrganizationDocumentFolders(organizationID int) (
+	fs []entity.DocumentFolder, err error) {
+	err = s.db.Select(&fs, `
+		SELECT * FROM document_folders WHERE organization_id = $1
+		ORDER BY name
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationDocumentFolder(organizationID int,
+	folderID int) (f entity.DocumentFolder, err error) {
+	err = s.db.QueryRowx(`
+		SELECT * FROM document_folders
+		WHERE organization_id = $1 AND id = $2
+	`, organizationID, folderID).StructScan(&f)
+	return
+}
+
+func (s *Storage) AddDocumentFolder(f entity.DocumentFolder) (
+	entity.DocumentFolder, error) {
+	f.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO document_folders (organization_id, name, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, f.OrganizationID, f.Name, f.CreatedAt).Scan(&f.ID)
+	return f, err
+}
+
+// RemoveOrganizationDocumentFolder removes the folder, documents of the
+// folder are kept without folder.
+func (s *Storage) RemoveOrganizationDocumentFolder(organizationID int,
+	folderID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM document_folders WHERE organization_id = $1 AND id = $2
+	`, organizationID, folderID)
+	return err
+}
+
+// documentsSelect selects documents with the latest version and downloads
+// of all versions.
+const documentsSelect = `
+	SELECT d.*, df.name as folder_name, b.address as building_address,
+		v.id as version_id, v.version, v.file_name, v.content_type, v.size, (
+			SELECT count(*) FROM document_downloads as dd
+			JOIN document_versions as dv ON dd.document_version_id = dv.id
+			WHERE dv.document_id = d.id
+		) as downloads_count, (
+			SELECT count(DISTINCT dd.owner_id) FROM document_downloads as dd
+			JOIN document_versions as dv ON dd.document_version_id = dv.id
+			WHERE dv.document_id = d.id
+		) as downloaders_count
+	FROM documents as d
+	LEFT JOIN document_folders as df ON d.folder_id = df.id
+	LEFT JOIN buildings as b ON d.building_id = b.id
+	JOIN LATERAL (
+		SELECT * FROM document_versions WHERE document_id = d.id
+		ORDER BY version DESC LIMIT 1
+	) as v ON true
+`
+
+func (s *Storage) OrganizationDocuments(organizationID int) (
+	ds []entity.Document, err error) {
+	err = s.db.Select(&ds, documentsSelect+`
+		WHERE d.organization_id = $1
+		ORDER BY df.name NULLS FIRST, d.title
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationDocument(organizationID int, documentID int) (
+	d entity.Document, err error) {
+	err = s.db.QueryRowx(documentsSelect+`
+		WHERE d.organization_id = $1 AND d.id = $2
+	`, organizationID, documentID).StructScan(&d)
+	return
+}
+
+// OwnerDocuments returns documents of the owner organization for all
+// buildings or for the owner flat building.
+func (s *Storage) OwnerDocuments(ownerID int) (ds []entity.Document,
+	err error) {
+	err = s.db.Select(&ds, documentsSelect+`
+		WHERE d.organization_id = (
+			SELECT organization_id FROM owners WHERE id = $1
+		) AND (d.building_id IS NULL OR d.building_id = (
+			SELECT f.building_id FROM owners as ow
+			JOIN flats as f ON ow.flat_id = f.id
+			WHERE ow.id = $1
+		))
+		ORDER BY df.name NULLS FIRST, d.title
+	`, ownerID)
+	return
+}
+
+// AddDocument adds the document with its first version.
+func (s *Storage) AddDocument(d entity.Document, v entity.DocumentVersion) (
+	entity.Document, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return d, err
+	}
+
+	d.CreatedAt = time.Now()
+	d.UpdatedAt = d.CreatedAt
+
+	err = tx.QueryRow(`
+		INSERT INTO documents (organization_id, folder_id, building_id,
+			title, description, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`, d.OrganizationID, d.FolderID, d.BuildingID, d.Title, d.Description,
+		d.CreatedAt, d.UpdatedAt).Scan(&d.ID)
+	if err != nil {
+		tx.Rollback()
+		return d, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO document_versions (document_id, version, attachment_key,
+			file_name, content_type, size, created_at)
+		VALUES ($1, 1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, d.ID, v.AttachmentKey, v.FileName, v.ContentType, v.Size,
+		d.CreatedAt).Scan(&d.VersionID)
+	if err != nil {
+		tx.Rollback()
+		return d, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return d, err
+	}
+
+	d.Version = 1
+	d.FileName = v.FileName
+	d.ContentType = v.ContentType
+	d.Size = v.Size
+
+	return d, nil
+}
+
+func (s *Storage) SetDocument(d entity.Document) (entity.Document, error) {
+	d.UpdatedAt = time.Now()
+	res, err := s.db.Exec(`
+		UPDATE documents SET folder_id = $1, building_id = $2, title = $3,
+			description = $4, updated_at = $5
+		WHERE organization_id = $6 AND id = $7
+	`, d.FolderID, d.BuildingID, d.Title, d.Description, d.UpdatedAt,
+		d.OrganizationID, d.ID)
+	if err != nil {
+		return d, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return d, err
+	}
+	if n == 0 {
+		return d, sql.ErrNoRows
+	}
+	return d, nil
+}
+
+// AddDocumentVersion adds the next version of the document. Returns
+// sql.ErrNoRows if document is not found.
+func (s *Storage) AddDocumentVersion(v entity.DocumentVersion) (
+	entity.DocumentVersion, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return v, err
+	}
+
+	// Document is locked, so concurrently added versions get different
+	// numbers.
+	var documentID int
+
+	err = tx.QueryRow(`
+		SELECT id FROM documents WHERE id = $1 FOR UPDATE
+	`, v.DocumentID).Scan(&documentID)
+	if err != nil {
+		tx.Rollback()
+		return v, err
+	}
+
+	v.CreatedAt = time.Now()
+
+	err = tx.QueryRow(`
+		INSERT INTO document_versions (document_id, version, attachment_key,
+			file_name, content_type, size, created_at)
+		VALUES ($1, (
+			SELECT coalesce(max(version), 0) + 1 FROM document_versions
+			WHERE document_id = $1
+		), $2, $3, $4, $5, $6)
+		RETURNING id, version
+	`, v.DocumentID, v.AttachmentKey, v.FileName, v.ContentType, v.Size,
+		v.CreatedAt).Scan(&v.ID, &v.Version)
+	if err != nil {
+		tx.Rollback()
+		return v, err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE documents SET updated_at = $1 WHERE id = $2
+	`, v.CreatedAt, v.DocumentID)
+	if err != nil {
+		tx.Rollback()
+		return v, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return v, err
+}
+
+const documentVersionsSelect = `
+	SELECT v.*, (
+		SELECT count(*) FROM document_downloads
+		WHERE document_version_id = v.id
+	) as downloads_count, (
+		SELECT count(DISTINCT owner_id) FROM document_downloads
+		WHERE document_version_id = v.id
+	) as downloaders_count
+	FROM document_versions as v
+`
+
+func (s *Storage) DocumentVersions(documentID int) (
+	vs []entity.DocumentVersion, err error) {
+	err = s.db.Select(&vs, documentVersionsSelect+`
+		WHERE v.document_id = $1
+		ORDER BY v.version DESC
+	`, documentID)
+	return
+}
+
+func (s *Storage) DocumentVersion(documentID int, versionID int) (
+	v entity.DocumentVersion, err error) {
+	err = s.db.QueryRowx(documentVersionsSelect+`
+		WHERE v.document_id = $1 AND v.id = $2
+	`, documentID, versionID).StructScan(&v)
+	return
+}
+
+func (s *Storage) AddDocumentDownload(versionID int, ownerID int,
+	downloadedAt time.Time) error {
+	_, err := s.db.Exec(`
+		INSERT INTO document_downloads (document_version_id, owner_id,
+			downloaded_at)
+		VALUES ($1, $2, $3)
+	`, versionID, ownerID, downloadedAt)
+	return err
+}
+
+// RemoveOrganizationDocument removes the document with all its versions and
+// returns attachment keys of the versions.
+func (s *Storage) RemoveOrganizationDocument(organizationID int,
+	documentID int) ([]string, error) {
+
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+
+	err = tx.Select(&keys, `
+		SELECT v.attachment_key FROM document_versions as v
+		JOIN documents as d ON v.document_id = d.id
+		WHERE d.organization_id = $1 AND d.id = $2
+	`, organizationID, documentID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM documents WHERE organization_id = $1 AND id = $2
+	`, organizationID, documentID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// ImportAddressRegistry adds the registry addresses read by the read
+// function until io.EOF or updates the addresses with the same GUID, the
+// last one wins. Addresses are copied to the temporary table first, so the
+// registry is loaded in a single pass. Returns the number of read addresses.
+func (s *Storage) ImportAddressRegistry(
+	read func() (entity.RegistryAddress, error)) (int, error) {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`
+		CREATE TEMP TABLE address_registry_import (
+			line BIGINT NOT NULL,
+			guid TEXT NOT NULL,
+			city TEXT NOT NULL,
+			street_type TEXT NOT NULL,
+			street TEXT NOT NULL,
+			house TEXT NOT NULL,
+			block TEXT NOT NULL,
+			structure TEXT NOT NULL,
+			address TEXT NOT NULL,
+			key TEXT NOT NULL
+		) ON COMMIT DROP
+	`)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("address_registry_import", "line",
+		"guid", "city", "street_type", "street", "house", "block",
+		"structure", "address", "key"))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	n := 0
+
+	for {
+		ra, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return n, err
+		}
+
+		n++
+
+		_, err = stmt.Exec(n, ra.GUID, ra.City, ra.StreetType, ra.Street,
+			ra.House, ra.Block, ra.Structure, ra.Address, ra.Key)
+		if err != nil {
+			stmt.Close()
+			tx.Rollback()
+			return n, err
+		}
+	}
+
+	_, err = stmt.Exec()
+	if err != nil {
+		stmt.Close()
+		tx.Rollback()
+		return n, err
+	}
+
+	err = stmt.Close()
+	if err != nil {
+		tx.Rollback()
+		return n, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO address_registry (guid, city, street_type, street, house,
+			block, structure, address, key)
+		SELECT DISTINCT ON (guid) guid, city, street_type, street, house,
+			block, structure, address, key
+		FROM address_registry_import
+		ORDER BY guid, line DESC
+		ON CONFLICT (guid) DO UPDATE SET city = excluded.city,
+			street_type = excluded.street_type, street = excluded.street,
+			house = excluded.house, block = excluded.block,
+			structure = excluded.structure, address = excluded.address,
+			key = excluded.key
+	`)
+	if err != nil {
+		tx.Rollback()
+		return n, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return n, err
+}
+
+func (s *Storage) AddressRegistrySize() (n int, err error) {
+	err = s.db.QueryRow(`SELECT count(*) FROM address_registry`).Scan(&n)
+	return
+}
+
+func (s *Storage) RegistryAddresses(key string) (
+	ras []entity.RegistryAddress, err error) {
+	err = s.db.Select(&ras, `
+		SELECT * FROM address_registry WHERE key = $1 ORDER BY address
+	`, key)
+	return
+}
+
+// SuggestAddresses returns registry addresses with the key starting with
+// the key prefix.
+func (s *Storage) SuggestAddresses(keyPrefix string, limit int) (
+	ras []entity.RegistryAddress, err error) {
+	err = s.db.Select(&ras, `
+		SELECT * FROM address_registry
+		WHERE key LIKE replace(replace($1, '%', '\%'), '_', '\_') || '%'
+		ORDER BY key, address
+		LIMIT $2
+	`, keyPrefix, limit)
+	return
+}
+
+func (s *Storage) OrganizationLabels(organizationID int) (
+	ls []entity.Label, err error) {
+	err = s.db.Select(&ls, `
+		SELECT * FROM labels WHERE organization_id = $1 ORDER BY name
+	`, organizationID)
+	return
+}
+
+func (s *Storage) AddLabel(l entity.Label) (entity.Label, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO labels (organization_id, name) VALUES ($1, $2)
+		RETURNING id
+	`, l.OrganizationID, l.Name).Scan(&l.ID)
+	return l, err
+}
+
+func (s *Storage) SetLabel(l entity.Label) (entity.Label, error) {
+	_, err := s.db.Exec(`
+		UPDATE labels SET name = $1 WHERE organization_id = $2 AND id = $3
+	`, l.Name, l.OrganizationID, l.ID)
+	return l, err
+}
+
+func (s *Storage) RemoveOrganizationLabel(organizationID int,
+	labelID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM labels WHERE organization_id = $1 AND id = $2
+	`, organizationID, labelID)
+	return err
+}
+
+func (s *Storage) OrganizationCustomFields(organizationID int) (
+	[]entity.CustomField, error) {
+
+	rows, err := s.db.Query(`
+		SELECT id, organization_id, name, type, options
+		FROM custom_fields WHERE organization_id = $1
+		ORDER BY name
+	`, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cfs []entity.CustomField
+
+	for rows.Next() {
+		var cf entity.CustomField
+		var os pq.StringArray
+
+		err = rows.Scan(&cf.ID, &cf.OrganizationID, &cf.Name, &cf.Type, &os)
+		if err != nil {
+			return nil, err
+		}
+
+		cf.Options = os
+		cfs = append(cfs, cf)
+	}
+
+	return cfs, rows.Err()
+}
+
+func (s *Storage) AddCustomField(cf entity.CustomField) (entity.CustomField,
+	error) {
+	err := s.db.QueryRow(`
+		INSERT INTO custom_fields (organization_id, name, type, options)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, cf.OrganizationID, cf.Name, cf.Type, pq.Array(cf.Options)).Scan(&cf.ID)
+	return cf, err
+}
+
+func (s *Storage) SetCustomField(cf entity.CustomField) (entity.CustomField,
+	error) {
+	_, err := s.db.Exec(`
+		UPDATE custom_fields SET name = $1, type = $2, options = $3
+		WHERE organization_id = $4 AND id = $5
+	`, cf.Name, cf.Type, pq.Array(cf.Options), cf.OrganizationID, cf.ID)
+	return cf, err
+}
+
+func (s *Storage) RemoveOrganizationCustomField(organizationID int,
+	customFieldID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM custom_fields WHERE organization_id = $1 AND id = $2
+	`, organizationID, customFieldID)
+	return err
+}
+
+// RequestsLabels returns labels of the requests. Label request ID is
+// returned as map key.
+func (s *Storage) RequestsLabels(requestIDs []int) (
+	map[int][]entity.Label, error) {
+
+	rows, err := s.db.Query(`
+		SELECT rl.request_id, l.id, l.organization_id, l.name
+		FROM request_labels as rl
+		JOIN labels as l ON rl.label_id = l.id
+		WHERE rl.request_id = ANY($1)
+		ORDER BY l.name
+	`, pq.Array(requestIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rls := map[int][]entity.Label{}
+
+	for rows.Next() {
+		var requestID int
+		var l entity.Label
+
+		err = rows.Scan(&requestID, &l.ID, &l.OrganizationID, &l.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		rls[requestID] = append(rls[requestID], l)
+	}
+
+	return rls, rows.Err()
+}
+
+func (s *Storage) RequestsFieldValues(requestIDs []int) (
+	fvs []entity.RequestFieldValue, err error) {
+	err = s.db.Select(&fvs, `
+		SELECT fv.request_id, fv.field_id, cf.name as field_name, fv.value
+		FROM request_field_values as fv
+		JOIN custom_fields as cf ON fv.field_id = cf.id
+		WHERE fv.request_id = ANY($1)
+		ORDER BY cf.name
+	`, pq.Array(requestIDs))
+	return
+}
+
+// SetRequestAttributes replaces labels and custom field values of the
+// request.
+func (s *Storage) SetRequestAttributes(requestID int, labelIDs []int,
+	fvs []entity.RequestFieldValue) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM request_labels WHERE request_id = $1`,
+		requestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, labelID := range labelIDs {
+		_, err = tx.Exec(`
+			INSERT INTO request_labels (request_id, label_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, requestID, labelID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`DELETE FROM request_field_values WHERE request_id = $1`,
+		requestID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, fv := range fvs {
+		_, err = tx.Exec(`
+			INSERT INTO request_field_values (request_id, field_id, value)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (request_id, field_id) DO UPDATE SET value = $3
+		`, requestID, fv.FieldID, fv.Value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+func (s *Storage) OrganizationChecklistTemplates(organizationID int) (
+	[]entity.ChecklistTemplate, error) {
+
+	rows, err := s.db.Query(`
+		SELECT ct.id, ct.organization_id, ct.category_id, c.name, ct.items
+		FROM checklist_templates as ct
+		JOIN categories as c ON ct.category_id = c.id
+		WHERE ct.organization_id = $1
+		ORDER BY c.name
+	`, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cts []entity.ChecklistTemplate
+
+	for rows.Next() {
+		var ct entity.ChecklistTemplate
+		var is pq.StringArray
+
+		err = rows.Scan(&ct.ID, &ct.OrganizationID, &ct.CategoryID,
+			&ct.CategoryName, &is)
+		if err != nil {
+			return nil, err
+		}
+
+		ct.Items = is
+		cts = append(cts, ct)
+	}
+
+	return cts, rows.Err()
+}
+
+// CategoryChecklistTemplateItems returns items of the organization checklist
+// template of the category. It returns sql.ErrNoRows if there is no
+// template.
+func (s *Storage) CategoryChecklistTemplateItems(organizationID int,
+	categoryID int) ([]string, error) {
+	var is pq.StringArray
+	err := s.db.QueryRow(`
+		SELECT items FROM checklist_templates
+		WHERE organization_id = $1 AND category_id = $2
+	`, organizationID, categoryID).Scan(&is)
+	return is, err
+}
+
+func (s *Storage) AddChecklistTemplate(ct entity.ChecklistTemplate) (
+	entity.ChecklistTemplate, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO checklist_templates (organization_id, category_id, items)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, ct.OrganizationID, ct.CategoryID, pq.Array(ct.Items)).Scan(&ct.ID)
+	return ct, err
+}
+
+func (s *Storage) SetChecklistTemplate(ct entity.ChecklistTemplate) (
+	entity.ChecklistTemplate, error) {
+	_, err := s.db.Exec(`
+		UPDATE checklist_templates SET category_id = $1, items = $2
+		WHERE organization_id = $3 AND id = $4
+	`, ct.CategoryID, pq.Array(ct.Items), ct.OrganizationID, ct.ID)
+	return ct, err
+}
+
+func (s *Storage) RemoveOrganizationChecklistTemplate(organizationID int,
+	checklistTemplateID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM checklist_templates WHERE organization_id = $1 AND id = $2
+	`, organizationID, checklistTemplateID)
+	return err
+}
+
+const requestTasksSelect = `
+	SELECT t.*, o.name as operator_name
+	FROM request_tasks as t
+	LEFT JOIN operators as o ON t.operator_id = o.id
+`
+
+// AddRequestTasks appends unassigned tasks with the titles to the request
+// checklist.
+func (s *Storage) AddRequestTasks(requestID int, titles []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, title := range titles {
+		_, err = tx.Exec(`
+			INSERT INTO request_tasks (request_id, position, title, created_at)
+			VALUES ($1, (
+				SELECT coalesce(max(position), 0) + 1 FROM request_tasks
+				WHERE request_id = $1
+			), $2, $3)
+		`, requestID, title, now)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+	}
+
+	return err
+}
+
+// AddRequestTask appends the task to the request checklist.
+func (s *Storage) AddRequestTask(t entity.RequestTask) (entity.RequestTask,
+	error) {
+	t.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO request_tasks
+			(request_id, position, title, operator_id, created_at)
+		VALUES ($1, (
+			SELECT coalesce(max(position), 0) + 1 FROM request_tasks
+			WHERE request_id = $1
+		), $2, $3, $4)
+		RETURNING id, position
+	`, t.RequestID, t.Title, t.OperatorID, t.CreatedAt).Scan(&t.ID,
+		&t.Position)
+	return t, err
+}
+
+func (s *Storage) RequestTask(requestID int, taskID int) (
+	t entity.RequestTask, err error) {
+	err = s.db.Get(&t, requestTasksSelect+`
+		WHERE t.request_id = $1 AND t.id = $2
+	`, requestID, taskID)
+	return
+}
+
+// SetRequestTask sets title, operator and done state of the request task.
+// Done time is kept while task stays done.
+func (s *Storage) SetRequestTask(t entity.RequestTask) error {
+	_, err := s.db.Exec(`
+		UPDATE request_tasks SET
+			title = $1,
+			operator_id = $2,
+			done_at = CASE WHEN $3 THEN coalesce(done_at, $4) END,
+			done = $3
+		WHERE request_id = $5 AND id = $6
+	`, t.Title, t.OperatorID, t.Done, time.Now(), t.RequestID, t.ID)
+	return err
+}
+
+func (s *Storage) RemoveRequestTask(requestID int, taskID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM request_tasks WHERE request_id = $1 AND id = $2
+	`, requestID, taskID)
+	return err
+}
+
+func (s *Storage) RequestsTasks(requestIDs []int) (
+	ts []entity.RequestTask, err error) {
+	err = s.db.Select(&ts, requestTasksSelect+`
+		WHERE t.request_id = ANY($1)
+		ORDER BY t.position, t.id
+	`, pq.Array(requestIDs))
+	return
+}
+
+// OperatorTasks returns undone tasks assigned to the operator.
+func (s *Storage) OperatorTasks(operatorID int) (
+	ts []entity.RequestTask, err error) {
+	err = s.db.Select(&ts, requestTasksSelect+`
+		WHERE t.operator_id = $1 AND NOT t.done
+		ORDER BY t.request_id, t.position
+	`, operatorID)
+	return
+}
+
+const responseTemplatesSelect = `
+	SELECT
+		rt.*,
+		count(u.id) as uses_count,
+		max(u.used_at) as last_used_at
+	FROM response_templates as rt
+	LEFT JOIN response_template_uses as u ON u.response_template_id = rt.id
+`
+
+func (s *Storage) OrganizationResponseTemplates(organizationID int) (
+	rts []entity.ResponseTemplate, err error) {
+	err = s.db.Select(&rts, responseTemplatesSelect+`
+		WHERE rt.organization_id = $1
+		GROUP BY rt.id
+		ORDER BY rt.title
+	`, organizationID)
+	return
+}
+
+func (s *Storage) OrganizationResponseTemplate(organizationID int,
+	responseTemplateID int) (rt entity.ResponseTemplate, err error) {
+	err = s.db.QueryRowx(responseTemplatesSelect+`
+		WHERE rt.organization_id = $1 AND rt.id = $2
+		GROUP BY rt.id
+	`, organizationID, responseTemplateID).StructScan(&rt)
+	return
+}
+
+func (s *Storage) AddResponseTemplate(rt entity.ResponseTemplate) (
+	entity.ResponseTemplate, error) {
+	err := s.db.QueryRow(`
+		INSERT INTO response_templates
+			(organization_id, category_id, title, text)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, rt.OrganizationID, rt.CategoryID, rt.Title, rt.Text).Scan(&rt.ID)
+	return rt, err
+}
+
+func (s *Storage) SetResponseTemplate(rt entity.ResponseTemplate) (
+	entity.ResponseTemplate, error) {
+	res, err := s.db.Exec(`
+		UPDATE response_templates SET category_id = $1, title = $2, text = $3
+		WHERE organization_id = $4 AND id = $5
+	`, rt.CategoryID, rt.Title, rt.Text, rt.OrganizationID, rt.ID)
+	if err != nil {
+		return rt, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return rt, err
+	}
+	if n == 0 {
+		return rt, sql.ErrNoRows
+	}
+	return rt, nil
+}
+
+func (s *Storage) RemoveOrganizationResponseTemplate(organizationID int,
+	responseTemplateID int) error {
+	res, err := s.db.Exec(`
+		DELETE FROM response_templates WHERE organization_id = $1 AND id = $2
+	`, organizationID, responseTemplateID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Storage) AddResponseTemplateUse(u entity.ResponseTemplateUse) error {
+	_, err := s.db.Exec(`
+		INSERT INTO response_template_uses
+			(response_template_id, request_id, role, actor_id, used_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, u.ResponseTemplateID, u.RequestID, u.Role, u.ActorID, time.Now())
+	return err
+}
+
+const workOrdersExtendedSelect = `
+	SELECT
+		wo.*,
+		c.name as contractor_name,
+		c.phone as contractor_phone,
+		c.email as contractor_email,
+		r.text as request_text,
+		ow.phone as owner_phone,
+		` + ownerAddress + ` as owner_address
+	FROM work_orders as wo
+	JOIN contractors as c ON wo.contractor_id = c.id
+	JOIN requests as r ON wo.request_id = r.id
+	LEFT JOIN owners as ow ON r.owner_id = ow.id
+` + requestFlatJoin
+
+// AddWorkOrder adds work order for the operator request to the contractor
+// of the request organization. Returns sql.ErrNoRows if request or
+// contractor is not found.
+func (s *Storage) AddWorkOrder(operatorID int, wo entity.WorkOrder) (
+	entity.WorkOrder, error) {
+	wo.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO work_orders
+			(request_id, contractor_id, scope, due_at, status, token,
+				created_at)
+		SELECT r.id, c.id, $3, $4, $5, $6, $7
+		FROM requests as r
+		JOIN contractors as c ON c.organization_id = r.organization_id
+		WHERE r.id = $1 AND c.id = $2 AND r.operator_id = $8
+		RETURNING id
+	`, wo.RequestID, wo.ContractorID, wo.Scope, wo.DueAt, wo.Status,
+		wo.Token, wo.CreatedAt, operatorID).Scan(&wo.ID)
+	return wo, err
+}
+
+func (s *Storage) WorkOrder(token string) (wo entity.WorkOrderExtended,
+	err error) {
+	err = s.db.QueryRowx(workOrdersExtendedSelect+`
+		WHERE wo.token = $1
+	`, token).StructScan(&wo)
+	return
+}
+
+// SetWorkOrderStatus sets status and comment of not final work order. Token
+// of the work order is expired when it becomes final. Returns sql.ErrNoRows
+// if work order is not found or is final.
+func (s *Storage) SetWorkOrderStatus(token string, status string,
+	comment *string) error {
+	res, err := s.db.Exec(`
+		UPDATE work_orders SET status = $1, comment = $2,
+			completed_at = CASE WHEN $1 = 'completed' THEN $3::timestamptz END,
+			token = CASE WHEN $1 IN ('completed', 'declined') THEN NULL
+				ELSE token END
+		WHERE token = $4 AND status NOT IN ('completed', 'declined')
+	`, status, comment, time.Now(), token)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) OperatorRequestsWorkOrders(operatorID int,
+	requestIDs []int) (wos []entity.WorkOrderExtended, err error) {
+	err = s.db.Select(&wos, workOrdersExtendedSelect+`
+		WHERE r.operator_id = $1 AND r.id = ANY($2)
+		ORDER BY wo.created_at
+	`, operatorID, pq.Array(requestIDs))
+	return
+}
+
+func (s *Storage) OwnerRequestsWorkOrders(ownerID int, requestIDs []int) (
+	wos []entity.WorkOrderExtended, err error) {
+	err = s.db.Select(&wos, workOrdersExtendedSelect+`
+		WHERE `+householdRequest("r", "$1")+`
+			AND r.id = ANY($2)
+		ORDER BY wo.created_at
+	`, ownerID, pq.Array(requestIDs))
+	return
+}
+
+// AddCostItem adds cost item to the operator request. Returns sql.ErrNoRows
+// if request is not found.
+func (s *Storage) AddCostItem(ci entity.CostItem) (entity.CostItem, error) {
+	ci.CreatedAt = time.Now()
+	err := s.db.QueryRow(`
+		INSERT INTO cost_items (request_id, kind, name, operator_id, quantity,
+			unit_price, billable, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, ci.RequestID, ci.Kind, ci.Name, ci.OperatorID, ci.Quantity,
+		ci.UnitPrice, ci.Billable, ci.CreatedAt).Scan(&ci.ID)
+	return ci, err
+}
+
+func (s *Storage) RemoveOperatorCostItem(operatorID int, costItemID int) error {
+	_, err := s.db.Exec(`
+		DELETE FROM cost_items as ci USING requests as r
+		WHERE ci.request_id = r.id AND r.operator_id = $1 AND ci.id = $2
+	`, operatorID, costItemID)
+	return err
+}
+
+func (s *Storage) RemoveRequestCostItem(requestID int, costItemID int) error {
+	res, err := s.db.Exec(`
+		DELETE FROM cost_items WHERE request_id = $1 AND id = $2
+	`, requestID, costItemID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s *Storage) OperatorRequestsCostItems(operatorID int,
+	requestIDs []int) (cis []entity.CostItem, err error) {
+	err = s.db.Select(&cis, `
+		SELECT ci.* FROM cost_items as ci
+		JOIN requests as r ON ci.request_id = r.id
+		WHERE r.operator_id = $1 AND r.id = ANY($2)
+		ORDER BY ci.created_at
+	`, operatorID, pq.Array(requestIDs))
+	return
+}
+
+func (s *Storage) OwnerRequestsCostItems(ownerID int, requestIDs []int) (
+	cis []entity.CostItem, err error) {
+	err = s.db.Select(&cis, `
+		SELECT ci.* FROM cost_items as ci
+		JOIN requests as r ON ci.request_id = r.id
+		WHERE `+householdRequest("r", "$1")+`
+			AND r.id = ANY($2)
+		ORDER BY ci.created_at
+	`, ownerID, pq.Array(requestIDs))
+	return
+}
+
+// OrganizationCostItems returns cost items of the organization requests
+// added in [from, to).
+func (s *Storage) OrganizationCostItems(organizationID int, from time.Time,
+	to time.Time) (cis []entity.CostItemExtended, err error) {
+	err = s.db.Select(&cis, `
+		SELECT
+			ci.*,
+			r.status as request_status,
+			c.name as category_name,
+			op.name as operator_name,
+			coalesce(r.building, `+ownerAddress+`) as owner_address
+		FROM cost_items as ci
+		JOIN requests as r ON ci.request_id = r.id
+		LEFT JOIN categories as c ON r.category_id = c.id
+		LEFT JOIN operators as op ON ci.operator_id = op.id
+		LEFT JOIN owners as ow ON r.owner_id = ow.id
+		`+requestFlatJoin+`
+		WHERE r.organization_id = $1
+			AND ci.created_at >= $2 AND ci.created_at < $3
+		ORDER BY ci.request_id, ci.created_at
+	`, organizationID, from, to)
+	return
+}
+
+func (s *Storage) RequestWorkOrders(requestID int) (
+	wos []entity.WorkOrderExtended, err error) {
+	err = s.db.Select(&wos, workOrdersExtendedSelect+`
+		WHERE wo.request_id = $1
+		ORDER BY wo.created_at
+	`, requestID)
+	return
+}
+
+func (s *Storage) RequestCostItems(requestID int) (
+	cis []entity.CostItem, err error) {
+	err = s.db.Select(&cis, `
+		SELECT * FROM cost_items WHERE request_id = $1 ORDER BY created_at
+	`, requestID)
+	return
+}
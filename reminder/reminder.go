@@ -0,0 +1,116 @@
+package reminder
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/dimuls/swan/entity"
+)
+
+type Storage interface {
+	UnremindedVisits(startsBefore time.Time) (
+		[]entity.VisitSlotExtended, error)
+	SetVisitSlotRemindedAt(visitSlotID int, remindedAt time.Time) error
+}
+
+type SMSSender interface {
+	SendSMS(phone string, msg string) error
+}
+
+const checkPeriod = time.Minute
+
+// Reminder notifies owners and operators about booked visits in advance.
+type Reminder struct {
+	storage   Storage
+	smsSender SMSSender
+	advance   time.Duration
+
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+
+	log *logrus.Entry
+}
+
+func NewReminder(s Storage, ss SMSSender, advance time.Duration) *Reminder {
+	return &Reminder{
+		storage:   s,
+		smsSender: ss,
+		advance:   advance,
+
+		log: logrus.WithField("subsystem", "reminder"),
+	}
+}
+
+func (r *Reminder) Start() error {
+	r.stop = make(chan struct{})
+
+	r.waitGroup.Add(1)
+	go func() {
+		defer r.waitGroup.Done()
+
+		t := time.NewTicker(checkPeriod)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-t.C:
+				r.remind()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (r *Reminder) Stop() {
+	close(r.stop)
+	r.waitGroup.Wait()
+}
+
+func (r *Reminder) remind() {
+	vss, err := r.storage.UnremindedVisits(time.Now().Add(r.advance))
+	if err != nil {
+		r.log.WithError(err).Error(
+			"failed to get unreminded visits from storage")
+		return
+	}
+
+	for _, vs := range vss {
+		l := r.log.WithField("visit_slot_id", vs.ID)
+
+		startsAt := vs.StartsAt.Local().Format("2006-01-02 15:04")
+
+		if vs.OwnerPhone != nil {
+			err = r.smsSender.SendSMS(*vs.OwnerPhone, fmt.Sprintf(
+				"Напоминание: визит специалиста по обращению №%d %s",
+				*vs.RequestID, startsAt))
+			if err != nil {
+				l.WithError(err).Error("failed to send owner reminder SMS")
+			}
+		}
+
+		if vs.OperatorPhone != nil {
+			var address string
+			if vs.OwnerAddress != nil {
+				address = *vs.OwnerAddress
+			}
+			err = r.smsSender.SendSMS(*vs.OperatorPhone, fmt.Sprintf(
+				"Напоминание: визит по обращению №%d %s, адрес: %s",
+				*vs.RequestID, startsAt, address))
+			if err != nil {
+				l.WithError(err).Error("failed to send operator reminder SMS")
+			}
+		}
+
+		err = r.storage.SetVisitSlotRemindedAt(vs.ID, time.Now())
+		if err != nil {
+			l.WithError(err).Error(
+				"failed to set visit slot reminded at in storage")
+		}
+	}
+}
@@ -2,23 +2,57 @@ package swan
 
 import (
 	"errors"
+	"net/url"
+	"time"
 
+	"github.com/dimuls/swan/alarm"
+	"github.com/dimuls/swan/announcer"
+	"github.com/dimuls/swan/attachment"
 	"github.com/dimuls/swan/classifier"
+	"github.com/dimuls/swan/payment"
 	"github.com/dimuls/swan/postgres"
+	"github.com/dimuls/swan/reminder"
 	"github.com/dimuls/swan/web"
 )
 
 type Service struct {
+	alarm     *alarm.Alarm
+	reminder  *reminder.Reminder
+	announcer *announcer.Announcer
 	webServer *web.Server
 }
 
+const (
+	emergencyNotificationInterval = 5 * time.Minute
+	visitReminderAdvance          = 24 * time.Hour
+)
+
 func NewService(
 	postgresStorageURI string,
 	classifierAPIURI string,
 	webServerBindAddr string,
 	webServerDebug bool,
+	webServerPublicBaseURL string,
+	protocolSigningKey string,
+	paymentStub bool,
+	paymentStubKey string,
+	attachmentsDir string,
 ) (*Service, error) {
 
+	u, err := url.Parse(webServerPublicBaseURL)
+	if err != nil {
+		return nil, errors.New("failed to parse web server public base URL: " +
+			err.Error())
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New(
+			"web server public base URL should be absolute")
+	}
+
+	if protocolSigningKey == "" {
+		return nil, errors.New("protocol signing key required")
+	}
+
 	s, err := postgres.NewStorage(postgresStorageURI)
 	if err != nil {
 		return nil, errors.New("failed to create postgres storage: " +
@@ -36,19 +70,76 @@ func NewService(
 	// TODO: implement sms and email senders
 	ds := dummySender{}
 
-	ws := web.NewServer(webServerBindAddr, s, ds, ds, c, webServerDebug)
+	a := alarm.NewAlarm(s, ds, emergencyNotificationInterval)
+
+	r := reminder.NewReminder(s, ds, visitReminderAdvance)
+
+	an := announcer.NewAnnouncer(s, ds, ds)
+
+	// Payments are disabled unless the stub payment provider is explicitly
+	// enabled for the development.
+	var pp web.PaymentProvider
+
+	if paymentStub {
+		pp, err = payment.NewStub([]byte(paymentStubKey))
+		if err != nil {
+			return nil, errors.New("failed to create stub payment provider: " +
+				err.Error())
+		}
+	}
+
+	as, err := attachment.NewFileStorage(attachmentsDir)
+	if err != nil {
+		return nil, errors.New("failed to create attachment storage: " +
+			err.Error())
+	}
+
+	ws := web.NewServer(webServerBindAddr, webServerPublicBaseURL, s, ds, ds,
+		c, webServerDebug, []byte(protocolSigningKey), pp, as)
 
 	return &Service{
+		alarm:     a,
+		reminder:  r,
+		announcer: an,
 		webServer: ws,
 	}, nil
 }
 
 func (s *Service) Start() error {
-	return s.webServer.Start()
+	err := s.alarm.Start()
+	if err != nil {
+		return errors.New("failed to start alarm: " + err.Error())
+	}
+
+	err = s.reminder.Start()
+	if err != nil {
+		s.alarm.Stop()
+		return errors.New("failed to start reminder: " + err.Error())
+	}
+
+	err = s.announcer.Start()
+	if err != nil {
+		s.reminder.Stop()
+		s.alarm.Stop()
+		return errors.New("failed to start announcer: " + err.Error())
+	}
+
+	err = s.webServer.Start()
+	if err != nil {
+		s.announcer.Stop()
+		s.reminder.Stop()
+		s.alarm.Stop()
+		return errors.New("failed to start web server: " + err.Error())
+	}
+
+	return nil
 }
 
 func (s *Service) Stop() {
 	s.webServer.Stop()
+	s.announcer.Stop()
+	s.reminder.Stop()
+	s.alarm.Stop()
 }
 
 type dummySender struct{}
@@ -0,0 +1,115 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/address"
+	"github.com/dimuls/swan/entity"
+)
+
+// addressSuggestionsLimit is the maximum number of the address suggestions.
+const addressSuggestionsLimit = 10
+
+// normalizeBuildingAddress returns canonical building address. Address is
+// looked up in the addresses registry if it is imported, otherwise the
+// parsed address is formatted.
+func (s *Server) normalizeBuildingAddress(a string) (string, error) {
+	pa, err := address.Parse(a)
+	if err != nil {
+		return a, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse address: "+err.Error())
+	}
+
+	n, err := s.storage.AddressRegistrySize()
+	if err != nil {
+		return a, errors.New(
+			"failed to get address registry size from storage: " +
+				err.Error())
+	}
+
+	if n == 0 {
+		return pa.Building(), nil
+	}
+
+	ras, err := s.storage.RegistryAddresses(pa.Key())
+	if err != nil {
+		return a, errors.New(
+			"failed to get registry addresses from storage: " + err.Error())
+	}
+
+	var found []entity.RegistryAddress
+	for _, ra := range ras {
+		if pa.City == "" || ra.City == pa.City {
+			found = append(found, ra)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return a, echo.NewHTTPError(http.StatusBadRequest,
+			"address not found in registry")
+	case 1:
+		return found[0].Address, nil
+	default:
+		return a, echo.NewHTTPError(http.StatusBadRequest,
+			"ambiguous address, specify city")
+	}
+}
+
+// suggestAddresses returns registry addresses matching partially entered
+// address.
+func (s *Server) suggestAddresses(q string) ([]entity.RegistryAddress,
+	error) {
+
+	k := address.SearchKey(q)
+	if k == "" {
+		return []entity.RegistryAddress{}, nil
+	}
+
+	ras, err := s.storage.SuggestAddresses(k, addressSuggestionsLimit)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get address suggestions from storage: " + err.Error())
+	}
+
+	if ras == nil {
+		ras = []entity.RegistryAddress{}
+	}
+
+	return ras, nil
+}
+
+// importAddressRegistry reads addresses registry in CSV format and imports
+// it to the storage while reading.
+func (s *Server) importAddressRegistry(r io.Reader) (int, error) {
+	rr, err := address.NewRegistryReader(r)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to read address registry: "+err.Error())
+	}
+
+	var readErr error
+
+	n, err := s.storage.ImportAddressRegistry(
+		func() (entity.RegistryAddress, error) {
+			ra, err := rr.Read()
+			if err != nil && err != io.EOF {
+				readErr = err
+			}
+			return ra, err
+		})
+	if readErr != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to read address registry: "+readErr.Error())
+	}
+	if err != nil {
+		return 0, errors.New(
+			"failed to import address registry to storage: " + err.Error())
+	}
+
+	return n, nil
+}
@@ -0,0 +1,153 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+)
+
+// bindAnnouncement binds and validates announcement. Zero building and
+// entrance from the form mean the whole organization and the whole building.
+// Announcement without publishing time is published immediately.
+func bindAnnouncement(c echo.Context) (entity.Announcement, error) {
+	var a entity.Announcement
+
+	err := c.Bind(&a)
+	if err != nil {
+		return a, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind announcement: "+err.Error())
+	}
+
+	if a.BuildingID != nil && *a.BuildingID == 0 {
+		a.BuildingID = nil
+	}
+	if a.Entrance != nil && *a.Entrance == 0 {
+		a.Entrance = nil
+	}
+
+	if a.PublishAtStr != "" {
+		a.PublishAt, err = time.ParseInLocation("2006-01-02T15:04",
+			a.PublishAtStr, time.Local)
+		if err != nil {
+			return a, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse publishing time: "+err.Error())
+		}
+	}
+	if a.PublishAt.IsZero() {
+		a.PublishAt = time.Now()
+	}
+
+	if a.ExpiresAtStr != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", a.ExpiresAtStr,
+			time.Local)
+		if err != nil {
+			return a, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse expiry time: "+err.Error())
+		}
+		a.ExpiresAt = &t
+	}
+
+	a.Title = strings.TrimSpace(a.Title)
+	a.Text = strings.TrimSpace(a.Text)
+
+	err = a.Validate()
+	if err != nil {
+		return a, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate announcement: "+err.Error())
+	}
+
+	return a, nil
+}
+
+// validateAnnouncementBuilding checks that the announcement building is of
+// the organization and has the announcement entrance.
+func (s *Server) validateAnnouncementBuilding(organizationID int,
+	a entity.Announcement) error {
+
+	if a.BuildingID == nil {
+		return nil
+	}
+
+	b, err := s.storage.OrganizationBuilding(organizationID, *a.BuildingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"building not found")
+		}
+		return errors.New(
+			"failed to get organization building from storage: " +
+				err.Error())
+	}
+
+	if a.Entrance != nil && (*a.Entrance < 1 || *a.Entrance > b.Entrances) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"entrance out of building entrances")
+	}
+
+	return nil
+}
+
+// ownerAnnouncements returns the owner announcement feed. Unread
+// announcements are marked read, so they are shown as new only once.
+func (s *Server) ownerAnnouncements(ownerID int) ([]entity.Announcement,
+	error) {
+
+	now := time.Now()
+
+	as, err := s.storage.OwnerAnnouncements(ownerID, now)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get owner announcements from storage: " + err.Error())
+	}
+
+	var unread []int
+	for _, a := range as {
+		if a.ReadAt == nil {
+			unread = append(unread, a.ID)
+		}
+	}
+
+	if len(unread) > 0 {
+		_, err = s.storage.ReadAnnouncements(ownerID, unread, now)
+		if err != nil {
+			s.log.WithError(err).WithField("owner_id", ownerID).
+				Error("failed to read announcements in storage")
+		}
+	}
+
+	return as, nil
+}
+
+// readAnnouncement marks the announcement of the owner feed read.
+func (s *Server) readAnnouncement(ownerID int, announcementID int) error {
+	now := time.Now()
+
+	as, err := s.storage.OwnerAnnouncements(ownerID, now)
+	if err != nil {
+		return errors.New(
+			"failed to get owner announcements from storage: " + err.Error())
+	}
+
+	for _, a := range as {
+		if a.ID != announcementID {
+			continue
+		}
+		if a.ReadAt != nil {
+			return nil
+		}
+		_, err = s.storage.ReadAnnouncements(ownerID, []int{a.ID}, now)
+		if err != nil {
+			return errors.New("failed to read announcement in storage: " +
+				err.Error())
+		}
+		return nil
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound, "announcement not found")
+}
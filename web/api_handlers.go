@@ -12,7 +12,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/priority"
 	"github.com/dimuls/swan/entity/role"
+	"github.com/dimuls/swan/entity/sorting"
+	"github.com/dimuls/swan/entity/status"
 )
 
 type loginData struct {
@@ -112,6 +115,7 @@ func (s *Server) postAPILogin(c echo.Context) error {
 		sess.Values["role"] = role.Operator
 		sess.Values["login"] = et.Phone
 		sess.Values["operator_id"] = et.ID
+		sess.Values["organization_id"] = et.OrganizationID
 
 	case entity.Owner:
 		if et.PasswordHash == nil {
@@ -477,6 +481,154 @@ func (s *Server) getAPICategorySamplesClassifierTraining(c echo.Context) error {
 	return c.JSON(http.StatusOK, training)
 }
 
+func (s *Server) getAPIPriorityRules(c echo.Context) error {
+	prs, err := s.storage.PriorityRules()
+	if err != nil {
+		return errors.New("failed to get priority rules from storage: " +
+			err.Error())
+	}
+
+	if prs == nil {
+		prs = []entity.PriorityRule{}
+	}
+
+	return c.JSON(http.StatusOK, prs)
+}
+
+func (s *Server) postAPIPriorityRules(c echo.Context) error {
+	var pr entity.PriorityRule
+
+	err := c.Bind(&pr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind priority rule: "+err.Error())
+	}
+
+	err = pr.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate priority rule: "+err.Error())
+	}
+
+	pr, err = s.storage.AddPriorityRule(pr)
+	if err != nil {
+		return errors.New("failed to add priority rule to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pr)
+}
+
+func (s *Server) putAPIPriorityRule(c echo.Context) error {
+	priorityRuleID, err := strconv.Atoi(c.Param("priority_rule_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse priority_rule_id: "+err.Error())
+	}
+
+	var pr entity.PriorityRule
+
+	err = c.Bind(&pr)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind priority rule: "+err.Error())
+	}
+
+	err = pr.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate priority rule: "+err.Error())
+	}
+
+	pr.ID = priorityRuleID
+
+	pr, err = s.storage.SetPriorityRule(pr)
+	if err != nil {
+		return errors.New("failed to set priority rule in storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, pr)
+}
+
+func (s *Server) deleteAPIPriorityRule(c echo.Context) error {
+	priorityRuleID, err := strconv.Atoi(c.Param("priority_rule_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse priority_rule_id: "+err.Error())
+	}
+
+	err = s.storage.RemovePriorityRule(priorityRuleID)
+	if err != nil {
+		return errors.New("failed to remove priority rule from storage: " +
+			err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIAdminRequests(c echo.Context) error {
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.requests(f)
+	if err != nil {
+		return err
+	}
+
+	if cursor != "" {
+		c.Response().Header().Set(nextCursorHeader, cursor)
+	}
+
+	if rs == nil {
+		rs = []entity.RequestExtended{}
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) getAPIAdminRequestsStats(c echo.Context) error {
+	oss, err := s.storage.OrganizationsStats()
+	if err != nil {
+		return errors.New("failed to get organizations stats from storage: " +
+			err.Error())
+	}
+
+	if oss == nil {
+		oss = []entity.OrganizationStats{}
+	}
+
+	return c.JSON(http.StatusOK, oss)
+}
+
+func (s *Server) getAPIAdminRequestsCategoryStats(c echo.Context) error {
+	var organizationID *int
+
+	if id := c.QueryParam("organization_id"); id != "" {
+		oid, err := strconv.Atoi(id)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse organization_id: "+err.Error())
+		}
+		organizationID = &oid
+	}
+
+	css, err := s.storage.CategoriesStats(organizationID)
+	if err != nil {
+		return errors.New("failed to get categories stats from storage: " +
+			err.Error())
+	}
+
+	if css == nil {
+		css = []entity.CategoryStats{}
+	}
+
+	return c.JSON(http.StatusOK, css)
+}
+
 func (s *Server) getAPIOrganizations(c echo.Context) error {
 	os, err := s.storage.Organizations()
 	if err != nil {
@@ -561,6 +713,36 @@ func (s *Server) deleteAPIOrganization(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
+func (s *Server) getAPIDeletedOrganizations(c echo.Context) error {
+	os, err := s.storage.DeletedOrganizations()
+	if err != nil {
+		return errors.New(
+			"failed to get deleted organizations from storage: " +
+				err.Error())
+	}
+
+	if os == nil {
+		os = []entity.Organization{}
+	}
+
+	return c.JSON(http.StatusOK, os)
+}
+
+func (s *Server) postAPIOrganizationRestore(c echo.Context) error {
+	organizationID, err := strconv.Atoi(c.Param("organization_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse organization_id: "+err.Error())
+	}
+
+	err = s.restoreOrganization(organizationID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
 func (s *Server) getAPIOperators(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
@@ -688,7 +870,7 @@ func (s *Server) deleteAPIOperator(c echo.Context) error {
 	return c.NoContent(http.StatusOK)
 }
 
-func (s *Server) getAPIOwners(c echo.Context) error {
+func (s *Server) getAPIDeletedOperators(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -700,16 +882,21 @@ func (s *Server) getAPIOwners(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	os, err := s.storage.OrganizationOwners(organizationID)
+	os, err := s.storage.OrganizationDeletedOperators(organizationID)
 	if err != nil {
-		return errors.New("failed to get organization owners from storage: " +
-			err.Error())
+		return errors.New(
+			"failed to get organization deleted operators from storage: " +
+				err.Error())
+	}
+
+	if os == nil {
+		os = []entity.Operator{}
 	}
 
 	return c.JSON(http.StatusOK, os)
 }
 
-func (s *Server) postAPIOwners(c echo.Context) error {
+func (s *Server) postAPIOperatorRestore(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -721,31 +908,39 @@ func (s *Server) postAPIOwners(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	var o entity.Owner
-
-	err = c.Bind(&o)
+	operatorID, err := strconv.Atoi(c.Param("operator_id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to bind owner: "+err.Error())
+			"failed to parse operator_id: "+err.Error())
 	}
 
-	err = o.Validate()
+	err = s.restoreOperator(organizationID, operatorID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to validate owner: "+err.Error())
+		return err
 	}
 
-	o.OrganizationID = organizationID
+	return c.NoContent(http.StatusOK)
+}
 
-	o, err = s.storage.AddOwner(o)
+func (s *Server) getAPIAddresses(c echo.Context) error {
+	ras, err := s.suggestAddresses(c.QueryParam("q"))
 	if err != nil {
-		return errors.New("failed to add owner to storage: " + err.Error())
+		return err
 	}
 
-	return c.JSON(http.StatusOK, o)
+	return c.JSON(http.StatusOK, ras)
 }
 
-func (s *Server) putAPIOwner(c echo.Context) error {
+func (s *Server) postAPIAddressRegistry(c echo.Context) error {
+	_, err := s.importAddressRegistry(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIBuildings(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -757,38 +952,49 @@ func (s *Server) putAPIOwner(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	ownerID, err := strconv.Atoi(c.Param("owner_id"))
+	bs, err := s.storage.OrganizationBuildings(organizationID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to parse owner_id: "+err.Error())
+		return errors.New(
+			"failed to get organization buildings from storage: " +
+				err.Error())
 	}
 
-	var o entity.Owner
+	if bs == nil {
+		bs = []entity.Building{}
+	}
 
-	err = c.Bind(&o)
+	return c.JSON(http.StatusOK, bs)
+}
+
+func (s *Server) postAPIBuildings(c echo.Context) error {
+	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to bind owner: "+err.Error())
+			"failed to get session")
 	}
 
-	err = o.Validate()
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	b, err := s.bindBuilding(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to validate owner: "+err.Error())
+		return err
 	}
 
-	o.ID = ownerID
-	o.OrganizationID = organizationID
+	b.OrganizationID = organizationID
 
-	o, err = s.storage.SetOwner(o)
+	b, err = s.storage.AddBuilding(b)
 	if err != nil {
-		return errors.New("failed to set owner to storage: " + err.Error())
+		return errors.New("failed to add building to storage: " +
+			err.Error())
 	}
 
-	return c.JSON(http.StatusOK, o)
+	return c.JSON(http.StatusOK, b)
 }
 
-func (s *Server) deleteAPIOwner(c echo.Context) error {
+func (s *Server) putAPIBuilding(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -800,151 +1006,3599 @@ func (s *Server) deleteAPIOwner(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	ownerID, err := strconv.Atoi(c.Param("owner_id"))
+	buildingID, err := strconv.Atoi(c.Param("building_id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to parse owner_id: "+err.Error())
+			"failed to parse building_id: "+err.Error())
 	}
 
-	err = s.storage.RemoveOrganizationOwner(organizationID, ownerID)
+	b, err := s.bindBuilding(c)
 	if err != nil {
-		return errors.New(
-			"failed to remove organization owner from storage: " + err.Error())
+		return err
 	}
 
-	return c.NoContent(http.StatusOK)
+	b.ID = buildingID
+	b.OrganizationID = organizationID
+
+	b, err = s.storage.SetBuilding(b)
+	if err != nil {
+		return errors.New("failed to set building in storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, b)
 }
 
-func (s *Server) getAPIOperatorsRequests(c echo.Context) error {
+func (s *Server) deleteAPIBuilding(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	operatorID, ok := sess.Values["operator_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get operator ID from session")
+		return errors.New("failed to get organization ID from session")
 	}
 
-	rs, err := s.storage.OperatorRequests(operatorID)
+	buildingID, err := strconv.Atoi(c.Param("building_id"))
 	if err != nil {
-		return errors.New("failed to get operator requests from storage: " +
-			err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse building_id: "+err.Error())
 	}
 
-	return c.JSON(http.StatusOK, rs)
+	err = s.removeBuilding(organizationID, buildingID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
 }
 
-func (s *Server) putAPIOperatorsRequest(c echo.Context) error {
+func (s *Server) getAPIFlats(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	operatorID, ok := sess.Values["operator_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get operator ID from session")
-	}
-
-	var r entity.Request
-
-	err = c.Bind(&r)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to bind request: "+err.Error())
+		return errors.New("failed to get organization ID from session")
 	}
 
-	err = r.Validate()
+	fs, err := s.storage.OrganizationFlats(organizationID)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to validate request: "+err.Error())
+		return errors.New("failed to get organization flats from storage: " +
+			err.Error())
 	}
 
-	r, err = s.storage.SetOperatorRequest(operatorID, r)
-	if err != nil {
-		return errors.New("failed to set operator request in storage: " +
-			err.Error())
+	if fs == nil {
+		fs = []entity.Flat{}
 	}
 
-	return c.JSON(http.StatusOK, r)
+	return c.JSON(http.StatusOK, fs)
 }
 
-func (s *Server) getAPIOwnersRequests(c echo.Context) error {
+func (s *Server) postAPIFlats(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	ownerID, ok := sess.Values["owner_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get owner ID from session")
+		return errors.New("failed to get organization ID from session")
 	}
 
-	rs, err := s.storage.OwnerRequests(ownerID)
+	f, err := bindFlat(c)
 	if err != nil {
-		return errors.New("failed to get owner requests: " + err.Error())
+		return err
 	}
 
-	return c.JSON(http.StatusOK, rs)
+	err = s.validateFlatBuilding(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	f, err = s.addFlat(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, f)
 }
 
-func (s *Server) postAPIOwnersRequests(c echo.Context) error {
+func (s *Server) putAPIFlat(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	ownerID, ok := sess.Values["owner_id"].(int)
-	if !ok {
-		return errors.New("failed to get owner ID from session")
-	}
-
 	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	var r entity.Request
-
-	err = c.Bind(&r)
+	flatID, err := strconv.Atoi(c.Param("flat_id"))
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			errors.New("failed to bind request: "+err.Error()))
+			"failed to parse flat_id: "+err.Error())
 	}
 
-	err = r.Validate()
+	f, err := bindFlat(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			errors.New("failed to validate request: "+err.Error()))
+		return err
 	}
 
-	r.OrganizationID = organizationID
-	r.OwnerID = ownerID
+	f.ID = flatID
 
-	categoryID, err := s.classifier.Classify(r.Text)
+	err = s.validateFlatBuilding(organizationID, f)
 	if err != nil {
-		s.log.WithError(err).Error("failed to classify category")
-	} else {
-		r.CategoryID = &categoryID
+		return err
 	}
 
-	o, err := s.storage.FindOrganizationOperator(
-		organizationID, categoryID)
+	f, err = s.setFlat(organizationID, f)
 	if err != nil {
-		s.log.WithError(err).Error(
-			"failed to find organization operator for request: " + err.Error())
-	} else {
-		r.OperatorID = &o.ID
+		return err
 	}
 
-	r, err = s.storage.AddRequest(r)
+	return c.JSON(http.StatusOK, f)
+}
+
+func (s *Server) deleteAPIFlat(c echo.Context) error {
+	sess, err := session.Get("session", c)
 	if err != nil {
-		return errors.New("failed to add request to storage: " + err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	flatID, err := strconv.Atoi(c.Param("flat_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse flat_id: "+err.Error())
+	}
+
+	err = s.removeFlat(organizationID, flatID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIMeters(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ms, err := s.storage.OrganizationMeters(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization meters from storage: " +
+			err.Error())
+	}
+
+	if ms == nil {
+		ms = []entity.Meter{}
+	}
+
+	return c.JSON(http.StatusOK, ms)
+}
+
+func (s *Server) postAPIMeters(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	m, err := bindMeter(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateMeterFlat(organizationID, m)
+	if err != nil {
+		return err
+	}
+
+	m, err = s.storage.AddMeter(m)
+	if err != nil {
+		return errors.New("failed to add meter to storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+func (s *Server) putAPIMeter(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	meterID, err := strconv.Atoi(c.Param("meter_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse meter_id: "+err.Error())
+	}
+
+	m, err := bindMeter(c)
+	if err != nil {
+		return err
+	}
+
+	m.ID = meterID
+
+	err = s.validateMeterFlat(organizationID, m)
+	if err != nil {
+		return err
+	}
+
+	m, err = s.storage.SetMeter(organizationID, m)
+	if err != nil {
+		return errors.New("failed to set meter in storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+func (s *Server) deleteAPIMeter(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	meterID, err := strconv.Atoi(c.Param("meter_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse meter_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationMeter(organizationID, meterID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization meter from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIAnnouncements(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	as, err := s.storage.OrganizationAnnouncements(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization announcements from storage: " +
+				err.Error())
+	}
+
+	if as == nil {
+		as = []entity.Announcement{}
+	}
+
+	return c.JSON(http.StatusOK, as)
+}
+
+func (s *Server) postAPIAnnouncements(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	a, err := bindAnnouncement(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateAnnouncementBuilding(organizationID, a)
+	if err != nil {
+		return err
+	}
+
+	a.OrganizationID = organizationID
+
+	a, err = s.storage.AddAnnouncement(a)
+	if err != nil {
+		return errors.New("failed to add announcement to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, a)
+}
+
+func (s *Server) putAPIAnnouncement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	announcementID, err := strconv.Atoi(c.Param("announcement_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse announcement_id: "+err.Error())
+	}
+
+	a, err := bindAnnouncement(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateAnnouncementBuilding(organizationID, a)
+	if err != nil {
+		return err
+	}
+
+	a.ID = announcementID
+	a.OrganizationID = organizationID
+
+	a, err = s.storage.SetAnnouncement(a)
+	if err != nil {
+		return errors.New("failed to set announcement in storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, a)
+}
+
+func (s *Server) deleteAPIAnnouncement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	announcementID, err := strconv.Atoi(c.Param("announcement_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse announcement_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationAnnouncement(organizationID,
+		announcementID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization announcement from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIPolls(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ps, err := s.storage.OrganizationPolls(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization polls from storage: " + err.Error())
+	}
+
+	if ps == nil {
+		ps = []entity.Poll{}
+	}
+
+	return c.JSON(http.StatusOK, ps)
+}
+
+func (s *Server) postAPIPolls(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	p, err := bindPoll(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validatePollBuilding(organizationID, p)
+	if err != nil {
+		return err
+	}
+
+	p.OrganizationID = organizationID
+
+	p, err = s.storage.AddPoll(p)
+	if err != nil {
+		return errors.New("failed to add poll to storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusCreated, p)
+}
+
+func (s *Server) deleteAPIPoll(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationPoll(organizationID, pollID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization poll from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) postAPIPollQuestions(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	q, err := bindPollQuestion(c)
+	if err != nil {
+		return err
+	}
+
+	q.PollID = pollID
+
+	q, err = s.addPollQuestion(organizationID, q)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusCreated, q)
+}
+
+func (s *Server) deleteAPIPollQuestion(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	questionID, err := strconv.Atoi(c.Param("question_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse question_id: "+err.Error())
+	}
+
+	err = s.removePollQuestion(organizationID, pollID, questionID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIPollResults(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	pr, err := s.organizationPollResults(organizationID, pollID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, pr)
+}
+
+func (s *Server) getAPIPollProtocol(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	pr, err := s.organizationPollResults(organizationID, pollID)
+	if err != nil {
+		return err
+	}
+
+	protocol, err := s.pollProtocol(pr)
+	if err != nil {
+		return err
+	}
+
+	return exportPollProtocol(c, pollID, protocol)
+}
+
+func (s *Server) postAPIPollsVerifyProtocol(c echo.Context) error {
+	valid, err := s.verifyProtocol(c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"valid": valid,
+	})
+}
+
+func (s *Server) postAPICharges(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	_, err = s.importCharges(organizationID, c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) postAPIPayments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	_, err = s.importPayments(organizationID, c.Request().Body)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIDebtors(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ds, err := s.organizationDebtors(organizationID,
+		c.QueryParam("min_debt"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ds)
+}
+
+func (s *Server) getAPIDebtorsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ds, err := s.organizationDebtors(organizationID,
+		c.QueryParam("min_debt"))
+	if err != nil {
+		return err
+	}
+
+	return exportDebtors(c, ds)
+}
+
+func (s *Server) getAPIDocumentFolders(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	fs, err := s.storage.OrganizationDocumentFolders(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization document folders from storage: " +
+				err.Error())
+	}
+
+	if fs == nil {
+		fs = []entity.DocumentFolder{}
+	}
+
+	return c.JSON(http.StatusOK, fs)
+}
+
+func (s *Server) postAPIDocumentFolders(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := bindDocumentFolder(c)
+	if err != nil {
+		return err
+	}
+
+	f.OrganizationID = organizationID
+
+	f, err = s.storage.AddDocumentFolder(f)
+	if err != nil {
+		return errors.New("failed to add document folder to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, f)
+}
+
+func (s *Server) deleteAPIDocumentFolder(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	folderID, err := strconv.Atoi(c.Param("folder_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse folder_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationDocumentFolder(organizationID, folderID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization document folder from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIDocuments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ds, err := s.storage.OrganizationDocuments(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization documents from storage: " +
+				err.Error())
+	}
+
+	if ds == nil {
+		ds = []entity.Document{}
+	}
+
+	return c.JSON(http.StatusOK, ds)
+}
+
+func (s *Server) postAPIDocuments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	d, err := s.addDocument(c, organizationID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+func (s *Server) getAPIDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	d, err := s.organizationDocument(organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+func (s *Server) putAPIDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	_, err = s.setDocument(c, organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	d, err := s.organizationDocument(organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, d)
+}
+
+func (s *Server) deleteAPIDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	err = s.removeDocument(organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIDocumentVersions(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	vs, err := s.organizationDocumentVersions(organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, vs)
+}
+
+func (s *Server) postAPIDocumentVersions(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	v, err := s.addDocumentVersion(c, organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+func (s *Server) getAPIDocumentVersionDownload(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	versionID, err := strconv.Atoi(c.Param("version_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse version_id: "+err.Error())
+	}
+
+	v, err := s.organizationDocumentVersion(organizationID, documentID,
+		versionID)
+	if err != nil {
+		return err
+	}
+
+	return s.downloadDocumentVersion(c, v)
+}
+
+func (s *Server) getAPIMeterReadings(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	_, rs, err := s.organizationMeterReadings(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) getAPIMeterReadingsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, rs, err := s.organizationMeterReadings(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return exportMeterReadings(c, from, rs)
+}
+
+func (s *Server) getAPIContractors(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cs, err := s.storage.OrganizationContractors(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization contractors from storage: " +
+				err.Error())
+	}
+
+	if cs == nil {
+		cs = []entity.Contractor{}
+	}
+
+	return c.JSON(http.StatusOK, cs)
+}
+
+func (s *Server) postAPIContractors(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var ct entity.Contractor
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind contractor: "+err.Error())
+	}
+
+	err = ct.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate contractor: "+err.Error())
+	}
+
+	ct.OrganizationID = organizationID
+
+	ct, err = s.storage.AddContractor(ct)
+	if err != nil {
+		return errors.New("failed to add contractor to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ct)
+}
+
+func (s *Server) putAPIContractor(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	contractorID, err := strconv.Atoi(c.Param("contractor_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse contractor_id: "+err.Error())
+	}
+
+	var ct entity.Contractor
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind contractor: "+err.Error())
+	}
+
+	err = ct.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate contractor: "+err.Error())
+	}
+
+	ct.ID = contractorID
+	ct.OrganizationID = organizationID
+
+	ct, err = s.storage.SetContractor(ct)
+	if err != nil {
+		return errors.New("failed to set contractor to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ct)
+}
+
+func (s *Server) deleteAPIContractor(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	contractorID, err := strconv.Atoi(c.Param("contractor_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse contractor_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationContractor(organizationID, contractorID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization contractor from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIRequestNotes(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	_, err = s.staffRequest(rl, actorID, organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	rns, err := s.storage.RequestNotes(requestID)
+	if err != nil {
+		return errors.New("failed to get request notes from storage: " +
+			err.Error())
+	}
+
+	if rns == nil {
+		rns = []entity.RequestNote{}
+	}
+
+	return c.JSON(http.StatusOK, rns)
+}
+
+func (s *Server) postAPIStaffRequests(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	r, err = s.addStaffRequest(rl, actorID, organizationID, r)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (s *Server) postAPIRequestNotes(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var n entity.RequestNote
+
+	err = c.Bind(&n)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request note: "+err.Error())
+	}
+
+	n.RequestID = requestID
+
+	n, err = s.addRequestNote(rl, actorID, organizationID, n)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, n)
+}
+
+func (s *Server) getAPIResponseTemplates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	rts, err := s.storage.OrganizationResponseTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization response templates from storage: " +
+				err.Error())
+	}
+
+	if categoryID := c.QueryParam("category_id"); categoryID != "" {
+		id, err := strconv.Atoi(categoryID)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse category_id: "+err.Error())
+		}
+		var frts []entity.ResponseTemplate
+		for _, rt := range rts {
+			if rt.Fits(&id) {
+				frts = append(frts, rt)
+			}
+		}
+		rts = frts
+	}
+
+	if rts == nil {
+		rts = []entity.ResponseTemplate{}
+	}
+
+	return c.JSON(http.StatusOK, rts)
+}
+
+func (s *Server) postAPIResponseTemplates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	rt, err := bindResponseTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	rt.OrganizationID = organizationID
+
+	rt, err = s.storage.AddResponseTemplate(rt)
+	if err != nil {
+		return errors.New("failed to add response template to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, rt)
+}
+
+func (s *Server) putAPIResponseTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	responseTemplateID, err := strconv.Atoi(c.Param("response_template_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse response_template_id: "+err.Error())
+	}
+
+	rt, err := bindResponseTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	rt.ID = responseTemplateID
+	rt.OrganizationID = organizationID
+
+	rt, err = s.setResponseTemplate(rt)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, rt)
+}
+
+func (s *Server) deleteAPIResponseTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	responseTemplateID, err := strconv.Atoi(c.Param("response_template_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse response_template_id: "+err.Error())
+	}
+
+	err = s.removeResponseTemplate(organizationID, responseTemplateID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPILabels(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	if ls == nil {
+		ls = []entity.Label{}
+	}
+
+	return c.JSON(http.StatusOK, ls)
+}
+
+func (s *Server) postAPILabels(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	l, err := bindLabel(c)
+	if err != nil {
+		return err
+	}
+
+	l.OrganizationID = organizationID
+
+	l, err = s.storage.AddLabel(l)
+	if err != nil {
+		return errors.New("failed to add label to storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, l)
+}
+
+func (s *Server) putAPILabel(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	labelID, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse label_id: "+err.Error())
+	}
+
+	l, err := bindLabel(c)
+	if err != nil {
+		return err
+	}
+
+	l.ID = labelID
+	l.OrganizationID = organizationID
+
+	l, err = s.storage.SetLabel(l)
+	if err != nil {
+		return errors.New("failed to set label in storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, l)
+}
+
+func (s *Server) deleteAPILabel(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	labelID, err := strconv.Atoi(c.Param("label_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse label_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationLabel(organizationID, labelID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization label from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPICustomFields(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	if cfs == nil {
+		cfs = []entity.CustomField{}
+	}
+
+	return c.JSON(http.StatusOK, cfs)
+}
+
+func (s *Server) postAPICustomFields(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cf, err := bindCustomField(c)
+	if err != nil {
+		return err
+	}
+
+	cf.OrganizationID = organizationID
+
+	cf, err = s.storage.AddCustomField(cf)
+	if err != nil {
+		return errors.New("failed to add custom field to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, cf)
+}
+
+func (s *Server) putAPICustomField(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	customFieldID, err := strconv.Atoi(c.Param("custom_field_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse custom_field_id: "+err.Error())
+	}
+
+	cf, err := bindCustomField(c)
+	if err != nil {
+		return err
+	}
+
+	cf.ID = customFieldID
+	cf.OrganizationID = organizationID
+
+	cf, err = s.storage.SetCustomField(cf)
+	if err != nil {
+		return errors.New("failed to set custom field in storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, cf)
+}
+
+func (s *Server) deleteAPICustomField(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	customFieldID, err := strconv.Atoi(c.Param("custom_field_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse custom_field_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationCustomField(organizationID,
+		customFieldID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization custom field from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) putAPIRequestAttributes(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var ra entity.RequestAttributes
+
+	err = c.Bind(&ra)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request attributes: "+err.Error())
+	}
+
+	err = s.setRequestAttributes(rl, actorID, organizationID, requestID, ra)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIRequestTasks(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	ts, err := s.requestTasks(rl, actorID, organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	if ts == nil {
+		ts = []entity.RequestTask{}
+	}
+
+	return c.JSON(http.StatusOK, ts)
+}
+
+func (s *Server) postAPIRequestTasks(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var t entity.RequestTask
+
+	err = c.Bind(&t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request task: "+err.Error())
+	}
+
+	t.RequestID = requestID
+
+	t, err = s.addRequestTask(rl, actorID, organizationID, t)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, t)
+}
+
+func (s *Server) putAPIRequestTask(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	taskID, err := strconv.Atoi(c.Param("task_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse task_id: "+err.Error())
+	}
+
+	var t entity.RequestTask
+
+	err = c.Bind(&t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request task: "+err.Error())
+	}
+
+	t.ID = taskID
+	t.RequestID = requestID
+
+	err = s.setRequestTask(rl, actorID, organizationID, t)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIRequestTask(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	taskID, err := strconv.Atoi(c.Param("task_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse task_id: "+err.Error())
+	}
+
+	err = s.removeRequestTask(rl, actorID, organizationID, requestID, taskID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOperatorsTasks(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	ts, err := s.storage.OperatorTasks(operatorID)
+	if err != nil {
+		return errors.New("failed to get operator tasks from storage: " +
+			err.Error())
+	}
+
+	if ts == nil {
+		ts = []entity.RequestTask{}
+	}
+
+	return c.JSON(http.StatusOK, ts)
+}
+
+func (s *Server) getAPIChecklistTemplates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cts, err := s.storage.OrganizationChecklistTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization checklist templates from storage: " +
+				err.Error())
+	}
+
+	if cts == nil {
+		cts = []entity.ChecklistTemplate{}
+	}
+
+	return c.JSON(http.StatusOK, cts)
+}
+
+func (s *Server) postAPIChecklistTemplates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ct, err := bindChecklistTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	ct.OrganizationID = organizationID
+
+	ct, err = s.storage.AddChecklistTemplate(ct)
+	if err != nil {
+		return errors.New("failed to add checklist template to storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ct)
+}
+
+func (s *Server) putAPIChecklistTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	checklistTemplateID, err := strconv.Atoi(c.Param("checklist_template_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse checklist_template_id: "+err.Error())
+	}
+
+	ct, err := bindChecklistTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	ct.ID = checklistTemplateID
+	ct.OrganizationID = organizationID
+
+	ct, err = s.storage.SetChecklistTemplate(ct)
+	if err != nil {
+		return errors.New("failed to set checklist template in storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, ct)
+}
+
+func (s *Server) deleteAPIChecklistTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	checklistTemplateID, err := strconv.Atoi(c.Param("checklist_template_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse checklist_template_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationChecklistTemplate(organizationID,
+		checklistTemplateID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization checklist template from storage: " +
+				err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIWorkOrder(c echo.Context) error {
+	wo, err := s.workOrder(c.Param("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, wo.ContractorWorkOrder())
+}
+
+func (s *Server) putAPIWorkOrder(c echo.Context) error {
+	var wo entity.WorkOrder
+
+	err := c.Bind(&wo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind work order: "+err.Error())
+	}
+
+	woe, err := s.setWorkOrderStatus(c.Param("token"), wo.Status, wo.Comment)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, woe.ContractorWorkOrder())
+}
+
+func (s *Server) getAPIOwners(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	os, err := s.storage.OrganizationOwners(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization owners from storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, os)
+}
+
+func (s *Server) postAPIOwners(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	o, err := bindOwner(c)
+	if err != nil {
+		return err
+	}
+
+	o, err = s.validateOwnerFlat(organizationID, o)
+	if err != nil {
+		return err
+	}
+
+	o.OrganizationID = organizationID
+
+	o, err = s.addOwner(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, o)
+}
+
+func (s *Server) putAPIOwner(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ownerID, err := strconv.Atoi(c.Param("owner_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse owner_id: "+err.Error())
+	}
+
+	o, err := bindOwner(c)
+	if err != nil {
+		return err
+	}
+
+	o, err = s.validateOwnerFlat(organizationID, o)
+	if err != nil {
+		return err
+	}
+
+	o.ID = ownerID
+	o.OrganizationID = organizationID
+
+	o, err = s.setOwner(o)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, o)
+}
+
+func (s *Server) deleteAPIOwner(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ownerID, err := strconv.Atoi(c.Param("owner_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse owner_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationOwner(organizationID, ownerID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization owner from storage: " + err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIDeletedOwners(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	os, err := s.storage.OrganizationDeletedOwners(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization deleted owners from storage: " +
+				err.Error())
+	}
+
+	if os == nil {
+		os = []entity.Owner{}
+	}
+
+	return c.JSON(http.StatusOK, os)
+}
+
+func (s *Server) postAPIOwnerRestore(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ownerID, err := strconv.Atoi(c.Param("owner_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse owner_id: "+err.Error())
+	}
+
+	err = s.restoreOwner(organizationID, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOperatorsRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Priority, status.New,
+		status.InProgress)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.operatorRequests(operatorID, f)
+	if err != nil {
+		return err
+	}
+
+	if cursor != "" {
+		c.Response().Header().Set(nextCursorHeader, cursor)
+	}
+
+	if rs == nil {
+		rs = []entity.RequestExtended{}
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) postAPIOperatorsRequestWorkOrders(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var wo entity.WorkOrder
+
+	err = c.Bind(&wo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind work order: "+err.Error())
+	}
+
+	wo.RequestID = requestID
+
+	wo, err = s.addWorkOrder(operatorID, wo, s.publicBaseURL)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, wo)
+}
+
+func (s *Server) postAPIRequestCostItems(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var ci entity.CostItem
+
+	err = c.Bind(&ci)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind cost item: "+err.Error())
+	}
+
+	ci.RequestID = requestID
+
+	ci, err = s.addCostItem(rl, actorID, organizationID, ci)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ci)
+}
+
+func (s *Server) deleteAPIRequestCostItem(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	costItemID, err := strconv.Atoi(c.Param("cost_item_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse cost_item_id: "+err.Error())
+	}
+
+	err = s.removeCostItem(rl, actorID, organizationID, requestID, costItemID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIOperatorsCostItem(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	costItemID, err := strconv.Atoi(c.Param("cost_item_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse cost_item_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOperatorCostItem(operatorID, costItemID)
+	if err != nil {
+		return errors.New("failed to remove operator cost item from storage: " +
+			err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPICostReport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, to, cis, err := s.organizationCostItems(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, costReport(from, to, cis))
+}
+
+func (s *Server) getAPICostReportExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, _, cis, err := s.organizationCostItems(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return exportCostItems(c, from, cis)
+}
+
+func (s *Server) getAPIOrganizationRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.organizationRequests(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	if cursor != "" {
+		c.Response().Header().Set(nextCursorHeader, cursor)
+	}
+
+	if rs == nil {
+		rs = []entity.RequestExtended{}
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) getAPIOrganizationRequestsStats(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	oss, err := s.storage.OrganizationOperatorsStats(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators stats from storage: " +
+				err.Error())
+	}
+
+	if oss == nil {
+		oss = []entity.OperatorStats{}
+	}
+
+	return c.JSON(http.StatusOK, oss)
+}
+
+func (s *Server) getAPIOrganizationRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	r, err := s.organizationRequest(organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (s *Server) getAPIOrganizationRequestsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	return s.exportRequests(c, organizationID, f)
+}
+
+func (s *Server) postAPIBulkRequests(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var ba entity.BulkAction
+
+	err = c.Bind(&ba)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind bulk action: "+err.Error())
+	}
+
+	brs, err := s.bulkAction(rl, actorID, organizationID, ba)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, brs)
+}
+
+func (s *Server) getAPIOperatorsVisitSlots(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	vss, err := s.storage.OperatorVisitSlots(operatorID, time.Now())
+	if err != nil {
+		return errors.New("failed to get operator visit slots from storage: " +
+			err.Error())
+	}
+
+	if vss == nil {
+		vss = []entity.VisitSlotExtended{}
+	}
+
+	return c.JSON(http.StatusOK, vss)
+}
+
+func (s *Server) postAPIOperatorsVisitSlots(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var vs entity.VisitSlot
+
+	err = c.Bind(&vs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind visit slot: "+err.Error())
+	}
+
+	vs.OperatorID = operatorID
+
+	vs, err = s.addVisitSlot(vs)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, vs)
+}
+
+func (s *Server) deleteAPIOperatorsVisitSlot(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	visitSlotID, err := strconv.Atoi(c.Param("visit_slot_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse visit_slot_id: "+err.Error())
+	}
+
+	err = s.storage.RemoveOperatorVisitSlot(operatorID, visitSlotID)
+	if err != nil {
+		return errors.New("failed to remove operator visit slot from storage: " +
+			err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) putAPIOperatorsRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	err = r.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request: "+err.Error())
+	}
+
+	if r.ResponseTemplateID != nil {
+		r.Response, err = s.operatorTemplateResponse(organizationID,
+			operatorID, *r.ResponseTemplateID, r.ID, r.Response)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, err = s.storage.SetOperatorRequest(operatorID, r)
+	if err != nil {
+		return errors.New("failed to set operator request in storage: " +
+			err.Error())
+	}
+
+	if r.ResponseTemplateID != nil {
+		s.addResponseTemplateUse(*r.ResponseTemplateID, r.ID, role.Operator,
+			operatorID)
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (s *Server) postAPIOperatorsRequestAcknowledgement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	err = s.storage.AcknowledgeOperatorRequest(operatorID, requestID)
+	if err != nil {
+		return errors.New("failed to acknowledge operator request in storage: " +
+			err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIIncidents(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	is, err := s.storage.OrganizationIncidents(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization incidents from storage: " +
+			err.Error())
+	}
+
+	if is == nil {
+		is = []entity.Incident{}
+	}
+
+	return c.JSON(http.StatusOK, is)
+}
+
+func (s *Server) postAPIIncidents(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var i entity.Incident
+
+	err = c.Bind(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind incident: "+err.Error())
+	}
+
+	err = i.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate incident: "+err.Error())
+	}
+
+	i.OrganizationID = organizationID
+
+	i, err = s.storage.AddIncident(i)
+	if err != nil {
+		return errors.New("failed to add incident to storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, i)
+}
+
+func (s *Server) putAPIIncident(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	incidentID, err := strconv.Atoi(c.Param("incident_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse incident_id: "+err.Error())
+	}
+
+	var i entity.Incident
+
+	err = c.Bind(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind incident: "+err.Error())
+	}
+
+	err = i.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate incident: "+err.Error())
+	}
+
+	i.ID = incidentID
+	i.OrganizationID = organizationID
+
+	i, err = s.storage.SetIncident(i)
+	if err != nil {
+		return errors.New("failed to set incident in storage: " + err.Error())
+	}
+
+	return c.JSON(http.StatusOK, i)
+}
+
+func (s *Server) postAPIIncidentResolution(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	incidentID, err := strconv.Atoi(c.Param("incident_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse incident_id: "+err.Error())
+	}
+
+	var resolutionData struct {
+		Response string
+	}
+
+	err = c.Bind(&resolutionData)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind resolution data: "+err.Error())
+	}
+
+	err = s.resolveIncident(organizationID, incidentID,
+		resolutionData.Response)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type linkRequestData struct {
+	ID               int `json:"-" form:"id"`
+	PrimaryRequestID int `json:"primary_request_id" form:"primary_request_id"`
+}
+
+func (s *Server) putAPIOperatorsRequestPrimary(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var ld linkRequestData
+
+	err = c.Bind(&ld)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind link request data: "+err.Error())
+	}
+
+	err = s.linkRequest(login, requestID, ld.PrimaryRequestID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIOperatorsRequestPrimary(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	err = s.unlinkRequest(operatorID, requestID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOwnersRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.ownerRequests(ownerID, f)
+	if err != nil {
+		return err
+	}
+
+	if cursor != "" {
+		c.Response().Header().Set(nextCursorHeader, cursor)
+	}
+
+	if rs == nil {
+		rs = []entity.RequestExtended{}
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) postAPIOwnersRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			errors.New("failed to bind request: "+err.Error()))
+	}
+
+	err = r.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			errors.New("failed to validate request: "+err.Error()))
+	}
+
+	switch r.Priority {
+	case "":
+		r.Priority = priority.Normal
+	case priority.Normal, priority.Urgent:
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"owner can set only normal or urgent priority")
+	}
+
+	r.OrganizationID = organizationID
+	r.OwnerID = &ownerID
+	r.CreatorRole = role.Owner
+	r.CreatorID = ownerID
+	r.Status = status.New
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	r, err = ownerRequestLocation(r, owner)
+	if err != nil {
+		return err
+	}
+
+	if r.IncidentID != nil {
+		r, err = s.attachRequest(r, owner.Address)
+		if err != nil {
+			return err
+		}
+
+		r, err = s.storage.AddRequest(r)
+		if err != nil {
+			return errors.New("failed to add request to storage: " +
+				err.Error())
+		}
+
+		return c.JSON(http.StatusOK, r)
+	}
+
+	r = s.classifyRequest(r)
+
+	rds, err := s.findDuplicates(r, owner.Address)
+	if err != nil {
+		s.log.WithError(err).Error("failed to find request duplicates")
+	}
+
+	r, o := s.routeRequest(r)
+
+	r, err = s.storage.AddRequest(r)
+	if err != nil {
+		return errors.New("failed to add request to storage: " + err.Error())
+	}
+
+	s.addRequestDuplicates(r, rds)
+	s.applyChecklistTemplate(r)
+	s.notifyEmergency(r, o)
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (s *Server) putAPIOwnersRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	err = s.editRequest(ownerID, requestID, r.Text)
+	if err != nil {
+		return err
+	}
+
+	r, err = s.storage.OwnerRequest(ownerID, requestID)
+	if err != nil {
+		return errors.New("failed to get owner request from storage: " +
+			err.Error())
+	}
+
+	return c.JSON(http.StatusOK, r)
+}
+
+func (s *Server) postAPIOwnersRequestCancellation(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var cancellationData struct {
+		Reason string
+	}
+
+	err = c.Bind(&cancellationData)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind cancellation data: "+err.Error())
+	}
+
+	err = s.cancelRequest(ownerID, requestID, cancellationData.Reason)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+type visitData struct {
+	VisitSlotID int `json:"visit_slot_id" form:"visit_slot_id"`
+}
+
+func (s *Server) putAPIOwnersRequestVisit(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	var vd visitData
+
+	err = c.Bind(&vd)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind visit data: "+err.Error())
+	}
+
+	err = s.bookVisitSlot(ownerID, requestID, vd.VisitSlotID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) deleteAPIOwnersRequestVisit(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	err = s.storage.CancelVisit(ownerID, requestID)
+	if err != nil {
+		return errors.New("failed to cancel visit in storage: " +
+			err.Error())
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) postAPIOwnersRequestsDuplicates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	r.OrganizationID = owner.OrganizationID
+	r.OwnerID = &owner.ID
+
+	r = s.classifyRequest(r)
+
+	rds, err := s.findDuplicates(r, owner.Address)
+	if err != nil {
+		return err
+	}
+
+	// Owners only learn whether similar requests exist: duplicates are
+	// neighbours' requests and their texts are shown to staff only.
+	return c.JSON(http.StatusOK, echo.Map{
+		"duplicates_count": len(rds),
+	})
+}
+
+func (s *Server) getAPIOwnersCommonRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	rs, err := s.buildingCommonRequests(owner)
+	if err != nil {
+		return err
+	}
+
+	if rs == nil {
+		rs = []entity.CommonRequest{}
+	}
+
+	return c.JSON(http.StatusOK, rs)
+}
+
+func (s *Server) postAPIOwnersCommonRequestSupport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	err = s.supportRequest(owner, requestID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOwnersIncidents(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	is, err := s.buildingIncidents(owner.OrganizationID, owner.Address)
+	if err != nil {
+		return err
+	}
+
+	if is == nil {
+		is = []entity.Incident{}
+	}
+
+	return c.JSON(http.StatusOK, is)
+}
+
+func (s *Server) getAPIOwnersHousehold(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	ms, err := s.storage.HouseholdMembers(ownerID)
+	if err != nil {
+		return errors.New("failed to get household members from storage: " +
+			err.Error())
+	}
+
+	if ms == nil {
+		ms = []entity.Owner{}
+	}
+
+	return c.JSON(http.StatusOK, ms)
+}
+
+func (s *Server) postAPIOwnersHousehold(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var m entity.Owner
+
+	err = c.Bind(&m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind owner: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	m, err = s.inviteHouseholdMember(owner, m)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, m)
+}
+
+func (s *Server) deleteAPIOwnersHouseholdMember(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	memberID, err := strconv.Atoi(c.Param("owner_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse owner_id: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	err = s.removeHouseholdMember(owner, memberID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOwnersMeters(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	ms, err := s.ownerMeters(owner)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ms)
+}
+
+func (s *Server) postAPIOwnersMeterReadings(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	meterID, err := strconv.Atoi(c.Param("meter_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse meter_id: "+err.Error())
+	}
+
+	var r entity.MeterReading
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind meter reading: "+err.Error())
+	}
+
+	r.MeterID = meterID
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	r, err = s.submitMeterReading(owner, r)
+	if err != nil {
+		return err
 	}
 
 	return c.JSON(http.StatusOK, r)
 }
+
+func (s *Server) getAPIOwnersAnnouncements(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	as, err := s.storage.OwnerAnnouncements(ownerID, time.Now())
+	if err != nil {
+		return errors.New(
+			"failed to get owner announcements from storage: " + err.Error())
+	}
+
+	if as == nil {
+		as = []entity.Announcement{}
+	}
+
+	return c.JSON(http.StatusOK, as)
+}
+
+func (s *Server) postAPIOwnersAnnouncementRead(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	announcementID, err := strconv.Atoi(c.Param("announcement_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse announcement_id: "+err.Error())
+	}
+
+	err = s.readAnnouncement(ownerID, announcementID)
+	if err != nil {
+		return err
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (s *Server) getAPIOwnersPolls(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	prs, err := s.ownerPolls(owner)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, prs)
+}
+
+func (s *Server) postAPIOwnersPollVotes(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	var v entity.PollVote
+
+	err = c.Bind(&v)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll vote: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	v, err = s.votePoll(owner, pollID, v)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, v)
+}
+
+func (s *Server) getAPIOwnersDocuments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	ds, err := s.ownerDocuments(owner.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, ds)
+}
+
+func (s *Server) getAPIOwnersDocumentDownload(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	v, err := s.ownerDocumentVersion(owner.ID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return s.downloadDocumentVersion(c, v)
+}
+
+func (s *Server) getAPIOwnersLedger(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	l, err := s.ownerLedger(owner)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, l)
+}
+
+func (s *Server) postAPIOwnersPayments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	pd, err := bindPaymentData(c)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	u, err := s.ownerPaymentURL(owner, pd.Amount, c.QueryParam("return_url"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, echo.Map{
+		"url": u,
+	})
+}
@@ -0,0 +1,193 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+)
+
+// bindBuilding binds and validates building. Building address is
+// normalized.
+func (s *Server) bindBuilding(c echo.Context) (entity.Building, error) {
+	var b entity.Building
+
+	err := c.Bind(&b)
+	if err != nil {
+		return b, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind building: "+err.Error())
+	}
+
+	b.Address = strings.TrimSpace(b.Address)
+
+	err = b.Validate()
+	if err != nil {
+		return b, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate building: "+err.Error())
+	}
+
+	b.Address, err = s.normalizeBuildingAddress(b.Address)
+	if err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+// bindFlat binds and validates flat. Zero entrance, floor and area from the
+// form mean they are unknown.
+func bindFlat(c echo.Context) (entity.Flat, error) {
+	var f entity.Flat
+
+	err := c.Bind(&f)
+	if err != nil {
+		return f, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind flat: "+err.Error())
+	}
+
+	if f.Entrance != nil && *f.Entrance == 0 {
+		f.Entrance = nil
+	}
+	if f.Floor != nil && *f.Floor == 0 {
+		f.Floor = nil
+	}
+	if f.Area != nil && *f.Area == 0 {
+		f.Area = nil
+	}
+
+	f.Number = strings.TrimSpace(f.Number)
+	f.Account = strings.TrimSpace(f.Account)
+
+	err = f.Validate()
+	if err != nil {
+		return f, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate flat: "+err.Error())
+	}
+
+	return f, nil
+}
+
+// validateFlatBuilding checks that the flat building is of the organization
+// and the flat fits it.
+func (s *Server) validateFlatBuilding(organizationID int,
+	f entity.Flat) error {
+
+	b, err := s.storage.OrganizationBuilding(organizationID, f.BuildingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"building not found")
+		}
+		return errors.New(
+			"failed to get organization building from storage: " +
+				err.Error())
+	}
+
+	err = f.FitsBuilding(b)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate flat: "+err.Error())
+	}
+
+	return nil
+}
+
+// removeBuilding removes the organization building. Returns conflict error
+// if some flat of the building has charges or payments.
+func (s *Server) removeBuilding(organizationID int, buildingID int) error {
+	err := s.storage.RemoveOrganizationBuilding(organizationID, buildingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusConflict,
+				"building flats have charges or payments")
+		}
+		return errors.New(
+			"failed to remove organization building from storage: " +
+				err.Error())
+	}
+	return nil
+}
+
+// addFlat adds the organization flat. Returns conflict error if the account
+// is taken by another flat of the organization.
+func (s *Server) addFlat(organizationID int, f entity.Flat) (entity.Flat,
+	error) {
+
+	f.OrganizationID = organizationID
+
+	f, err := s.storage.AddFlat(f)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return f, echo.NewHTTPError(http.StatusConflict,
+				"account is taken by another flat")
+		}
+		return f, errors.New("failed to add flat to storage: " + err.Error())
+	}
+
+	return f, nil
+}
+
+// setFlat sets the organization flat. Returns conflict error if the account
+// is taken by another flat of the organization.
+func (s *Server) setFlat(organizationID int, f entity.Flat) (entity.Flat,
+	error) {
+
+	f.OrganizationID = organizationID
+
+	f, err := s.storage.SetFlat(organizationID, f)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return f, echo.NewHTTPError(http.StatusConflict,
+				"account is taken by another flat")
+		}
+		return f, errors.New("failed to set flat in storage: " + err.Error())
+	}
+
+	return f, nil
+}
+
+// removeFlat removes the organization flat. Returns conflict error if the
+// flat has charges or payments.
+func (s *Server) removeFlat(organizationID int, flatID int) error {
+	err := s.storage.RemoveOrganizationFlat(organizationID, flatID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusConflict,
+				"flat has charges or payments")
+		}
+		return errors.New(
+			"failed to remove organization flat from storage: " + err.Error())
+	}
+	return nil
+}
+
+// validateOwnerFlat checks that the owner flat is of the organization. Zero
+// flat ID from the form unlinks the owner from the flat.
+func (s *Server) validateOwnerFlat(organizationID int,
+	o entity.Owner) (entity.Owner, error) {
+
+	if o.FlatID == nil {
+		return o, nil
+	}
+
+	if *o.FlatID == 0 {
+		o.FlatID = nil
+		return o, nil
+	}
+
+	_, err := s.storage.OrganizationFlat(organizationID, *o.FlatID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return o, echo.NewHTTPError(http.StatusBadRequest,
+				"flat not found")
+		}
+		return o, errors.New(
+			"failed to get organization flat from storage: " + err.Error())
+	}
+
+	return o, nil
+}
@@ -0,0 +1,228 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/event"
+	"github.com/dimuls/swan/entity/role"
+	"github.com/dimuls/swan/entity/status"
+)
+
+// bulkAction applies the bulk action to each of the selected organization
+// requests on behalf of the actor. The operator can change only requests
+// assigned to them. Every request is checked by the same workflow rules as
+// single request and its outcome is reported separately.
+func (s *Server) bulkAction(actorRole string, actorID int,
+	organizationID int, ba entity.BulkAction) ([]entity.BulkResult, error) {
+
+	// Empty form fields are bound as zero values.
+	if ba.OperatorID != nil && *ba.OperatorID == 0 {
+		ba.OperatorID = nil
+	}
+	if ba.CategoryID != nil && *ba.CategoryID == 0 {
+		ba.CategoryID = nil
+	}
+	if ba.Response != nil && strings.TrimSpace(*ba.Response) == "" {
+		ba.Response = nil
+	}
+	if ba.ResponseTemplateID != nil && *ba.ResponseTemplateID == 0 {
+		ba.ResponseTemplateID = nil
+	}
+
+	err := ba.Validate()
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate bulk action: "+err.Error())
+	}
+
+	var o *entity.Operator
+
+	if ba.OperatorID != nil {
+		os, err := s.storage.OrganizationOperators(organizationID)
+		if err != nil {
+			return nil, errors.New(
+				"failed to get organization operators from storage: " +
+					err.Error())
+		}
+		for i := range os {
+			if os[i].ID == *ba.OperatorID {
+				o = &os[i]
+			}
+		}
+		if o == nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				"operator not found")
+		}
+	}
+
+	var c *entity.Category
+
+	if ba.CategoryID != nil {
+		cs, err := s.storage.Categories()
+		if err != nil {
+			return nil, errors.New("failed to get categories from storage: " +
+				err.Error())
+		}
+		for i := range cs {
+			if cs[i].ID == *ba.CategoryID {
+				c = &cs[i]
+			}
+		}
+		if c == nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				"category not found")
+		}
+	}
+
+	var rt *entity.ResponseTemplate
+
+	if ba.ResponseTemplateID != nil {
+		t, err := s.responseTemplate(organizationID, *ba.ResponseTemplateID)
+		if err != nil {
+			return nil, err
+		}
+		rt = &t
+	}
+
+	brs := make([]entity.BulkResult, 0, len(ba.RequestIDs))
+	done := map[int]bool{}
+
+	for _, requestID := range ba.RequestIDs {
+		if done[requestID] {
+			continue
+		}
+		done[requestID] = true
+
+		br := entity.BulkResult{RequestID: requestID, OK: true}
+
+		err = s.bulkActionRequest(actorRole, actorID, organizationID, ba, o, c,
+			rt, requestID)
+		if err != nil {
+			br.OK = false
+			br.Error = err.Error()
+		}
+
+		brs = append(brs, br)
+	}
+
+	return brs, nil
+}
+
+// bulkActionRequest applies the bulk action to the single request. Returned
+// error is reported to the actor as is, so storage errors are logged and
+// replaced.
+func (s *Server) bulkActionRequest(actorRole string, actorID int,
+	organizationID int, ba entity.BulkAction, o *entity.Operator,
+	c *entity.Category, rt *entity.ResponseTemplate, requestID int) error {
+
+	re, err := s.storage.OrganizationRequest(organizationID, requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("request not found")
+		}
+		s.log.WithError(err).WithField("request_id", requestID).
+			Error("failed to get organization request from storage")
+		return errors.New("failed to get request")
+	}
+
+	r := re.Request
+
+	if actorRole == role.Operator &&
+		(r.OperatorID == nil || *r.OperatorID != actorID) {
+		return errors.New("request is not assigned to operator")
+	}
+
+	if status.Final(r.Status) {
+		return errors.New("request status is already in final state")
+	}
+
+	if r.PrimaryRequestID != nil &&
+		(ba.Status != "" || o != nil || ba.Response != nil || rt != nil) {
+		return fmt.Errorf("request is linked to primary request %d",
+			*r.PrimaryRequestID)
+	}
+
+	if ba.Status == status.InProgress && r.Status != status.New {
+		return errors.New("request status is not in new state")
+	}
+
+	var es []entity.RequestEvent
+
+	addEvent := func(kind string, text string) {
+		es = append(es, entity.RequestEvent{
+			RequestID: r.ID,
+			Role:      actorRole,
+			ActorID:   actorID,
+			Kind:      kind,
+			Text:      &text,
+		})
+	}
+
+	if c != nil && (r.CategoryID == nil || *r.CategoryID != c.ID) {
+		r.CategoryID = &c.ID
+		re.CategoryName = &c.Name
+		addEvent(event.CategoryChanged, c.Name)
+	}
+
+	reassigned := o != nil && (r.OperatorID == nil || *r.OperatorID != o.ID)
+	if reassigned {
+		r.OperatorID = &o.ID
+		re.OperatorName = &o.Name
+		addEvent(event.Reassigned, o.Name)
+	}
+
+	if ba.Status != "" {
+		r.Status = ba.Status
+		addEvent(event.StatusChanged, ba.Status)
+	}
+
+	response := ba.Response
+	if rt != nil {
+		if !rt.Fits(r.CategoryID) {
+			return errors.New("response template doesn't fit request category")
+		}
+		response = templateResponse(*rt, re, ba.Response)
+	}
+
+	if response != nil {
+		r.Response = response
+		addEvent(event.Responded, *response)
+	}
+
+	if len(es) == 0 {
+		return nil
+	}
+
+	err = s.storage.SetRequest(r, es)
+	if err != nil {
+		s.log.WithError(err).WithField("request_id", requestID).
+			Error("failed to set request in storage")
+		return errors.New("failed to set request")
+	}
+
+	if rt != nil {
+		s.addResponseTemplateUse(rt.ID, r.ID, actorRole, actorID)
+	}
+
+	if ba.Status == status.InProgress && actorRole == role.Operator &&
+		!reassigned {
+		err = s.storage.AcknowledgeOperatorRequest(actorID, r.ID)
+		if err != nil {
+			s.log.WithError(err).WithField("request_id", requestID).
+				Error("failed to acknowledge operator request in storage")
+		}
+	}
+
+	if reassigned && !status.Final(r.Status) {
+		s.notifyEmergency(r, o)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dimuls/swan/entity"
+)
+
+const calendarTimeLayout = "20060102T150405Z"
+
+var calendarEscaper = strings.NewReplacer(
+	`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// visitsCalendar renders booked visit slots as iCalendar (RFC 5545) feed.
+func visitsCalendar(vss []entity.VisitSlotExtended) string {
+	var b strings.Builder
+
+	writeLine := func(line string) {
+		// Lines longer than 75 octets are folded. Multi-byte runes are not
+		// split between lines.
+		for len(line) > 75 {
+			i := 75
+			for i > 0 && line[i]&0xC0 == 0x80 {
+				i--
+			}
+			b.WriteString(line[:i] + "\r\n")
+			line = " " + line[i:]
+		}
+		b.WriteString(line + "\r\n")
+	}
+
+	now := time.Now().UTC().Format(calendarTimeLayout)
+
+	writeLine("BEGIN:VCALENDAR")
+	writeLine("VERSION:2.0")
+	writeLine("PRODID:-//swan//visits//RU")
+	writeLine("CALSCALE:GREGORIAN")
+
+	for _, vs := range vss {
+		if !vs.Booked() {
+			continue
+		}
+
+		writeLine("BEGIN:VEVENT")
+		writeLine(fmt.Sprintf("UID:visit-slot-%d@swan", vs.ID))
+		writeLine("DTSTAMP:" + now)
+		writeLine("DTSTART:" + vs.StartsAt.UTC().Format(calendarTimeLayout))
+		writeLine("DTEND:" + vs.EndsAt.UTC().Format(calendarTimeLayout))
+		writeLine(fmt.Sprintf("SUMMARY:Визит по обращению №%d",
+			*vs.RequestID))
+
+		var description []string
+		if vs.OwnerName != nil {
+			description = append(description, "Владелец: "+*vs.OwnerName)
+		}
+		if vs.OwnerPhone != nil {
+			description = append(description, "Телефон: "+*vs.OwnerPhone)
+		}
+		if vs.RequestText != nil {
+			description = append(description, *vs.RequestText)
+		}
+		writeLine("DESCRIPTION:" + calendarEscaper.Replace(
+			strings.Join(description, "\n")))
+
+		if vs.OwnerAddress != nil {
+			writeLine("LOCATION:" + calendarEscaper.Replace(*vs.OwnerAddress))
+		}
+
+		writeLine("END:VEVENT")
+	}
+
+	writeLine("END:VCALENDAR")
+
+	return b.String()
+}
@@ -0,0 +1,299 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/role"
+)
+
+// bindChecklistTemplate binds and validates checklist template. Form passes
+// items one per line.
+func bindChecklistTemplate(c echo.Context) (entity.ChecklistTemplate, error) {
+	var ct entity.ChecklistTemplate
+
+	err := c.Bind(&ct)
+	if err != nil {
+		return ct, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind checklist template: "+err.Error())
+	}
+
+	if ct.ItemsStr != "" {
+		ct.Items = strings.Split(ct.ItemsStr, "\n")
+	}
+
+	var is []string
+	for _, i := range ct.Items {
+		i = strings.TrimSpace(i)
+		if i != "" {
+			is = append(is, i)
+		}
+	}
+	ct.Items = is
+
+	err = ct.Validate()
+	if err != nil {
+		return ct, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate checklist template: "+err.Error())
+	}
+
+	return ct, nil
+}
+
+// applyChecklistTemplate adds tasks of the organization checklist template
+// of the request category to the new request. Failures are logged only, so
+// request is created anyway.
+func (s *Server) applyChecklistTemplate(r entity.Request) {
+	if r.CategoryID == nil {
+		return
+	}
+
+	is, err := s.storage.CategoryChecklistTemplateItems(r.OrganizationID,
+		*r.CategoryID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			s.log.WithError(err).WithField("request_id", r.ID).
+				Error("failed to get category checklist template from storage")
+		}
+		return
+	}
+
+	err = s.storage.AddRequestTasks(r.ID, is)
+	if err != nil {
+		s.log.WithError(err).WithField("request_id", r.ID).
+			Error("failed to add request tasks to storage")
+	}
+}
+
+// attachRequestsTasks attaches progress to the requests. Tasks are attached
+// for staff only.
+func (s *Server) attachRequestsTasks(rs []entity.RequestExtended,
+	staff bool) error {
+
+	if len(rs) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(rs))
+	index := map[int]int{}
+	for i, r := range rs {
+		ids = append(ids, r.ID)
+		index[r.ID] = i
+	}
+
+	ts, err := s.storage.RequestsTasks(ids)
+	if err != nil {
+		return errors.New("failed to get requests tasks from storage: " +
+			err.Error())
+	}
+
+	rts := map[int][]entity.RequestTask{}
+	for _, t := range ts {
+		rts[t.RequestID] = append(rts[t.RequestID], t)
+	}
+
+	for requestID, ts := range rts {
+		i, ok := index[requestID]
+		if !ok {
+			continue
+		}
+		rs[i].Progress = entity.TasksProgress(ts)
+		if staff {
+			rs[i].Tasks = ts
+		}
+	}
+
+	return nil
+}
+
+// requestTasks returns tasks of the request the staff actor has access to.
+func (s *Server) requestTasks(actorRole string, actorID int,
+	organizationID int, requestID int) ([]entity.RequestTask, error) {
+
+	_, err := s.staffRequest(actorRole, actorID, organizationID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	ts, err := s.storage.RequestsTasks([]int{requestID})
+	if err != nil {
+		return nil, errors.New("failed to get request tasks from storage: " +
+			err.Error())
+	}
+
+	return ts, nil
+}
+
+// validateTaskOperator checks that the task operator is of the organization.
+// Zero operator ID from the form unassigns the task.
+func (s *Server) validateTaskOperator(organizationID int,
+	t entity.RequestTask) (entity.RequestTask, error) {
+
+	if t.OperatorID == nil {
+		return t, nil
+	}
+
+	if *t.OperatorID == 0 {
+		t.OperatorID = nil
+		return t, nil
+	}
+
+	return t, s.validateOrganizationOperator(organizationID, *t.OperatorID)
+}
+
+// validateOrganizationOperator checks that the operator is not deleted
+// operator of the organization.
+func (s *Server) validateOrganizationOperator(organizationID int,
+	operatorID int) error {
+
+	os, err := s.storage.OrganizationOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators from storage: " +
+				err.Error())
+	}
+
+	for _, o := range os {
+		if o.ID == operatorID {
+			return nil
+		}
+	}
+
+	return echo.NewHTTPError(http.StatusBadRequest, "operator not found")
+}
+
+// addRequestTask appends the task to the checklist of the request the staff
+// actor has access to.
+func (s *Server) addRequestTask(actorRole string, actorID int,
+	organizationID int, t entity.RequestTask) (entity.RequestTask, error) {
+
+	t.Title = strings.TrimSpace(t.Title)
+
+	err := t.Validate()
+	if err != nil {
+		return t, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request task: "+err.Error())
+	}
+
+	_, err = s.staffRequest(actorRole, actorID, organizationID, t.RequestID)
+	if err != nil {
+		return t, err
+	}
+
+	t, err = s.validateTaskOperator(organizationID, t)
+	if err != nil {
+		return t, err
+	}
+
+	t.Done = false
+
+	t, err = s.storage.AddRequestTask(t)
+	if err != nil {
+		return t, errors.New("failed to add request task to storage: " +
+			err.Error())
+	}
+
+	return t, nil
+}
+
+// staffRequestTask returns the request task the staff actor has access to.
+// Operator assigned to the task of the request handled by another operator
+// has limited access: they can only mark the task done.
+func (s *Server) staffRequestTask(actorRole string, actorID int,
+	organizationID int, requestID int, taskID int) (
+	t entity.RequestTask, limited bool, err error) {
+
+	r, err := s.storage.OrganizationRequest(organizationID, requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, false, echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		return t, false, errors.New(
+			"failed to get organization request from storage: " +
+				err.Error())
+	}
+
+	t, err = s.storage.RequestTask(requestID, taskID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return t, false, echo.NewHTTPError(http.StatusNotFound,
+				"request task not found")
+		}
+		return t, false, errors.New(
+			"failed to get request task from storage: " + err.Error())
+	}
+
+	if actorRole == role.Operator &&
+		(r.OperatorID == nil || *r.OperatorID != actorID) {
+		if !t.AssignedTo(actorID) {
+			return t, false, echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		limited = true
+	}
+
+	return t, limited, nil
+}
+
+// setRequestTask sets title, operator and done state of the request task.
+// Operator with limited access sets done state only.
+func (s *Server) setRequestTask(actorRole string, actorID int,
+	organizationID int, t entity.RequestTask) error {
+
+	cur, limited, err := s.staffRequestTask(actorRole, actorID,
+		organizationID, t.RequestID, t.ID)
+	if err != nil {
+		return err
+	}
+
+	if limited {
+		t.Title = cur.Title
+		t.OperatorID = cur.OperatorID
+	}
+
+	t.Title = strings.TrimSpace(t.Title)
+
+	err = t.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request task: "+err.Error())
+	}
+
+	t, err = s.validateTaskOperator(organizationID, t)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.SetRequestTask(t)
+	if err != nil {
+		return errors.New("failed to set request task in storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// removeRequestTask removes the task from the checklist of the request the
+// staff actor has access to.
+func (s *Server) removeRequestTask(actorRole string, actorID int,
+	organizationID int, requestID int, taskID int) error {
+
+	_, err := s.staffRequest(actorRole, actorID, organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.RemoveRequestTask(requestID, taskID)
+	if err != nil {
+		return errors.New("failed to remove request task from storage: " +
+			err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package web
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/dedup"
+	"github.com/dimuls/swan/entity"
+)
+
+// parseMonth parses month in 2006-01 format and returns its bounds. Current
+// month is used if month is empty.
+func parseMonth(month string) (from time.Time, to time.Time, err error) {
+	if month == "" {
+		now := time.Now()
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	} else {
+		from, err = time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			return from, to, errors.New("failed to parse month: " +
+				err.Error())
+		}
+	}
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+// costReport sums cost items per request, category and building.
+func costReport(from time.Time, to time.Time,
+	cis []entity.CostItemExtended) entity.CostReport {
+
+	cr := entity.CostReport{
+		From:       from,
+		To:         to,
+		Total:      entity.CostTotal{Name: "Итого"},
+		Requests:   []entity.CostTotal{},
+		Categories: []entity.CostTotal{},
+		Buildings:  []entity.CostTotal{},
+	}
+
+	add := func(totals *[]entity.CostTotal, index map[string]int,
+		name string, ci entity.CostItem) {
+		i, ok := index[name]
+		if !ok {
+			i = len(*totals)
+			index[name] = i
+			*totals = append(*totals, entity.CostTotal{Name: name})
+		}
+		(*totals)[i].Add(ci)
+	}
+
+	requests := map[string]int{}
+	categories := map[string]int{}
+	buildings := map[string]int{}
+
+	for _, ci := range cis {
+		cr.Total.Add(ci.CostItem)
+
+		add(&cr.Requests, requests, "№"+strconv.Itoa(ci.RequestID),
+			ci.CostItem)
+
+		category := "Без категории"
+		if ci.CategoryName != nil {
+			category = *ci.CategoryName
+		}
+		add(&cr.Categories, categories, category, ci.CostItem)
+
+		building := "Без адреса"
+		if ci.OwnerAddress != nil {
+			building = dedup.BuildingAddress(*ci.OwnerAddress)
+		}
+		add(&cr.Buildings, buildings, building, ci.CostItem)
+	}
+
+	byTotal := func(totals []entity.CostTotal) func(i, j int) bool {
+		return func(i, j int) bool {
+			return totals[i].Total > totals[j].Total
+		}
+	}
+
+	sort.Slice(cr.Categories, byTotal(cr.Categories))
+	sort.Slice(cr.Buildings, byTotal(cr.Buildings))
+
+	return cr
+}
+
+// writeCostItemsCSV writes cost items as CSV for the monthly cost report.
+func writeCostItemsCSV(w io.Writer, cis []entity.CostItemExtended) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"request_id", "created_at", "kind", "name",
+		"operator", "quantity", "unit_price", "amount", "billable",
+		"category", "building"})
+	if err != nil {
+		return err
+	}
+
+	for _, ci := range cis {
+		var operator, category, building string
+		if ci.OperatorName != nil {
+			operator = *ci.OperatorName
+		}
+		if ci.CategoryName != nil {
+			category = *ci.CategoryName
+		}
+		if ci.OwnerAddress != nil {
+			building = dedup.BuildingAddress(*ci.OwnerAddress)
+		}
+
+		err = cw.Write([]string{
+			strconv.Itoa(ci.RequestID),
+			ci.CreatedAt.In(time.Local).Format("2006-01-02 15:04"),
+			ci.Kind,
+			ci.Name,
+			operator,
+			ci.Quantity.String(),
+			ci.UnitPrice.String(),
+			ci.Amount().String(),
+			strconv.FormatBool(ci.Billable),
+			category,
+			building,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func exportCostItems(c echo.Context, from time.Time,
+	cis []entity.CostItemExtended) error {
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		"attachment; filename=costs-"+from.Format("2006-01")+".csv")
+	c.Response().WriteHeader(http.StatusOK)
+
+	return writeCostItemsCSV(c.Response(), cis)
+}
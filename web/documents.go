@@ -0,0 +1,406 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+)
+
+// maxDocumentSize is the maximum size of the uploaded document file.
+const maxDocumentSize = 20 << 20
+
+func bindDocumentFolder(c echo.Context) (entity.DocumentFolder, error) {
+	var f entity.DocumentFolder
+
+	err := c.Bind(&f)
+	if err != nil {
+		return f, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind document folder: "+err.Error())
+	}
+
+	f.Name = strings.TrimSpace(f.Name)
+
+	err = f.Validate()
+	if err != nil {
+		return f, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate document folder: "+err.Error())
+	}
+
+	return f, nil
+}
+
+// bindDocument binds and validates document. Zero folder and building from
+// the form mean no folder and all buildings.
+func bindDocument(c echo.Context) (entity.Document, error) {
+	var d entity.Document
+
+	err := c.Bind(&d)
+	if err != nil {
+		return d, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind document: "+err.Error())
+	}
+
+	if d.FolderID != nil && *d.FolderID == 0 {
+		d.FolderID = nil
+	}
+	if d.BuildingID != nil && *d.BuildingID == 0 {
+		d.BuildingID = nil
+	}
+
+	d.Title = strings.TrimSpace(d.Title)
+	d.Description = strings.TrimSpace(d.Description)
+
+	err = d.Validate()
+	if err != nil {
+		return d, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate document: "+err.Error())
+	}
+
+	return d, nil
+}
+
+// validateDocumentRefs checks that the document folder and building are of
+// the organization.
+func (s *Server) validateDocumentRefs(organizationID int,
+	d entity.Document) error {
+
+	if d.FolderID != nil {
+		_, err := s.storage.OrganizationDocumentFolder(organizationID,
+			*d.FolderID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"folder not found")
+			}
+			return errors.New(
+				"failed to get organization document folder from storage: " +
+					err.Error())
+		}
+	}
+
+	if d.BuildingID != nil {
+		_, err := s.storage.OrganizationBuilding(organizationID,
+			*d.BuildingID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"building not found")
+			}
+			return errors.New(
+				"failed to get organization building from storage: " +
+					err.Error())
+		}
+	}
+
+	return nil
+}
+
+// putDocumentFile puts the uploaded file to the attachment storage and
+// returns the document version of it.
+func (s *Server) putDocumentFile(c echo.Context) (entity.DocumentVersion,
+	error) {
+
+	var v entity.DocumentVersion
+
+	ff, err := c.FormFile("file")
+	if err != nil {
+		return v, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get file: "+err.Error())
+	}
+
+	if ff.Size > maxDocumentSize {
+		return v, echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+			"file is too large")
+	}
+
+	f, err := ff.Open()
+	if err != nil {
+		return v, errors.New("failed to open file: " + err.Error())
+	}
+	defer f.Close()
+
+	v.AttachmentKey, v.Size, err = s.attachmentStorage.Put(f)
+	if err != nil {
+		return v, errors.New("failed to put file to attachment storage: " +
+			err.Error())
+	}
+
+	v.FileName = filepath.Base(ff.Filename)
+
+	v.ContentType = ff.Header.Get(echo.HeaderContentType)
+	if v.ContentType == "" {
+		v.ContentType = mime.TypeByExtension(filepath.Ext(v.FileName))
+	}
+	if v.ContentType == "" {
+		v.ContentType = "application/octet-stream"
+	}
+
+	return v, nil
+}
+
+// removeAttachments removes the attachments, failures are only logged since
+// the attachments are not referenced anymore.
+func (s *Server) removeAttachments(keys ...string) {
+	for _, k := range keys {
+		err := s.attachmentStorage.Remove(k)
+		if err != nil {
+			s.log.WithError(err).WithField("attachment_key", k).
+				Error("failed to remove attachment from attachment storage")
+		}
+	}
+}
+
+// addDocument adds the document from the request with the uploaded file as
+// its first version.
+func (s *Server) addDocument(c echo.Context, organizationID int) (
+	entity.Document, error) {
+
+	d, err := bindDocument(c)
+	if err != nil {
+		return d, err
+	}
+
+	d.OrganizationID = organizationID
+
+	err = s.validateDocumentRefs(organizationID, d)
+	if err != nil {
+		return d, err
+	}
+
+	v, err := s.putDocumentFile(c)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = s.storage.AddDocument(d, v)
+	if err != nil {
+		s.removeAttachments(v.AttachmentKey)
+		return d, errors.New("failed to add document to storage: " +
+			err.Error())
+	}
+
+	return d, nil
+}
+
+// setDocument sets the document from the request.
+func (s *Server) setDocument(c echo.Context, organizationID int,
+	documentID int) (entity.Document, error) {
+
+	d, err := bindDocument(c)
+	if err != nil {
+		return d, err
+	}
+
+	d.ID = documentID
+	d.OrganizationID = organizationID
+
+	err = s.validateDocumentRefs(organizationID, d)
+	if err != nil {
+		return d, err
+	}
+
+	d, err = s.storage.SetDocument(d)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, echo.NewHTTPError(http.StatusNotFound,
+				"document not found")
+		}
+		return d, errors.New("failed to set document in storage: " +
+			err.Error())
+	}
+
+	return d, nil
+}
+
+// organizationDocument returns the organization document or 404 error.
+func (s *Server) organizationDocument(organizationID int, documentID int) (
+	entity.Document, error) {
+
+	d, err := s.storage.OrganizationDocument(organizationID, documentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return d, echo.NewHTTPError(http.StatusNotFound,
+				"document not found")
+		}
+		return d, errors.New(
+			"failed to get organization document from storage: " +
+				err.Error())
+	}
+
+	return d, nil
+}
+
+// addDocumentVersion adds the uploaded file as the next version of the
+// organization document.
+func (s *Server) addDocumentVersion(c echo.Context, organizationID int,
+	documentID int) (entity.DocumentVersion, error) {
+
+	_, err := s.organizationDocument(organizationID, documentID)
+	if err != nil {
+		return entity.DocumentVersion{}, err
+	}
+
+	v, err := s.putDocumentFile(c)
+	if err != nil {
+		return v, err
+	}
+
+	v.DocumentID = documentID
+
+	v, err = s.storage.AddDocumentVersion(v)
+	if err != nil {
+		s.removeAttachments(v.AttachmentKey)
+		if err == sql.ErrNoRows {
+			return v, echo.NewHTTPError(http.StatusNotFound,
+				"document not found")
+		}
+		return v, errors.New("failed to add document version to storage: " +
+			err.Error())
+	}
+
+	return v, nil
+}
+
+// organizationDocumentVersions returns the organization document versions,
+// the latest first.
+func (s *Server) organizationDocumentVersions(organizationID int,
+	documentID int) ([]entity.DocumentVersion, error) {
+
+	_, err := s.organizationDocument(organizationID, documentID)
+	if err != nil {
+		return nil, err
+	}
+
+	vs, err := s.storage.DocumentVersions(documentID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get document versions from storage: " + err.Error())
+	}
+
+	if vs == nil {
+		vs = []entity.DocumentVersion{}
+	}
+
+	return vs, nil
+}
+
+// removeDocument removes the organization document with files of all its
+// versions.
+func (s *Server) removeDocument(organizationID int, documentID int) error {
+	keys, err := s.storage.RemoveOrganizationDocument(organizationID,
+		documentID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization document from storage: " +
+				err.Error())
+	}
+
+	s.removeAttachments(keys...)
+
+	return nil
+}
+
+// documentVersion returns the document version or 404 error.
+func (s *Server) documentVersion(documentID int, versionID int) (
+	entity.DocumentVersion, error) {
+
+	v, err := s.storage.DocumentVersion(documentID, versionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, echo.NewHTTPError(http.StatusNotFound,
+				"document version not found")
+		}
+		return v, errors.New(
+			"failed to get document version from storage: " + err.Error())
+	}
+
+	return v, nil
+}
+
+// organizationDocumentVersion returns the version of the organization
+// document.
+func (s *Server) organizationDocumentVersion(organizationID int,
+	documentID int, versionID int) (entity.DocumentVersion, error) {
+
+	_, err := s.organizationDocument(organizationID, documentID)
+	if err != nil {
+		return entity.DocumentVersion{}, err
+	}
+
+	return s.documentVersion(documentID, versionID)
+}
+
+// ownerDocuments returns the documents the owner can see.
+func (s *Server) ownerDocuments(ownerID int) ([]entity.Document, error) {
+	ds, err := s.storage.OwnerDocuments(ownerID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get owner documents from storage: " + err.Error())
+	}
+
+	if ds == nil {
+		ds = []entity.Document{}
+	}
+
+	return ds, nil
+}
+
+// ownerDocumentVersion returns the latest version of the document the owner
+// can see and records the owner download of it.
+func (s *Server) ownerDocumentVersion(ownerID int, documentID int) (
+	entity.DocumentVersion, error) {
+
+	ds, err := s.ownerDocuments(ownerID)
+	if err != nil {
+		return entity.DocumentVersion{}, err
+	}
+
+	for _, d := range ds {
+		if d.ID != documentID {
+			continue
+		}
+
+		v, err := s.documentVersion(d.ID, d.VersionID)
+		if err != nil {
+			return v, err
+		}
+
+		err = s.storage.AddDocumentDownload(v.ID, ownerID, time.Now())
+		if err != nil {
+			s.log.WithError(err).WithField("owner_id", ownerID).
+				Error("failed to add document download to storage")
+		}
+
+		return v, nil
+	}
+
+	return entity.DocumentVersion{}, echo.NewHTTPError(http.StatusNotFound,
+		"document not found")
+}
+
+// downloadDocumentVersion sends the file of the document version.
+func (s *Server) downloadDocumentVersion(c echo.Context,
+	v entity.DocumentVersion) error {
+
+	f, err := s.attachmentStorage.Open(v.AttachmentKey)
+	if err != nil {
+		return errors.New("failed to open file from attachment storage: " +
+			err.Error())
+	}
+	defer f.Close()
+
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		"attachment; filename*=UTF-8''"+url.PathEscape(v.FileName))
+	c.Response().Header().Set(echo.HeaderXContentTypeOptions, "nosniff")
+
+	return c.Stream(http.StatusOK, v.ContentType, f)
+}
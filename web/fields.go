@@ -0,0 +1,318 @@
+package web
+
+import (
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/priority"
+)
+
+// requestsExportBatchSize is the number of requests fetched from storage at
+// once while exporting.
+const requestsExportBatchSize = 1000
+
+func bindLabel(c echo.Context) (entity.Label, error) {
+	var l entity.Label
+
+	err := c.Bind(&l)
+	if err != nil {
+		return l, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind label: "+err.Error())
+	}
+
+	l.Name = strings.TrimSpace(l.Name)
+
+	err = l.Validate()
+	if err != nil {
+		return l, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate label: "+err.Error())
+	}
+
+	return l, nil
+}
+
+// bindCustomField binds and validates custom field. Form passes enum
+// options one per line.
+func bindCustomField(c echo.Context) (entity.CustomField, error) {
+	var cf entity.CustomField
+
+	err := c.Bind(&cf)
+	if err != nil {
+		return cf, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind custom field: "+err.Error())
+	}
+
+	if cf.OptionsStr != "" {
+		cf.Options = nil
+		for _, o := range strings.Split(cf.OptionsStr, "\n") {
+			o = strings.TrimSpace(o)
+			if o != "" {
+				cf.Options = append(cf.Options, o)
+			}
+		}
+	}
+
+	if cf.Options == nil {
+		cf.Options = []string{}
+	}
+
+	cf.Name = strings.TrimSpace(cf.Name)
+
+	err = cf.Validate()
+	if err != nil {
+		return cf, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate custom field: "+err.Error())
+	}
+
+	return cf, nil
+}
+
+// attachRequestsAttributes attaches labels and custom field values to the
+// requests.
+func (s *Server) attachRequestsAttributes(rs []entity.RequestExtended) error {
+	if len(rs) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(rs))
+	index := map[int]int{}
+	for i, r := range rs {
+		ids = append(ids, r.ID)
+		index[r.ID] = i
+	}
+
+	rls, err := s.storage.RequestsLabels(ids)
+	if err != nil {
+		return errors.New("failed to get requests labels from storage: " +
+			err.Error())
+	}
+
+	for requestID, ls := range rls {
+		if i, ok := index[requestID]; ok {
+			rs[i].Labels = ls
+		}
+	}
+
+	fvs, err := s.storage.RequestsFieldValues(ids)
+	if err != nil {
+		return errors.New(
+			"failed to get requests field values from storage: " +
+				err.Error())
+	}
+
+	for _, fv := range fvs {
+		if i, ok := index[fv.RequestID]; ok {
+			rs[i].Fields = append(rs[i].Fields, fv)
+		}
+	}
+
+	return nil
+}
+
+// setRequestAttributes replaces labels and custom field values of the
+// request the staff actor has access to. Labels and fields should be of the
+// organization and values should be of the field types.
+func (s *Server) setRequestAttributes(actorRole string, actorID int,
+	organizationID int, requestID int, ra entity.RequestAttributes) error {
+
+	if len(ra.Fields) == 0 {
+		if len(ra.FieldIDs) != len(ra.FieldValues) {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"field IDs and values count mismatch")
+		}
+		for i, fieldID := range ra.FieldIDs {
+			ra.Fields = append(ra.Fields, entity.RequestFieldValue{
+				FieldID: fieldID,
+				Value:   ra.FieldValues[i],
+			})
+		}
+	}
+
+	_, err := s.staffRequest(actorRole, actorID, organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	labels := map[int]bool{}
+	for _, l := range ls {
+		labels[l.ID] = true
+	}
+
+	for _, labelID := range ra.LabelIDs {
+		if !labels[labelID] {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"label "+strconv.Itoa(labelID)+" not found")
+		}
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	fields := map[int]entity.CustomField{}
+	for _, cf := range cfs {
+		fields[cf.ID] = cf
+	}
+
+	var fvs []entity.RequestFieldValue
+
+	for _, fv := range ra.Fields {
+		cf, ok := fields[fv.FieldID]
+		if !ok {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"field "+strconv.Itoa(fv.FieldID)+" not found")
+		}
+
+		fv.Value = strings.TrimSpace(fv.Value)
+		if fv.Value == "" {
+			continue
+		}
+
+		err = cf.ValidateValue(fv.Value)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid value of field "+cf.Name+": "+err.Error())
+		}
+
+		fvs = append(fvs, fv)
+	}
+
+	err = s.storage.SetRequestAttributes(requestID, ra.LabelIDs, fvs)
+	if err != nil {
+		return errors.New(
+			"failed to set request attributes in storage: " + err.Error())
+	}
+
+	return nil
+}
+
+// exportRequests writes all organization requests selected by the filter
+// as CSV with a column per custom field. Requests are fetched from storage
+// and written in batches, so the export is never truncated.
+func (s *Server) exportRequests(c echo.Context, organizationID int,
+	f entity.RequestFilter) error {
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		"attachment; filename=requests-"+time.Now().Format("2006-01-02")+
+			".csv")
+	c.Response().WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(c.Response())
+
+	err = cw.Write(requestsCSVHeader(cfs))
+	if err != nil {
+		return err
+	}
+
+	f.Limit = requestsExportBatchSize
+
+	for {
+		rs, err := s.storage.OrganizationRequests(organizationID, f)
+		if err != nil {
+			return errors.New(
+				"failed to get organization requests from storage: " +
+					err.Error())
+		}
+
+		err = s.attachRequestsAttributes(rs)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range rs {
+			err = cw.Write(requestCSVRecord(r, cfs))
+			if err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+
+		err = cw.Error()
+		if err != nil {
+			return err
+		}
+
+		if len(rs) < f.Limit {
+			return nil
+		}
+
+		last := rs[len(rs)-1].Request
+
+		f.After = &entity.RequestCursor{
+			PriorityRank: priority.Rank(last.Priority),
+			CreatedAt:    last.CreatedAt,
+			ID:           last.ID,
+		}
+	}
+}
+
+// requestsCSVHeader returns header of the requests CSV with a column per
+// custom field.
+func requestsCSVHeader(cfs []entity.CustomField) []string {
+	header := []string{"id", "created_at", "status", "priority", "category",
+		"operator", "address", "labels"}
+	for _, cf := range cfs {
+		header = append(header, cf.Name)
+	}
+	return header
+}
+
+// requestCSVRecord returns the request with its labels and custom field
+// values as the requests CSV record.
+func requestCSVRecord(r entity.RequestExtended,
+	cfs []entity.CustomField) []string {
+
+	var category, operator string
+	if r.CategoryName != nil {
+		category = *r.CategoryName
+	}
+	if r.OperatorName != nil {
+		operator = *r.OperatorName
+	}
+
+	var labels []string
+	for _, l := range r.Labels {
+		labels = append(labels, l.Name)
+	}
+
+	record := []string{
+		strconv.Itoa(r.ID),
+		r.CreatedAt.In(time.Local).Format("2006-01-02 15:04"),
+		r.Status,
+		r.Priority,
+		category,
+		operator,
+		r.Address(),
+		strings.Join(labels, ", "),
+	}
+	for _, cf := range cfs {
+		record = append(record, r.FieldValue(cf.ID))
+	}
+
+	return record
+}
@@ -0,0 +1,198 @@
+package web
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/priority"
+	"github.com/dimuls/swan/entity/sorting"
+	"github.com/dimuls/swan/entity/status"
+)
+
+const (
+	requestsDefaultLimit = 50
+	requestsMaxLimit     = 200
+)
+
+// nextCursorHeader is a response header with the next page cursor of API
+// requests lists. Cursor is passed back in after query param.
+const nextCursorHeader = "X-Next-Cursor"
+
+// allStatuses is a statuses query param value to select requests of any
+// status.
+const allStatuses = "all"
+
+// parseRequestFilter parses request filter from query params. Sorting and
+// statuses default to defaultSorting and defaultStatuses if corresponding
+// params are empty.
+func parseRequestFilter(c echo.Context, defaultSorting string,
+	defaultStatuses ...string) (f entity.RequestFilter, err error) {
+
+	switch statuses := c.QueryParam("statuses"); statuses {
+	case "":
+		f.Statuses = defaultStatuses
+	case allStatuses:
+	default:
+		for _, s := range strings.Split(statuses, ",") {
+			s = strings.TrimSpace(s)
+			err = status.Validate(s)
+			if err != nil {
+				return f, errors.New("invalid statuses: " + err.Error())
+			}
+			f.Statuses = append(f.Statuses, s)
+		}
+	}
+
+	if organizationID := c.QueryParam("organization_id"); organizationID != "" {
+		id, err := strconv.Atoi(organizationID)
+		if err != nil {
+			return f, errors.New("failed to parse organization_id: " +
+				err.Error())
+		}
+		f.OrganizationID = &id
+	}
+
+	if categoryID := c.QueryParam("category_id"); categoryID != "" {
+		id, err := strconv.Atoi(categoryID)
+		if err != nil {
+			return f, errors.New("failed to parse category_id: " + err.Error())
+		}
+		f.CategoryID = &id
+	}
+
+	if operatorID := c.QueryParam("operator_id"); operatorID != "" {
+		id, err := strconv.Atoi(operatorID)
+		if err != nil {
+			return f, errors.New("failed to parse operator_id: " + err.Error())
+		}
+		f.OperatorID = &id
+	}
+
+	if labelID := c.QueryParam("label_id"); labelID != "" {
+		id, err := strconv.Atoi(labelID)
+		if err != nil {
+			return f, errors.New("failed to parse label_id: " + err.Error())
+		}
+		f.LabelID = &id
+	}
+
+	if fieldID := c.QueryParam("field_id"); fieldID != "" {
+		id, err := strconv.Atoi(fieldID)
+		if err != nil {
+			return f, errors.New("failed to parse field_id: " + err.Error())
+		}
+		f.FieldID = &id
+		f.FieldValue = strings.TrimSpace(c.QueryParam("field_value"))
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			return f, errors.New("failed to parse from: " + err.Error())
+		}
+		f.From = &t
+	}
+
+	if to := c.QueryParam("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			return f, errors.New("failed to parse to: " + err.Error())
+		}
+		// To date is inclusive.
+		t = t.AddDate(0, 0, 1)
+		f.To = &t
+	}
+
+	if overdue := c.QueryParam("overdue"); overdue != "" {
+		f.Overdue, err = strconv.ParseBool(overdue)
+		if err != nil {
+			return f, errors.New("failed to parse overdue: " + err.Error())
+		}
+	}
+
+	f.OwnerAddress = strings.TrimSpace(c.QueryParam("address"))
+	f.Query = strings.TrimSpace(c.QueryParam("q"))
+
+	f.Sorting = c.QueryParam("sort")
+	if f.Sorting == "" {
+		f.Sorting = defaultSorting
+	}
+	err = sorting.Validate(f.Sorting)
+	if err != nil {
+		return f, errors.New("invalid sort: " + err.Error())
+	}
+
+	if after := c.QueryParam("after"); after != "" {
+		rc, err := parseRequestCursor(after)
+		if err != nil {
+			return f, errors.New("failed to parse after: " + err.Error())
+		}
+		f.After = &rc
+	}
+
+	f.Limit = requestsDefaultLimit
+	if limit := c.QueryParam("limit"); limit != "" {
+		f.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return f, errors.New("failed to parse limit: " + err.Error())
+		}
+		if f.Limit <= 0 || f.Limit > requestsMaxLimit {
+			return f, fmt.Errorf("limit must be in [1, %d]", requestsMaxLimit)
+		}
+	}
+
+	return f, nil
+}
+
+func formatRequestCursor(r entity.Request) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(
+		"%d.%d.%d", priority.Rank(r.Priority), r.CreatedAt.UnixNano(), r.ID)))
+}
+
+func parseRequestCursor(cursor string) (rc entity.RequestCursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return rc, err
+	}
+
+	var createdAt int64
+
+	_, err = fmt.Sscanf(string(b), "%d.%d.%d", &rc.PriorityRank, &createdAt,
+		&rc.ID)
+	if err != nil {
+		return rc, err
+	}
+
+	rc.CreatedAt = time.Unix(0, createdAt)
+
+	return rc, nil
+}
+
+// nextRequestsCursor trims requests fetched with limit increased by one to
+// the filter limit and returns cursor of the next page if there is any.
+func nextRequestsCursor(rs []entity.RequestExtended, limit int) (
+	[]entity.RequestExtended, string) {
+	if len(rs) <= limit {
+		return rs, ""
+	}
+	rs = rs[:limit]
+	return rs, formatRequestCursor(rs[limit-1].Request)
+}
+
+// nextPageURL returns URL of the current page with after query param set to
+// the cursor.
+func nextPageURL(c echo.Context, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	q := c.QueryParams()
+	q.Set("after", cursor)
+	return c.Request().URL.Path + "?" + q.Encode()
+}
@@ -0,0 +1,145 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/household"
+)
+
+// bindOwner binds and validates owner. Owner without household role is the
+// primary owner of the flat. Zero share from the form means no share.
+func bindOwner(c echo.Context) (entity.Owner, error) {
+	var o entity.Owner
+
+	err := c.Bind(&o)
+	if err != nil {
+		return o, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind owner: "+err.Error())
+	}
+
+	if o.HouseholdRole == "" {
+		o.HouseholdRole = household.Owner
+	}
+
+	if o.Share != nil && *o.Share == 0 {
+		o.Share = nil
+	}
+
+	err = o.Validate()
+	if err != nil {
+		return o, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate owner: "+err.Error())
+	}
+
+	return o, nil
+}
+
+// addOwner adds the owner to storage. Returns conflict error if the phone is
+// taken or the owner flat already has primary owner.
+func (s *Server) addOwner(o entity.Owner) (entity.Owner, error) {
+	o, err := s.storage.AddOwner(o)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return o, echo.NewHTTPError(http.StatusConflict,
+				"phone is taken or flat already has primary owner")
+		}
+		return o, errors.New("failed to add owner to storage: " + err.Error())
+	}
+	return o, nil
+}
+
+// setOwner sets the owner to storage. Returns conflict error if the phone is
+// taken or the owner flat already has primary owner.
+func (s *Server) setOwner(o entity.Owner) (entity.Owner, error) {
+	o, err := s.storage.SetOwner(o)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return o, echo.NewHTTPError(http.StatusConflict,
+				"phone is taken or flat already has primary owner")
+		}
+		return o, errors.New("failed to set owner to storage: " + err.Error())
+	}
+	return o, nil
+}
+
+// inviteHouseholdMember adds the resident to the flat of the primary owner
+// and notifies the resident by SMS. Resident sets password by the usual
+// registration using the phone.
+func (s *Server) inviteHouseholdMember(primary entity.Owner,
+	m entity.Owner) (entity.Owner, error) {
+
+	if !primary.PrimaryOwner() {
+		return m, echo.NewHTTPError(http.StatusForbidden,
+			"only primary owner can invite household members")
+	}
+
+	m.Phone = strings.TrimSpace(m.Phone)
+	m.Name = strings.TrimSpace(m.Name)
+
+	if m.Phone == "" {
+		return m, echo.NewHTTPError(http.StatusBadRequest, "phone required")
+	}
+
+	if m.HouseholdRole == household.Owner {
+		return m, echo.NewHTTPError(http.StatusBadRequest,
+			"flat can have only one primary owner")
+	}
+
+	err := household.Validate(m.HouseholdRole)
+	if err != nil {
+		return m, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate household role: "+err.Error())
+	}
+
+	m.ID = 0
+	m.PasswordHash = nil
+	m.OrganizationID = primary.OrganizationID
+	m.FlatID = primary.FlatID
+	m.Address = primary.Address
+
+	m, err = s.addOwner(m)
+	if err != nil {
+		return m, err
+	}
+
+	err = s.smsSender.SendSMS(m.Phone, fmt.Sprintf(
+		"Вас добавили жильцом квартиры: %s. Для входа установите пароль "+
+			"по номеру телефона.", primary.Address))
+	if err != nil {
+		s.log.WithError(err).WithField("owner_id", m.ID).
+			Error("failed to send household invitation SMS")
+	}
+
+	return m, nil
+}
+
+// removeHouseholdMember unlinks the resident from the flat of the primary
+// owner, so the resident no longer sees the household requests.
+func (s *Server) removeHouseholdMember(primary entity.Owner,
+	memberID int) error {
+
+	if !primary.PrimaryOwner() {
+		return echo.NewHTTPError(http.StatusForbidden,
+			"only primary owner can remove household members")
+	}
+
+	err := s.storage.RemoveHouseholdMember(primary.ID, memberID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound,
+				"household member not found")
+		}
+		return errors.New(
+			"failed to remove household member from storage: " +
+				err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,448 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/decimal"
+	"github.com/dimuls/swan/entity/provider"
+)
+
+// paymentData is the amount the resident pays.
+type paymentData struct {
+	Amount    decimal.Money `json:"amount" form:"-"`
+	AmountStr string        `json:"-" form:"amount"`
+}
+
+// bindPaymentData binds the amount the resident pays.
+func bindPaymentData(c echo.Context) (paymentData, error) {
+	var pd paymentData
+
+	err := c.Bind(&pd)
+	if err != nil {
+		return pd, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind payment: "+err.Error())
+	}
+
+	if pd.AmountStr != "" {
+		pd.Amount, err = decimal.ParseMoney(pd.AmountStr)
+		if err != nil {
+			return pd, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse amount: "+err.Error())
+		}
+	}
+
+	return pd, nil
+}
+
+// readCharges reads charges CSV with header: account;period;service;amount.
+// Period is in 2006-01 format. Flats maps accounts to the flat IDs.
+func readCharges(r io.Reader, flats map[string]int) ([]entity.Charge,
+	error) {
+
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.FieldsPerRecord = 4
+
+	_, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("failed to read header: " + err.Error())
+	}
+
+	now := time.Now()
+
+	var cs []entity.Charge
+
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("failed to read record: " + err.Error())
+		}
+
+		l := " at line " + strconv.Itoa(line)
+
+		flatID, ok := flats[strings.TrimSpace(rec[0])]
+		if !ok {
+			return nil, errors.New("unknown account" + l)
+		}
+
+		period, err := time.ParseInLocation("2006-01",
+			strings.TrimSpace(rec[1]), time.UTC)
+		if err != nil {
+			return nil, errors.New("failed to parse period" + l)
+		}
+
+		amount, err := decimal.ParseMoney(rec[3])
+		if err != nil {
+			return nil, errors.New("failed to parse amount" + l)
+		}
+
+		c := entity.Charge{
+			FlatID:    flatID,
+			Period:    period,
+			Service:   strings.TrimSpace(rec[2]),
+			Amount:    amount,
+			CreatedAt: now,
+		}
+
+		err = c.Validate()
+		if err != nil {
+			return nil, errors.New(err.Error() + l)
+		}
+
+		cs = append(cs, c)
+	}
+
+	return cs, nil
+}
+
+// readPayments reads payments CSV with header:
+// account;amount;paid_at;external_id. Payment time is in 2006-01-02 15:04
+// or 2006-01-02 format of the local time. Flats maps accounts to the flat
+// IDs.
+func readPayments(r io.Reader, flats map[string]int) ([]entity.Payment,
+	error) {
+
+	cr := csv.NewReader(r)
+	cr.Comma = ';'
+	cr.FieldsPerRecord = 4
+
+	_, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("failed to read header: " + err.Error())
+	}
+
+	now := time.Now()
+
+	var ps []entity.Payment
+
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("failed to read record: " + err.Error())
+		}
+
+		l := " at line " + strconv.Itoa(line)
+
+		flatID, ok := flats[strings.TrimSpace(rec[0])]
+		if !ok {
+			return nil, errors.New("unknown account" + l)
+		}
+
+		amount, err := decimal.ParseMoney(rec[1])
+		if err != nil {
+			return nil, errors.New("failed to parse amount" + l)
+		}
+
+		paidAt, err := time.ParseInLocation("2006-01-02 15:04",
+			strings.TrimSpace(rec[2]), time.Local)
+		if err != nil {
+			paidAt, err = time.ParseInLocation("2006-01-02",
+				strings.TrimSpace(rec[2]), time.Local)
+			if err != nil {
+				return nil, errors.New("failed to parse payment time" + l)
+			}
+		}
+
+		p := entity.Payment{
+			FlatID:     flatID,
+			Amount:     amount,
+			PaidAt:     paidAt,
+			Provider:   provider.Import,
+			ExternalID: strings.TrimSpace(rec[3]),
+			CreatedAt:  now,
+		}
+
+		err = p.Validate()
+		if err != nil {
+			return nil, errors.New(err.Error() + l)
+		}
+
+		ps = append(ps, p)
+	}
+
+	return ps, nil
+}
+
+// accountFlats maps accounts of the organization flats to the flat IDs.
+// Accounts are unique within the organization.
+func (s *Server) accountFlats(organizationID int) (map[string]int, error) {
+	fs, err := s.storage.OrganizationFlats(organizationID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get organization flats from storage: " + err.Error())
+	}
+
+	flats := map[string]int{}
+	for _, f := range fs {
+		if f.Account != "" {
+			flats[f.Account] = f.ID
+		}
+	}
+
+	return flats, nil
+}
+
+// importCharges sets the organization flats charges from the CSV and
+// returns the number of the imported charges.
+func (s *Server) importCharges(organizationID int, r io.Reader) (int,
+	error) {
+
+	flats, err := s.accountFlats(organizationID)
+	if err != nil {
+		return 0, err
+	}
+
+	cs, err := readCharges(r, flats)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to read charges: "+err.Error())
+	}
+
+	err = s.storage.SetCharges(cs)
+	if err != nil {
+		return 0, errors.New("failed to set charges in storage: " +
+			err.Error())
+	}
+
+	return len(cs), nil
+}
+
+// importPayments adds the organization flats payments from the CSV and
+// returns the number of the added payments.
+func (s *Server) importPayments(organizationID int, r io.Reader) (int,
+	error) {
+
+	flats, err := s.accountFlats(organizationID)
+	if err != nil {
+		return 0, err
+	}
+
+	ps, err := readPayments(r, flats)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to read payments: "+err.Error())
+	}
+
+	n, err := s.storage.AddPayments(ps)
+	if err != nil {
+		return 0, errors.New("failed to add payments to storage: " +
+			err.Error())
+	}
+
+	return n, nil
+}
+
+// receivePayment records the payment from the payment provider callback.
+// Repeated callback of the same payment is ignored.
+func (s *Server) receivePayment(r *http.Request) error {
+	p, err := s.paymentProvider.ParsePaymentCallback(r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse payment callback: "+err.Error())
+	}
+
+	p.CreatedAt = time.Now()
+
+	err = p.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate payment: "+err.Error())
+	}
+
+	_, err = s.storage.AddPayments([]entity.Payment{p})
+	if err != nil {
+		return errors.New("failed to add payment to storage: " + err.Error())
+	}
+
+	return nil
+}
+
+// ownerLedger returns the ledger of the owner flat.
+func (s *Server) ownerLedger(owner entity.Owner) (entity.Ledger, error) {
+	l := entity.Ledger{
+		Charges:  []entity.Charge{},
+		Payments: []entity.Payment{},
+	}
+
+	if owner.FlatID == nil {
+		return l, nil
+	}
+
+	l.FlatID = *owner.FlatID
+
+	cs, err := s.storage.FlatCharges(l.FlatID)
+	if err != nil {
+		return l, errors.New("failed to get flat charges from storage: " +
+			err.Error())
+	}
+
+	ps, err := s.storage.FlatPayments(l.FlatID)
+	if err != nil {
+		return l, errors.New("failed to get flat payments from storage: " +
+			err.Error())
+	}
+
+	for _, c := range cs {
+		l.Charged += c.Amount
+		l.Charges = append(l.Charges, c)
+	}
+
+	for _, p := range ps {
+		l.Paid += p.Amount
+		l.Payments = append(l.Payments, p)
+	}
+
+	l.Balance = l.Charged - l.Paid
+
+	return l, nil
+}
+
+// ownerPaymentURL returns the payment provider URL the owner pays the amount
+// for the flat at.
+func (s *Server) ownerPaymentURL(owner entity.Owner, amount decimal.Money,
+	returnURL string) (string, error) {
+
+	if s.paymentProvider == nil {
+		return "", echo.NewHTTPError(http.StatusNotImplemented,
+			"payments are not available")
+	}
+
+	if owner.FlatID == nil {
+		return "", echo.NewHTTPError(http.StatusBadRequest,
+			"owner has no flat")
+	}
+
+	if amount <= 0 {
+		return "", echo.NewHTTPError(http.StatusBadRequest,
+			"amount must be positive")
+	}
+
+	id := make([]byte, 16)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", errors.New("failed to generate payment ID: " +
+			err.Error())
+	}
+
+	u, err := s.paymentProvider.PaymentURL(entity.Payment{
+		FlatID:     *owner.FlatID,
+		Amount:     amount,
+		ExternalID: hex.EncodeToString(id),
+	}, returnURL)
+	if err != nil {
+		return "", errors.New("failed to get payment URL: " + err.Error())
+	}
+
+	return u, nil
+}
+
+// organizationDebtors returns the organization debtors with the debt not
+// less than minDebt. Empty minDebt means any debt.
+func (s *Server) organizationDebtors(organizationID int,
+	minDebt string) ([]entity.Debtor, error) {
+
+	var md decimal.Money
+
+	if minDebt != "" {
+		var err error
+		md, err = decimal.ParseMoney(minDebt)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse min_debt: "+err.Error())
+		}
+	}
+
+	ds, err := s.storage.OrganizationDebtors(organizationID, md)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get organization debtors from storage: " +
+				err.Error())
+	}
+
+	if ds == nil {
+		ds = []entity.Debtor{}
+	}
+
+	return ds, nil
+}
+
+// writeDebtorsCSV writes the debtors report as CSV.
+func writeDebtorsCSV(w io.Writer, ds []entity.Debtor) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"flat", "account", "charged", "paid", "debt",
+		"last_paid_at"})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range ds {
+		var lastPaidAt string
+		if d.LastPaidAt != nil {
+			lastPaidAt = d.LastPaidAt.In(time.Local).Format("2006-01-02")
+		}
+
+		err = cw.Write([]string{
+			d.FlatAddress,
+			d.Account,
+			d.Charged.String(),
+			d.Paid.String(),
+			d.Debt.String(),
+			lastPaidAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func exportDebtors(c echo.Context, ds []entity.Debtor) error {
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		"attachment; filename=debtors-"+time.Now().Format("2006-01-02")+
+			".csv")
+	c.Response().WriteHeader(http.StatusOK)
+
+	return writeDebtorsCSV(c.Response(), ds)
+}
+
+// localURL checks that the URL is a path of this site, so redirect to it is
+// not an open redirect. Backslashes are rejected since browsers treat them
+// as slashes.
+func localURL(u string) bool {
+	if strings.Contains(u, `\`) {
+		return false
+	}
+
+	pu, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+
+	return pu.Scheme == "" && pu.Host == "" && pu.User == nil &&
+		strings.HasPrefix(pu.Path, "/") && !strings.HasPrefix(u, "//") &&
+		!strings.Contains(pu.Path, `\`)
+}
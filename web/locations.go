@@ -0,0 +1,229 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/dedup"
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/priority"
+	"github.com/dimuls/swan/entity/status"
+)
+
+// normalizeLocation unsets location fields left empty in the form.
+func normalizeLocation(r entity.Request) entity.Request {
+	trim := func(s *string) *string {
+		if s == nil {
+			return nil
+		}
+		t := strings.TrimSpace(*s)
+		if t == "" {
+			return nil
+		}
+		return &t
+	}
+
+	r.Building = trim(r.Building)
+	r.Entrance = trim(r.Entrance)
+	r.CommonArea = trim(r.CommonArea)
+
+	// Floors are numbered from one, so zero is an empty form field.
+	if r.Floor != nil && *r.Floor == 0 {
+		r.Floor = nil
+	}
+
+	return r
+}
+
+// ownerRequestLocation locates the owner request. Common area request is
+// located in the owner building, other requests are located at the owner
+// flat. The flat is kept in the request, so it stays with the flat household
+// when the owner moves out.
+func ownerRequestLocation(r entity.Request, owner entity.Owner) (
+	entity.Request, error) {
+
+	r = normalizeLocation(r)
+	r.FlatID = owner.FlatID
+
+	if r.CommonArea == nil {
+		r.Building = nil
+		r.Entrance = nil
+		r.Floor = nil
+		return r, nil
+	}
+
+	err := r.Validate()
+	if err != nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request: "+err.Error())
+	}
+
+	building := dedup.StripFlat(owner.Address)
+	r.Building = &building
+
+	return r, nil
+}
+
+// addStaffRequest adds common area request spotted by the staff actor.
+// Request has no owner and is classified and routed as owner requests.
+func (s *Server) addStaffRequest(actorRole string, actorID int,
+	organizationID int, r entity.Request) (entity.Request, error) {
+
+	r = normalizeLocation(r)
+
+	if r.Building == nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"building required")
+	}
+
+	if r.CommonArea == nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"common area required")
+	}
+
+	building, err := s.normalizeBuildingAddress(*r.Building)
+	if err != nil {
+		return r, err
+	}
+	r.Building = &building
+
+	r.Text = strings.TrimSpace(r.Text)
+	if r.Text == "" {
+		return r, echo.NewHTTPError(http.StatusBadRequest, "text required")
+	}
+
+	if r.Priority == "" {
+		r.Priority = priority.Normal
+	}
+
+	err = priority.Validate(r.Priority)
+	if err != nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid priority: "+err.Error())
+	}
+
+	err = r.Validate()
+	if err != nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request: "+err.Error())
+	}
+
+	r.ID = 0
+	r.OrganizationID = organizationID
+	r.OwnerID = nil
+	r.FlatID = nil
+	r.CreatorRole = actorRole
+	r.CreatorID = actorID
+	r.OperatorID = nil
+	r.PrimaryRequestID = nil
+	r.IncidentID = nil
+	r.Response = nil
+	r.Status = status.New
+
+	r = s.classifyRequest(r)
+
+	rds, err := s.findDuplicates(r, *r.Building)
+	if err != nil {
+		s.log.WithError(err).Error("failed to find request duplicates")
+	}
+
+	r, o := s.routeRequest(r)
+
+	r, err = s.storage.AddRequest(r)
+	if err != nil {
+		return r, errors.New("failed to add request to storage: " +
+			err.Error())
+	}
+
+	s.addRequestDuplicates(r, rds)
+	s.applyChecklistTemplate(r)
+	s.notifyEmergency(r, o)
+
+	return r, nil
+}
+
+// buildingCommonRequests returns open common area requests of the owner
+// building without details of the owners who filed them. Requests filed or
+// supported by the owner are marked.
+func (s *Server) buildingCommonRequests(owner entity.Owner) (
+	[]entity.CommonRequest, error) {
+
+	rs, err := s.storage.OpenOrganizationCommonRequests(owner.OrganizationID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get open organization common requests from storage: " +
+				err.Error())
+	}
+
+	ids, err := s.storage.OwnerSupportedRequests(owner.ID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get owner supported requests from storage: " +
+				err.Error())
+	}
+
+	supported := map[int]bool{}
+	for _, id := range ids {
+		supported[id] = true
+	}
+
+	building := dedup.BuildingAddress(owner.Address)
+
+	var crs []entity.CommonRequest
+
+	for _, r := range rs {
+		if dedup.BuildingAddress(r.Address()) != building {
+			continue
+		}
+		own := r.OwnerID != nil && *r.OwnerID == owner.ID
+		crs = append(crs, entity.CommonRequest{
+			ID:            r.ID,
+			Building:      r.Building,
+			Entrance:      r.Entrance,
+			Floor:         r.Floor,
+			CommonArea:    r.CommonArea,
+			Text:          r.Text,
+			Status:        r.Status,
+			CreatedAt:     r.CreatedAt,
+			SupportsCount: r.SupportsCount,
+			Supported:     supported[r.ID] || own,
+			Own:           own,
+		})
+	}
+
+	return crs, nil
+}
+
+// supportRequest records that the common area problem concerns the owner
+// too. The owner can support open common area requests of their building
+// only.
+func (s *Server) supportRequest(owner entity.Owner, requestID int) error {
+	rs, err := s.buildingCommonRequests(owner)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range rs {
+		if r.ID != requestID {
+			continue
+		}
+
+		if r.Own {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"owner can't support their own request")
+		}
+
+		err = s.storage.AddRequestSupport(requestID, owner.ID)
+		if err != nil {
+			return errors.New("failed to add request support to storage: " +
+				err.Error())
+		}
+
+		return nil
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound, "request not found")
+}
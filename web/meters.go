@@ -0,0 +1,258 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+)
+
+const (
+	// outlierHistory is the number of the previous readings the usual
+	// consumption is calculated by.
+	outlierHistory = 6
+
+	// outlierFactor is how many times consumption must exceed the usual one
+	// to be an outlier.
+	outlierFactor = 3
+)
+
+// bindMeter binds and validates meter. Form passes verification date in
+// 2006-01-02 format.
+func bindMeter(c echo.Context) (entity.Meter, error) {
+	var m entity.Meter
+
+	err := c.Bind(&m)
+	if err != nil {
+		return m, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind meter: "+err.Error())
+	}
+
+	if m.VerificationDateStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", m.VerificationDateStr,
+			time.UTC)
+		if err != nil {
+			return m, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse verification date: "+err.Error())
+		}
+		m.VerificationDate = &t
+	}
+
+	m.SerialNumber = strings.TrimSpace(m.SerialNumber)
+
+	err = m.Validate()
+	if err != nil {
+		return m, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate meter: "+err.Error())
+	}
+
+	return m, nil
+}
+
+// validateMeterFlat checks that the meter flat is of the organization.
+func (s *Server) validateMeterFlat(organizationID int, m entity.Meter) error {
+	_, err := s.storage.OrganizationFlat(organizationID, m.FlatID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest, "flat not found")
+		}
+		return errors.New(
+			"failed to get organization flat from storage: " + err.Error())
+	}
+	return nil
+}
+
+// ownerMeters returns meters of the owner flat.
+func (s *Server) ownerMeters(owner entity.Owner) ([]entity.Meter, error) {
+	if owner.FlatID == nil {
+		return []entity.Meter{}, nil
+	}
+
+	ms, err := s.storage.FlatMeters(*owner.FlatID)
+	if err != nil {
+		return nil, errors.New("failed to get flat meters from storage: " +
+			err.Error())
+	}
+
+	if ms == nil {
+		ms = []entity.Meter{}
+	}
+
+	return ms, nil
+}
+
+// meterReadingOutlier checks that consumption since the previous reading is
+// much higher than the average one. Previous readings are ordered from the
+// latest. Few readings are not enough to judge.
+func meterReadingOutlier(value float64, prev []entity.MeterReading) bool {
+	if len(prev) < 3 {
+		return false
+	}
+
+	avg := (prev[0].Value - prev[len(prev)-1].Value) / float64(len(prev)-1)
+	if avg <= 0 {
+		return false
+	}
+
+	return value-prev[0].Value > outlierFactor*avg
+}
+
+// submitMeterReading sets the current month reading of the owner flat
+// meter. Reading is accepted within the monthly window only and must not be
+// less than the previous one. Resubmission within the window replaces the
+// reading.
+func (s *Server) submitMeterReading(owner entity.Owner,
+	r entity.MeterReading) (entity.MeterReading, error) {
+
+	if owner.FlatID == nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"owner has no flat")
+	}
+
+	_, err := s.storage.FlatMeter(*owner.FlatID, r.MeterID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return r, echo.NewHTTPError(http.StatusNotFound,
+				"meter not found")
+		}
+		return r, errors.New("failed to get flat meter from storage: " +
+			err.Error())
+	}
+
+	now := time.Now()
+
+	period, open := entity.MeterReadingsPeriod(now)
+	if !open {
+		return r, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf(
+			"meter readings are accepted from %d to %d day of month",
+			entity.MeterReadingsFromDay, entity.MeterReadingsToDay))
+	}
+
+	err = r.Validate()
+	if err != nil {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate meter reading: "+err.Error())
+	}
+
+	prev, err := s.storage.PreviousMeterReadings(r.MeterID, period,
+		outlierHistory)
+	if err != nil {
+		return r, errors.New(
+			"failed to get previous meter readings from storage: " +
+				err.Error())
+	}
+
+	if len(prev) > 0 && r.Value < prev[0].Value {
+		return r, echo.NewHTTPError(http.StatusBadRequest,
+			"value is less than previous one")
+	}
+
+	r.ID = 0
+	r.Period = period
+	r.Outlier = meterReadingOutlier(r.Value, prev)
+	r.OwnerID = &owner.ID
+	r.SubmittedAt = now
+
+	r, err = s.storage.SetMeterReading(r)
+	if err != nil {
+		return r, errors.New("failed to set meter reading in storage: " +
+			err.Error())
+	}
+
+	return r, nil
+}
+
+// organizationMeterReadings returns organization meter readings of the
+// month in 2006-01 format.
+func (s *Server) organizationMeterReadings(organizationID int,
+	month string) (time.Time, []entity.MeterReadingExtended, error) {
+
+	from, _, err := parseMonth(month)
+	if err != nil {
+		return from, nil, echo.NewHTTPError(http.StatusBadRequest,
+			err.Error())
+	}
+
+	period := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	rs, err := s.storage.OrganizationMeterReadings(organizationID, period)
+	if err != nil {
+		return from, nil, errors.New(
+			"failed to get organization meter readings from storage: " +
+				err.Error())
+	}
+
+	if rs == nil {
+		rs = []entity.MeterReadingExtended{}
+	}
+
+	return from, rs, nil
+}
+
+// writeMeterReadingsCSV writes meter readings as CSV for the billing.
+func writeMeterReadingsCSV(w io.Writer,
+	rs []entity.MeterReadingExtended) error {
+
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"flat", "meter_type", "serial_number",
+		"period", "value", "previous_value", "consumption", "outlier",
+		"owner", "submitted_at"})
+	if err != nil {
+		return err
+	}
+
+	formatValue := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+
+	for _, r := range rs {
+		var owner string
+		if r.OwnerName != nil {
+			owner = *r.OwnerName
+		}
+
+		err = cw.Write([]string{
+			r.FlatAddress,
+			r.MeterType,
+			r.SerialNumber,
+			r.Period.Format("2006-01"),
+			formatValue(&r.Value),
+			formatValue(r.PreviousValue),
+			formatValue(r.Consumption()),
+			strconv.FormatBool(r.Outlier),
+			owner,
+			r.SubmittedAt.In(time.Local).Format("2006-01-02 15:04"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func exportMeterReadings(c echo.Context, from time.Time,
+	rs []entity.MeterReadingExtended) error {
+
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		"attachment; filename=meter-readings-"+from.Format("2006-01")+".csv")
+	c.Response().WriteHeader(http.StatusOK)
+
+	return writeMeterReadingsCSV(c.Response(), rs)
+}
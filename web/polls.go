@@ -0,0 +1,577 @@
+package web
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/household"
+	"github.com/dimuls/swan/entity/weighting"
+)
+
+const (
+	// protocolSignaturePrefix starts the last line of the poll protocol
+	// holding the signature of the lines above.
+	protocolSignaturePrefix = "HMAC-SHA256: "
+
+	// maxProtocolSize limits the size of the protocol to verify.
+	maxProtocolSize = 1 << 20
+)
+
+// bindPoll binds and validates poll. Form passes voting start and end in
+// 2006-01-02T15:04 format of the local time.
+func bindPoll(c echo.Context) (entity.Poll, error) {
+	var p entity.Poll
+
+	err := c.Bind(&p)
+	if err != nil {
+		return p, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll: "+err.Error())
+	}
+
+	if p.StartsAtStr != "" {
+		p.StartsAt, err = time.ParseInLocation("2006-01-02T15:04",
+			p.StartsAtStr, time.Local)
+		if err != nil {
+			return p, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse voting start: "+err.Error())
+		}
+	}
+
+	if p.EndsAtStr != "" {
+		p.EndsAt, err = time.ParseInLocation("2006-01-02T15:04",
+			p.EndsAtStr, time.Local)
+		if err != nil {
+			return p, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse voting end: "+err.Error())
+		}
+	}
+
+	p.Title = strings.TrimSpace(p.Title)
+	p.Description = strings.TrimSpace(p.Description)
+
+	err = p.Validate()
+	if err != nil {
+		return p, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate poll: "+err.Error())
+	}
+
+	return p, nil
+}
+
+// validatePollBuilding checks that the poll building is of the organization.
+func (s *Server) validatePollBuilding(organizationID int, p entity.Poll) error {
+	_, err := s.storage.OrganizationBuilding(organizationID, p.BuildingID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"building not found")
+		}
+		return errors.New(
+			"failed to get organization building from storage: " +
+				err.Error())
+	}
+	return nil
+}
+
+// bindPollQuestion binds and validates poll question. Form passes options
+// one per line.
+func bindPollQuestion(c echo.Context) (entity.PollQuestion, error) {
+	var q entity.PollQuestion
+
+	err := c.Bind(&q)
+	if err != nil {
+		return q, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll question: "+err.Error())
+	}
+
+	if q.OptionsStr != "" {
+		q.Options = nil
+		for _, o := range strings.Split(q.OptionsStr, "\n") {
+			o = strings.TrimSpace(o)
+			if o != "" {
+				q.Options = append(q.Options, o)
+			}
+		}
+	}
+
+	q.Text = strings.TrimSpace(q.Text)
+
+	err = q.Validate()
+	if err != nil {
+		return q, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate poll question: "+err.Error())
+	}
+
+	return q, nil
+}
+
+// organizationPollNotStarted returns the organization poll which questions
+// can be changed, so owners vote on the same questions.
+func (s *Server) organizationPollNotStarted(organizationID int,
+	pollID int) (entity.Poll, error) {
+
+	p, err := s.storage.OrganizationPoll(organizationID, pollID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, echo.NewHTTPError(http.StatusNotFound, "poll not found")
+		}
+		return p, errors.New(
+			"failed to get organization poll from storage: " + err.Error())
+	}
+
+	if p.Started(time.Now()) {
+		return p, echo.NewHTTPError(http.StatusBadRequest,
+			"poll is already started")
+	}
+
+	return p, nil
+}
+
+// addPollQuestion adds the question to the organization poll.
+func (s *Server) addPollQuestion(organizationID int,
+	q entity.PollQuestion) (entity.PollQuestion, error) {
+
+	_, err := s.organizationPollNotStarted(organizationID, q.PollID)
+	if err != nil {
+		return q, err
+	}
+
+	q, err = s.storage.AddPollQuestion(q)
+	if err != nil {
+		return q, errors.New("failed to add poll question to storage: " +
+			err.Error())
+	}
+
+	return q, nil
+}
+
+// removePollQuestion removes the question from the organization poll.
+func (s *Server) removePollQuestion(organizationID int, pollID int,
+	questionID int) error {
+
+	_, err := s.organizationPollNotStarted(organizationID, pollID)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.RemovePollQuestion(pollID, questionID)
+	if err != nil {
+		return errors.New("failed to remove poll question from storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// pollVoter checks that the owner owns a flat of the building.
+func pollVoter(o entity.Owner) bool {
+	return o.FlatID != nil && (o.HouseholdRole == household.Owner ||
+		o.HouseholdRole == household.CoOwner)
+}
+
+// pollWeights returns the vote weights of the building owners and the
+// weight of the whole building. Flat weight is 1 or the flat area by the
+// poll weighting. Owners with share get the share of the flat weight, the
+// rest of the flat weight is split equally between owners without share.
+func (s *Server) pollWeights(p entity.Poll) (map[int]float64, float64,
+	error) {
+
+	fs, err := s.storage.BuildingFlats(p.BuildingID)
+	if err != nil {
+		return nil, 0, errors.New(
+			"failed to get building flats from storage: " + err.Error())
+	}
+
+	os, err := s.storage.BuildingOwners(p.BuildingID)
+	if err != nil {
+		return nil, 0, errors.New(
+			"failed to get building owners from storage: " + err.Error())
+	}
+
+	flatOwners := map[int][]entity.Owner{}
+	for _, o := range os {
+		if pollVoter(o) {
+			flatOwners[*o.FlatID] = append(flatOwners[*o.FlatID], o)
+		}
+	}
+
+	weights := map[int]float64{}
+
+	var total float64
+
+	for _, f := range fs {
+		w := 1.0
+		if p.Weighting == weighting.Area {
+			w = 0
+			if f.Area != nil {
+				w = *f.Area
+			}
+		}
+
+		total += w
+
+		rest := w
+		var unshared []entity.Owner
+
+		for _, o := range flatOwners[f.ID] {
+			if o.Share == nil {
+				unshared = append(unshared, o)
+				continue
+			}
+			weights[o.ID] = *o.Share * w
+			rest -= weights[o.ID]
+		}
+
+		if rest < 0 {
+			rest = 0
+		}
+
+		for _, o := range unshared {
+			weights[o.ID] = rest / float64(len(unshared))
+		}
+	}
+
+	return weights, total, nil
+}
+
+// pollResults counts the poll votes. Option percent is of the weight voted
+// on the question.
+func (s *Server) pollResults(p entity.Poll) (entity.PollResults, error) {
+	pr := entity.PollResults{Poll: p}
+
+	qs, err := s.storage.PollQuestions(p.ID)
+	if err != nil {
+		return pr, errors.New("failed to get poll questions from storage: " +
+			err.Error())
+	}
+
+	vs, err := s.storage.PollVotes(p.ID)
+	if err != nil {
+		return pr, errors.New("failed to get poll votes from storage: " +
+			err.Error())
+	}
+
+	_, pr.TotalWeight, err = s.pollWeights(p)
+	if err != nil {
+		return pr, err
+	}
+
+	questionVotes := map[int][]entity.PollVote{}
+	voters := map[int]float64{}
+
+	for _, v := range vs {
+		questionVotes[v.QuestionID] = append(questionVotes[v.QuestionID], v)
+		if w, ok := voters[v.OwnerID]; !ok || v.Weight > w {
+			voters[v.OwnerID] = v.Weight
+		}
+	}
+
+	pr.Questions = []entity.PollQuestionResult{}
+
+	for i, q := range qs {
+		qr := entity.PollQuestionResult{
+			PollQuestion: q,
+			Number:       i + 1,
+			Votes:        questionVotes[q.ID],
+			Results:      make([]entity.PollOptionResult, len(q.Options)),
+		}
+
+		for i, o := range q.Options {
+			qr.Results[i].Option = o
+		}
+
+		var voted float64
+
+		for _, v := range qr.Votes {
+			voted += v.Weight
+			for _, o := range v.Options {
+				if o >= 0 && o < len(qr.Results) {
+					qr.Results[o].Votes++
+					qr.Results[o].Weight += v.Weight
+				}
+			}
+		}
+
+		if voted > 0 {
+			for i := range qr.Results {
+				qr.Results[i].Percent = qr.Results[i].Weight * 100 / voted
+			}
+		}
+
+		pr.Questions = append(pr.Questions, qr)
+	}
+
+	for _, w := range voters {
+		pr.VotedWeight += w
+	}
+
+	pr.VotersCount = len(voters)
+
+	if pr.TotalWeight > 0 {
+		pr.VotedPercent = pr.VotedWeight * 100 / pr.TotalWeight
+		pr.QuorumReached = pr.VotedPercent >= p.QuorumPercent
+	}
+
+	return pr, nil
+}
+
+// organizationPollResults returns results of the organization poll.
+func (s *Server) organizationPollResults(organizationID int,
+	pollID int) (entity.PollResults, error) {
+
+	p, err := s.storage.OrganizationPoll(organizationID, pollID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return entity.PollResults{}, echo.NewHTTPError(
+				http.StatusNotFound, "poll not found")
+		}
+		return entity.PollResults{}, errors.New(
+			"failed to get organization poll from storage: " + err.Error())
+	}
+
+	return s.pollResults(p)
+}
+
+// ownerBuildingID returns the building of the owner flat.
+func (s *Server) ownerBuildingID(owner entity.Owner) (int, error) {
+	if owner.FlatID == nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest,
+			"owner has no flat")
+	}
+
+	f, err := s.storage.OrganizationFlat(owner.OrganizationID, *owner.FlatID)
+	if err != nil {
+		return 0, errors.New(
+			"failed to get organization flat from storage: " + err.Error())
+	}
+
+	return f.BuildingID, nil
+}
+
+// ownerPolls returns the polls of the owner building with the results.
+func (s *Server) ownerPolls(owner entity.Owner) ([]entity.PollResults,
+	error) {
+
+	if owner.FlatID == nil {
+		return []entity.PollResults{}, nil
+	}
+
+	buildingID, err := s.ownerBuildingID(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	ps, err := s.storage.BuildingPolls(buildingID)
+	if err != nil {
+		return nil, errors.New("failed to get building polls from storage: " +
+			err.Error())
+	}
+
+	prs := []entity.PollResults{}
+
+	for _, p := range ps {
+		pr, err := s.pollResults(p)
+		if err != nil {
+			return nil, err
+		}
+		prs = append(prs, pr)
+	}
+
+	return prs, nil
+}
+
+// votePoll sets the owner vote on the poll question. Only owners of the
+// building flats vote while the poll is open. Revote replaces the vote.
+func (s *Server) votePoll(owner entity.Owner, pollID int,
+	v entity.PollVote) (entity.PollVote, error) {
+
+	if !pollVoter(owner) {
+		return v, echo.NewHTTPError(http.StatusForbidden,
+			"only flat owners can vote")
+	}
+
+	p, err := s.storage.OrganizationPoll(owner.OrganizationID, pollID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, echo.NewHTTPError(http.StatusNotFound, "poll not found")
+		}
+		return v, errors.New(
+			"failed to get organization poll from storage: " + err.Error())
+	}
+
+	buildingID, err := s.ownerBuildingID(owner)
+	if err != nil {
+		return v, err
+	}
+
+	if buildingID != p.BuildingID {
+		return v, echo.NewHTTPError(http.StatusNotFound, "poll not found")
+	}
+
+	now := time.Now()
+
+	if !p.Open(now) {
+		return v, echo.NewHTTPError(http.StatusBadRequest,
+			"poll is not open")
+	}
+
+	qs, err := s.storage.PollQuestions(p.ID)
+	if err != nil {
+		return v, errors.New("failed to get poll questions from storage: " +
+			err.Error())
+	}
+
+	var q *entity.PollQuestion
+	for i := range qs {
+		if qs[i].ID == v.QuestionID {
+			q = &qs[i]
+			break
+		}
+	}
+
+	if q == nil {
+		return v, echo.NewHTTPError(http.StatusNotFound,
+			"poll question not found")
+	}
+
+	err = q.ValidateChoice(v.Options)
+	if err != nil {
+		return v, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate poll vote: "+err.Error())
+	}
+
+	weights, _, err := s.pollWeights(p)
+	if err != nil {
+		return v, err
+	}
+
+	v.OwnerID = owner.ID
+	v.Weight = weights[owner.ID]
+	v.VotedAt = now
+
+	v, err = s.storage.SetPollVote(v)
+	if err != nil {
+		return v, errors.New("failed to set poll vote in storage: " +
+			err.Error())
+	}
+
+	return v, nil
+}
+
+// signProtocol returns the hex HMAC-SHA256 of the protocol body.
+func (s *Server) signProtocol(body []byte) string {
+	m := hmac.New(sha256.New, s.signingKey)
+	m.Write(body)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// pollProtocol returns the signed plain text protocol of the finished poll.
+func (s *Server) pollProtocol(pr entity.PollResults) ([]byte, error) {
+	if !pr.Finished(time.Now()) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			"poll is not finished")
+	}
+
+	var b bytes.Buffer
+
+	weightUnit := "голосов"
+	if pr.Weighting == weighting.Area {
+		weightUnit = "кв. м"
+	}
+
+	fmt.Fprintf(&b, "ПРОТОКОЛ № %d\n", pr.ID)
+	fmt.Fprintf(&b, "общего собрания собственников помещений\n")
+	fmt.Fprintf(&b, "Адрес дома: %s\n", pr.BuildingAddress)
+	fmt.Fprintf(&b, "Повестка: %s\n", pr.Title)
+	if pr.Description != "" {
+		fmt.Fprintf(&b, "%s\n", pr.Description)
+	}
+	fmt.Fprintf(&b, "Период голосования: %s - %s\n",
+		pr.StartsAt.Local().Format("02.01.2006 15:04"),
+		pr.EndsAt.Local().Format("02.01.2006 15:04"))
+	if pr.Weighting == weighting.Area {
+		fmt.Fprintf(&b, "Подсчёт голосов: пропорционально площади квартир\n")
+	} else {
+		fmt.Fprintf(&b, "Подсчёт голосов: один голос от квартиры\n")
+	}
+	fmt.Fprintf(&b, "Всего: %.2f %s\n", pr.TotalWeight, weightUnit)
+	fmt.Fprintf(&b, "Приняли участие: %d собственников, %.2f %s (%.2f%%)\n",
+		pr.VotersCount, pr.VotedWeight, weightUnit, pr.VotedPercent)
+	if pr.QuorumReached {
+		fmt.Fprintf(&b, "Кворум (%.2f%%) имеется, собрание правомочно\n",
+			pr.QuorumPercent)
+	} else {
+		fmt.Fprintf(&b, "Кворум (%.2f%%) отсутствует, собрание неправомочно\n",
+			pr.QuorumPercent)
+	}
+
+	for _, q := range pr.Questions {
+		fmt.Fprintf(&b, "\nВопрос %d. %s\n", q.Number, q.Text)
+		for _, r := range q.Results {
+			fmt.Fprintf(&b, "- %s: %d собственников, %.2f %s (%.2f%%)\n",
+				r.Option, r.Votes, r.Weight, weightUnit, r.Percent)
+		}
+	}
+
+	fmt.Fprintf(&b, "\nСформирован: %s\n",
+		time.Now().Format("02.01.2006 15:04"))
+
+	sign := s.signProtocol(b.Bytes())
+
+	b.WriteString(protocolSignaturePrefix + sign + "\n")
+
+	return b.Bytes(), nil
+}
+
+// verifyProtocol checks that the protocol is signed by the server and is not
+// changed.
+func (s *Server) verifyProtocol(r io.Reader) (bool, error) {
+	protocol, err := ioutil.ReadAll(io.LimitReader(r, maxProtocolSize))
+	if err != nil {
+		return false, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to read protocol: "+err.Error())
+	}
+
+	protocol = bytes.TrimRight(protocol, "\r\n")
+
+	i := bytes.LastIndexByte(protocol, '\n')
+	if i < 0 {
+		return false, nil
+	}
+
+	body, last := protocol[:i+1], string(protocol[i+1:])
+
+	if !strings.HasPrefix(last, protocolSignaturePrefix) {
+		return false, nil
+	}
+
+	sign, err := hex.DecodeString(strings.TrimSpace(
+		strings.TrimPrefix(last, protocolSignaturePrefix)))
+	if err != nil {
+		return false, nil
+	}
+
+	m := hmac.New(sha256.New, s.signingKey)
+	m.Write(body)
+
+	return hmac.Equal(sign, m.Sum(nil)), nil
+}
+
+func exportPollProtocol(c echo.Context, pollID int, protocol []byte) error {
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=poll-protocol-%d.txt", pollID))
+	return c.Blob(http.StatusOK, "text/plain; charset=utf-8", protocol)
+}
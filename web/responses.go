@@ -0,0 +1,148 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo"
+
+	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/role"
+)
+
+// bindResponseTemplate binds and validates response template. Empty category
+// select is bound as zero category ID which means template of any category.
+func bindResponseTemplate(c echo.Context) (entity.ResponseTemplate, error) {
+	var rt entity.ResponseTemplate
+
+	err := c.Bind(&rt)
+	if err != nil {
+		return rt, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind response template: "+err.Error())
+	}
+
+	if rt.CategoryID != nil && *rt.CategoryID == 0 {
+		rt.CategoryID = nil
+	}
+
+	err = rt.Validate()
+	if err != nil {
+		return rt, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate response template: "+err.Error())
+	}
+
+	return rt, nil
+}
+
+func (s *Server) responseTemplate(organizationID int,
+	responseTemplateID int) (entity.ResponseTemplate, error) {
+
+	rt, err := s.storage.OrganizationResponseTemplate(organizationID,
+		responseTemplateID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rt, echo.NewHTTPError(http.StatusBadRequest,
+				"response template not found")
+		}
+		return rt, errors.New(
+			"failed to get organization response template from storage: " +
+				err.Error())
+	}
+
+	return rt, nil
+}
+
+// templateResponse renders response template for the request. Text typed by
+// operator is appended to the rendered template if it is not empty.
+func templateResponse(rt entity.ResponseTemplate, r entity.RequestExtended,
+	text *string) *string {
+
+	response := rt.Render(r, time.Now())
+
+	if text != nil && strings.TrimSpace(*text) != "" {
+		response += "\n\n" + *text
+	}
+
+	return &response
+}
+
+// operatorTemplateResponse renders the organization response template for
+// the operator request.
+func (s *Server) operatorTemplateResponse(organizationID int, operatorID int,
+	responseTemplateID int, requestID int, text *string) (*string, error) {
+
+	rt, err := s.responseTemplate(organizationID, responseTemplateID)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := s.staffRequest(role.Operator, operatorID, organizationID,
+		requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rt.Fits(r.CategoryID) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest,
+			"response template doesn't fit request category")
+	}
+
+	return templateResponse(rt, r, text), nil
+}
+
+// setResponseTemplate sets the organization response template.
+func (s *Server) setResponseTemplate(rt entity.ResponseTemplate) (
+	entity.ResponseTemplate, error) {
+
+	rt, err := s.storage.SetResponseTemplate(rt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rt, echo.NewHTTPError(http.StatusNotFound,
+				"response template not found")
+		}
+		return rt, errors.New("failed to set response template in storage: " +
+			err.Error())
+	}
+
+	return rt, nil
+}
+
+// removeResponseTemplate removes the organization response template.
+func (s *Server) removeResponseTemplate(organizationID int,
+	responseTemplateID int) error {
+
+	err := s.storage.RemoveOrganizationResponseTemplate(organizationID,
+		responseTemplateID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound,
+				"response template not found")
+		}
+		return errors.New(
+			"failed to remove organization response template from storage: " +
+				err.Error())
+	}
+
+	return nil
+}
+
+// addResponseTemplateUse records use of the response template. Failure is
+// only logged since response is already set.
+func (s *Server) addResponseTemplateUse(responseTemplateID int,
+	requestID int, actorRole string, actorID int) {
+
+	err := s.storage.AddResponseTemplateUse(entity.ResponseTemplateUse{
+		ResponseTemplateID: &responseTemplateID,
+		RequestID:          requestID,
+		Role:               actorRole,
+		ActorID:            actorID,
+	})
+	if err != nil {
+		s.log.WithError(err).WithField("response_template_id",
+			responseTemplateID).Error(
+			"failed to add response template use to storage")
+	}
+}
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo"
+)
+
+// restoreOrganization restores the deleted organization unless its email is
+// taken by another organization.
+func (s *Server) restoreOrganization(organizationID int) error {
+	os, err := s.storage.DeletedOrganizations()
+	if err != nil {
+		return errors.New(
+			"failed to get deleted organizations from storage: " +
+				err.Error())
+	}
+
+	for _, o := range os {
+		if o.ID != organizationID {
+			continue
+		}
+
+		_, err = s.storage.Organization(o.Email)
+		if err == nil {
+			return echo.NewHTTPError(http.StatusConflict,
+				"email is taken by another organization")
+		}
+		if err != sql.ErrNoRows {
+			return errors.New("failed to get organization from storage: " +
+				err.Error())
+		}
+
+		err = s.storage.RestoreOrganization(organizationID)
+		if err != nil {
+			return errors.New("failed to restore organization in storage: " +
+				err.Error())
+		}
+
+		return nil
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound,
+		"deleted organization not found")
+}
+
+// restoreOperator restores the deleted operator of the organization unless
+// its phone is taken by another operator.
+func (s *Server) restoreOperator(organizationID int, operatorID int) error {
+	os, err := s.storage.OrganizationDeletedOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization deleted operators from storage: " +
+				err.Error())
+	}
+
+	for _, o := range os {
+		if o.ID != operatorID {
+			continue
+		}
+
+		taken, err := s.storage.OperatorPhoneTaken(o.Phone)
+		if err != nil {
+			return errors.New("failed to check operator phone in storage: " +
+				err.Error())
+		}
+		if taken {
+			return echo.NewHTTPError(http.StatusConflict,
+				"phone is taken by another operator")
+		}
+
+		err = s.storage.RestoreOrganizationOperator(organizationID,
+			operatorID)
+		if err != nil {
+			return errors.New(
+				"failed to restore organization operator in storage: " +
+					err.Error())
+		}
+
+		return nil
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound,
+		"deleted operator not found")
+}
+
+// restoreOwner restores the deleted owner of the organization unless its
+// phone is taken by another owner.
+func (s *Server) restoreOwner(organizationID int, ownerID int) error {
+	os, err := s.storage.OrganizationDeletedOwners(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization deleted owners from storage: " +
+				err.Error())
+	}
+
+	for _, o := range os {
+		if o.ID != ownerID {
+			continue
+		}
+
+		taken, err := s.storage.OwnerPhoneTaken(o.Phone)
+		if err != nil {
+			return errors.New("failed to check owner phone in storage: " +
+				err.Error())
+		}
+		if taken {
+			return echo.NewHTTPError(http.StatusConflict,
+				"phone is taken by another owner")
+		}
+
+		err = s.storage.RestoreOrganizationOwner(organizationID, ownerID)
+		if err != nil {
+			return errors.New(
+				"failed to restore organization owner in storage: " +
+					err.Error())
+		}
+
+		return nil
+	}
+
+	return echo.NewHTTPError(http.StatusNotFound, "deleted owner not found")
+}
@@ -2,10 +2,16 @@ package web
 
 import (
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,8 +21,15 @@ import (
 	"github.com/labstack/echo/middleware"
 	"github.com/sirupsen/logrus"
 
+	"github.com/dimuls/swan/dedup"
 	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/decimal"
+	"github.com/dimuls/swan/entity/event"
+	"github.com/dimuls/swan/entity/priority"
 	"github.com/dimuls/swan/entity/role"
+	"github.com/dimuls/swan/entity/status"
+	"github.com/dimuls/swan/entity/workstatus"
+	"github.com/dimuls/swan/payment"
 )
 
 type Storage interface {
@@ -35,20 +48,36 @@ type Storage interface {
 	CategorySamples() ([]entity.CategorySample, error)
 	SetCategorySamples([]entity.CategorySample) error
 
+	PriorityRules() ([]entity.PriorityRule, error)
+	AddPriorityRule(entity.PriorityRule) (entity.PriorityRule, error)
+	SetPriorityRule(entity.PriorityRule) (entity.PriorityRule, error)
+	RemovePriorityRule(priorityRuleID int) error
+
 	Organization(email string) (entity.Organization, error)
 	Organizations() ([]entity.Organization, error)
+	OrganizationsStats() ([]entity.OrganizationStats, error)
+	CategoriesStats(organizationID *int) ([]entity.CategoryStats, error)
 	AddOrganization(entity.Organization) (entity.Organization, error)
 	SetOrganization(entity.Organization) (entity.Organization, error)
 	RemoveOrganization(organizationID int) error
+	DeletedOrganizations() ([]entity.Organization, error)
+	RestoreOrganization(organizationID int) error
 	SetOrganizationPasswordHash(organizationID int, passwordHash []byte) error
 
 	Operator(phone string) (entity.Operator, error)
+	OperatorByID(operatorID int) (entity.Operator, error)
 	OrganizationOperators(organizationID int) ([]entity.Operator, error)
 	AddOperator(entity.Operator) (entity.Operator, error)
 	SetOperator(entity.Operator) (entity.Operator, error)
 	RemoveOrganizationOperator(organizationID int, operatorID int) error
+	OrganizationDeletedOperators(organizationID int) ([]entity.Operator,
+		error)
+	RestoreOrganizationOperator(organizationID int, operatorID int) error
+	OperatorPhoneTaken(phone string) (bool, error)
 	FindOrganizationOperator(organizationID int, categoryID int) (
 		entity.Operator, error)
+	FindOrganizationOnDutyOperator(organizationID int, categoryID *int) (
+		entity.Operator, error)
 	SetOperatorPasswordHash(operatorID int, passwordHash []byte) error
 
 	Owner(phone string) (entity.Owner, error)
@@ -56,14 +85,236 @@ type Storage interface {
 	AddOwner(entity.Owner) (entity.Owner, error)
 	SetOwner(entity.Owner) (entity.Owner, error)
 	RemoveOrganizationOwner(organizationID int, ownerID int) error
+	OrganizationDeletedOwners(organizationID int) ([]entity.Owner, error)
+	RestoreOrganizationOwner(organizationID int, ownerID int) error
+	OwnerPhoneTaken(phone string) (bool, error)
+	ActiveActor(actorRole string, actorID int) (bool, error)
 	SetOwnerPasswordHash(ownerID int, passwordHash []byte) error
+	HouseholdMembers(ownerID int) ([]entity.Owner, error)
+	RemoveHouseholdMember(primaryOwnerID int, memberID int) error
 
 	OperatorRequest(operatorID int, requestID int) (entity.Request, error)
-	OperatorRequests(operatorID int) ([]entity.RequestExtended, error)
+	OperatorRequests(operatorID int, f entity.RequestFilter) (
+		[]entity.RequestExtended, error)
 	SetOperatorRequest(operatorID int, r entity.Request) (entity.Request, error)
+	AcknowledgeOperatorRequest(operatorID int, requestID int) error
+	SetRequestNotifiedAt(requestID int, notifiedAt time.Time) error
 
-	OwnerRequests(ownerID int) ([]entity.RequestExtended, error)
+	OwnerRequests(ownerID int, f entity.RequestFilter) (
+		[]entity.RequestExtended, error)
 	AddRequest(entity.Request) (entity.Request, error)
+	OpenOrganizationCommonRequests(organizationID int) (
+		[]entity.RequestExtended, error)
+	AddRequestSupport(requestID int, ownerID int) error
+	OwnerSupportedRequests(ownerID int) ([]int, error)
+	OwnerRequest(ownerID int, requestID int) (entity.Request, error)
+	EditOwnerRequest(ownerID int, r entity.Request, e entity.RequestEvent) (
+		entity.Request, error)
+	CancelOwnerRequest(ownerID int, requestID int, e entity.RequestEvent) error
+	OperatorRequestsEvents(operatorID int, requestIDs []int) (
+		[]entity.RequestEvent, error)
+
+	AddRequestNote(entity.RequestNote) (entity.RequestNote, error)
+	RequestNotes(requestID int) ([]entity.RequestNote, error)
+	OperatorRequestsNotes(operatorID int, requestIDs []int) (
+		[]entity.RequestNote, error)
+
+	Requests(f entity.RequestFilter) ([]entity.RequestExtended, error)
+	OrganizationRequests(organizationID int, f entity.RequestFilter) (
+		[]entity.RequestExtended, error)
+	OrganizationRequest(organizationID int, requestID int) (
+		entity.RequestExtended, error)
+	OrganizationOperatorsStats(organizationID int) (
+		[]entity.OperatorStats, error)
+	RequestEvents(requestID int) ([]entity.RequestEvent, error)
+	RequestWorkOrders(requestID int) ([]entity.WorkOrderExtended, error)
+	RequestCostItems(requestID int) ([]entity.CostItem, error)
+
+	Request(requestID int) (entity.Request, error)
+	SetRequest(r entity.Request, es []entity.RequestEvent) error
+	LinkRequest(requestID int, primaryRequestID int) error
+	UnlinkRequest(requestID int) error
+	DuplicateCandidates(organizationID int, categoryID *int,
+		since time.Time) ([]entity.RequestExtended, error)
+	AddRequestDuplicates([]entity.RequestDuplicate) error
+	OperatorRequestsDuplicates(operatorID int, requestIDs []int) (
+		[]entity.RequestDuplicate, error)
+
+	OrganizationIncidents(organizationID int) ([]entity.Incident, error)
+	OpenOrganizationIncidents(organizationID int) ([]entity.Incident, error)
+	OrganizationIncident(organizationID int, incidentID int) (
+		entity.Incident, error)
+	AddIncident(entity.Incident) (entity.Incident, error)
+	SetIncident(entity.Incident) (entity.Incident, error)
+	ResolveIncident(organizationID int, incidentID int, response string) (
+		[]string, error)
+
+	OperatorVisitSlots(operatorID int, since time.Time) (
+		[]entity.VisitSlotExtended, error)
+	AddVisitSlot(entity.VisitSlot) (entity.VisitSlot, error)
+	RemoveOperatorVisitSlot(operatorID int, visitSlotID int) error
+	OwnerRequestsVisitSlots(ownerID int, requestIDs []int) (
+		[]entity.VisitSlot, error)
+	OwnerRequestsFreeVisitSlots(ownerID int, requestIDs []int,
+		since time.Time) ([]entity.VisitSlot, error)
+	BookVisitSlot(ownerID int, requestID int, visitSlotID int) error
+	CancelVisit(ownerID int, requestID int) error
+	OperatorCalendarToken(operatorID int) (*string, error)
+	SetOperatorCalendarToken(operatorID int, token *string) error
+	CalendarTokenOperatorID(token string) (int, error)
+
+	OrganizationBuildings(organizationID int) ([]entity.Building, error)
+	OrganizationBuilding(organizationID int, buildingID int) (
+		entity.Building, error)
+	AddBuilding(entity.Building) (entity.Building, error)
+	SetBuilding(entity.Building) (entity.Building, error)
+	RemoveOrganizationBuilding(organizationID int, buildingID int) error
+
+	OrganizationFlats(organizationID int) ([]entity.Flat, error)
+	OrganizationFlat(organizationID int, flatID int) (entity.Flat, error)
+	AddFlat(entity.Flat) (entity.Flat, error)
+	SetFlat(organizationID int, f entity.Flat) (entity.Flat, error)
+	RemoveOrganizationFlat(organizationID int, flatID int) error
+
+	OrganizationMeters(organizationID int) ([]entity.Meter, error)
+	OrganizationMeter(organizationID int, meterID int) (entity.Meter, error)
+	FlatMeters(flatID int) ([]entity.Meter, error)
+	FlatMeter(flatID int, meterID int) (entity.Meter, error)
+	AddMeter(entity.Meter) (entity.Meter, error)
+	SetMeter(organizationID int, m entity.Meter) (entity.Meter, error)
+	RemoveOrganizationMeter(organizationID int, meterID int) error
+	PreviousMeterReadings(meterID int, period time.Time, limit int) (
+		[]entity.MeterReading, error)
+	SetMeterReading(entity.MeterReading) (entity.MeterReading, error)
+	OrganizationMeterReadings(organizationID int, period time.Time) (
+		[]entity.MeterReadingExtended, error)
+
+	OrganizationAnnouncements(organizationID int) ([]entity.Announcement,
+		error)
+	AddAnnouncement(entity.Announcement) (entity.Announcement, error)
+	SetAnnouncement(entity.Announcement) (entity.Announcement, error)
+	RemoveOrganizationAnnouncement(organizationID int,
+		announcementID int) error
+	OwnerAnnouncements(ownerID int, publishedAt time.Time) (
+		[]entity.Announcement, error)
+	ReadAnnouncements(ownerID int, announcementIDs []int,
+		readAt time.Time) (int, error)
+
+	OrganizationDocumentFolders(organizationID int) (
+		[]entity.DocumentFolder, error)
+	OrganizationDocumentFolder(organizationID int, folderID int) (
+		entity.DocumentFolder, error)
+	AddDocumentFolder(entity.DocumentFolder) (entity.DocumentFolder, error)
+	RemoveOrganizationDocumentFolder(organizationID int, folderID int) error
+	OrganizationDocuments(organizationID int) ([]entity.Document, error)
+	OrganizationDocument(organizationID int, documentID int) (
+		entity.Document, error)
+	OwnerDocuments(ownerID int) ([]entity.Document, error)
+	AddDocument(entity.Document, entity.DocumentVersion) (entity.Document,
+		error)
+	SetDocument(entity.Document) (entity.Document, error)
+	AddDocumentVersion(entity.DocumentVersion) (entity.DocumentVersion,
+		error)
+	DocumentVersions(documentID int) ([]entity.DocumentVersion, error)
+	DocumentVersion(documentID int, versionID int) (entity.DocumentVersion,
+		error)
+	AddDocumentDownload(versionID int, ownerID int,
+		downloadedAt time.Time) error
+	RemoveOrganizationDocument(organizationID int, documentID int) (
+		[]string, error)
+
+	SetCharges([]entity.Charge) error
+	AddPayments([]entity.Payment) (int, error)
+	FlatCharges(flatID int) ([]entity.Charge, error)
+	FlatPayments(flatID int) ([]entity.Payment, error)
+	OrganizationDebtors(organizationID int, minDebt decimal.Money) (
+		[]entity.Debtor, error)
+
+	OrganizationPolls(organizationID int) ([]entity.Poll, error)
+	OrganizationPoll(organizationID int, pollID int) (entity.Poll, error)
+	BuildingPolls(buildingID int) ([]entity.Poll, error)
+	AddPoll(entity.Poll) (entity.Poll, error)
+	RemoveOrganizationPoll(organizationID int, pollID int) error
+	PollQuestions(pollID int) ([]entity.PollQuestion, error)
+	AddPollQuestion(entity.PollQuestion) (entity.PollQuestion, error)
+	RemovePollQuestion(pollID int, questionID int) error
+	PollVotes(pollID int) ([]entity.PollVote, error)
+	SetPollVote(entity.PollVote) (entity.PollVote, error)
+	BuildingOwners(buildingID int) ([]entity.Owner, error)
+	BuildingFlats(buildingID int) ([]entity.Flat, error)
+
+	ImportAddressRegistry(read func() (entity.RegistryAddress, error)) (
+		int, error)
+	AddressRegistrySize() (int, error)
+	RegistryAddresses(key string) ([]entity.RegistryAddress, error)
+	SuggestAddresses(keyPrefix string, limit int) (
+		[]entity.RegistryAddress, error)
+
+	OrganizationContractors(organizationID int) ([]entity.Contractor, error)
+	AddContractor(entity.Contractor) (entity.Contractor, error)
+	SetContractor(entity.Contractor) (entity.Contractor, error)
+	RemoveOrganizationContractor(organizationID int, contractorID int) error
+
+	OrganizationResponseTemplates(organizationID int) (
+		[]entity.ResponseTemplate, error)
+	OrganizationResponseTemplate(organizationID int, responseTemplateID int) (
+		entity.ResponseTemplate, error)
+	AddResponseTemplate(entity.ResponseTemplate) (entity.ResponseTemplate,
+		error)
+	SetResponseTemplate(entity.ResponseTemplate) (entity.ResponseTemplate,
+		error)
+	RemoveOrganizationResponseTemplate(organizationID int,
+		responseTemplateID int) error
+	AddResponseTemplateUse(entity.ResponseTemplateUse) error
+
+	OrganizationLabels(organizationID int) ([]entity.Label, error)
+	AddLabel(entity.Label) (entity.Label, error)
+	SetLabel(entity.Label) (entity.Label, error)
+	RemoveOrganizationLabel(organizationID int, labelID int) error
+	OrganizationCustomFields(organizationID int) ([]entity.CustomField, error)
+	AddCustomField(entity.CustomField) (entity.CustomField, error)
+	SetCustomField(entity.CustomField) (entity.CustomField, error)
+	RemoveOrganizationCustomField(organizationID int, customFieldID int) error
+	RequestsLabels(requestIDs []int) (map[int][]entity.Label, error)
+	RequestsFieldValues(requestIDs []int) ([]entity.RequestFieldValue, error)
+	SetRequestAttributes(requestID int, labelIDs []int,
+		fvs []entity.RequestFieldValue) error
+
+	OrganizationChecklistTemplates(organizationID int) (
+		[]entity.ChecklistTemplate, error)
+	CategoryChecklistTemplateItems(organizationID int, categoryID int) (
+		[]string, error)
+	AddChecklistTemplate(entity.ChecklistTemplate) (entity.ChecklistTemplate,
+		error)
+	SetChecklistTemplate(entity.ChecklistTemplate) (entity.ChecklistTemplate,
+		error)
+	RemoveOrganizationChecklistTemplate(organizationID int,
+		checklistTemplateID int) error
+	AddRequestTasks(requestID int, titles []string) error
+	AddRequestTask(entity.RequestTask) (entity.RequestTask, error)
+	RequestTask(requestID int, taskID int) (entity.RequestTask, error)
+	SetRequestTask(entity.RequestTask) error
+	RemoveRequestTask(requestID int, taskID int) error
+	RequestsTasks(requestIDs []int) ([]entity.RequestTask, error)
+	OperatorTasks(operatorID int) ([]entity.RequestTask, error)
+
+	AddWorkOrder(operatorID int, wo entity.WorkOrder) (entity.WorkOrder, error)
+	WorkOrder(token string) (entity.WorkOrderExtended, error)
+	SetWorkOrderStatus(token string, status string, comment *string) error
+	OperatorRequestsWorkOrders(operatorID int, requestIDs []int) (
+		[]entity.WorkOrderExtended, error)
+	OwnerRequestsWorkOrders(ownerID int, requestIDs []int) (
+		[]entity.WorkOrderExtended, error)
+
+	AddCostItem(ci entity.CostItem) (entity.CostItem, error)
+	RemoveOperatorCostItem(operatorID int, costItemID int) error
+	RemoveRequestCostItem(requestID int, costItemID int) error
+	OperatorRequestsCostItems(operatorID int, requestIDs []int) (
+		[]entity.CostItem, error)
+	OwnerRequestsCostItems(ownerID int, requestIDs []int) (
+		[]entity.CostItem, error)
+	OrganizationCostItems(organizationID int, from time.Time, to time.Time) (
+		[]entity.CostItemExtended, error)
 }
 
 type Classifier interface {
@@ -80,6 +331,20 @@ type EmailSender interface {
 	SendEmail(email string, msg string) error
 }
 
+// PaymentProvider accepts the residents payments and notifies about them by
+// the callback.
+type PaymentProvider interface {
+	PaymentURL(p entity.Payment, returnURL string) (string, error)
+	ParsePaymentCallback(r *http.Request) (entity.Payment, error)
+}
+
+// AttachmentStorage keeps the uploaded files by the generated keys.
+type AttachmentStorage interface {
+	Put(r io.Reader) (key string, size int64, err error)
+	Open(key string) (io.ReadCloser, error)
+	Remove(key string) error
+}
+
 type Server struct {
 	bindAddr    string
 	debug       bool
@@ -87,6 +352,11 @@ type Server struct {
 	smsSender   SMSSender
 	emailSender EmailSender
 	classifier  Classifier
+	signingKey  []byte
+
+	publicBaseURL     string
+	paymentProvider   PaymentProvider
+	attachmentStorage AttachmentStorage
 
 	echo *echo.Echo
 
@@ -95,8 +365,11 @@ type Server struct {
 	log *logrus.Entry
 }
 
-func NewServer(bindAddr string, s Storage, ss SMSSender, es EmailSender,
-	c Classifier, debug bool) *Server {
+// NewServer creates the web server. Links sent outside of the web UI, such
+// as contractor status and calendar links, are built from publicBaseURL.
+func NewServer(bindAddr string, publicBaseURL string, s Storage,
+	ss SMSSender, es EmailSender, c Classifier, debug bool, signingKey []byte,
+	pp PaymentProvider, as AttachmentStorage) *Server {
 
 	return &Server{
 		bindAddr:    bindAddr,
@@ -105,6 +378,11 @@ func NewServer(bindAddr string, s Storage, ss SMSSender, es EmailSender,
 		smsSender:   ss,
 		emailSender: es,
 		classifier:  c,
+		signingKey:  signingKey,
+
+		publicBaseURL:     strings.TrimSuffix(publicBaseURL, "/"),
+		paymentProvider:   pp,
+		attachmentStorage: as,
 
 		log: logrus.WithField("subsystem", "web_server"),
 	}
@@ -121,15 +399,39 @@ func (s *Server) Start() error {
 	var err error
 
 	e.Renderer, err = initRenderer(map[string]string{
-		"login":                  loginPage,
-		"register":               registerPage,
-		"password":               passwordPage,
-		"admin_organizations":    adminOrganizationsPage,
-		"admin_classifier":       adminClassifierPage,
-		"organization_owners":    organizationOwnersPage,
-		"organization_operators": organizationOperatorsPage,
-		"operator_requests":      operatorRequestsPage,
-		"owner_requests":         ownerRequestsPage,
+		"login":                      loginPage,
+		"register":                   registerPage,
+		"password":                   passwordPage,
+		"admin_organizations":        adminOrganizationsPage,
+		"admin_classifier":           adminClassifierPage,
+		"admin_requests":             adminRequestsPage,
+		"organization_owners":        organizationOwnersPage,
+		"organization_operators":     organizationOperatorsPage,
+		"operator_requests":          operatorRequestsPage,
+		"incidents":                  incidentsPage,
+		"operator_visit_slots":       operatorVisitSlotsPage,
+		"organization_buildings":     organizationBuildingsPage,
+		"organization_meters":        organizationMetersPage,
+		"organization_announcements": organizationAnnouncementsPage,
+		"organization_polls":         organizationPollsPage,
+		"organization_ledger":        organizationLedgerPage,
+		"organization_documents":     organizationDocumentsPage,
+		"organization_contractors":   organizationContractorsPage,
+		"work_order":                 workOrderPage,
+		"organization_costs":         organizationCostsPage,
+		"organization_templates":     organizationResponseTemplatesPage,
+		"organization_fields":        organizationFieldsPage,
+		"organization_checklists":    organizationChecklistsPage,
+		"organization_requests":      organizationRequestsPage,
+		"organization_request":       organizationRequestPage,
+		"bulk_results":               bulkResultsPage,
+		"owner_requests":             ownerRequestsPage,
+		"owner_household":            ownerHouseholdPage,
+		"owner_meters":               ownerMetersPage,
+		"owner_polls":                ownerPollsPage,
+		"owner_ledger":               ownerLedgerPage,
+		"owner_documents":            ownerDocumentsPage,
+		"payment_stub":               paymentStubPage,
 	})
 	if err != nil {
 		return errors.New("failed to init renderer: " + err.Error())
@@ -191,7 +493,20 @@ func (s *Server) Start() error {
 	e.GET("/password", s.getPassword)
 	e.POST("/password", s.postPassword)
 
-	admin := e.Group("/admin", forRoles(role.Admin))
+	e.GET("/calendars/:token/visits.ics", s.getCalendarVisits)
+
+	e.GET("/work-orders/:token", s.getWorkOrder)
+	e.POST("/work-orders/:token", s.postWorkOrder)
+
+	if s.paymentProvider != nil {
+		e.POST("/payment/callback", s.postPaymentCallback)
+	}
+
+	if _, ok := s.paymentProvider.(*payment.Stub); ok {
+		e.GET(payment.StubPagePath, s.getPaymentStub)
+	}
+
+	admin := e.Group("/admin", s.forRoles(role.Admin))
 
 	admin.GET("", s.getAdmin)
 
@@ -200,6 +515,10 @@ func (s *Server) Start() error {
 	admin.POST("/create-organization", s.postAdminCreateOrganization)
 	admin.POST("/set-organization", s.postAdminSetOrganization)
 	admin.POST("/remove-organization", s.postAdminRemoveOrganization)
+	admin.POST("/restore-organization", s.postAdminRestoreOrganization)
+	admin.POST("/import-address-registry", s.postAdminImportAddressRegistry)
+
+	admin.GET("/requests", s.getAdminRequests)
 
 	admin.GET("/classifier", s.getAdminClassifier)
 	admin.POST("/classifier/create-category", s.postAdminCreateCategory)
@@ -208,34 +527,175 @@ func (s *Server) Start() error {
 
 	admin.POST("/classifier/train", s.postClassifierTrain)
 
-	org := e.Group("/organization", forRoles(role.Organization))
+	org := e.Group("/organization", s.forRoles(role.Organization))
 
 	org.GET("", s.getOrganization)
 
+	org.GET("/requests", s.getOrganizationRequests)
+	org.GET("/requests/export", s.getOrganizationRequestsExport)
+	org.GET("/requests/:request_id", s.getOrganizationRequest)
+	org.POST("/create-request", s.postCreateRequest)
+	org.POST("/bulk-requests", s.postBulkRequests)
+	org.POST("/create-request-note", s.postCreateRequestNote)
+	org.POST("/set-request-attributes", s.postSetRequestAttributes)
+	org.POST("/create-request-task", s.postCreateRequestTask)
+	org.POST("/set-request-task", s.postSetRequestTask)
+	org.POST("/remove-request-task", s.postRemoveRequestTask)
+	org.POST("/create-cost-item", s.postCreateCostItem)
+	org.POST("/remove-cost-item", s.postOrganizationRemoveCostItem)
+
 	org.GET("/owners", s.getOrganizationOwners)
 	org.POST("/create-owner", s.postOrganizationCreateOwner)
 	org.POST("/set-owner", s.postOrganizationSetOwner)
 	org.POST("/remove-owner", s.postOrganizationRemoveOwner)
+	org.POST("/restore-owner", s.postOrganizationRestoreOwner)
+
+	org.GET("/buildings", s.getOrganizationBuildings)
+	org.POST("/create-building", s.postOrganizationCreateBuilding)
+	org.POST("/set-building", s.postOrganizationSetBuilding)
+	org.POST("/remove-building", s.postOrganizationRemoveBuilding)
+	org.POST("/create-flat", s.postOrganizationCreateFlat)
+	org.POST("/set-flat", s.postOrganizationSetFlat)
+	org.POST("/remove-flat", s.postOrganizationRemoveFlat)
+
+	org.GET("/meters", s.getOrganizationMeters)
+	org.GET("/meters/export", s.getOrganizationMeterReadingsExport)
+	org.POST("/create-meter", s.postOrganizationCreateMeter)
+	org.POST("/set-meter", s.postOrganizationSetMeter)
+	org.POST("/remove-meter", s.postOrganizationRemoveMeter)
+
+	org.GET("/announcements", s.getOrganizationAnnouncements)
+	org.POST("/create-announcement", s.postOrganizationCreateAnnouncement)
+	org.POST("/set-announcement", s.postOrganizationSetAnnouncement)
+	org.POST("/remove-announcement", s.postOrganizationRemoveAnnouncement)
+
+	org.GET("/polls", s.getOrganizationPolls)
+	org.GET("/polls/:poll_id/protocol", s.getOrganizationPollProtocol)
+	org.POST("/create-poll", s.postOrganizationCreatePoll)
+	org.POST("/remove-poll", s.postOrganizationRemovePoll)
+	org.POST("/create-poll-question", s.postOrganizationCreatePollQuestion)
+	org.POST("/remove-poll-question", s.postOrganizationRemovePollQuestion)
+
+	org.GET("/ledger", s.getOrganizationLedger)
+	org.GET("/ledger/debtors-export", s.getOrganizationDebtorsExport)
+	org.POST("/import-charges", s.postOrganizationImportCharges)
+	org.POST("/import-payments", s.postOrganizationImportPayments)
+
+	org.GET("/documents", s.getOrganizationDocuments)
+	org.GET("/documents/:document_id/versions/:version_id/download",
+		s.getOrganizationDocumentVersionDownload)
+	org.POST("/create-document-folder",
+		s.postOrganizationCreateDocumentFolder)
+	org.POST("/remove-document-folder",
+		s.postOrganizationRemoveDocumentFolder)
+	org.POST("/create-document", s.postOrganizationCreateDocument)
+	org.POST("/set-document", s.postOrganizationSetDocument)
+	org.POST("/create-document-version",
+		s.postOrganizationCreateDocumentVersion)
+	org.POST("/remove-document", s.postOrganizationRemoveDocument)
 
 	org.GET("/operators", s.getOrganizationOperators)
 	org.POST("/create-operator", s.postOrganizationCreateOperator)
 	org.POST("/set-operator", s.postOrganizationSetOperator)
 	org.POST("/remove-operator", s.postOrganizationRemoveOperator)
-
-	oper := e.Group("/operator", forRoles(role.Operator))
+	org.POST("/restore-operator", s.postOrganizationRestoreOperator)
+
+	org.GET("/contractors", s.getOrganizationContractors)
+	org.POST("/create-contractor", s.postOrganizationCreateContractor)
+	org.POST("/set-contractor", s.postOrganizationSetContractor)
+	org.POST("/remove-contractor", s.postOrganizationRemoveContractor)
+
+	org.GET("/response-templates", s.getOrganizationResponseTemplates)
+	org.POST("/create-response-template",
+		s.postOrganizationCreateResponseTemplate)
+	org.POST("/set-response-template", s.postOrganizationSetResponseTemplate)
+	org.POST("/remove-response-template",
+		s.postOrganizationRemoveResponseTemplate)
+
+	org.GET("/fields", s.getOrganizationFields)
+	org.POST("/create-label", s.postOrganizationCreateLabel)
+	org.POST("/set-label", s.postOrganizationSetLabel)
+	org.POST("/remove-label", s.postOrganizationRemoveLabel)
+	org.POST("/create-custom-field", s.postOrganizationCreateCustomField)
+	org.POST("/set-custom-field", s.postOrganizationSetCustomField)
+	org.POST("/remove-custom-field", s.postOrganizationRemoveCustomField)
+
+	org.GET("/checklists", s.getOrganizationChecklists)
+	org.POST("/create-checklist-template",
+		s.postOrganizationCreateChecklistTemplate)
+	org.POST("/set-checklist-template", s.postOrganizationSetChecklistTemplate)
+	org.POST("/remove-checklist-template",
+		s.postOrganizationRemoveChecklistTemplate)
+
+	org.GET("/costs", s.getOrganizationCosts)
+	org.GET("/costs/export", s.getOrganizationCostsExport)
+
+	org.GET("/incidents", s.getIncidents)
+	org.POST("/create-incident", s.postCreateIncident)
+	org.POST("/set-incident", s.postSetIncident)
+	org.POST("/resolve-incident", s.postResolveIncident)
+
+	oper := e.Group("/operator", s.forRoles(role.Operator))
 
 	oper.GET("", s.getOperator)
 
 	oper.GET("/requests", s.getOperatorRequests)
+	oper.POST("/acknowledge-request", s.postAcknowledgeRequest)
 	oper.POST("/set-request-in-progress", s.postSetRequestInProgress)
 	oper.POST("/set-request-final", s.postSetRequestFinal)
-
-	own := e.Group("/owner", forRoles(role.Owner))
+	oper.POST("/create-request", s.postCreateRequest)
+	oper.POST("/bulk-requests", s.postBulkRequests)
+	oper.POST("/create-request-note", s.postCreateRequestNote)
+	oper.POST("/set-request-attributes", s.postSetRequestAttributes)
+	oper.POST("/create-request-task", s.postCreateRequestTask)
+	oper.POST("/set-request-task", s.postSetRequestTask)
+	oper.POST("/remove-request-task", s.postRemoveRequestTask)
+	oper.POST("/link-request", s.postLinkRequest)
+	oper.POST("/unlink-request", s.postUnlinkRequest)
+	oper.POST("/create-work-order", s.postCreateWorkOrder)
+	oper.POST("/create-cost-item", s.postCreateCostItem)
+	oper.POST("/remove-cost-item", s.postRemoveCostItem)
+
+	oper.GET("/incidents", s.getIncidents)
+	oper.POST("/create-incident", s.postCreateIncident)
+	oper.POST("/set-incident", s.postSetIncident)
+	oper.POST("/resolve-incident", s.postResolveIncident)
+
+	oper.GET("/visit-slots", s.getOperatorVisitSlots)
+	oper.POST("/create-visit-slot", s.postOperatorCreateVisitSlot)
+	oper.POST("/remove-visit-slot", s.postOperatorRemoveVisitSlot)
+	oper.POST("/regenerate-calendar-token",
+		s.postOperatorRegenerateCalendarToken)
+	oper.POST("/revoke-calendar-token", s.postOperatorRevokeCalendarToken)
+
+	own := e.Group("/owner", s.forRoles(role.Owner))
 
 	own.GET("", s.getOwner)
 
 	own.GET("/requests", s.getOwnerRequests)
 	own.POST("/create-request", s.postOwnerCreateRequest)
+	own.POST("/join-incident", s.postOwnerJoinIncident)
+	own.POST("/support-request", s.postOwnerSupportRequest)
+	own.POST("/edit-request", s.postOwnerEditRequest)
+	own.POST("/cancel-request", s.postOwnerCancelRequest)
+	own.POST("/book-visit", s.postOwnerBookVisit)
+	own.POST("/cancel-visit", s.postOwnerCancelVisit)
+
+	own.GET("/household", s.getOwnerHousehold)
+	own.POST("/invite-household-member", s.postOwnerInviteHouseholdMember)
+	own.POST("/remove-household-member", s.postOwnerRemoveHouseholdMember)
+
+	own.GET("/meters", s.getOwnerMeters)
+	own.POST("/submit-meter-reading", s.postOwnerSubmitMeterReading)
+
+	own.GET("/ledger", s.getOwnerLedger)
+	own.POST("/pay", s.postOwnerPay)
+
+	own.GET("/polls", s.getOwnerPolls)
+	own.POST("/vote-poll", s.postOwnerVotePoll)
+
+	own.GET("/documents", s.getOwnerDocuments)
+	own.GET("/documents/:document_id/download", s.getOwnerDocumentDownload)
 
 	// API
 
@@ -246,52 +706,299 @@ func (s *Server) Start() error {
 	api.POST("/password-code", s.postAPIPasswordCode)
 	api.POST("/password", s.postAPIPassword)
 
-	api.GET("/entity", s.getAPIEntity, forRoles(role.Admin, role.Organization,
+	api.GET("/entity", s.getAPIEntity, s.forRoles(role.Admin, role.Organization,
 		role.Operator, role.Owner))
 
 	api.GET("/categories", s.getAPICategories,
-		forRoles(role.Organization, role.Operator))
+		s.forRoles(role.Organization, role.Operator))
 
 	categories := api.Group("/categories",
-		forRoles(role.Admin))
+		s.forRoles(role.Admin))
 	categories.POST("", s.postAPICategories)
 	categories.PUT("/:category_id", s.putAPICategory)
 	categories.DELETE("/:category_id", s.deleteAPICategory)
 
 	categorySamples := api.Group("/category-samples",
-		forRoles(role.Admin))
+		s.forRoles(role.Admin))
 	categorySamples.POST("", s.postAPICategorySamples)
 	categorySamples.POST("/classifier", s.postAPICategorySamplesClassifier)
 	categorySamples.GET("/classifier/training",
 		s.getAPICategorySamplesClassifierTraining)
 
-	organizations := api.Group("/organizations", forRoles(role.Admin))
+	buildings := api.Group("/buildings", s.forRoles(role.Organization))
+	buildings.GET("", s.getAPIBuildings)
+	buildings.POST("", s.postAPIBuildings)
+	buildings.PUT("/:building_id", s.putAPIBuilding)
+	buildings.DELETE("/:building_id", s.deleteAPIBuilding)
+
+	flats := api.Group("/flats", s.forRoles(role.Organization))
+	flats.GET("", s.getAPIFlats)
+	flats.POST("", s.postAPIFlats)
+	flats.PUT("/:flat_id", s.putAPIFlat)
+	flats.DELETE("/:flat_id", s.deleteAPIFlat)
+
+	meters := api.Group("/meters", s.forRoles(role.Organization))
+	meters.GET("", s.getAPIMeters)
+	meters.POST("", s.postAPIMeters)
+	meters.PUT("/:meter_id", s.putAPIMeter)
+	meters.DELETE("/:meter_id", s.deleteAPIMeter)
+
+	announcements := api.Group("/announcements", s.forRoles(role.Organization))
+	announcements.GET("", s.getAPIAnnouncements)
+	announcements.POST("", s.postAPIAnnouncements)
+	announcements.PUT("/:announcement_id", s.putAPIAnnouncement)
+	announcements.DELETE("/:announcement_id", s.deleteAPIAnnouncement)
+
+	polls := api.Group("/polls", s.forRoles(role.Organization))
+	polls.GET("", s.getAPIPolls)
+	polls.POST("", s.postAPIPolls)
+	polls.DELETE("/:poll_id", s.deleteAPIPoll)
+	polls.POST("/:poll_id/questions", s.postAPIPollQuestions)
+	polls.DELETE("/:poll_id/questions/:question_id",
+		s.deleteAPIPollQuestion)
+	polls.GET("/:poll_id/results", s.getAPIPollResults)
+	polls.GET("/:poll_id/protocol", s.getAPIPollProtocol)
+	polls.POST("/verify-protocol", s.postAPIPollsVerifyProtocol)
+
+	api.POST("/charges", s.postAPICharges, s.forRoles(role.Organization))
+	api.POST("/payments", s.postAPIPayments, s.forRoles(role.Organization))
+
+	debtors := api.Group("/debtors", s.forRoles(role.Organization))
+	debtors.GET("", s.getAPIDebtors)
+	debtors.GET("/export", s.getAPIDebtorsExport)
+
+	documentFolders := api.Group("/document-folders",
+		s.forRoles(role.Organization))
+	documentFolders.GET("", s.getAPIDocumentFolders)
+	documentFolders.POST("", s.postAPIDocumentFolders)
+	documentFolders.DELETE("/:folder_id", s.deleteAPIDocumentFolder)
+
+	documents := api.Group("/documents", s.forRoles(role.Organization))
+	documents.GET("", s.getAPIDocuments)
+	documents.POST("", s.postAPIDocuments)
+	documents.GET("/:document_id", s.getAPIDocument)
+	documents.PUT("/:document_id", s.putAPIDocument)
+	documents.DELETE("/:document_id", s.deleteAPIDocument)
+	documents.GET("/:document_id/versions", s.getAPIDocumentVersions)
+	documents.POST("/:document_id/versions", s.postAPIDocumentVersions)
+	documents.GET("/:document_id/versions/:version_id/download",
+		s.getAPIDocumentVersionDownload)
+
+	meterReadings := api.Group("/meter-readings",
+		s.forRoles(role.Organization))
+	meterReadings.GET("", s.getAPIMeterReadings)
+	meterReadings.GET("/export", s.getAPIMeterReadingsExport)
+
+	api.GET("/contractors", s.getAPIContractors,
+		s.forRoles(role.Organization, role.Operator))
+
+	contractors := api.Group("/contractors", s.forRoles(role.Organization))
+	contractors.POST("", s.postAPIContractors)
+	contractors.PUT("/:contractor_id", s.putAPIContractor)
+	contractors.DELETE("/:contractor_id", s.deleteAPIContractor)
+
+	api.GET("/response-templates", s.getAPIResponseTemplates,
+		s.forRoles(role.Organization, role.Operator))
+
+	responseTemplates := api.Group("/response-templates",
+		s.forRoles(role.Organization))
+	responseTemplates.POST("", s.postAPIResponseTemplates)
+	responseTemplates.PUT("/:response_template_id", s.putAPIResponseTemplate)
+	responseTemplates.DELETE("/:response_template_id",
+		s.deleteAPIResponseTemplate)
+
+	api.GET("/labels", s.getAPILabels,
+		s.forRoles(role.Organization, role.Operator))
+
+	labels := api.Group("/labels", s.forRoles(role.Organization))
+	labels.POST("", s.postAPILabels)
+	labels.PUT("/:label_id", s.putAPILabel)
+	labels.DELETE("/:label_id", s.deleteAPILabel)
+
+	api.GET("/custom-fields", s.getAPICustomFields,
+		s.forRoles(role.Organization, role.Operator))
+
+	customFields := api.Group("/custom-fields", s.forRoles(role.Organization))
+	customFields.POST("", s.postAPICustomFields)
+	customFields.PUT("/:custom_field_id", s.putAPICustomField)
+	customFields.DELETE("/:custom_field_id", s.deleteAPICustomField)
+
+	checklistTemplates := api.Group("/checklist-templates",
+		s.forRoles(role.Organization))
+	checklistTemplates.GET("", s.getAPIChecklistTemplates)
+	checklistTemplates.POST("", s.postAPIChecklistTemplates)
+	checklistTemplates.PUT("/:checklist_template_id",
+		s.putAPIChecklistTemplate)
+	checklistTemplates.DELETE("/:checklist_template_id",
+		s.deleteAPIChecklistTemplate)
+
+	api.GET("/work-orders/:token", s.getAPIWorkOrder)
+	api.PUT("/work-orders/:token", s.putAPIWorkOrder)
+
+	priorityRules := api.Group("/priority-rules", s.forRoles(role.Admin))
+	priorityRules.GET("", s.getAPIPriorityRules)
+	priorityRules.POST("", s.postAPIPriorityRules)
+	priorityRules.PUT("/:priority_rule_id", s.putAPIPriorityRule)
+	priorityRules.DELETE("/:priority_rule_id", s.deleteAPIPriorityRule)
+
+	api.GET("/addresses", s.getAPIAddresses, s.forRoles(role.Admin,
+		role.Organization, role.Operator))
+	api.POST("/address-registry", s.postAPIAddressRegistry,
+		s.forRoles(role.Admin))
+
+	adminRequests := api.Group("/admin/requests", s.forRoles(role.Admin))
+	adminRequests.GET("", s.getAPIAdminRequests)
+	adminRequests.GET("/stats", s.getAPIAdminRequestsStats)
+	adminRequests.GET("/category-stats", s.getAPIAdminRequestsCategoryStats)
+
+	organizations := api.Group("/organizations", s.forRoles(role.Admin))
 	organizations.GET("", s.getAPIOrganizations)
 	organizations.POST("", s.postAPIOrganizations)
 	organizations.PUT("/:organization_id", s.putAPIOrganization)
 	organizations.DELETE("/:organization_id", s.deleteAPIOrganization)
+	organizations.GET("/deleted", s.getAPIDeletedOrganizations)
+	organizations.POST("/:organization_id/restore",
+		s.postAPIOrganizationRestore)
 
-	operators := api.Group("/operators", forRoles(role.Organization))
+	operators := api.Group("/operators", s.forRoles(role.Organization))
 	operators.GET("", s.getAPIOperators)
 	operators.POST("", s.postAPIOperators)
 	operators.PUT("/:operator_id", s.putAPIOperator)
 	operators.DELETE("/:operator_id", s.deleteAPIOperator)
+	operators.GET("/deleted", s.getAPIDeletedOperators)
+	operators.POST("/:operator_id/restore", s.postAPIOperatorRestore)
 
-	owners := api.Group("/owners", forRoles(role.Organization))
+	owners := api.Group("/owners", s.forRoles(role.Organization))
 	owners.GET("", s.getAPIOwners)
 	owners.POST("", s.postAPIOwners)
 	owners.PUT("/:owner_id", s.putAPIOwner)
 	owners.DELETE("/:owner_id", s.deleteAPIOwner)
+	owners.GET("/deleted", s.getAPIDeletedOwners)
+	owners.POST("/:owner_id/restore", s.postAPIOwnerRestore)
+
+	incidents := api.Group("/incidents",
+		s.forRoles(role.Organization, role.Operator))
+	incidents.GET("", s.getAPIIncidents)
+	incidents.POST("", s.postAPIIncidents)
+	incidents.PUT("/:incident_id", s.putAPIIncident)
+	incidents.POST("/:incident_id/resolution", s.postAPIIncidentResolution)
 
 	operatorRequests := api.Group("/operators/requests",
-		forRoles(role.Operator))
+		s.forRoles(role.Operator))
 	operatorRequests.GET("", s.getAPIOperatorsRequests)
+	operatorRequests.POST("", s.postAPIStaffRequests)
+	operatorRequests.POST("/bulk", s.postAPIBulkRequests)
+	operatorRequests.GET("/:request_id/notes", s.getAPIRequestNotes)
+	operatorRequests.POST("/:request_id/notes", s.postAPIRequestNotes)
+	operatorRequests.PUT("/:request_id/attributes",
+		s.putAPIRequestAttributes)
+	operatorRequests.GET("/:request_id/tasks", s.getAPIRequestTasks)
+	operatorRequests.POST("/:request_id/tasks", s.postAPIRequestTasks)
+	operatorRequests.PUT("/:request_id/tasks/:task_id", s.putAPIRequestTask)
+	operatorRequests.DELETE("/:request_id/tasks/:task_id",
+		s.deleteAPIRequestTask)
 	operatorRequests.PUT("/:request_id", s.putAPIOperatorsRequest)
+	operatorRequests.POST("/:request_id/acknowledgement",
+		s.postAPIOperatorsRequestAcknowledgement)
+	operatorRequests.PUT("/:request_id/primary",
+		s.putAPIOperatorsRequestPrimary)
+	operatorRequests.DELETE("/:request_id/primary",
+		s.deleteAPIOperatorsRequestPrimary)
+	operatorRequests.POST("/:request_id/work-orders",
+		s.postAPIOperatorsRequestWorkOrders)
+	operatorRequests.POST("/:request_id/cost-items",
+		s.postAPIRequestCostItems)
+
+	operatorCostItems := api.Group("/operators/cost-items",
+		s.forRoles(role.Operator))
+	operatorCostItems.DELETE("/:cost_item_id",
+		s.deleteAPIOperatorsCostItem)
+
+	operatorTasks := api.Group("/operators/tasks", s.forRoles(role.Operator))
+	operatorTasks.GET("", s.getAPIOperatorsTasks)
+
+	organizationRequests := api.Group("/organization/requests",
+		s.forRoles(role.Organization))
+	organizationRequests.GET("", s.getAPIOrganizationRequests)
+	organizationRequests.POST("", s.postAPIStaffRequests)
+	organizationRequests.GET("/stats", s.getAPIOrganizationRequestsStats)
+	organizationRequests.GET("/export",
+		s.getAPIOrganizationRequestsExport)
+	organizationRequests.POST("/bulk", s.postAPIBulkRequests)
+	organizationRequests.GET("/:request_id", s.getAPIOrganizationRequest)
+	organizationRequests.GET("/:request_id/notes", s.getAPIRequestNotes)
+	organizationRequests.POST("/:request_id/notes", s.postAPIRequestNotes)
+	organizationRequests.PUT("/:request_id/attributes",
+		s.putAPIRequestAttributes)
+	organizationRequests.GET("/:request_id/tasks", s.getAPIRequestTasks)
+	organizationRequests.POST("/:request_id/tasks", s.postAPIRequestTasks)
+	organizationRequests.PUT("/:request_id/tasks/:task_id",
+		s.putAPIRequestTask)
+	organizationRequests.DELETE("/:request_id/tasks/:task_id",
+		s.deleteAPIRequestTask)
+	organizationRequests.POST("/:request_id/cost-items",
+		s.postAPIRequestCostItems)
+	organizationRequests.DELETE("/:request_id/cost-items/:cost_item_id",
+		s.deleteAPIRequestCostItem)
+
+	costReport := api.Group("/cost-report", s.forRoles(role.Organization))
+	costReport.GET("", s.getAPICostReport)
+	costReport.GET("/export", s.getAPICostReportExport)
+
+	operatorVisitSlots := api.Group("/operators/visit-slots",
+		s.forRoles(role.Operator))
+	operatorVisitSlots.GET("", s.getAPIOperatorsVisitSlots)
+	operatorVisitSlots.POST("", s.postAPIOperatorsVisitSlots)
+	operatorVisitSlots.DELETE("/:visit_slot_id",
+		s.deleteAPIOperatorsVisitSlot)
 
 	ownerRequests := api.Group("/owners/requests",
-		forRoles(role.Owner))
+		s.forRoles(role.Owner))
 	ownerRequests.GET("", s.getAPIOwnersRequests)
 	ownerRequests.POST("", s.postAPIOwnersRequests)
+	ownerRequests.POST("/duplicates", s.postAPIOwnersRequestsDuplicates)
+	ownerRequests.PUT("/:request_id", s.putAPIOwnersRequest)
+	ownerRequests.POST("/:request_id/cancellation",
+		s.postAPIOwnersRequestCancellation)
+	ownerRequests.PUT("/:request_id/visit", s.putAPIOwnersRequestVisit)
+	ownerRequests.DELETE("/:request_id/visit", s.deleteAPIOwnersRequestVisit)
+
+	ownerIncidents := api.Group("/owners/incidents", s.forRoles(role.Owner))
+	ownerIncidents.GET("", s.getAPIOwnersIncidents)
+
+	ownerHousehold := api.Group("/owners/household", s.forRoles(role.Owner))
+	ownerHousehold.GET("", s.getAPIOwnersHousehold)
+	ownerHousehold.POST("", s.postAPIOwnersHousehold)
+	ownerHousehold.DELETE("/:owner_id", s.deleteAPIOwnersHouseholdMember)
+
+	ownerAnnouncements := api.Group("/owners/announcements",
+		s.forRoles(role.Owner))
+	ownerAnnouncements.GET("", s.getAPIOwnersAnnouncements)
+	ownerAnnouncements.POST("/:announcement_id/read",
+		s.postAPIOwnersAnnouncementRead)
+
+	ownerMeters := api.Group("/owners/meters", s.forRoles(role.Owner))
+	ownerMeters.GET("", s.getAPIOwnersMeters)
+	ownerMeters.POST("/:meter_id/readings", s.postAPIOwnersMeterReadings)
+
+	ownerLedger := api.Group("/owners/ledger", s.forRoles(role.Owner))
+	ownerLedger.GET("", s.getAPIOwnersLedger)
+	ownerLedger.POST("/payments", s.postAPIOwnersPayments)
+
+	ownerPolls := api.Group("/owners/polls", s.forRoles(role.Owner))
+	ownerPolls.GET("", s.getAPIOwnersPolls)
+	ownerPolls.POST("/:poll_id/votes", s.postAPIOwnersPollVotes)
+
+	ownerDocuments := api.Group("/owners/documents", s.forRoles(role.Owner))
+	ownerDocuments.GET("", s.getAPIOwnersDocuments)
+	ownerDocuments.GET("/:document_id/download",
+		s.getAPIOwnersDocumentDownload)
+
+	ownerCommonRequests := api.Group("/owners/common-requests",
+		s.forRoles(role.Owner))
+	ownerCommonRequests.GET("", s.getAPIOwnersCommonRequests)
+	ownerCommonRequests.POST("/:request_id/support",
+		s.postAPIOwnersCommonRequestSupport)
 
 	s.echo = e
 
@@ -319,39 +1026,1323 @@ func (s *Server) Stop() {
 	s.waitGroup.Wait()
 }
 
-func forRoles(wantRoles ...string) func(echo.HandlerFunc) echo.HandlerFunc {
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			if len(wantRoles) == 0 {
-				return errors.New("wanted roles are empty")
-			}
+func (s *Server) classifyRequest(r entity.Request) entity.Request {
+	categoryID, err := s.classifier.Classify(r.Text)
+	if err != nil {
+		s.log.WithError(err).Error("failed to classify request text")
+		r.CategoryID = nil
+	} else {
+		r.CategoryID = &categoryID
+	}
+	return r
+}
 
-			sess, err := session.Get("session", c)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest,
-					"failed to get session")
-			}
+// routeRequest raises priority of the classified request by the priority
+// rules and finds operator to handle it.
+func (s *Server) routeRequest(r entity.Request) (
+	entity.Request, *entity.Operator) {
 
-			roleI, exists := sess.Values["role"]
-			if !exists {
-				return echo.NewHTTPError(http.StatusForbidden)
-			}
+	r = s.prioritizeRequest(r)
+	return s.assignRequest(r)
+}
 
-			gotRole, ok := roleI.(string)
-			if !ok {
-				return echo.NewHTTPError(http.StatusBadRequest,
-					"failed to get role from session")
-			}
+// prioritizeRequest raises priority of the classified request by the
+// priority rules. Priority chosen by the owner is remembered so that it is
+// not lost when the request is prioritized again.
+func (s *Server) prioritizeRequest(r entity.Request) entity.Request {
+	prs, err := s.storage.PriorityRules()
+	if err != nil {
+		s.log.WithError(err).Error("failed to get priority rules from storage")
+	}
 
-			for _, wantRole := range wantRoles {
-				if gotRole == wantRole {
-					return next(c)
-				}
-			}
+	r.Priority = priority.Max(r.Priority)
 
-			return echo.NewHTTPError(http.StatusForbidden)
+	if r.OwnerPriority == "" {
+		r.OwnerPriority = r.Priority
+	}
+
+	for _, pr := range prs {
+		match, err := pr.Match(r.Text, r.CategoryID)
+		if err != nil {
+			s.log.WithError(err).WithField("priority_rule_id", pr.ID).
+				Error("failed to match priority rule")
+			continue
+		}
+		if match {
+			r.Priority = priority.Max(r.Priority, pr.Priority)
+		}
+	}
+
+	return r
+}
+
+// assignRequest finds operator to handle the prioritized request. Emergency
+// requests are assigned to on duty operators first.
+func (s *Server) assignRequest(r entity.Request) (
+	entity.Request, *entity.Operator) {
+
+	if r.Priority == priority.Emergency {
+		o, err := s.storage.FindOrganizationOnDutyOperator(
+			r.OrganizationID, r.CategoryID)
+		if err == nil {
+			r.OperatorID = &o.ID
+			return r, &o
+		}
+		s.log.WithError(err).Warn(
+			"failed to find on duty operator for emergency request")
+	}
+
+	if r.CategoryID == nil {
+		return r, nil
+	}
+
+	o, err := s.storage.FindOrganizationOperator(
+		r.OrganizationID, *r.CategoryID)
+	if err != nil {
+		s.log.WithError(err).Error(
+			"failed to find organization operator for request")
+		return r, nil
+	}
+
+	r.OperatorID = &o.ID
+
+	return r, &o
+}
+
+// notifyEmergency immediately notifies operator about emergency request.
+// Next notifications are sent by alarm until operator acknowledges request.
+func (s *Server) notifyEmergency(r entity.Request, o *entity.Operator) {
+	if r.Priority != priority.Emergency || o == nil {
+		return
+	}
+
+	err := s.smsSender.SendSMS(o.Phone, fmt.Sprintf(
+		"Аварийное обращение №%d: %s", r.ID, r.Text))
+	if err != nil {
+		s.log.WithError(err).Error("failed to send emergency SMS")
+		return
+	}
+
+	err = s.storage.SetRequestNotifiedAt(r.ID, time.Now())
+	if err != nil {
+		s.log.WithError(err).Error(
+			"failed to set request notified at in storage")
+	}
+}
+
+// tokenSize is a size in bytes of the random tokens authorizing access
+// without session, such as calendar feeds.
+const tokenSize = 32
+
+// randomToken generates hex encoded random token.
+func randomToken() (string, error) {
+	t := make([]byte, tokenSize)
+
+	_, err := rand.Read(t)
+	if err != nil {
+		return "", errors.New("failed to generate token: " + err.Error())
+	}
+
+	return hex.EncodeToString(t), nil
+}
+
+// calendarHistoryPeriod is how long past visits are kept in the operator
+// calendar feed.
+const calendarHistoryPeriod = 30 * 24 * time.Hour
+
+const (
+	duplicateMinSimilarity = 0.5
+	duplicatePeriod        = 14 * 24 * time.Hour
+	duplicatesMaxCount     = 5
+)
+
+// findDuplicates finds likely duplicates of the classified request among
+// recent open requests of the same building and category.
+func (s *Server) findDuplicates(r entity.Request, address string) (
+	[]entity.RequestDuplicate, error) {
+
+	rs, err := s.storage.DuplicateCandidates(r.OrganizationID, r.CategoryID,
+		time.Now().Add(-duplicatePeriod))
+	if err != nil {
+		return nil, errors.New(
+			"failed to get duplicate candidates from storage: " + err.Error())
+	}
+
+	building := dedup.BuildingAddress(address)
+
+	var rds []entity.RequestDuplicate
+
+	for _, cr := range rs {
+		if cr.ID == r.ID || cr.Address() == "" ||
+			dedup.BuildingAddress(cr.Address()) != building {
+			continue
+		}
+
+		similarity := dedup.Similarity(r.Text, cr.Text)
+		if similarity < duplicateMinSimilarity {
+			continue
+		}
+
+		rds = append(rds, entity.RequestDuplicate{
+			RequestID:   r.ID,
+			DuplicateID: cr.ID,
+			Similarity:  similarity,
+			Text:        cr.Text,
+			Status:      cr.Status,
+			CreatedAt:   cr.CreatedAt,
+		})
+	}
+
+	sort.Slice(rds, func(i, j int) bool {
+		return rds[i].Similarity > rds[j].Similarity
+	})
+
+	if len(rds) > duplicatesMaxCount {
+		rds = rds[:duplicatesMaxCount]
+	}
+
+	return rds, nil
+}
+
+// addRequestDuplicates stores duplicates found for the request before it
+// was added, so operators can see them.
+func (s *Server) addRequestDuplicates(r entity.Request,
+	rds []entity.RequestDuplicate) {
+
+	if len(rds) == 0 {
+		return
+	}
+
+	for i := range rds {
+		rds[i].RequestID = r.ID
+	}
+
+	err := s.storage.AddRequestDuplicates(rds)
+	if err != nil {
+		s.log.WithError(err).Error(
+			"failed to add request duplicates to storage")
+	}
+}
+
+// operatorRequests returns page of operator requests selected by the filter
+// with their likely duplicates, history, work orders, costs, notes, labels,
+// custom fields and tasks. Cursor of the next page is returned if there is
+// any.
+func (s *Server) operatorRequests(operatorID int, f entity.RequestFilter) (
+	[]entity.RequestExtended, string, error) {
+
+	limit := f.Limit
+	f.Limit++
+
+	rs, err := s.storage.OperatorRequests(operatorID, f)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests from storage: " + err.Error())
+	}
+
+	rs, cursor := nextRequestsCursor(rs, limit)
+
+	ids := make([]int, 0, len(rs))
+	index := map[int]int{}
+	for i, r := range rs {
+		ids = append(ids, r.ID)
+		index[r.ID] = i
+	}
+
+	rds, err := s.storage.OperatorRequestsDuplicates(operatorID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests duplicates from storage: " +
+				err.Error())
+	}
+
+	res, err := s.storage.OperatorRequestsEvents(operatorID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests events from storage: " +
+				err.Error())
+	}
+
+	for _, rd := range rds {
+		if i, ok := index[rd.RequestID]; ok {
+			rs[i].Duplicates = append(rs[i].Duplicates, rd)
+		}
+	}
+
+	for _, re := range res {
+		if i, ok := index[re.RequestID]; ok {
+			rs[i].Events = append(rs[i].Events, re)
+		}
+	}
+
+	wos, err := s.storage.OperatorRequestsWorkOrders(operatorID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests work orders from storage: " +
+				err.Error())
+	}
+
+	for _, wo := range wos {
+		if i, ok := index[wo.RequestID]; ok {
+			rs[i].WorkOrders = append(rs[i].WorkOrders, wo)
+		}
+	}
+
+	cis, err := s.storage.OperatorRequestsCostItems(operatorID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests cost items from storage: " +
+				err.Error())
+	}
+
+	for _, ci := range cis {
+		if i, ok := index[ci.RequestID]; ok {
+			rs[i].CostItems = append(rs[i].CostItems, ci)
+		}
+	}
+
+	rns, err := s.storage.OperatorRequestsNotes(operatorID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get operator requests notes from storage: " +
+				err.Error())
+	}
+
+	for _, rn := range rns {
+		if i, ok := index[rn.RequestID]; ok {
+			rs[i].Notes = append(rs[i].Notes, rn)
+		}
+	}
+
+	err = s.attachRequestsAttributes(rs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.attachRequestsTasks(rs, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rs, cursor, nil
+}
+
+// editRequest sets text of the new owner request. Request is classified and
+// prioritized again unless it is linked to the primary request, and routed
+// again only if its priority or category changes.
+func (s *Server) editRequest(ownerID int, requestID int, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request text is empty")
+	}
+
+	r, err := s.storage.OwnerRequest(ownerID, requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		return errors.New("failed to get owner request from storage: " +
+			err.Error())
+	}
+
+	if r.Status != status.New {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"only new request can be edited")
+	}
+
+	prevText := r.Text
+	r.Text = text
+
+	var o *entity.Operator
+
+	// Priority raised by the rules for the previous text should not stick,
+	// so the request is prioritized from the priority chosen by the owner.
+	if r.PrimaryRequestID == nil {
+		prevPriority := r.Priority
+		prevCategoryID := r.CategoryID
+
+		r.Priority = r.OwnerPriority
+		r = s.classifyRequest(r)
+		r = s.prioritizeRequest(r)
+
+		if r.Priority != prevPriority ||
+			!sameID(r.CategoryID, prevCategoryID) {
+			r, o = s.assignRequest(r)
+		}
+	}
+
+	r, err = s.storage.EditOwnerRequest(ownerID, r, entity.RequestEvent{
+		RequestID: r.ID,
+		Role:      role.Owner,
+		ActorID:   ownerID,
+		Kind:      event.Edited,
+		Text:      &prevText,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		return errors.New("failed to edit owner request in storage: " +
+			err.Error())
+	}
+
+	s.notifyEmergency(r, o)
+
+	return nil
+}
+
+// sameID reports whether both optional IDs are empty or equal.
+func sameID(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func (s *Server) cancelRequest(ownerID int, requestID int,
+	reason string) error {
+
+	r, err := s.storage.OwnerRequest(ownerID, requestID)
+	if err != nil {
+		return errors.New("failed to get owner request from storage: " +
+			err.Error())
+	}
+
+	if status.Final(r.Status) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request status is already in final state")
+	}
+
+	err = s.storage.CancelOwnerRequest(ownerID, requestID, entity.RequestEvent{
+		RequestID: requestID,
+		Role:      role.Owner,
+		ActorID:   ownerID,
+		Kind:      event.Cancelled,
+		Text:      &reason,
+	})
+	if err != nil {
+		return errors.New("failed to cancel owner request in storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// linkRequest links the request to the primary one as duplicate. Both
+// requests should be of the operator organization and operator should be
+// assigned to one of them.
+func (s *Server) linkRequest(operatorLogin string, requestID int,
+	primaryRequestID int) error {
+
+	if requestID == primaryRequestID {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request can't be linked to itself")
+	}
+
+	o, err := s.storage.Operator(operatorLogin)
+	if err != nil {
+		return errors.New("failed to get operator from storage: " +
+			err.Error())
+	}
+
+	r, err := s.storage.Request(requestID)
+	if err != nil {
+		return errors.New("failed to get request from storage: " +
+			err.Error())
+	}
+
+	p, err := s.storage.Request(primaryRequestID)
+	if err != nil {
+		return errors.New("failed to get primary request from storage: " +
+			err.Error())
+	}
+
+	if r.OrganizationID != o.OrganizationID ||
+		p.OrganizationID != o.OrganizationID {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	if (r.OperatorID == nil || *r.OperatorID != o.ID) &&
+		(p.OperatorID == nil || *p.OperatorID != o.ID) {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	if p.PrimaryRequestID != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"primary request is linked to other request")
+	}
+
+	if status.Final(r.Status) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request status is already in final state")
+	}
+
+	err = s.storage.LinkRequest(requestID, primaryRequestID)
+	if err != nil {
+		return errors.New("failed to link request in storage: " + err.Error())
+	}
+
+	return nil
+}
+
+func (s *Server) unlinkRequest(operatorID int, requestID int) error {
+	r, err := s.storage.Request(requestID)
+	if err != nil {
+		return errors.New("failed to get request from storage: " +
+			err.Error())
+	}
+
+	if r.OperatorID == nil || *r.OperatorID != operatorID {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	err = s.storage.UnlinkRequest(requestID)
+	if err != nil {
+		return errors.New("failed to unlink request in storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// buildingIncidents returns open organization incidents of the owner
+// building.
+func (s *Server) buildingIncidents(organizationID int, ownerAddress string) (
+	[]entity.Incident, error) {
+
+	is, err := s.storage.OpenOrganizationIncidents(organizationID)
+	if err != nil {
+		return nil, errors.New(
+			"failed to get open organization incidents from storage: " +
+				err.Error())
+	}
+
+	building := dedup.BuildingAddress(ownerAddress)
+
+	var bis []entity.Incident
+
+	for _, i := range is {
+		for _, b := range i.Buildings {
+			if dedup.BuildingAddress(b) == building {
+				bis = append(bis, i)
+				break
+			}
 		}
 	}
+
+	return bis, nil
+}
+
+// attachRequest attaches the owner request to the incident of the owner
+// building. Attached requests are not routed to operators, they are
+// resolved with the incident.
+func (s *Server) attachRequest(r entity.Request, ownerAddress string) (
+	entity.Request, error) {
+
+	is, err := s.buildingIncidents(r.OrganizationID, ownerAddress)
+	if err != nil {
+		return r, err
+	}
+
+	for _, i := range is {
+		if i.ID == *r.IncidentID {
+			if r.Text == "" {
+				r.Text = i.Title
+			}
+			r.Status = status.InProgress
+			r.Priority = priority.Normal
+			r.OperatorID = nil
+			return r, nil
+		}
+	}
+
+	return r, echo.NewHTTPError(http.StatusBadRequest,
+		"incident is not found in owner building or already resolved")
+}
+
+// resolveIncident resolves the incident with its attached requests and
+// notifies their owners.
+func (s *Server) resolveIncident(organizationID int, incidentID int,
+	response string) error {
+
+	i, err := s.storage.OrganizationIncident(organizationID, incidentID)
+	if err != nil {
+		return errors.New("failed to get organization incident from storage: " +
+			err.Error())
+	}
+
+	if i.Resolved() {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"incident is already resolved")
+	}
+
+	phones, err := s.storage.ResolveIncident(organizationID, incidentID,
+		response)
+	if err != nil {
+		return errors.New("failed to resolve incident in storage: " +
+			err.Error())
+	}
+
+	for _, phone := range phones {
+		err = s.smsSender.SendSMS(phone, fmt.Sprintf(
+			"Авария устранена: %s. %s", i.Title, response))
+		if err != nil {
+			s.log.WithError(err).WithField("incident_id", incidentID).
+				Error("failed to send incident resolution SMS")
+		}
+	}
+
+	return nil
+}
+
+// requests returns page of requests of all organizations selected by the
+// filter. Cursor of the next page is returned if there is any.
+func (s *Server) requests(f entity.RequestFilter) ([]entity.RequestExtended,
+	string, error) {
+
+	limit := f.Limit
+	f.Limit++
+
+	rs, err := s.storage.Requests(f)
+	if err != nil {
+		return nil, "", errors.New("failed to get requests from storage: " +
+			err.Error())
+	}
+
+	rs, cursor := nextRequestsCursor(rs, limit)
+
+	return rs, cursor, nil
+}
+
+// organizationRequests returns page of organization requests selected by the
+// filter with their labels, custom fields and tasks. Cursor of the next page is
+// returned if there is any.
+func (s *Server) organizationRequests(organizationID int,
+	f entity.RequestFilter) ([]entity.RequestExtended, string, error) {
+
+	limit := f.Limit
+	f.Limit++
+
+	rs, err := s.storage.OrganizationRequests(organizationID, f)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get organization requests from storage: " +
+				err.Error())
+	}
+
+	rs, cursor := nextRequestsCursor(rs, limit)
+
+	err = s.attachRequestsAttributes(rs)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = s.attachRequestsTasks(rs, true)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rs, cursor, nil
+}
+
+// organizationRequest returns organization request with its history, work
+// orders, costs, notes, labels, custom fields and tasks.
+func (s *Server) organizationRequest(organizationID int, requestID int) (
+	entity.RequestExtended, error) {
+
+	r, err := s.storage.OrganizationRequest(organizationID, requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return r, echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		return r, errors.New(
+			"failed to get organization request from storage: " +
+				err.Error())
+	}
+
+	r.Events, err = s.storage.RequestEvents(requestID)
+	if err != nil {
+		return r, errors.New("failed to get request events from storage: " +
+			err.Error())
+	}
+
+	r.WorkOrders, err = s.storage.RequestWorkOrders(requestID)
+	if err != nil {
+		return r, errors.New(
+			"failed to get request work orders from storage: " + err.Error())
+	}
+
+	r.CostItems, err = s.storage.RequestCostItems(requestID)
+	if err != nil {
+		return r, errors.New(
+			"failed to get request cost items from storage: " + err.Error())
+	}
+
+	r.Notes, err = s.storage.RequestNotes(requestID)
+	if err != nil {
+		return r, errors.New("failed to get request notes from storage: " +
+			err.Error())
+	}
+
+	rs := []entity.RequestExtended{r}
+
+	err = s.attachRequestsAttributes(rs)
+	if err != nil {
+		return r, err
+	}
+
+	err = s.attachRequestsTasks(rs, true)
+	if err != nil {
+		return r, err
+	}
+
+	return rs[0], nil
+}
+
+// staffRequest returns organization request the staff actor has access to.
+// The operator has access only to the requests assigned to them.
+func (s *Server) staffRequest(actorRole string, actorID int,
+	organizationID int, requestID int) (entity.RequestExtended, error) {
+
+	r, err := s.storage.OrganizationRequest(organizationID, requestID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return r, echo.NewHTTPError(http.StatusNotFound,
+				"request not found")
+		}
+		return r, errors.New(
+			"failed to get organization request from storage: " +
+				err.Error())
+	}
+
+	if actorRole == role.Operator &&
+		(r.OperatorID == nil || *r.OperatorID != actorID) {
+		return r, echo.NewHTTPError(http.StatusNotFound,
+			"request not found")
+	}
+
+	return r, nil
+}
+
+// addRequestNote adds internal note of the staff actor to the request.
+func (s *Server) addRequestNote(actorRole string, actorID int,
+	organizationID int, n entity.RequestNote) (entity.RequestNote, error) {
+
+	n.Text = strings.TrimSpace(n.Text)
+
+	err := n.Validate()
+	if err != nil {
+		return n, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate request note: "+err.Error())
+	}
+
+	_, err = s.staffRequest(actorRole, actorID, organizationID, n.RequestID)
+	if err != nil {
+		return n, err
+	}
+
+	n.Role = actorRole
+	n.AuthorID = actorID
+
+	n, err = s.storage.AddRequestNote(n)
+	if err != nil {
+		return n, errors.New("failed to add request note to storage: " +
+			err.Error())
+	}
+
+	return n, nil
+}
+
+// ownerRequests returns page of owner requests selected by the filter with
+// booked visits, free visit slots of the operators handling them and tasks
+// progress. Cursor of the next page is returned if there is any.
+func (s *Server) ownerRequests(ownerID int, f entity.RequestFilter) (
+	[]entity.RequestExtended, string, error) {
+
+	limit := f.Limit
+	f.Limit++
+
+	rs, err := s.storage.OwnerRequests(ownerID, f)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get owner requests from storage: " + err.Error())
+	}
+
+	rs, cursor := nextRequestsCursor(rs, limit)
+
+	ids := make([]int, 0, len(rs))
+	index := map[int]int{}
+	for i, r := range rs {
+		ids = append(ids, r.ID)
+		index[r.ID] = i
+	}
+
+	vss, err := s.storage.OwnerRequestsVisitSlots(ownerID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get owner requests visit slots from storage: " +
+				err.Error())
+	}
+
+	fvss, err := s.storage.OwnerRequestsFreeVisitSlots(ownerID, ids,
+		time.Now())
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get owner requests free visit slots from storage: " +
+				err.Error())
+	}
+
+	for i := range vss {
+		if j, ok := index[*vss[i].RequestID]; ok {
+			rs[j].Visit = &vss[i]
+		}
+	}
+
+	wos, err := s.storage.OwnerRequestsWorkOrders(ownerID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get owner requests work orders from storage: " +
+				err.Error())
+	}
+
+	for _, wo := range wos {
+		if i, ok := index[wo.RequestID]; ok {
+			rs[i].WorkOrders = append(rs[i].WorkOrders, wo)
+		}
+	}
+
+	cis, err := s.storage.OwnerRequestsCostItems(ownerID, ids)
+	if err != nil {
+		return nil, "", errors.New(
+			"failed to get owner requests cost items from storage: " +
+				err.Error())
+	}
+
+	// Owners see only costs billable to them.
+	for _, ci := range cis {
+		if i, ok := index[ci.RequestID]; ok && ci.Billable {
+			rs[i].CostItems = append(rs[i].CostItems, ci)
+		}
+	}
+
+	for i, r := range rs {
+		if r.OperatorID == nil || status.Final(r.Status) {
+			continue
+		}
+		for _, vs := range fvss {
+			if vs.OperatorID == *r.OperatorID {
+				rs[i].FreeVisitSlots = append(rs[i].FreeVisitSlots, vs)
+			}
+		}
+	}
+
+	err = s.attachRequestsTasks(rs, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return rs, cursor, nil
+}
+
+func (s *Server) addVisitSlot(vs entity.VisitSlot) (entity.VisitSlot, error) {
+	err := parseVisitSlot(&vs)
+	if err != nil {
+		return vs, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse visit slot: "+err.Error())
+	}
+
+	err = vs.Validate()
+	if err != nil {
+		return vs, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid visit slot: "+err.Error())
+	}
+
+	if !vs.StartsAt.After(time.Now()) {
+		return vs, echo.NewHTTPError(http.StatusBadRequest,
+			"visit slot must start in future")
+	}
+
+	vs, err = s.storage.AddVisitSlot(vs)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return vs, echo.NewHTTPError(http.StatusConflict,
+				"visit slot overlaps other visit slot")
+		}
+		return vs, errors.New("failed to add visit slot to storage: " +
+			err.Error())
+	}
+
+	return vs, nil
+}
+
+func (s *Server) bookVisitSlot(ownerID int, requestID int,
+	visitSlotID int) error {
+
+	err := s.storage.BookVisitSlot(ownerID, requestID, visitSlotID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"visit slot is not available for request")
+		}
+		return errors.New("failed to book visit slot in storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+// addWorkOrder issues work order for the operator request and sends the
+// link to update it to the contractor.
+func (s *Server) addWorkOrder(operatorID int, wo entity.WorkOrder,
+	baseURL string) (entity.WorkOrder, error) {
+
+	if wo.DueAtStr != "" {
+		t, err := time.ParseInLocation("2006-01-02", wo.DueAtStr, time.Local)
+		if err != nil {
+			return wo, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse due at: "+err.Error())
+		}
+		wo.DueAt = &t
+	}
+
+	wo.Status = workstatus.Issued
+	wo.Comment = nil
+
+	err := wo.Validate()
+	if err != nil {
+		return wo, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid work order: "+err.Error())
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return wo, err
+	}
+
+	wo.Token = &token
+
+	wo, err = s.storage.AddWorkOrder(operatorID, wo)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return wo, echo.NewHTTPError(http.StatusBadRequest,
+				"request or contractor not found")
+		}
+		return wo, errors.New("failed to add work order to storage: " +
+			err.Error())
+	}
+
+	woe, err := s.storage.WorkOrder(token)
+	if err != nil {
+		s.log.WithError(err).Error("failed to get work order from storage")
+		return wo, nil
+	}
+
+	msg := fmt.Sprintf("Заказ-наряд №%d: %s. Ссылка для отметки о ходе "+
+		"работ: %s/work-orders/%s", wo.ID, wo.Scope, baseURL, token)
+
+	if woe.ContractorPhone != "" {
+		err = s.smsSender.SendSMS(woe.ContractorPhone, msg)
+		if err != nil {
+			s.log.WithError(err).Error("failed to send work order SMS")
+		}
+	}
+
+	if woe.ContractorEmail != "" {
+		err = s.emailSender.SendEmail(woe.ContractorEmail, msg)
+		if err != nil {
+			s.log.WithError(err).Error("failed to send work order email")
+		}
+	}
+
+	return wo, nil
+}
+
+// workOrder returns work order by its token. Expired tokens are not found.
+func (s *Server) workOrder(token string) (entity.WorkOrderExtended, error) {
+	wo, err := s.storage.WorkOrder(token)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return wo, echo.NewHTTPError(http.StatusNotFound,
+				"work order not found")
+		}
+		return wo, errors.New("failed to get work order from storage: " +
+			err.Error())
+	}
+	return wo, nil
+}
+
+// setWorkOrderStatus sets status of the work order by the contractor and
+// notifies owner when work is completed. The updated work order is
+// returned since its token is expired once it becomes final.
+func (s *Server) setWorkOrderStatus(token string, st string,
+	comment *string) (entity.WorkOrderExtended, error) {
+
+	var wo entity.WorkOrderExtended
+
+	if st == workstatus.Issued {
+		return wo, echo.NewHTTPError(http.StatusBadRequest,
+			"work order can't be set to issued status")
+	}
+
+	err := workstatus.Validate(st)
+	if err != nil {
+		return wo, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid work status: "+err.Error())
+	}
+
+	wo, err = s.workOrder(token)
+	if err != nil {
+		return wo, err
+	}
+
+	err = s.storage.SetWorkOrderStatus(token, st, comment)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return wo, echo.NewHTTPError(http.StatusBadRequest,
+				"work order not found or already completed")
+		}
+		return wo, errors.New(
+			"failed to set work order status in storage: " + err.Error())
+	}
+
+	wo.Status = st
+	wo.Comment = comment
+
+	if st != workstatus.Completed {
+		return wo, nil
+	}
+
+	now := time.Now()
+	wo.CompletedAt = &now
+
+	if wo.OwnerPhone != nil {
+		err = s.smsSender.SendSMS(*wo.OwnerPhone, fmt.Sprintf(
+			"Подрядчик выполнил работы по обращению №%d: %s",
+			wo.RequestID, wo.Scope))
+		if err != nil {
+			s.log.WithError(err).Error(
+				"failed to send work order completion SMS")
+		}
+	}
+
+	return wo, nil
+}
+
+// addCostItem adds cost item to the request the staff actor has access to.
+// Operators record their own labor, organization staff records labor of any
+// of the organization operators.
+func (s *Server) addCostItem(actorRole string, actorID int,
+	organizationID int, ci entity.CostItem) (entity.CostItem, error) {
+
+	var err error
+
+	if ci.QuantityStr != "" {
+		ci.Quantity, err = decimal.ParseQuantity(ci.QuantityStr)
+		if err != nil {
+			return ci, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse quantity: "+err.Error())
+		}
+	}
+
+	if ci.UnitPriceStr != "" {
+		ci.UnitPrice, err = decimal.ParseMoney(ci.UnitPriceStr)
+		if err != nil {
+			return ci, echo.NewHTTPError(http.StatusBadRequest,
+				"failed to parse unit price: "+err.Error())
+		}
+	}
+
+	_, err = s.staffRequest(actorRole, actorID, organizationID, ci.RequestID)
+	if err != nil {
+		return ci, err
+	}
+
+	if !ci.Labor() {
+		ci.OperatorID = nil
+	} else {
+		if actorRole == role.Operator {
+			ci.OperatorID = &actorID
+		}
+		if ci.OperatorID == nil {
+			return ci, echo.NewHTTPError(http.StatusBadRequest,
+				"labor operator required")
+		}
+		err = s.validateOrganizationOperator(organizationID, *ci.OperatorID)
+		if err != nil {
+			return ci, err
+		}
+		if ci.Name == "" {
+			ci.Name = "Работы"
+		}
+	}
+
+	err = ci.Validate()
+	if err != nil {
+		return ci, echo.NewHTTPError(http.StatusBadRequest,
+			"invalid cost item: "+err.Error())
+	}
+
+	ci, err = s.storage.AddCostItem(ci)
+	if err != nil {
+		return ci, errors.New("failed to add cost item to storage: " +
+			err.Error())
+	}
+
+	return ci, nil
+}
+
+// removeCostItem removes cost item of the request the staff actor has
+// access to.
+func (s *Server) removeCostItem(actorRole string, actorID int,
+	organizationID int, requestID int, costItemID int) error {
+
+	_, err := s.staffRequest(actorRole, actorID, organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.RemoveRequestCostItem(requestID, costItemID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound,
+				"cost item not found")
+		}
+		return errors.New("failed to remove request cost item from storage: " +
+			err.Error())
+	}
+
+	return nil
+}
+
+func (s *Server) organizationCostItems(organizationID int, month string) (
+	time.Time, time.Time, []entity.CostItemExtended, error) {
+
+	from, to, err := parseMonth(month)
+	if err != nil {
+		return from, to, nil, echo.NewHTTPError(http.StatusBadRequest,
+			err.Error())
+	}
+
+	cis, err := s.storage.OrganizationCostItems(organizationID, from, to)
+	if err != nil {
+		return from, to, nil, errors.New(
+			"failed to get organization cost items from storage: " +
+				err.Error())
+	}
+
+	return from, to, cis, nil
+}
+
+// parseVisitSlot parses visit slot fields submitted as form strings.
+func parseVisitSlot(vs *entity.VisitSlot) error {
+	if vs.StartsAtStr != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", vs.StartsAtStr,
+			time.Local)
+		if err != nil {
+			return errors.New("failed to parse starts at: " + err.Error())
+		}
+		vs.StartsAt = t
+	}
+
+	if vs.EndsAtStr != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04", vs.EndsAtStr,
+			time.Local)
+		if err != nil {
+			return errors.New("failed to parse ends at: " + err.Error())
+		}
+		vs.EndsAt = t
+	}
+
+	return nil
+}
+
+// parseIncident parses incident fields submitted as form strings.
+func parseIncident(i *entity.Incident) error {
+	if i.BuildingsStr != "" {
+		i.Buildings = nil
+		for _, b := range strings.Split(i.BuildingsStr, "\n") {
+			b = strings.TrimSpace(b)
+			if b != "" {
+				i.Buildings = append(i.Buildings, b)
+			}
+		}
+	}
+
+	if i.ExpectedResolutionAtStr != "" {
+		t, err := time.ParseInLocation("2006-01-02T15:04",
+			i.ExpectedResolutionAtStr, time.Local)
+		if err != nil {
+			return errors.New("failed to parse expected resolution time: " +
+				err.Error())
+		}
+		i.ExpectedResolutionAt = &t
+	}
+
+	return nil
+}
+
+// sessionActor returns role, ID and organization ID of the session staff
+// user. Actor ID of organization is organization ID.
+func sessionActor(c echo.Context) (rl string, actorID int, organizationID int,
+	err error) {
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return "", 0, 0, echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	rl, ok := sess.Values["role"].(string)
+	if !ok {
+		return "", 0, 0, errors.New("failed to get role from session")
+	}
+
+	organizationID, ok = sess.Values["organization_id"].(int)
+	if !ok {
+		return "", 0, 0, errors.New(
+			"failed to get organization ID from session")
+	}
+
+	actorID = organizationID
+
+	if rl == role.Operator {
+		actorID, ok = sess.Values["operator_id"].(int)
+		if !ok {
+			return "", 0, 0, errors.New(
+				"failed to get operator ID from session")
+		}
+	}
+
+	return rl, actorID, organizationID, nil
+}
+
+// forRoles allows only the session actors of the wanted roles. Sessions of
+// the deleted organizations, operators and owners are not allowed anymore.
+func (s *Server) forRoles(
+	wantRoles ...string) func(echo.HandlerFunc) echo.HandlerFunc {
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if len(wantRoles) == 0 {
+				return errors.New("wanted roles are empty")
+			}
+
+			sess, err := session.Get("session", c)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"failed to get session")
+			}
+
+			roleI, exists := sess.Values["role"]
+			if !exists {
+				return echo.NewHTTPError(http.StatusForbidden)
+			}
+
+			gotRole, ok := roleI.(string)
+			if !ok {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"failed to get role from session")
+			}
+
+			for _, wantRole := range wantRoles {
+				if gotRole != wantRole {
+					continue
+				}
+
+				err = s.checkActiveActor(sess, gotRole)
+				if err != nil {
+					return err
+				}
+
+				err = s.fillOperatorOrganization(c, sess, gotRole)
+				if err != nil {
+					return err
+				}
+
+				return next(c)
+			}
+
+			return echo.NewHTTPError(http.StatusForbidden)
+		}
+	}
+}
+
+// sessionActorIDKeys are session keys of the actor IDs by actor roles which
+// can be deleted.
+var sessionActorIDKeys = map[string]string{
+	role.Organization: "organization_id",
+	role.Operator:     "operator_id",
+	role.Owner:        "owner_id",
+}
+
+// checkActiveActor checks that the session actor is not deleted.
+func (s *Server) checkActiveActor(sess *sessions.Session,
+	actorRole string) error {
+
+	key, ok := sessionActorIDKeys[actorRole]
+	if !ok {
+		return nil
+	}
+
+	actorID, ok := sess.Values[key].(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get actor ID from session")
+	}
+
+	active, err := s.storage.ActiveActor(actorRole, actorID)
+	if err != nil {
+		return errors.New("failed to check active actor in storage: " +
+			err.Error())
+	}
+
+	if !active {
+		return echo.NewHTTPError(http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// fillOperatorOrganization adds the organization ID to the operator sessions
+// created before it was stored in the session on login.
+func (s *Server) fillOperatorOrganization(c echo.Context,
+	sess *sessions.Session, actorRole string) error {
+
+	if actorRole != role.Operator {
+		return nil
+	}
+
+	if _, ok := sess.Values["organization_id"].(int); ok {
+		return nil
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get operator ID from session")
+	}
+
+	o, err := s.storage.OperatorByID(operatorID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusForbidden)
+		}
+		return errors.New("failed to get operator from storage: " +
+			err.Error())
+	}
+
+	sess.Values["organization_id"] = o.OrganizationID
+
+	err = sess.Save(c.Request(), c.Response())
+	if err != nil {
+		return errors.New("failed to save session: " + err.Error())
+	}
+
+	return nil
 }
 
 func logrusLogger(next echo.HandlerFunc) echo.HandlerFunc {
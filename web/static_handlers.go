@@ -1,6 +1,7 @@
 package web
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -13,7 +14,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/dimuls/swan/entity"
+	"github.com/dimuls/swan/entity/fieldtype"
+	"github.com/dimuls/swan/entity/priority"
 	"github.com/dimuls/swan/entity/role"
+	"github.com/dimuls/swan/entity/sorting"
 	"github.com/dimuls/swan/entity/status"
 )
 
@@ -131,6 +135,7 @@ func (s *Server) postLogin(c echo.Context) error {
 		sess.Values["role"] = role.Operator
 		sess.Values["login"] = et.Phone
 		sess.Values["operator_id"] = et.ID
+		sess.Values["organization_id"] = et.OrganizationID
 
 	case entity.Owner:
 		if et.PasswordHash == nil {
@@ -395,7 +400,7 @@ func (s *Server) getAdmin(c echo.Context) error {
 	return c.Redirect(http.StatusFound, "/admin/organizations")
 }
 
-const adminOrganizationsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Админка / Организации</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 460px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; margin-bottom: 20px } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/admin/classifier">Классификатор</a> </div> <div class="main-root__ri"> <b class="main-root__title">Организации</b> <div class="main-root__content"> {{range .Organizations}} <div class="main-root__content-form"> <form method="POST" action="/admin/set-organization"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="email" value="{{.Email}}" placeholder="Email" /> <input type="number" name="flats_count" value="{{.FlatsCount}}" placeholder="Кол-во жильцов" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/admin/remove-organization"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/admin/create-organization"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="name" value="" placeholder="Имя" /> <input type="text" name="email" value="" placeholder="Email" /> <input type="number" name="flats_count" value="" placeholder="Кол-во жильцов" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+const adminOrganizationsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Админка / Организации</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 460px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; margin-bottom: 20px } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/admin/requests">Обращения</a> <a class="main-root__link" href="/admin/classifier">Классификатор</a> </div> <div class="main-root__ri"> <b class="main-root__title">Организации</b> <div class="main-root__content"> {{range .Organizations}} <div class="main-root__content-form"> <form method="POST" action="/admin/set-organization"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="email" value="{{.Email}}" placeholder="Email" /> <input type="number" name="flats_count" value="{{.FlatsCount}}" placeholder="Кол-во квартир" readonly /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/admin/remove-organization"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/admin/create-organization"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="name" value="" placeholder="Имя" /> <input type="text" name="email" value="" placeholder="Email" /> <button type="submit">Добавить</button> </div> </form> {{if .DeletedOrganizations}} <p><b>Удалённые организации</b></p> {{range .DeletedOrganizations}} <form method="POST" action="/admin/restore-organization"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}"> <p>{{.Name}}, {{.Email}}, удалена {{.DeletedAt.Local.Format "2006-01-02 15:04"}}</p> <button type="submit">Восстановить</button> </div> </form> {{end}} {{end}} <form method="POST" action="/admin/import-address-registry" enctype="multipart/form-data"> <p><b>Реестр адресов</b> (адресов: {{.RegistrySize}})</p> <label for="registry">CSV-выгрузка ФИАС: guid;город;тип улицы;улица;дом</label> <div class="main-root__wrap"> <input type="file" name="registry" id="registry" /> <button type="submit">Загрузить</button> </div> </form> </div> </div> </div></body></html>`
 
 func (s *Server) getAdminOrganizations(c echo.Context) error {
 	sess, err := session.Get("session", c)
@@ -415,9 +420,25 @@ func (s *Server) getAdminOrganizations(c echo.Context) error {
 			err.Error())
 	}
 
+	dos, err := s.storage.DeletedOrganizations()
+	if err != nil {
+		return errors.New(
+			"failed to get deleted organizations from storage: " +
+				err.Error())
+	}
+
+	n, err := s.storage.AddressRegistrySize()
+	if err != nil {
+		return errors.New(
+			"failed to get address registry size from storage: " +
+				err.Error())
+	}
+
 	return c.Render(http.StatusOK, "admin_organizations", echo.Map{
-		"Login":         login,
-		"Organizations": os,
+		"Login":                login,
+		"Organizations":        os,
+		"DeletedOrganizations": dos,
+		"RegistrySize":         n,
 	})
 }
 
@@ -484,7 +505,106 @@ func (s *Server) postAdminRemoveOrganization(c echo.Context) error {
 	return c.Redirect(http.StatusFound, "/admin")
 }
 
-const adminClassifierPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Админка / Классификатор</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 660px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/admin/organizations">Организации</a> </div> <div class="main-root__ri"> <b class="main-root__title">Классификатор</b> <div class="main-root__content"> {{range .Categories}} <div class="main-root__content-form"> <form method="POST" action="/admin/classifier/set-category"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/admin/classifier/remove-category"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/admin/classifier/create-category"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <button type="submit">Добавить</button> </div> </form> <form method="POST" action="/admin/classifier/train" enctype="multipart/form-data"> <label for="samples">Данные для тренировки</label> <div class="main-root__wrap"> <input type="file" name="samples" id="samples" {{if .Training}}disabled{{end}} /> <button type="submit">Тренировать</button> </div> </form> {{if .Training}} <b class="main-root__txt--red">Классификатор в процессе тренировки</b> {{end}} </div> </div> </div></body></html>`
+func (s *Server) postAdminRestoreOrganization(c echo.Context) error {
+	var o entity.Organization
+
+	err := c.Bind(&o)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind organization: "+err.Error())
+	}
+
+	err = s.restoreOrganization(o.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/admin")
+}
+
+const adminRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Админка / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-root__txt--red { color: #ff0000; }  .main-root__table { border-collapse: collapse; margin-bottom: 20px; } .main-root__table th, .main-root__table td { border: 1px solid #E0E0E0; padding: 5px 10px; text-align: left; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/admin/organizations">Организации</a> <a class="main-root__link" href="/admin/classifier">Классификатор</a> </div> <div class="main-root__ri"> <b class="main-root__title">Обращения</b> <div class="main-root__content"> <p><b>По организациям</b></p> <table class="main-root__table"> <tr><th>Организация</th><th>Квартир</th><th>Всего</th><th>Открытые</th><th>Не назначены</th><th>Просрочены</th><th>Самое старое открытое</th><th>Без категории</th><th>Категория изменена</th><th>Среднее время решения, ч</th></tr> {{range .Stats}} <tr><td><a href="/admin/requests?organization_id={{.OrganizationID}}">{{.OrganizationName}}</a></td><td>{{.FlatsCount}}</td><td>{{.Total}}</td><td>{{.Open}}</td><td>{{.Unassigned}}</td><td>{{if .Overdue}}<a class="main-root__txt--red" href="/admin/requests?organization_id={{.OrganizationID}}&overdue=true">{{.Overdue}}</a>{{else}}0{{end}}</td><td>{{if .OldestOpenAt}}{{.OldestOpenAt.Format "2006-01-02 15:04"}}{{else}}—{{end}}</td><td>{{.Uncategorized}}</td><td>{{.Recategorized}}</td><td>{{if .AvgResolutionHours}}{{.AvgResolutionHours}}{{else}}—{{end}}</td></tr> {{end}} </table> <p><b>По категориям</b></p> <table class="main-root__table"> <tr><th>Категория</th><th>Всего</th><th>Открытые</th><th>Категория изменена</th></tr> {{range .CategoryStats}} <tr><td>{{if .CategoryName}}{{.CategoryName}}{{else}}Без категории{{end}}</td><td>{{.Total}}</td><td>{{.Open}}</td><td>{{.Recategorized}}</td></tr> {{end}} </table> <form method="GET" action="/admin/requests"> <div class="main-root__wrap"> {{$org := .Query.Get "organization_id"}} <select name="organization_id"> <option value="">Все организации</option> {{range .Organizations}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $org}}selected{{end}}>{{.Name}}</option> {{end}} </select> {{$cat := .Query.Get "category_id"}} <select name="category_id"> <option value="">Все категории</option> {{range .Categories}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $cat}}selected{{end}}>{{.Name}}</option> {{end}} </select> </div> <div class="main-root__wrap"> <input type="text" name="q" value="{{.Query.Get "q"}}" placeholder="Поиск по тексту и ответу" /> <input type="text" name="address" value="{{.Query.Get "address"}}" placeholder="Адрес" /> {{$st := .Query.Get "statuses"}} <select name="statuses"> <option value="">Все статусы</option> <option value="new,in_progress" {{if eq $st "new,in_progress"}}selected{{end}}>Открытые</option> <option value="new" {{if eq $st "new"}}selected{{end}}>new</option> <option value="in_progress" {{if eq $st "in_progress"}}selected{{end}}>in_progress</option> <option value="resolved" {{if eq $st "resolved"}}selected{{end}}>resolved</option> <option value="rejected" {{if eq $st "rejected"}}selected{{end}}>rejected</option> <option value="irrelevant" {{if eq $st "irrelevant"}}selected{{end}}>irrelevant</option> <option value="cancelled" {{if eq $st "cancelled"}}selected{{end}}>cancelled</option> </select> <label><input type="checkbox" name="overdue" value="true" {{if eq (.Query.Get "overdue") "true"}}checked{{end}} /> Просроченные</label> </div> <div class="main-root__wrap"> <label>С <input type="date" name="from" value="{{.Query.Get "from"}}" /></label> <label>По <input type="date" name="to" value="{{.Query.Get "to"}}" /></label> {{$so := .Query.Get "sort"}} <select name="sort"> <option value="newest" {{if eq $so "newest"}}selected{{end}}>Сначала новые</option> <option value="oldest" {{if eq $so "oldest"}}selected{{end}}>Сначала старые</option> <option value="priority" {{if eq $so "priority"}}selected{{end}}>По приоритету</option> </select> <button type="submit">Найти</button> </div> </form> <table class="main-root__table"> <tr><th>№</th><th>Организация</th><th>Дата и время</th><th>Адрес</th><th>Категория</th><th>Текст</th><th>Оператор</th><th>Статус</th><th>Приоритет</th><th>Возраст, ч</th><th>Срок</th></tr> {{range .Requests}} <tr><td>{{.ID}}</td><td>{{.OrganizationName}}</td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>{{.Address}}{{if .HasCommonArea}} ({{.CommonArea}}){{end}}</td><td>{{if .CategoryName}}{{.CategoryName}}{{else}}—{{end}}</td><td>{{.Text}}</td><td>{{if .OperatorName}}{{.OperatorName}}{{else}}Не назначен{{end}}</td><td>{{.Status}}</td><td>{{.Priority}}</td><td>{{.AgeHours}}</td><td>{{if .Overdue}}<b class="main-root__txt--red">{{.Deadline.Format "2006-01-02 15:04"}}</b>{{else}}{{.Deadline.Format "2006-01-02 15:04"}}{{end}}</td></tr> {{end}} </table> {{if .NextURL}} <p><a href="{{.NextURL}}">Следующая страница</a></p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) postAdminImportAddressRegistry(c echo.Context) error {
+	ff, err := c.FormFile("registry")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get address registry file: "+err.Error())
+	}
+
+	f, err := ff.Open()
+	if err != nil {
+		return errors.New("failed to open address registry: " + err.Error())
+	}
+	defer f.Close()
+
+	_, err = s.importAddressRegistry(f)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/admin/organizations")
+}
+
+func (s *Server) getAdminRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.requests(f)
+	if err != nil {
+		return err
+	}
+
+	oss, err := s.storage.OrganizationsStats()
+	if err != nil {
+		return errors.New("failed to get organizations stats from storage: " +
+			err.Error())
+	}
+
+	css, err := s.storage.CategoriesStats(f.OrganizationID)
+	if err != nil {
+		return errors.New("failed to get categories stats from storage: " +
+			err.Error())
+	}
+
+	os, err := s.storage.Organizations()
+	if err != nil {
+		return errors.New("failed to get organizations from storage: " +
+			err.Error())
+	}
+
+	cs, err := s.storage.Categories()
+	if err != nil {
+		return errors.New("failed to get categories from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "admin_requests", echo.Map{
+		"Login":         login,
+		"Requests":      rs,
+		"Stats":         oss,
+		"CategoryStats": css,
+		"Organizations": os,
+		"Categories":    cs,
+		"Query":         c.QueryParams(),
+		"NextURL":       nextPageURL(c, cursor),
+	})
+}
+
+const adminClassifierPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Админка / Классификатор</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 660px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/admin/organizations">Организации</a> <a class="main-root__link" href="/admin/requests">Обращения</a> </div> <div class="main-root__ri"> <b class="main-root__title">Классификатор</b> <div class="main-root__content"> {{range .Categories}} <div class="main-root__content-form"> <form method="POST" action="/admin/classifier/set-category"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/admin/classifier/remove-category"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/admin/classifier/create-category"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <button type="submit">Добавить</button> </div> </form> <form method="POST" action="/admin/classifier/train" enctype="multipart/form-data"> <label for="samples">Данные для тренировки</label> <div class="main-root__wrap"> <input type="file" name="samples" id="samples" {{if .Training}}disabled{{end}} /> <button type="submit">Тренировать</button> </div> </form> {{if .Training}} <b class="main-root__txt--red">Классификатор в процессе тренировки</b> {{end}} </div> </div> </div></body></html>`
 
 func (s *Server) getAdminClassifier(c echo.Context) error {
 	sess, err := session.Get("session", c)
@@ -608,10 +728,174 @@ func (s *Server) postClassifierTrain(c echo.Context) error {
 }
 
 func (s *Server) getOrganization(c echo.Context) error {
-	return c.Redirect(http.StatusFound, "/organization/owners")
+	return c.Redirect(http.StatusFound, "/organization/requests")
+}
+
+const organizationRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-root__txt--red { color: #ff0000; }  .main-root__table { border-collapse: collapse; margin-bottom: 20px; } .main-root__table th, .main-root__table td { border: 1px solid #E0E0E0; padding: 5px 10px; text-align: left; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Обращения</b> <div class="main-root__content"> <p><b>По операторам</b></p> <table class="main-root__table"> <tr><th>Оператор</th><th>Новые</th><th>В работе</th><th>Просрочены</th><th>Завершены</th><th>Завершены с просрочкой</th><th>Среднее время решения, ч</th></tr> {{range .Stats}} <tr><td>{{if .OperatorID}}<a href="/organization/requests?operator_id={{.OperatorID}}&statuses=all">{{.OperatorName}}</a>{{else}}Не назначен{{end}}</td><td>{{.New}}</td><td>{{.InProgress}}</td><td>{{if .Overdue}}<b class="main-root__txt--red">{{.Overdue}}</b>{{else}}0{{end}}</td><td>{{.Finished}}</td><td>{{.FinishedLate}}</td><td>{{if .AvgResolutionHours}}{{.AvgResolutionHours}}{{else}}—{{end}}</td></tr> {{end}} </table> <form method="GET" action="/organization/requests"> <div class="main-root__wrap"> <input type="text" name="q" value="{{.Query.Get "q"}}" placeholder="Поиск по тексту и ответу" /> <input type="text" name="address" value="{{.Query.Get "address"}}" placeholder="Адрес" /> {{$st := .Query.Get "statuses"}} <select name="statuses"> <option value="">Все статусы</option> <option value="new,in_progress" {{if eq $st "new,in_progress"}}selected{{end}}>Открытые</option> <option value="new" {{if eq $st "new"}}selected{{end}}>new</option> <option value="in_progress" {{if eq $st "in_progress"}}selected{{end}}>in_progress</option> <option value="resolved" {{if eq $st "resolved"}}selected{{end}}>resolved</option> <option value="rejected" {{if eq $st "rejected"}}selected{{end}}>rejected</option> <option value="irrelevant" {{if eq $st "irrelevant"}}selected{{end}}>irrelevant</option> <option value="cancelled" {{if eq $st "cancelled"}}selected{{end}}>cancelled</option> </select> </div> <div class="main-root__wrap"> {{$op := .Query.Get "operator_id"}} <select name="operator_id"> <option value="">Все операторы</option> {{range .Operators}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $op}}selected{{end}}>{{.Name}}</option> {{end}} </select> {{$cat := .Query.Get "category_id"}} <select name="category_id"> <option value="">Все категории</option> {{range .Categories}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $cat}}selected{{end}}>{{.Name}}</option> {{end}} </select> </div> <div class="main-root__wrap"> {{$lb := .Query.Get "label_id"}} <select name="label_id"> <option value="">Все метки</option> {{range .Labels}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $lb}}selected{{end}}>{{.Name}}</option> {{end}} </select> {{$fd := .Query.Get "field_id"}} <select name="field_id"> <option value="">Любые поля</option> {{range .CustomFields}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $fd}}selected{{end}}>{{.Name}}</option> {{end}} </select> <input type="text" name="field_value" value="{{.Query.Get "field_value"}}" placeholder="Значение поля" /> </div> <div class="main-root__wrap"> <label>С <input type="date" name="from" value="{{.Query.Get "from"}}" /></label> <label>По <input type="date" name="to" value="{{.Query.Get "to"}}" /></label> {{$so := .Query.Get "sort"}} <select name="sort"> <option value="newest" {{if eq $so "newest"}}selected{{end}}>Сначала новые</option> <option value="oldest" {{if eq $so "oldest"}}selected{{end}}>Сначала старые</option> <option value="priority" {{if eq $so "priority"}}selected{{end}}>По приоритету</option> </select> <button type="submit">Найти</button> </div> </form> <p><a href="/organization/requests/export?{{.Query.Encode}}">Выгрузить в CSV</a></p> <form method="POST" action="/organization/create-request"> <p><b>Новое обращение по местам общего пользования</b></p> <div class="main-root__wrap"> <input type="text" name="building" placeholder="Адрес дома" required /> <input type="text" name="entrance" placeholder="Подъезд" /> <input type="number" name="floor" placeholder="Этаж" /> <select name="common_area" required> <option value="stairwell">Подъезд, лестница</option> <option value="elevator">Лифт</option> <option value="basement">Подвал</option> <option value="roof">Крыша</option> <option value="yard">Двор</option> <option value="other">Другое</option> </select> <select name="priority"> <option value="normal">Обычный</option> <option value="urgent">Срочно</option> <option value="emergency">Авария</option> </select> </div> <textarea class="main-cell__text" name="text" placeholder="Текст обращения" required></textarea> <div class="main-root__wrap"> <button type="submit">Создать обращение</button> </div> </form> <form id="bulk" method="POST" action="/organization/bulk-requests"> <p><b>Действие с выбранными обращениями</b></p> <div class="main-root__wrap"> <select name="status"> <option value="">Статус не менять</option> <option value="in_progress">В работе</option> <option value="resolved">Разрешён</option> <option value="rejected">Отклонён</option> <option value="irrelevant">Не релевантен</option> </select> <select name="operator_id"> <option value="">Оператора не менять</option> {{range .Operators}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <select name="category_id"> <option value="">Категорию не менять</option> {{range .Categories}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <select name="response_template_id"> <option value="">Без шаблона ответа</option> {{range .ResponseTemplates}} <option value="{{.ID}}">{{.Title}}</option> {{end}} </select> </div> <textarea class="main-cell__text" name="response" placeholder="Ответ всем выбранным"></textarea> <div class="main-root__wrap"> <button type="submit">Применить к выбранным</button> </div> </form> <table class="main-root__table"> <tr><th></th><th>№</th><th>Дата и время</th><th>Адрес</th><th>Категория</th><th>Оператор</th><th>Статус</th><th>Приоритет</th><th>Метки</th><th>Возраст, ч</th><th>Срок</th></tr> {{range .Requests}} <tr><td><input type="checkbox" name="request_ids" value="{{.ID}}" form="bulk" /></td><td><a href="/organization/requests/{{.ID}}">{{.ID}}</a></td><td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td><td>{{.Address}}{{if .HasCommonArea}} ({{.CommonArea}}){{end}}</td><td>{{if .CategoryName}}{{.CategoryName}}{{else}}—{{end}}</td><td>{{if .OperatorName}}{{.OperatorName}}{{else}}Не назначен{{end}}</td><td>{{.Status}}</td><td>{{.Priority}}</td><td>{{range .Labels}}{{.Name}} {{end}}</td><td>{{.AgeHours}}</td><td>{{if .Overdue}}<b class="main-root__txt--red">{{.Deadline.Format "2006-01-02 15:04"}}</b>{{else}}{{.Deadline.Format "2006-01-02 15:04"}}{{end}}</td></tr> {{end}} </table> {{if .NextURL}} <p><a href="{{.NextURL}}">Следующая страница</a></p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.organizationRequests(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	oss, err := s.storage.OrganizationOperatorsStats(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators stats from storage: " +
+				err.Error())
+	}
+
+	ops, err := s.storage.OrganizationOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators from storage: " +
+				err.Error())
+	}
+
+	categories, err := s.storage.Categories()
+	if err != nil {
+		return errors.New("failed to get categories from storage: " +
+			err.Error())
+	}
+
+	rts, err := s.storage.OrganizationResponseTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization response templates from storage: " +
+				err.Error())
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_requests", echo.Map{
+		"Login":             login,
+		"Requests":          rs,
+		"Stats":             oss,
+		"Operators":         ops,
+		"Categories":        categories,
+		"Query":             c.QueryParams(),
+		"NextURL":           nextPageURL(c, cursor),
+		"ResponseTemplates": rts,
+		"Labels":            ls,
+		"CustomFields":      cfs,
+	})
+}
+
+func (s *Server) getOrganizationRequestsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	return s.exportRequests(c, organizationID, f)
+}
+
+const organizationRequestPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Обращения / Обращение</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-root__txt--red { color: #ff0000; } .main-root__note { background-color: #FFF8DC; padding: 5px; }  .main-root__table { border-collapse: collapse; margin-bottom: 20px; } .main-root__table th, .main-root__table td { border: 1px solid #E0E0E0; padding: 5px 10px; text-align: left; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Обращение</b> <div class="main-root__content"> {{with .Request}} <p><a href="/organization/requests">Все обращения</a></p> <p><b>Обращение №{{.ID}}</b>, <b>Статус: {{.Status}}</b>, Приоритет: {{.Priority}}{{if .Overdue}} <b class="main-root__txt--red">Просрочено</b>{{end}}</p> <p>Категория: {{if .CategoryName}}{{.CategoryName}}{{else}}—{{end}}, Оператор: {{if .OperatorName}}{{.OperatorName}}, Телефон: {{.OperatorPhone}}{{else}}не назначен{{end}}</p> {{if .OwnerID}} <p><b>Владелец:</b> Имя: {{.OwnerName}}, Телефон: {{.OwnerPhone}} Адрес: {{.OwnerAddress}}</p> {{end}} {{if .HasCommonArea}} <p><b>Место:</b> {{.Building}}{{if .Entrance}}, подъезд {{.Entrance}}{{end}}{{if .Floor}}, этаж {{.Floor}}{{end}}, {{.CommonArea}}{{if .SupportsCount}}, подтвердили жильцы: {{.SupportsCount}}{{end}}</p> {{end}} {{if .PrimaryRequestID}} <p>Дубликат обращения <a href="/organization/requests/{{.PrimaryRequestID}}">№{{.PrimaryRequestID}}</a></p> {{end}} <p>{{.Text}}</p> {{if .Response}} <p><b>Ответ:</b> {{.Response}}</p> {{end}} <p><b>История</b></p> <p>{{.CreatedAt.Format "2006-01-02 15:04"}} создано, срок: {{.Deadline.Format "2006-01-02 15:04"}}</p> {{if .AcknowledgedAt}} <p>{{.AcknowledgedAt.Format "2006-01-02 15:04"}} оператор подтвердил получение</p> {{end}} {{range .Events}} {{if .Edited}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} владелец изменил текст обращения, прежний текст: {{.Text}}</p> {{else if .Cancelled}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} владелец отменил обращение: {{.Text}}</p> {{else if .StatusChanged}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} установлен статус {{.Text}} ({{if eq .Role "organization"}}организация{{else}}оператор{{end}})</p> {{else if .Reassigned}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} назначен оператор {{.Text}} ({{if eq .Role "organization"}}организация{{else}}оператор{{end}})</p> {{else if .CategoryChanged}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} установлена категория {{.Text}} ({{if eq .Role "organization"}}организация{{else}}оператор{{end}})</p> {{else if .Responded}} <p>{{.CreatedAt.Format "2006-01-02 15:04"}} ответ ({{if eq .Role "organization"}}организация{{else}}оператор{{end}}): {{.Text}}</p> {{end}} {{end}} {{if .FinishedAt}} <p>{{.FinishedAt.Format "2006-01-02 15:04"}} завершено за {{.AgeHours}} ч</p> {{end}} <p><b>Метки и поля</b></p> {{$r := .}} <form method="POST" action="/organization/set-request-attributes"> <input type="hidden" name="request_id" value="{{.ID}}" /> <div class="main-root__wrap"> {{range $.Labels}} <label><input type="checkbox" name="label_ids" value="{{.ID}}" {{if $r.HasLabel .ID}}checked{{end}} /> {{.Name}}</label> {{end}} </div> {{range $.CustomFields}} {{$v := $r.FieldValue .ID}} <div class="main-root__wrap"> <input type="hidden" name="field_id" value="{{.ID}}" /> <label>{{.Name}} {{if .IsEnum}}<select name="field_value"> <option value="">—</option> {{range .Options}} <option value="{{.}}" {{if eq . $v}}selected{{end}}>{{.}}</option> {{end}} </select>{{else if .IsDate}}<input type="date" name="field_value" value="{{$v}}" />{{else if .IsNumber}}<input type="number" step="any" name="field_value" value="{{$v}}" />{{else}}<input type="text" name="field_value" value="{{$v}}" />{{end}}</label> </div> {{end}} <div class="main-root__wrap"> <button type="submit">Сохранить метки и поля</button> </div> </form> <p><b>Чек-лист{{if .Progress}}, выполнено {{.Progress}}%{{end}}</b></p> {{range .Tasks}} {{$t := .}} <form method="POST" action="/organization/set-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <label><input type="checkbox" name="done" value="true" {{if .Done}}checked{{end}} /> {{.Position}}.</label> <input type="text" name="title" value="{{.Title}}" /> <select name="operator_id"> <option value="0">Не назначена</option> {{range $.Operators}} <option value="{{.ID}}" {{if $t.AssignedTo .ID}}selected{{end}}>{{.Name}}</option> {{end}} </select> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <input type="text" name="title" placeholder="Задача" /> <select name="operator_id"> <option value="0">Не назначена</option> {{range $.Operators}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <button type="submit">Добавить задачу</button> </div> </form> <p><b>Внутренние заметки</b></p> {{range .Notes}} <p class="main-root__note"><i>Заметка, {{.CreatedAt.Format "2006-01-02 15:04"}}, {{if .AuthorName}}{{.AuthorName}}{{else}}{{.Role}}{{end}}:</i> {{.Text}}</p> {{end}} <form method="POST" action="/organization/create-request-note"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <input type="text" name="text" placeholder="Внутренняя заметка, владелец её не увидит" /> <button type="submit">Добавить заметку</button> </div> </form> {{if .WorkOrders}} <p><b>Заказ-наряды</b></p> {{range .WorkOrders}} <p>№{{.ID}}, Подрядчик: {{.ContractorName}}, Статус: {{.Status}}{{if .DueAt}}, Срок: {{.DueAt.Format "2006-01-02"}}{{end}}: {{.Scope}}{{if .Comment}} ({{.Comment}}){{end}}</p> {{end}} {{end}} <p><b>Затраты</b></p> {{range .CostItems}} <form method="POST" action="/organization/remove-cost-item"> <div class="main-root__wrap"> <p>{{if .Labor}}Работы{{else}}Материал{{end}}: {{.Name}}, {{.Quantity}} x {{.UnitPrice}} = {{.Amount}}{{if .Billable}}, к оплате владельцем{{end}}</p> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} {{if .CostItems}}{{with .CostTotal}} <p><b>Итого: {{.Total}}</b>, к оплате владельцем: {{.Billable}}</p> {{end}}{{end}} <form method="POST" action="/organization/create-cost-item"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <select name="kind" required> <option value="material">Материал</option> <option value="labor">Работы, ч</option> </select> <input type="text" name="name" placeholder="Наименование" /> <select name="operator_id"> <option value="">Исполнитель работ</option> {{range $.Operators}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <input type="number" name="quantity" step="0.001" min="0" placeholder="Количество" required /> <input type="number" name="unit_price" step="0.01" min="0" placeholder="Цена" required /> <label><input type="checkbox" name="billable" value="true" /> К оплате владельцем</label> <button type="submit">Добавить затраты</button> </div> </form> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	requestID, err := strconv.Atoi(c.Param("request_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request_id: "+err.Error())
+	}
+
+	r, err := s.organizationRequest(organizationID, requestID)
+	if err != nil {
+		return err
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	ops, err := s.storage.OrganizationOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_request", echo.Map{
+		"Login":        login,
+		"Request":      r,
+		"Labels":       ls,
+		"CustomFields": cfs,
+		"Operators":    ops,
+	})
 }
 
-const organizationOwnersPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Жильцы </title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 300px; } .main-root__wrap input[name="address"] { width: 550px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/operators">Операторы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Жильцы</b> <div class="main-root__content"> {{range .Owners}} <div class="main-root__content-form"> <form method="POST" action="/organization/set-owner"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="address" value="{{.Address}}" placeholder="Адрес" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-owner"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/organization/create-owner"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="address" value="{{.Address}}" placeholder="Адрес" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+const organizationOwnersPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Жильцы </title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 300px; } .main-root__wrap input[name="address"] { width: 550px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Жильцы</b> <div class="main-root__content"> {{range .Owners}} <div class="main-root__content-form"> <form method="POST" action="/organization/set-owner"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="email" value="{{.Email}}" placeholder="Email" /> <input type="number" step="0.0001" min="0" max="1" name="share" value="{{if .Share}}{{.Share}}{{end}}" placeholder="Доля" /> {{$o := .}} <select name="flat_id"> <option value="0">Без квартиры</option> {{range $.Flats}} <option value="{{.ID}}" {{if $o.LivesIn .ID}}selected{{end}}>{{.Address}}</option> {{end}} </select> <select name="household_role"> <option value="owner" {{if .HasHouseholdRole "owner"}}selected{{end}}>Владелец</option> <option value="co_owner" {{if .HasHouseholdRole "co_owner"}}selected{{end}}>Совладелец</option> <option value="tenant" {{if .HasHouseholdRole "tenant"}}selected{{end}}>Арендатор</option> <option value="family_member" {{if .HasHouseholdRole "family_member"}}selected{{end}}>Член семьи</option> </select> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-owner"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/organization/create-owner"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="email" placeholder="Email" /> <input type="number" step="0.0001" min="0" max="1" name="share" placeholder="Доля" /> <select name="flat_id"> <option value="0">Без квартиры</option> {{range .Flats}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> <select name="household_role"> <option value="owner">Владелец</option> <option value="co_owner">Совладелец</option> <option value="tenant">Арендатор</option> <option value="family_member">Член семьи</option> </select> <button type="submit">Добавить</button> </div> </form> {{if .DeletedOwners}} <p><b>Удалённые жильцы</b></p> {{range .DeletedOwners}} <form method="POST" action="/organization/restore-owner"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}"> <p>{{.Name}}, {{.Phone}}{{if .Address}}, {{.Address}}{{end}}, удалён {{.DeletedAt.Local.Format "2006-01-02 15:04"}}</p> <button type="submit">Восстановить</button> </div> </form> {{end}} {{end}} </div> </div> </div></body></html>`
 
 func (s *Server) getOrganizationOwners(c echo.Context) error {
 	sess, err := session.Get("session", c)
@@ -636,9 +920,24 @@ func (s *Server) getOrganizationOwners(c echo.Context) error {
 			err.Error())
 	}
 
+	dos, err := s.storage.OrganizationDeletedOwners(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization deleted owners from storage: " +
+				err.Error())
+	}
+
+	fs, err := s.storage.OrganizationFlats(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization flats from storage: " +
+			err.Error())
+	}
+
 	return c.Render(http.StatusOK, "organization_owners", echo.Map{
-		"Login":  login,
-		"Owners": os,
+		"Login":         login,
+		"Owners":        os,
+		"DeletedOwners": dos,
+		"Flats":         fs,
 	})
 }
 
@@ -654,25 +953,21 @@ func (s *Server) postOrganizationCreateOwner(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	var o entity.Owner
-
-	err = c.Bind(&o)
+	o, err := bindOwner(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to bind owner: "+err.Error())
+		return err
 	}
 
 	o.OrganizationID = organizationID
 
-	err = o.Validate()
+	o, err = s.validateOwnerFlat(organizationID, o)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to validate owner: "+err.Error())
+		return err
 	}
 
-	_, err = s.storage.AddOwner(o)
+	_, err = s.addOwner(o)
 	if err != nil {
-		return errors.New("failed to add owner: " + err.Error())
+		return err
 	}
 
 	return c.Redirect(http.StatusFound, "/organization/owners")
@@ -690,25 +985,21 @@ func (s *Server) postOrganizationSetOwner(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	var o entity.Owner
-
-	err = c.Bind(&o)
+	o, err := bindOwner(c)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to bind owner: "+err.Error())
+		return err
 	}
 
 	o.OrganizationID = organizationID
 
-	err = o.Validate()
+	o, err = s.validateOwnerFlat(organizationID, o)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest,
-			"failed to validate owner: "+err.Error())
+		return err
 	}
 
-	_, err = s.storage.SetOwner(o)
+	_, err = s.setOwner(o)
 	if err != nil {
-		return errors.New("failed to add owner: " + err.Error())
+		return err
 	}
 
 	return c.Redirect(http.StatusFound, "/organization/owners")
@@ -743,7 +1034,35 @@ func (s *Server) postOrganizationRemoveOwner(c echo.Context) error {
 	return c.Redirect(http.StatusFound, "/organization/owners")
 }
 
-const organizationOperatorsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Операторы</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 300px; } .main-root__wrap input[name="address"] { width: 550px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Операторы</b> <div class="main-root__content"> {{range .Operators}} <div class="main-root__content-form"> <form method="POST" action="/organization/set-operator"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="responsible_categories" value="{{.ResponsibleCategoriesStr}}" placeholder="Зона ответственности" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-operator"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/organization/create-operator"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="responsible_categories" value="{{.ResponsibleCategoriesStr}}" placeholder="Зона ответственности" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+func (s *Server) postOrganizationRestoreOwner(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var o entity.Owner
+
+	err = c.Bind(&o)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind owner: "+err.Error())
+	}
+
+	err = s.restoreOwner(organizationID, o.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/owners")
+}
+
+const organizationOperatorsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Операторы</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap input[name="name"] { width: 300px; } .main-root__wrap input[name="address"] { width: 550px; } .main-root__wrap input[name="id"], .main-root__wrap input[name="flats_count"] { width: 115px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-root__delete button { padding: 10px; min-height: 41px; margin-left: 5px; } .main-root__delete button:hover { background-color: #ff0000; color: #fff; } .main-root__null { width: 125px; } .main-root__content-form { display: flex; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Операторы</b> <div class="main-root__content"> {{range .Operators}} <div class="main-root__content-form"> <form method="POST" action="/organization/set-operator"> <div class="main-root__wrap"> <input type="number" name="id" value="{{.ID}}" readonly /> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="responsible_categories" value="{{.ResponsibleCategoriesStr}}" placeholder="Зона ответственности" /> <label><input type="checkbox" name="on_duty" value="true" {{if .OnDuty}}checked{{end}} /> Дежурный</label> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-operator"> <div class="main-root__wrap main-root__delete"> <input type="hidden" name="id" value="{{.ID}}"> <button type="submit">Удалить</button> </div> </form> </div> {{end}} <form method="POST" action="/organization/create-operator"> <div class="main-root__wrap"> <div class="main-root__null"></div> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="name" value="{{.Name}}" placeholder="Имя" /> <input type="text" name="responsible_categories" value="{{.ResponsibleCategoriesStr}}" placeholder="Зона ответственности" /> <label><input type="checkbox" name="on_duty" value="true" /> Дежурный</label> <button type="submit">Добавить</button> </div> </form> {{if .DeletedOperators}} <p><b>Удалённые операторы</b></p> {{range .DeletedOperators}} <form method="POST" action="/organization/restore-operator"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}"> <p>{{.Name}}, {{.Phone}}, удалён {{.DeletedAt.Local.Format "2006-01-02 15:04"}}</p> <button type="submit">Восстановить</button> </div> </form> {{end}} {{end}} </div> </div> </div></body></html>`
 
 func (s *Server) getOrganizationOperators(c echo.Context) error {
 	sess, err := session.Get("session", c)
@@ -776,9 +1095,17 @@ func (s *Server) getOrganizationOperators(c echo.Context) error {
 		os[i].ResponsibleCategoriesStr = strings.Join(idStrs, ", ")
 	}
 
+	dos, err := s.storage.OrganizationDeletedOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization deleted operators from storage: " +
+				err.Error())
+	}
+
 	return c.Render(http.StatusOK, "organization_operators", echo.Map{
-		"Login":     login,
-		"Operators": os,
+		"Login":            login,
+		"Operators":        os,
+		"DeletedOperators": dos,
 	})
 }
 
@@ -901,143 +1228,248 @@ func (s *Server) postOrganizationRemoveOperator(c echo.Context) error {
 	return c.Redirect(http.StatusFound, "/organization/operators")
 }
 
-func (s *Server) getOperator(c echo.Context) error {
-	return c.Redirect(http.StatusFound, "/operator/requests")
-}
-
-const operatorRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Оператор / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> </div> <div class="main-root__ri"> <b class="main-root__title">Обращения</b> <div class="main-root__content"> {{range .Requests}} <p><b>{{.ID}}</b>, <b>Статус: {{.Status}}</b>, Дата и время: {{.CreatedAt.Format "2006-01-02 15:04"}}</p> <p><b>Владелец:</b> Имя: {{.OwnerName}}, Телефон: {{.OwnerPhone}} Адрес: {{.OwnerAddress}}</p> <p>{{.Text}}</p> {{if .HasNewStatus}} <form method="POST" action="/operator/set-request-in-progress"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Начать обработку</button> </div> </form> {{else if .HasInProgressStatus}} <form method="POST" action="/operator/set-request-final"> <input type="hidden" name="id" value="{{.ID}}" /> <select class="main-cell__select" name="status" required> <option value="resolved">Разрешён</option> <option value="rejected">Отклонён</option> <option value="irrelevant">Не релевантен</option> </select> <textarea class="main-cell__text" name="response" placeholder="Комментарий"></textarea> <div class="main-root__wrap"> <button type="submit">Завершить обработку</button> </div> </form> {{else if .Response}} <p>{{.Response}}</p> {{end}} {{end}} </div> </div> </div></body></html>`
-
-func (s *Server) getOperatorRequests(c echo.Context) error {
+func (s *Server) postOrganizationRestoreOperator(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	login, ok := sess.Values["login"].(string)
-	if !ok {
-		return errors.New("failed to get login from session")
-	}
-
-	operatorID, ok := sess.Values["operator_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get operator ID from session")
+		return errors.New("failed to get organization ID from session")
 	}
 
-	var statuses []string
-
-	for _, s := range strings.Split(c.QueryParam("statuses"), ",") {
-		statuses = append(statuses, s)
-	}
+	var o entity.Operator
 
-	if len(statuses) == 0 {
-		statuses = []string{status.New, status.InProgress}
+	err = c.Bind(&o)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind operator: "+err.Error())
 	}
 
-	rs, err := s.storage.OperatorRequests(operatorID)
+	err = s.restoreOperator(organizationID, o.ID)
 	if err != nil {
-		return errors.New("failed to get operator requests from storage: " + err.Error())
+		return err
 	}
 
-	return c.Render(http.StatusOK, "operator_requests", echo.Map{
-		"Login":    login,
-		"Requests": rs,
-	})
+	return c.Redirect(http.StatusFound, "/organization/operators")
 }
 
-func (s *Server) postSetRequestInProgress(c echo.Context) error {
+const organizationBuildingsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Дома</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Дома</b> <div class="main-root__content"> {{range .Buildings}} <form method="POST" action="/organization/set-building"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <input type="text" name="address" value="{{.Address}}" placeholder="Адрес" /> <input type="number" name="entrances" value="{{.Entrances}}" placeholder="Подъездов" /> <input type="number" name="floors" value="{{.Floors}}" placeholder="Этажей" /> <span>Квартир: {{.FlatsCount}}</span> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-building"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-building"> <div class="main-root__wrap"> <input type="text" name="address" placeholder="Адрес" /> <input type="number" name="entrances" value="1" placeholder="Подъездов" /> <input type="number" name="floors" value="1" placeholder="Этажей" /> <button type="submit">Добавить</button> </div> </form> <p><b>Квартиры</b></p> {{range .Flats}} {{$f := .}} <form method="POST" action="/organization/set-flat"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <select name="building_id"> {{range $.Buildings}} <option value="{{.ID}}" {{if eq .ID $f.BuildingID}}selected{{end}}>{{.Address}}</option> {{end}} </select> <input type="text" name="number" value="{{.Number}}" placeholder="Номер" /> <input type="number" name="entrance" value="{{if .Entrance}}{{.Entrance}}{{end}}" placeholder="Подъезд" /> <input type="number" name="floor" value="{{if .Floor}}{{.Floor}}{{end}}" placeholder="Этаж" /> <input type="number" step="0.01" name="area" value="{{if .Area}}{{.Area}}{{end}}" placeholder="Площадь, м²" /> <input type="text" name="account" value="{{.Account}}" placeholder="Лицевой счёт" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-flat"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-flat"> <div class="main-root__wrap"> <select name="building_id"> {{range .Buildings}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> <input type="text" name="number" placeholder="Номер" /> <input type="number" name="entrance" placeholder="Подъезд" /> <input type="number" name="floor" placeholder="Этаж" /> <input type="number" step="0.01" name="area" placeholder="Площадь, м²" /> <input type="text" name="account" placeholder="Лицевой счёт" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationBuildings(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	operatorID, ok := sess.Values["operator_id"].(int)
+	login, ok := sess.Values["login"].(string)
 	if !ok {
-		return errors.New("failed to get operator ID from session")
+		return errors.New("failed to get login from session")
 	}
 
-	var newReq entity.Request
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
 
-	err = c.Bind(&newReq)
+	bs, err := s.storage.OrganizationBuildings(organizationID)
 	if err != nil {
-		return errors.New("failed bind request: " + err.Error())
+		return errors.New(
+			"failed to get organization buildings from storage: " +
+				err.Error())
 	}
 
-	req, err := s.storage.OperatorRequest(operatorID, newReq.ID)
+	fs, err := s.storage.OrganizationFlats(organizationID)
 	if err != nil {
-		return errors.New("failed to get operator request from storage: " +
+		return errors.New("failed to get organization flats from storage: " +
 			err.Error())
 	}
 
-	if req.Status != status.New {
+	return c.Render(http.StatusOK, "organization_buildings", echo.Map{
+		"Login":     login,
+		"Buildings": bs,
+		"Flats":     fs,
+	})
+}
+
+func (s *Server) postOrganizationCreateBuilding(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"request status is not in new state")
+			"failed to get session")
 	}
 
-	req.Status = status.InProgress
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
 
-	_, err = s.storage.SetOperatorRequest(operatorID, req)
+	b, err := s.bindBuilding(c)
 	if err != nil {
-		return errors.New("failed to set operator request: " + err.Error())
+		return err
 	}
 
-	return c.Redirect(http.StatusFound, "/operator/requests")
+	b.OrganizationID = organizationID
+
+	_, err = s.storage.AddBuilding(b)
+	if err != nil {
+		return errors.New("failed to add building to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/buildings")
 }
 
-func (s *Server) postSetRequestFinal(c echo.Context) error {
+func (s *Server) postOrganizationSetBuilding(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	operatorID, ok := sess.Values["operator_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get operator ID from session")
+		return errors.New("failed to get organization ID from session")
 	}
 
-	var newReq entity.Request
+	b, err := s.bindBuilding(c)
+	if err != nil {
+		return err
+	}
 
-	err = c.Bind(&newReq)
+	b.OrganizationID = organizationID
+
+	_, err = s.storage.SetBuilding(b)
 	if err != nil {
-		return errors.New("failed bind request: " + err.Error())
+		return errors.New("failed to set building in storage: " +
+			err.Error())
 	}
 
-	if !status.Final(newReq.Status) {
+	return c.Redirect(http.StatusFound, "/organization/buildings")
+}
+
+func (s *Server) postOrganizationRemoveBuilding(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"status set is not final")
+			"failed to get session")
 	}
 
-	req, err := s.storage.OperatorRequest(operatorID, newReq.ID)
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var b entity.Building
+
+	err = c.Bind(&b)
 	if err != nil {
-		return errors.New("failed to get operator request from storage: " +
-			err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind building: "+err.Error())
 	}
 
-	if status.Final(req.Status) {
+	err = s.removeBuilding(organizationID, b.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/buildings")
+}
+
+func (s *Server) postOrganizationCreateFlat(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
-			"request status is already in final state")
+			"failed to get session")
 	}
 
-	req.Status = newReq.Status
-	req.Response = newReq.Response
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
 
-	_, err = s.storage.SetOperatorRequest(operatorID, req)
+	f, err := bindFlat(c)
 	if err != nil {
-		return errors.New("failed to set operator request: " + err.Error())
+		return err
 	}
 
-	return c.Redirect(http.StatusFound, "/operator/requests")
+	err = s.validateFlatBuilding(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.addFlat(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/buildings")
 }
 
-func (s *Server) getOwner(c echo.Context) error {
-	return c.Redirect(http.StatusFound, "/owner/requests")
+func (s *Server) postOrganizationSetFlat(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := bindFlat(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateFlatBuilding(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.setFlat(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/buildings")
 }
 
-const ownerRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> </div> <div class="main-root__ri"> <b class="main-root__title">Владелец Обращения</b> <div class="main-root__content"> <form method="post" action="/owner/create-request"> <textarea name="text" placeholder="Текст обращения" class="main-cell__text"></textarea> <div class="main-root__wrap"> <button type="submit">Отправить</button> </div> </form> {{range .Requests}} <p><b>{{.ID}}</b> , <b>Статус: {{.Status}}</b>, Дата и время: {{.CreatedAt.Format "2006-01-02 15:04"}}</p> {{if .CategoryName}} <p>Категория: {{.CategoryName}}</p> {{end}} <p>{{.Text}}</p> {{if .Response}} <p>{{.Response}}</p> {{end}} {{end}} </div> </div> </div></body></html>`
+func (s *Server) postOrganizationRemoveFlat(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
 
-func (s *Server) getOwnerRequests(c echo.Context) error {
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var f entity.Flat
+
+	err = c.Bind(&f)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind flat: "+err.Error())
+	}
+
+	err = s.removeFlat(organizationID, f.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/buildings")
+}
+
+const organizationMetersPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Счётчики</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Счётчики</b> <div class="main-root__content"> <p>Показания принимаются с {{.FromDay}} по {{.ToDay}} число месяца.</p> <form method="GET" action="/organization/meters/export"> <div class="main-root__wrap"> <input type="month" name="month" value="{{.Month}}" /> <button type="submit">Выгрузить показания в CSV</button> </div> </form> {{range .Meters}} {{$m := .}} <form method="POST" action="/organization/set-meter"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <select name="flat_id"> {{range $.Flats}} <option value="{{.ID}}" {{if eq .ID $m.FlatID}}selected{{end}}>{{.Address}}</option> {{end}} </select> <select name="type"> <option value="cold_water" {{if eq .Type "cold_water"}}selected{{end}}>Холодная вода</option> <option value="hot_water" {{if eq .Type "hot_water"}}selected{{end}}>Горячая вода</option> <option value="heating" {{if eq .Type "heating"}}selected{{end}}>Отопление</option> <option value="electricity" {{if eq .Type "electricity"}}selected{{end}}>Электроэнергия</option> <option value="gas" {{if eq .Type "gas"}}selected{{end}}>Газ</option> </select> <input type="text" name="serial_number" value="{{.SerialNumber}}" placeholder="Заводской номер" /> <input type="date" name="verification_date" value="{{if .VerificationDate}}{{.VerificationDate.Format "2006-01-02"}}{{end}}" placeholder="Дата поверки" /> <span>{{if .LastValue}}Последние показания: {{.LastValue}} ({{.LastPeriod.Format "2006-01"}}){{else}}Показаний нет{{end}}</span> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-meter"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-meter"> <div class="main-root__wrap"> <select name="flat_id"> {{range .Flats}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> <select name="type"> <option value="cold_water">Холодная вода</option> <option value="hot_water">Горячая вода</option> <option value="heating">Отопление</option> <option value="electricity">Электроэнергия</option> <option value="gas">Газ</option> </select> <input type="text" name="serial_number" placeholder="Заводской номер" /> <input type="date" name="verification_date" placeholder="Дата поверки" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationMeters(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
@@ -1049,33 +1481,68 @@ func (s *Server) getOwnerRequests(c echo.Context) error {
 		return errors.New("failed to get login from session")
 	}
 
-	ownerID, ok := sess.Values["owner_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get owner ID from session")
+		return errors.New("failed to get organization ID from session")
 	}
 
-	rs, err := s.storage.OwnerRequests(ownerID)
+	ms, err := s.storage.OrganizationMeters(organizationID)
 	if err != nil {
-		return errors.New("failed to get owner requests from storage: " +
+		return errors.New("failed to get organization meters from storage: " +
 			err.Error())
 	}
 
-	return c.Render(http.StatusOK, "owner_requests", echo.Map{
-		"Login":    login,
-		"Requests": rs,
+	fs, err := s.storage.OrganizationFlats(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization flats from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_meters", echo.Map{
+		"Login":   login,
+		"Meters":  ms,
+		"Flats":   fs,
+		"Month":   time.Now().Format("2006-01"),
+		"FromDay": entity.MeterReadingsFromDay,
+		"ToDay":   entity.MeterReadingsToDay,
 	})
 }
 
-func (s *Server) postOwnerCreateRequest(c echo.Context) error {
+func (s *Server) postOrganizationCreateMeter(c echo.Context) error {
 	sess, err := session.Get("session", c)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest,
 			"failed to get session")
 	}
 
-	ownerID, ok := sess.Values["owner_id"].(int)
+	organizationID, ok := sess.Values["organization_id"].(int)
 	if !ok {
-		return errors.New("failed to get owner ID from session")
+		return errors.New("failed to get organization ID from session")
+	}
+
+	m, err := bindMeter(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateMeterFlat(organizationID, m)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.storage.AddMeter(m)
+	if err != nil {
+		return errors.New("failed to add meter to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/meters")
+}
+
+func (s *Server) postOrganizationSetMeter(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
 	}
 
 	organizationID, ok := sess.Values["organization_id"].(int)
@@ -1083,36 +1550,3205 @@ func (s *Server) postOwnerCreateRequest(c echo.Context) error {
 		return errors.New("failed to get organization ID from session")
 	}
 
-	text := c.FormValue("text")
+	m, err := bindMeter(c)
+	if err != nil {
+		return err
+	}
 
-	r := entity.Request{
-		OrganizationID: organizationID,
-		OwnerID:        ownerID,
-		Text:           text,
-		Status:         status.New,
-		CreatedAt:      time.Now(),
+	err = s.validateMeterFlat(organizationID, m)
+	if err != nil {
+		return err
 	}
 
-	categoryID, err := s.classifier.Classify(text)
+	_, err = s.storage.SetMeter(organizationID, m)
 	if err != nil {
-		s.log.WithError(err).Error("failed to classify request text")
-	} else {
-		r.CategoryID = &categoryID
+		return errors.New("failed to set meter in storage: " + err.Error())
+	}
 
-		o, err := s.storage.FindOrganizationOperator(
-			organizationID, categoryID)
-		if err != nil {
-			s.log.WithError(err).Error(
-				"failed to get request operator ID")
-		} else {
-			r.OperatorID = &o.ID
-		}
+	return c.Redirect(http.StatusFound, "/organization/meters")
+}
+
+func (s *Server) postOrganizationRemoveMeter(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
 	}
 
-	_, err = s.storage.AddRequest(r)
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var m entity.Meter
+
+	err = c.Bind(&m)
 	if err != nil {
-		return errors.New("failed to add request to storage: " + err.Error())
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind meter: "+err.Error())
 	}
 
-	return c.Redirect(http.StatusFound, "/owner/requests")
+	err = s.storage.RemoveOrganizationMeter(organizationID, m.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization meter from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/meters")
+}
+
+func (s *Server) getOrganizationMeterReadingsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, rs, err := s.organizationMeterReadings(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return exportMeterReadings(c, from, rs)
+}
+
+const organizationAnnouncementsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Объявления</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } .main-cell__check { display: block; margin-bottom: 10px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Объявления</b> <div class="main-root__content"> <form method="POST" action="/organization/create-announcement"> <input class="main-cell__input" type="text" name="title" placeholder="Заголовок" required /> <textarea class="main-cell__text" name="text" placeholder="Текст объявления" required></textarea> <div class="main-root__wrap"> <select name="building_id"> <option value="0">Все дома</option> {{range .Buildings}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> <input type="number" name="entrance" placeholder="Подъезд" /> </div> <div class="main-root__wrap"> <label>Опубликовать <input type="datetime-local" name="publish_at" /></label> <label>Снять <input type="datetime-local" name="expires_at" /></label> </div> <label class="main-cell__check"><input type="checkbox" name="push_sms" value="true" /> Отправить SMS</label> <label class="main-cell__check"><input type="checkbox" name="push_email" value="true" /> Отправить email</label> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> {{range .Announcements}} {{$a := .}} <p><b>{{.Title}}</b>, {{if .BuildingAddress}}{{.BuildingAddress}}{{if .Entrance}}, подъезд {{.Entrance}}{{end}}{{else}}все дома{{end}}{{if .Published $.Now}}, опубликовано{{else if $.Now.Before .PublishAt}}, запланировано{{else}}, снято{{end}}{{if .PushedAt}}, разослано {{.PushedAt.Local.Format "2006-01-02 15:04"}}{{end}}, прочитали: {{.ReadsCount}}</p> <form method="POST" action="/organization/set-announcement"> <input type="hidden" name="id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="title" value="{{.Title}}" placeholder="Заголовок" /> <textarea class="main-cell__text" name="text" placeholder="Текст объявления">{{.Text}}</textarea> <div class="main-root__wrap"> <select name="building_id"> <option value="0">Все дома</option> {{range $.Buildings}} <option value="{{.ID}}" {{if $a.TargetedAt .ID}}selected{{end}}>{{.Address}}</option> {{end}} </select> <input type="number" name="entrance" value="{{if .Entrance}}{{.Entrance}}{{end}}" placeholder="Подъезд" /> </div> <div class="main-root__wrap"> <label>Опубликовать <input type="datetime-local" name="publish_at" value="{{.PublishAtLocal}}" /></label> <label>Снять <input type="datetime-local" name="expires_at" value="{{.ExpiresAtLocal}}" /></label> </div> <label class="main-cell__check"><input type="checkbox" name="push_sms" value="true" {{if .PushSMS}}checked{{end}} /> Отправить SMS</label> <label class="main-cell__check"><input type="checkbox" name="push_email" value="true" {{if .PushEmail}}checked{{end}} /> Отправить email</label> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-announcement"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить</button> </div> </form> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationAnnouncements(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	as, err := s.storage.OrganizationAnnouncements(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization announcements from storage: " +
+				err.Error())
+	}
+
+	bs, err := s.storage.OrganizationBuildings(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization buildings from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_announcements", echo.Map{
+		"Login":         login,
+		"Announcements": as,
+		"Buildings":     bs,
+		"Now":           time.Now(),
+	})
+}
+
+func (s *Server) postOrganizationCreateAnnouncement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	a, err := bindAnnouncement(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateAnnouncementBuilding(organizationID, a)
+	if err != nil {
+		return err
+	}
+
+	a.OrganizationID = organizationID
+
+	_, err = s.storage.AddAnnouncement(a)
+	if err != nil {
+		return errors.New("failed to add announcement to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/announcements")
+}
+
+func (s *Server) postOrganizationSetAnnouncement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	a, err := bindAnnouncement(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validateAnnouncementBuilding(organizationID, a)
+	if err != nil {
+		return err
+	}
+
+	a.OrganizationID = organizationID
+
+	_, err = s.storage.SetAnnouncement(a)
+	if err != nil {
+		return errors.New("failed to set announcement in storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/announcements")
+}
+
+func (s *Server) postOrganizationRemoveAnnouncement(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var a entity.Announcement
+
+	err = c.Bind(&a)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind announcement: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationAnnouncement(organizationID, a.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization announcement from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/announcements")
+}
+
+const organizationPollsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Собрания</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } .main-cell__check { display: block; margin-bottom: 10px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Собрания</b> <div class="main-root__content"> <form method="POST" action="/organization/create-poll"> <input class="main-cell__input" type="text" name="title" placeholder="Повестка" required /> <textarea class="main-cell__text" name="description" placeholder="Описание"></textarea> <div class="main-root__wrap"> <select name="building_id" required> {{range .Buildings}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> <select name="weighting"> <option value="area">Голоса по площади</option> <option value="flat">Голос от квартиры</option> </select> <input type="number" step="0.01" min="0.01" max="100" name="quorum_percent" value="50" placeholder="Кворум, %" required /> </div> <div class="main-root__wrap"> <label>Начало <input type="datetime-local" name="starts_at" required /></label> <label>Окончание <input type="datetime-local" name="ends_at" required /></label> </div> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> {{range .Polls}} {{$p := .}} <p><b>{{.Title}}</b>, {{.BuildingAddress}}, {{.StartsAt.Local.Format "2006-01-02 15:04"}} - {{.EndsAt.Local.Format "2006-01-02 15:04"}}{{if .Finished $.Now}}, завершено{{else if .Started $.Now}}, идёт голосование{{else}}, запланировано{{end}}</p> {{if .Description}} <p>{{.Description}}</p> {{end}} <p>Приняли участие: {{.VotersCount}} собственников, {{printf "%.2f" .VotedWeight}} из {{printf "%.2f" .TotalWeight}} {{if eq .Weighting "area"}}кв. м{{else}}голосов{{end}} ({{printf "%.2f" .VotedPercent}}%), кворум {{printf "%.2f" .QuorumPercent}}% {{if .QuorumReached}}имеется{{else}}отсутствует{{end}}</p> {{range $q := .Questions}} <p><b>Вопрос {{$q.Number}}. {{$q.Text}}</b>{{if $q.Multiple}} (несколько вариантов){{end}}</p> {{range $q.Results}} <p>{{.Option}}: {{.Votes}} собственников, {{printf "%.2f" .Weight}} ({{printf "%.2f" .Percent}}%)</p> {{end}} {{if not ($p.Started $.Now)}} <form method="POST" action="/organization/remove-poll-question"> <input type="hidden" name="poll_id" value="{{$p.ID}}" /> <input type="hidden" name="id" value="{{$q.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить вопрос</button> </div> </form> {{end}} {{end}} {{if not (.Started $.Now)}} <form method="POST" action="/organization/create-poll-question"> <input type="hidden" name="poll_id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="text" placeholder="Вопрос" required /> <textarea class="main-cell__text" name="options" placeholder="Варианты ответа, по одному в строке" required>За
+Против
+Воздержался</textarea> <label class="main-cell__check"><input type="checkbox" name="multiple" value="true" /> Несколько вариантов</label> <div class="main-root__wrap"> <button type="submit">Добавить вопрос</button> </div> </form> {{end}} {{if .Finished $.Now}} <p><a href="/organization/polls/{{.ID}}/protocol">Протокол</a></p> {{end}} <form method="POST" action="/organization/remove-poll"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить</button> </div> </form> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationPolls(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ps, err := s.storage.OrganizationPolls(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization polls from storage: " + err.Error())
+	}
+
+	var prs []entity.PollResults
+
+	for _, p := range ps {
+		pr, err := s.pollResults(p)
+		if err != nil {
+			return err
+		}
+		prs = append(prs, pr)
+	}
+
+	bs, err := s.storage.OrganizationBuildings(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization buildings from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_polls", echo.Map{
+		"Login":     login,
+		"Polls":     prs,
+		"Buildings": bs,
+		"Now":       time.Now(),
+	})
+}
+
+func (s *Server) getOrganizationPollProtocol(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	pollID, err := strconv.Atoi(c.Param("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	pr, err := s.organizationPollResults(organizationID, pollID)
+	if err != nil {
+		return err
+	}
+
+	protocol, err := s.pollProtocol(pr)
+	if err != nil {
+		return err
+	}
+
+	return exportPollProtocol(c, pollID, protocol)
+}
+
+func (s *Server) postOrganizationCreatePoll(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	p, err := bindPoll(c)
+	if err != nil {
+		return err
+	}
+
+	err = s.validatePollBuilding(organizationID, p)
+	if err != nil {
+		return err
+	}
+
+	p.OrganizationID = organizationID
+
+	_, err = s.storage.AddPoll(p)
+	if err != nil {
+		return errors.New("failed to add poll to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/polls")
+}
+
+func (s *Server) postOrganizationRemovePoll(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var p entity.Poll
+
+	err = c.Bind(&p)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationPoll(organizationID, p.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization poll from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/polls")
+}
+
+func (s *Server) postOrganizationCreatePollQuestion(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	q, err := bindPollQuestion(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.addPollQuestion(organizationID, q)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/polls")
+}
+
+func (s *Server) postOrganizationRemovePollQuestion(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var q entity.PollQuestion
+
+	err = c.Bind(&q)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll question: "+err.Error())
+	}
+
+	err = s.removePollQuestion(organizationID, q.PollID, q.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/polls")
+}
+
+const organizationLedgerPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Начисления</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } .main-cell__check { display: block; margin-bottom: 10px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Начисления</b> <div class="main-root__content"> <form method="POST" action="/organization/import-charges" enctype="multipart/form-data"> <p><b>Начисления</b></p> <label for="charges">CSV: лицевой счёт;месяц (2006-01);услуга;сумма</label> <div class="main-root__wrap"> <input type="file" name="charges" id="charges" accept=".csv" required /> <button type="submit">Загрузить</button> </div> </form> <form method="POST" action="/organization/import-payments" enctype="multipart/form-data"> <p><b>Оплаты</b></p> <label for="payments">CSV: лицевой счёт;сумма;дата оплаты (2006-01-02);номер платежа</label> <div class="main-root__wrap"> <input type="file" name="payments" id="payments" accept=".csv" required /> <button type="submit">Загрузить</button> </div> </form> <p><b>Должники</b></p> <form method="GET" action="/organization/ledger"> <div class="main-root__wrap"> <input type="number" step="0.01" min="0" name="min_debt" value="{{.MinDebt}}" placeholder="Долг от, ₽" /> <button type="submit">Показать</button> </div> </form> <p><a href="/organization/ledger/debtors-export?min_debt={{.MinDebt}}">Выгрузить в CSV</a></p> {{range .Debtors}} <p>{{.FlatAddress}}{{if .Account}}, л/с {{.Account}}{{end}}: <span class="main-root__txt--red">долг {{.Debt}} ₽</span>, начислено {{.Charged}} ₽, оплачено {{.Paid}} ₽{{if .LastPaidAt}}, последняя оплата {{.LastPaidAt.Local.Format "2006-01-02"}}{{else}}, оплат не было{{end}}</p> {{else}} <p>Должников нет.</p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationLedger(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	minDebt := c.QueryParam("min_debt")
+
+	ds, err := s.organizationDebtors(organizationID, minDebt)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "organization_ledger", echo.Map{
+		"Login":   login,
+		"Debtors": ds,
+		"MinDebt": minDebt,
+	})
+}
+
+func (s *Server) getOrganizationDebtorsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ds, err := s.organizationDebtors(organizationID,
+		c.QueryParam("min_debt"))
+	if err != nil {
+		return err
+	}
+
+	return exportDebtors(c, ds)
+}
+
+func (s *Server) postOrganizationImportCharges(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ff, err := c.FormFile("charges")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get charges file: "+err.Error())
+	}
+
+	f, err := ff.Open()
+	if err != nil {
+		return errors.New("failed to open charges: " + err.Error())
+	}
+	defer f.Close()
+
+	_, err = s.importCharges(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/ledger")
+}
+
+func (s *Server) postOrganizationImportPayments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ff, err := c.FormFile("payments")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get payments file: "+err.Error())
+	}
+
+	f, err := ff.Open()
+	if err != nil {
+		return errors.New("failed to open payments: " + err.Error())
+	}
+	defer f.Close()
+
+	_, err = s.importPayments(organizationID, f)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/ledger")
+}
+
+const organizationDocumentsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Документы</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } .main-cell__check { display: block; margin-bottom: 10px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Документы</b> <div class="main-root__content"> <p><b>Папки</b></p> {{range .Folders}} <form method="POST" action="/organization/remove-document-folder"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <input type="text" value="{{.Name}}" disabled /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-document-folder"> <div class="main-root__wrap"> <input type="text" name="name" placeholder="Название папки" required /> <button type="submit">Добавить</button> </div> </form> <p><b>Новый документ</b></p> <form method="POST" action="/organization/create-document" enctype="multipart/form-data"> <input class="main-cell__input" type="text" name="title" placeholder="Название" required /> <textarea class="main-cell__text" name="description" placeholder="Описание"></textarea> <div class="main-root__wrap"> <select name="folder_id"> <option value="0">Без папки</option> {{range .Folders}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <select name="building_id"> <option value="0">Все дома</option> {{range .Buildings}} <option value="{{.ID}}">{{.Address}}</option> {{end}} </select> </div> <div class="main-root__wrap"> <input type="file" name="file" required /> <button type="submit">Загрузить</button> </div> </form> {{range .Documents}} {{$d := .}} <p><b>{{.Title}}</b>, {{if .FolderName}}{{.FolderName}}{{else}}без папки{{end}}, {{if .BuildingAddress}}{{.BuildingAddress}}{{else}}все дома{{end}}, версия {{.Version}} от {{.UpdatedAt.Local.Format "2006-01-02 15:04"}}, скачиваний: {{.DownloadsCount}}, скачали жильцов: {{.DownloadersCount}}</p> <form method="POST" action="/organization/set-document"> <input type="hidden" name="id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="title" value="{{.Title}}" placeholder="Название" /> <textarea class="main-cell__text" name="description" placeholder="Описание">{{.Description}}</textarea> <div class="main-root__wrap"> <select name="folder_id"> <option value="0">Без папки</option> {{range $.Folders}} <option value="{{.ID}}" {{if $d.InFolder .ID}}selected{{end}}>{{.Name}}</option> {{end}} </select> <select name="building_id"> <option value="0">Все дома</option> {{range $.Buildings}} <option value="{{.ID}}" {{if $d.TargetedAt .ID}}selected{{end}}>{{.Address}}</option> {{end}} </select> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/create-document-version" enctype="multipart/form-data"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <input type="file" name="file" required /> <button type="submit">Загрузить версию</button> </div> </form> {{range index $.Versions .ID}} <p><a href="/organization/documents/{{$d.ID}}/versions/{{.ID}}/download">Версия {{.Version}}: {{.FileName}}</a>, {{.Size}} байт, {{.CreatedAt.Local.Format "2006-01-02 15:04"}}, скачиваний: {{.DownloadsCount}}, скачали жильцов: {{.DownloadersCount}}</p> {{end}} <form method="POST" action="/organization/remove-document"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить</button> </div> </form> {{else}} <p>Документов нет.</p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationDocuments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	fs, err := s.storage.OrganizationDocumentFolders(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization document folders from storage: " +
+				err.Error())
+	}
+
+	ds, err := s.storage.OrganizationDocuments(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization documents from storage: " +
+				err.Error())
+	}
+
+	vs := map[int][]entity.DocumentVersion{}
+
+	for _, d := range ds {
+		vs[d.ID], err = s.storage.DocumentVersions(d.ID)
+		if err != nil {
+			return errors.New(
+				"failed to get document versions from storage: " +
+					err.Error())
+		}
+	}
+
+	bs, err := s.storage.OrganizationBuildings(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization buildings from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_documents", echo.Map{
+		"Login":     login,
+		"Folders":   fs,
+		"Documents": ds,
+		"Versions":  vs,
+		"Buildings": bs,
+	})
+}
+
+func (s *Server) getOrganizationDocumentVersionDownload(
+	c echo.Context) error {
+
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	versionID, err := strconv.Atoi(c.Param("version_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse version_id: "+err.Error())
+	}
+
+	v, err := s.organizationDocumentVersion(organizationID, documentID,
+		versionID)
+	if err != nil {
+		return err
+	}
+
+	return s.downloadDocumentVersion(c, v)
+}
+
+func (s *Server) postOrganizationCreateDocumentFolder(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := bindDocumentFolder(c)
+	if err != nil {
+		return err
+	}
+
+	f.OrganizationID = organizationID
+
+	_, err = s.storage.AddDocumentFolder(f)
+	if err != nil {
+		return errors.New("failed to add document folder to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+func (s *Server) postOrganizationRemoveDocumentFolder(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var f entity.DocumentFolder
+
+	err = c.Bind(&f)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind document folder: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationDocumentFolder(organizationID, f.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization document folder from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+func (s *Server) postOrganizationCreateDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	_, err = s.addDocument(c, organizationID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+func (s *Server) postOrganizationSetDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.FormValue("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse id: "+err.Error())
+	}
+
+	_, err = s.setDocument(c, organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+func (s *Server) postOrganizationCreateDocumentVersion(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	documentID, err := strconv.Atoi(c.FormValue("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse id: "+err.Error())
+	}
+
+	_, err = s.addDocumentVersion(c, organizationID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+func (s *Server) postOrganizationRemoveDocument(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var d entity.Document
+
+	err = c.Bind(&d)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind document: "+err.Error())
+	}
+
+	err = s.removeDocument(organizationID, d.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/documents")
+}
+
+const organizationContractorsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Подрядчики</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Подрядчики</b> <div class="main-root__content"> {{range .Contractors}} <form method="POST" action="/organization/set-contractor"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <input type="text" name="phone" value="{{.Phone}}" placeholder="Телефон" /> <input type="text" name="email" value="{{.Email}}" placeholder="Email" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-contractor"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-contractor"> <div class="main-root__wrap"> <input type="text" name="name" placeholder="Название" /> <input type="text" name="phone" placeholder="Телефон" /> <input type="text" name="email" placeholder="Email" /> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationContractors(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cs, err := s.storage.OrganizationContractors(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization contractors from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_contractors", echo.Map{
+		"Login":       login,
+		"Contractors": cs,
+	})
+}
+
+func (s *Server) postOrganizationCreateContractor(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var ct entity.Contractor
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind contractor: "+err.Error())
+	}
+
+	ct.OrganizationID = organizationID
+
+	err = ct.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate contractor: "+err.Error())
+	}
+
+	_, err = s.storage.AddContractor(ct)
+	if err != nil {
+		return errors.New("failed to add contractor to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/contractors")
+}
+
+func (s *Server) postOrganizationSetContractor(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var ct entity.Contractor
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind contractor: "+err.Error())
+	}
+
+	ct.OrganizationID = organizationID
+
+	err = ct.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate contractor: "+err.Error())
+	}
+
+	_, err = s.storage.SetContractor(ct)
+	if err != nil {
+		return errors.New("failed to set contractor in storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/contractors")
+}
+
+func (s *Server) postOrganizationRemoveContractor(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var ct entity.Contractor
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind contractor: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationContractor(organizationID, ct.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization contractor from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/contractors")
+}
+
+const organizationResponseTemplatesPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Шаблоны ответов</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Шаблоны ответов</b> <div class="main-root__content"> <p>Подстановки: {{range .Placeholders}}{{.}} {{end}}</p> {{range .ResponseTemplates}} {{$rt := .}} <form method="POST" action="/organization/set-response-template"> <input type="hidden" name="id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="title" value="{{.Title}}" placeholder="Название" /> <select class="main-cell__select" name="category_id"> <option value="">Все категории</option> {{range $.Categories}} <option value="{{.ID}}" {{if $rt.HasCategory .ID}}selected{{end}}>{{.Name}}</option> {{end}} </select> <textarea class="main-cell__text" name="text" placeholder="Текст">{{.Text}}</textarea> <p>Использован: {{.UsesCount}} раз{{if .LastUsedAt}}, последний раз {{.LastUsedAt.Format "2006-01-02 15:04"}}{{end}}</p> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-response-template"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-response-template"> <input class="main-cell__input" type="text" name="title" placeholder="Название" /> <select class="main-cell__select" name="category_id"> <option value="">Все категории</option> {{range $.Categories}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <textarea class="main-cell__text" name="text" placeholder="Текст"></textarea> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationResponseTemplates(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	rts, err := s.storage.OrganizationResponseTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization response templates from storage: " +
+				err.Error())
+	}
+
+	categories, err := s.storage.Categories()
+	if err != nil {
+		return errors.New("failed to get categories from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_templates", echo.Map{
+		"Login":             login,
+		"ResponseTemplates": rts,
+		"Categories":        categories,
+		"Placeholders":      entity.ResponseTemplatePlaceholders,
+	})
+}
+
+func (s *Server) postOrganizationCreateResponseTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	rt, err := bindResponseTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	rt.OrganizationID = organizationID
+
+	_, err = s.storage.AddResponseTemplate(rt)
+	if err != nil {
+		return errors.New("failed to add response template to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/response-templates")
+}
+
+func (s *Server) postOrganizationSetResponseTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	rt, err := bindResponseTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	rt.OrganizationID = organizationID
+
+	_, err = s.setResponseTemplate(rt)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/response-templates")
+}
+
+func (s *Server) postOrganizationRemoveResponseTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var rt entity.ResponseTemplate
+
+	err = c.Bind(&rt)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind response template: "+err.Error())
+	}
+
+	err = s.removeResponseTemplate(organizationID, rt.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/response-templates")
+}
+
+const organizationFieldsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Метки и поля</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Метки</b> <div class="main-root__content"> {{range .Labels}} <form method="POST" action="/organization/set-label"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <input type="text" name="name" value="{{.Name}}" placeholder="Название" /> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-label"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-label"> <div class="main-root__wrap"> <input type="text" name="name" placeholder="Название" /> <button type="submit">Добавить</button> </div> </form> </div> <b class="main-root__title">Поля</b> <div class="main-root__content"> <p>Варианты значений списка указываются по одному в строке.</p> {{range .CustomFields}} {{$cf := .}} <form method="POST" action="/organization/set-custom-field"> <input type="hidden" name="id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="name" value="{{.Name}}" placeholder="Название" /> <select class="main-cell__select" name="type"> {{range $.FieldTypes}} <option value="{{.}}" {{if eq . $cf.Type}}selected{{end}}>{{.}}</option> {{end}} </select> <textarea class="main-cell__text" name="options" placeholder="Варианты">{{range .Options}}{{.}}
+{{end}}</textarea> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-custom-field"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-custom-field"> <input class="main-cell__input" type="text" name="name" placeholder="Название" /> <select class="main-cell__select" name="type"> {{range $.FieldTypes}} <option value="{{.}}">{{.}}</option> {{end}} </select> <textarea class="main-cell__text" name="options" placeholder="Варианты"></textarea> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationFields(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_fields", echo.Map{
+		"Login":        login,
+		"Labels":       ls,
+		"CustomFields": cfs,
+		"FieldTypes": []string{fieldtype.Text, fieldtype.Number,
+			fieldtype.Date, fieldtype.Enum},
+	})
+}
+
+func (s *Server) postOrganizationCreateLabel(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	l, err := bindLabel(c)
+	if err != nil {
+		return err
+	}
+
+	l.OrganizationID = organizationID
+
+	_, err = s.storage.AddLabel(l)
+	if err != nil {
+		return errors.New("failed to add label to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+func (s *Server) postOrganizationSetLabel(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	l, err := bindLabel(c)
+	if err != nil {
+		return err
+	}
+
+	l.OrganizationID = organizationID
+
+	_, err = s.storage.SetLabel(l)
+	if err != nil {
+		return errors.New("failed to set label in storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+func (s *Server) postOrganizationRemoveLabel(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var l entity.Label
+
+	err = c.Bind(&l)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind label: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationLabel(organizationID, l.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization label from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+func (s *Server) postOrganizationCreateCustomField(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cf, err := bindCustomField(c)
+	if err != nil {
+		return err
+	}
+
+	cf.OrganizationID = organizationID
+
+	_, err = s.storage.AddCustomField(cf)
+	if err != nil {
+		return errors.New("failed to add custom field to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+func (s *Server) postOrganizationSetCustomField(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cf, err := bindCustomField(c)
+	if err != nil {
+		return err
+	}
+
+	cf.OrganizationID = organizationID
+
+	_, err = s.storage.SetCustomField(cf)
+	if err != nil {
+		return errors.New("failed to set custom field in storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+func (s *Server) postOrganizationRemoveCustomField(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var cf entity.CustomField
+
+	err = c.Bind(&cf)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind custom field: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationCustomField(organizationID, cf.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization custom field from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/fields")
+}
+
+const organizationChecklistsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Чек-листы</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> </div> <div class="main-root__ri"> <b class="main-root__title">Чек-листы</b> <div class="main-root__content"> <p>Пункты чек-листа указываются по одному в строке. Чек-лист категории добавляется к новым обращениям этой категории.</p> {{range .ChecklistTemplates}} {{$ct := .}} <form method="POST" action="/organization/set-checklist-template"> <input type="hidden" name="id" value="{{.ID}}" /> <select class="main-cell__select" name="category_id"> {{range $.Categories}} <option value="{{.ID}}" {{if eq .ID $ct.CategoryID}}selected{{end}}>{{.Name}}</option> {{end}} </select> <textarea class="main-cell__text" name="items" placeholder="Пункты">{{range .Items}}{{.}}
+{{end}}</textarea> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/organization/remove-checklist-template"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/organization/create-checklist-template"> <select class="main-cell__select" name="category_id"> {{range $.Categories}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <textarea class="main-cell__text" name="items" placeholder="Пункты"></textarea> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationChecklists(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	cts, err := s.storage.OrganizationChecklistTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization checklist templates from storage: " +
+				err.Error())
+	}
+
+	cs, err := s.storage.Categories()
+	if err != nil {
+		return errors.New("failed to get categories from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "organization_checklists", echo.Map{
+		"Login":              login,
+		"ChecklistTemplates": cts,
+		"Categories":         cs,
+	})
+}
+
+func (s *Server) postOrganizationCreateChecklistTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ct, err := bindChecklistTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	ct.OrganizationID = organizationID
+
+	_, err = s.storage.AddChecklistTemplate(ct)
+	if err != nil {
+		return errors.New("failed to add checklist template to storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/checklists")
+}
+
+func (s *Server) postOrganizationSetChecklistTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	ct, err := bindChecklistTemplate(c)
+	if err != nil {
+		return err
+	}
+
+	ct.OrganizationID = organizationID
+
+	_, err = s.storage.SetChecklistTemplate(ct)
+	if err != nil {
+		return errors.New("failed to set checklist template in storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/checklists")
+}
+
+func (s *Server) postOrganizationRemoveChecklistTemplate(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var ct entity.ChecklistTemplate
+
+	err = c.Bind(&ct)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind checklist template: "+err.Error())
+	}
+
+	err = s.storage.RemoveOrganizationChecklistTemplate(organizationID, ct.ID)
+	if err != nil {
+		return errors.New(
+			"failed to remove organization checklist template from storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/organization/checklists")
+}
+
+const organizationCostsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Организация / Затраты</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Затраты</b> <div class="main-root__content"> <form method="GET" action="/organization/costs"> <div class="main-root__wrap"> <input type="month" name="month" value="{{.Month}}" /> <button type="submit">Показать</button> </div> </form> <p><a href="/organization/costs/export?month={{.Month}}">Выгрузить в CSV</a></p> {{with .Report}} <p><b>Итого: {{.Total.Total}}</b>, к оплате владельцами: {{.Total.Billable}}</p> <p><b>По категориям</b></p> {{range .Categories}} <p>{{.Name}}: {{.Total}} (к оплате владельцами: {{.Billable}})</p> {{end}} <p><b>По домам</b></p> {{range .Buildings}} <p>{{.Name}}: {{.Total}} (к оплате владельцами: {{.Billable}})</p> {{end}} <p><b>По обращениям</b></p> {{range .Requests}} <p>{{.Name}}: {{.Total}} (к оплате владельцем: {{.Billable}})</p> {{end}} {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOrganizationCosts(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, to, cis, err := s.organizationCostItems(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "organization_costs", echo.Map{
+		"Login":  login,
+		"Month":  from.Format("2006-01"),
+		"Report": costReport(from, to, cis),
+	})
+}
+
+func (s *Server) getOrganizationCostsExport(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	from, _, cis, err := s.organizationCostItems(organizationID,
+		c.QueryParam("month"))
+	if err != nil {
+		return err
+	}
+
+	return exportCostItems(c, from, cis)
+}
+
+const incidentsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Аварии</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> {{if .Organization}} <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> {{else}} <a class="main-root__link" href="/operator/requests">Обращения</a> <a class="main-root__link" href="/operator/visit-slots">Визиты</a> {{end}} </div> <div class="main-root__ri"> <b class="main-root__title">Аварии</b> <div class="main-root__content"> <form method="POST" action="{{.Path}}/create-incident"> <input class="main-cell__input" type="text" name="title" placeholder="Заголовок" /> <textarea class="main-cell__text" name="description" placeholder="Описание"></textarea> <textarea class="main-cell__text" name="buildings" placeholder="Адреса домов, по одному на строку"></textarea> <label>Ожидаемое время устранения <input type="datetime-local" name="expected_resolution_at" /></label> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> {{$path := .Path}} {{range .Incidents}} <p><b>{{.ID}}</b>, <b>{{.Title}}</b>, Дата и время: {{.CreatedAt.Format "2006-01-02 15:04"}}{{if .Resolved}}, <b>Устранена: {{.ResolvedAt.Format "2006-01-02 15:04"}}</b>{{end}}</p> {{if .Resolved}} <p>{{.Description}}</p> <p>Дома: {{.BuildingsStr}}</p> {{if .Response}} <p>{{.Response}}</p> {{end}} {{else}} <form method="POST" action="{{$path}}/set-incident"> <input type="hidden" name="id" value="{{.ID}}" /> <input class="main-cell__input" type="text" name="title" value="{{.Title}}" placeholder="Заголовок" /> <textarea class="main-cell__text" name="description" placeholder="Описание">{{.Description}}</textarea> <textarea class="main-cell__text" name="buildings" placeholder="Адреса домов, по одному на строку">{{.BuildingsStr}}</textarea> <label>Ожидаемое время устранения <input type="datetime-local" name="expected_resolution_at" value="{{.ExpectedResolutionAtStr}}" /></label> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="{{$path}}/resolve-incident"> <input type="hidden" name="id" value="{{.ID}}" /> <textarea class="main-cell__text" name="response" placeholder="Ответ жильцам"></textarea> <div class="main-root__wrap"> <button type="submit">Устранена</button> </div> </form> {{end}} {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getIncidents(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	rl, ok := sess.Values["role"].(string)
+	if !ok {
+		return errors.New("failed to get role from session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	is, err := s.storage.OrganizationIncidents(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization incidents from storage: " +
+			err.Error())
+	}
+
+	for i := range is {
+		is[i].BuildingsStr = strings.Join(is[i].Buildings, "\n")
+		if is[i].ExpectedResolutionAt != nil {
+			is[i].ExpectedResolutionAtStr = is[i].ExpectedResolutionAt.
+				In(time.Local).Format("2006-01-02T15:04")
+		}
+	}
+
+	return c.Render(http.StatusOK, "incidents", echo.Map{
+		"Login":        login,
+		"Organization": rl == role.Organization,
+		"Path":         "/" + rl,
+		"Incidents":    is,
+	})
+}
+
+func (s *Server) postCreateIncident(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	rl, ok := sess.Values["role"].(string)
+	if !ok {
+		return errors.New("failed to get role from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var i entity.Incident
+
+	err = c.Bind(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind incident: "+err.Error())
+	}
+
+	err = parseIncident(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse incident: "+err.Error())
+	}
+
+	err = i.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate incident: "+err.Error())
+	}
+
+	i.OrganizationID = organizationID
+
+	_, err = s.storage.AddIncident(i)
+	if err != nil {
+		return errors.New("failed to add incident to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/"+rl+"/incidents")
+}
+
+func (s *Server) postSetIncident(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	rl, ok := sess.Values["role"].(string)
+	if !ok {
+		return errors.New("failed to get role from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var i entity.Incident
+
+	err = c.Bind(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind incident: "+err.Error())
+	}
+
+	err = parseIncident(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse incident: "+err.Error())
+	}
+
+	err = i.Validate()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to validate incident: "+err.Error())
+	}
+
+	i.OrganizationID = organizationID
+
+	_, err = s.storage.SetIncident(i)
+	if err != nil {
+		return errors.New("failed to set incident in storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/"+rl+"/incidents")
+}
+
+func (s *Server) postResolveIncident(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	rl, ok := sess.Values["role"].(string)
+	if !ok {
+		return errors.New("failed to get role from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var i entity.Incident
+
+	err = c.Bind(&i)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind incident: "+err.Error())
+	}
+
+	var response string
+	if i.Response != nil {
+		response = *i.Response
+	}
+
+	err = s.resolveIncident(organizationID, i.ID, response)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/"+rl+"/incidents")
+}
+
+func (s *Server) getOperator(c echo.Context) error {
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+const operatorRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Оператор / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-root__txt--red { color: #ff0000; } .main-root__note { background-color: #FFF8DC; padding: 5px; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/operator/incidents">Аварии</a> <a class="main-root__link" href="/operator/visit-slots">Визиты</a> </div> <div class="main-root__ri"> <b class="main-root__title">Обращения</b> <div class="main-root__content"> {{if .Tasks}} <p><b>Мои задачи</b></p> {{range .Tasks}} <form method="POST" action="/operator/set-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <input type="hidden" name="title" value="{{.Title}}" /> <input type="hidden" name="operator_id" value="{{.OperatorID}}" /> <input type="hidden" name="done" value="true" /> <p>Обращение №{{.RequestID}}: {{.Title}}</p> <button type="submit">Выполнено</button> </div> </form> {{end}} {{end}} <form method="GET" action="/operator/requests"> <div class="main-root__wrap"> <input type="text" name="q" value="{{.Query.Get "q"}}" placeholder="Поиск по тексту и ответу" /> <input type="text" name="address" value="{{.Query.Get "address"}}" placeholder="Адрес" /> {{$st := .Query.Get "statuses"}} <select name="statuses"> <option value="">Открытые</option> <option value="all" {{if eq $st "all"}}selected{{end}}>Все статусы</option> <option value="new" {{if eq $st "new"}}selected{{end}}>new</option> <option value="in_progress" {{if eq $st "in_progress"}}selected{{end}}>in_progress</option> <option value="resolved" {{if eq $st "resolved"}}selected{{end}}>resolved</option> <option value="rejected" {{if eq $st "rejected"}}selected{{end}}>rejected</option> <option value="irrelevant" {{if eq $st "irrelevant"}}selected{{end}}>irrelevant</option> <option value="cancelled" {{if eq $st "cancelled"}}selected{{end}}>cancelled</option> </select> </div> <div class="main-root__wrap"> {{$lb := .Query.Get "label_id"}} <select name="label_id"> <option value="">Все метки</option> {{range .Labels}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $lb}}selected{{end}}>{{.Name}}</option> {{end}} </select> {{$fd := .Query.Get "field_id"}} <select name="field_id"> <option value="">Любые поля</option> {{range .CustomFields}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $fd}}selected{{end}}>{{.Name}}</option> {{end}} </select> <input type="text" name="field_value" value="{{.Query.Get "field_value"}}" placeholder="Значение поля" /> </div> <div class="main-root__wrap"> {{$cat := .Query.Get "category_id"}} <select name="category_id"> <option value="">Все категории</option> {{range .Categories}} <option value="{{.ID}}" {{if eq (printf "%d" .ID) $cat}}selected{{end}}>{{.Name}}</option> {{end}} </select> <label>С <input type="date" name="from" value="{{.Query.Get "from"}}" /></label> <label>По <input type="date" name="to" value="{{.Query.Get "to"}}" /></label> {{$so := .Query.Get "sort"}} <select name="sort"> <option value="priority" {{if eq $so "priority"}}selected{{end}}>По приоритету</option> <option value="newest" {{if eq $so "newest"}}selected{{end}}>Сначала новые</option> <option value="oldest" {{if eq $so "oldest"}}selected{{end}}>Сначала старые</option> </select> <button type="submit">Найти</button> </div> </form> <form method="POST" action="/operator/create-request"> <p><b>Новое обращение по местам общего пользования</b></p> <div class="main-root__wrap"> <input type="text" name="building" placeholder="Адрес дома" required /> <input type="text" name="entrance" placeholder="Подъезд" /> <input type="number" name="floor" placeholder="Этаж" /> <select name="common_area" required> <option value="stairwell">Подъезд, лестница</option> <option value="elevator">Лифт</option> <option value="basement">Подвал</option> <option value="roof">Крыша</option> <option value="yard">Двор</option> <option value="other">Другое</option> </select> <select name="priority"> <option value="normal">Обычный</option> <option value="urgent">Срочно</option> <option value="emergency">Авария</option> </select> </div> <textarea class="main-cell__text" name="text" placeholder="Текст обращения" required></textarea> <div class="main-root__wrap"> <button type="submit">Создать обращение</button> </div> </form> <form id="bulk" method="POST" action="/operator/bulk-requests"> <p><b>Действие с выбранными обращениями</b></p> <div class="main-root__wrap"> <select name="status"> <option value="">Статус не менять</option> <option value="in_progress">В работе</option> <option value="resolved">Разрешён</option> <option value="rejected">Отклонён</option> <option value="irrelevant">Не релевантен</option> </select> <select name="operator_id"> <option value="">Оператора не менять</option> {{range .Operators}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <select name="category_id"> <option value="">Категорию не менять</option> {{range .Categories}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <select name="response_template_id"> <option value="">Без шаблона ответа</option> {{range .ResponseTemplates}} <option value="{{.ID}}">{{.Title}}</option> {{end}} </select> </div> <textarea class="main-cell__text" name="response" placeholder="Ответ всем выбранным"></textarea> <div class="main-root__wrap"> <button type="submit">Применить к выбранным</button> </div> </form> {{range .Requests}} <p><input type="checkbox" name="request_ids" value="{{.ID}}" form="bulk" /> <b>{{.ID}}</b>, <b>Статус: {{.Status}}</b>, Дата и время: {{.CreatedAt.Format "2006-01-02 15:04"}} {{if .HasEmergencyPriority}}<b class="main-root__txt--red">АВАРИЯ</b>{{else if .HasUrgentPriority}}<b class="main-root__txt--red">Срочно</b>{{end}}</p> {{if and .HasEmergencyPriority (not .Acknowledged)}} <form method="POST" action="/operator/acknowledge-request"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Подтвердить получение</button> </div> </form> {{end}} {{if .OwnerID}} <p><b>Владелец:</b> Имя: {{.OwnerName}}, Телефон: {{.OwnerPhone}} Адрес: {{.OwnerAddress}}</p> {{end}} {{if .HasCommonArea}} <p><b>Место:</b> {{.Building}}{{if .Entrance}}, подъезд {{.Entrance}}{{end}}{{if .Floor}}, этаж {{.Floor}}{{end}}, {{.CommonArea}}{{if .SupportsCount}}, подтвердили жильцы: {{.SupportsCount}}{{end}}</p> {{end}} {{if .Labels}} <p>Метки: {{range .Labels}}{{.Name}} {{end}}</p> {{end}} <p>{{.Text}}</p> {{range .Events}} {{if .Edited}} <p><i>{{.CreatedAt.Format "2006-01-02 15:04"}} владелец изменил текст обращения, прежний текст: {{.Text}}</i></p> {{else if .Cancelled}} <p class="main-root__txt--red"><i>{{.CreatedAt.Format "2006-01-02 15:04"}} владелец отменил обращение: {{.Text}}</i></p> {{else if .StatusChanged}} <p><i>{{.CreatedAt.Format "2006-01-02 15:04"}} установлен статус {{.Text}}</i></p> {{else if .Reassigned}} <p><i>{{.CreatedAt.Format "2006-01-02 15:04"}} назначен оператор {{.Text}}</i></p> {{else if .CategoryChanged}} <p><i>{{.CreatedAt.Format "2006-01-02 15:04"}} установлена категория {{.Text}}</i></p> {{else if .Responded}} <p><i>{{.CreatedAt.Format "2006-01-02 15:04"}} ответ: {{.Text}}</i></p> {{end}} {{end}} {{range .Notes}} <p class="main-root__note"><i>Заметка, {{.CreatedAt.Format "2006-01-02 15:04"}}, {{if .AuthorName}}{{.AuthorName}}{{else}}{{.Role}}{{end}}:</i> {{.Text}}</p> {{end}} <form method="POST" action="/operator/create-request-note"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <input type="text" name="text" placeholder="Внутренняя заметка, владелец её не увидит" /> <button type="submit">Добавить заметку</button> </div> </form> <p><b>Метки и поля</b></p> {{$r := .}} <form method="POST" action="/operator/set-request-attributes"> <input type="hidden" name="request_id" value="{{.ID}}" /> <div class="main-root__wrap"> {{range $.Labels}} <label><input type="checkbox" name="label_ids" value="{{.ID}}" {{if $r.HasLabel .ID}}checked{{end}} /> {{.Name}}</label> {{end}} </div> {{range $.CustomFields}} {{$v := $r.FieldValue .ID}} <div class="main-root__wrap"> <input type="hidden" name="field_id" value="{{.ID}}" /> <label>{{.Name}} {{if .IsEnum}}<select name="field_value"> <option value="">—</option> {{range .Options}} <option value="{{.}}" {{if eq . $v}}selected{{end}}>{{.}}</option> {{end}} </select>{{else if .IsDate}}<input type="date" name="field_value" value="{{$v}}" />{{else if .IsNumber}}<input type="number" step="any" name="field_value" value="{{$v}}" />{{else}}<input type="text" name="field_value" value="{{$v}}" />{{end}}</label> </div> {{end}} <div class="main-root__wrap"> <button type="submit">Сохранить метки и поля</button> </div> </form> <p><b>Чек-лист{{if .Progress}}, выполнено {{.Progress}}%{{end}}</b></p> {{range .Tasks}} {{$t := .}} <form method="POST" action="/operator/set-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <label><input type="checkbox" name="done" value="true" {{if .Done}}checked{{end}} /> {{.Position}}.</label> <input type="text" name="title" value="{{.Title}}" /> <select name="operator_id"> <option value="0">Не назначена</option> {{range $.Operators}} <option value="{{.ID}}" {{if $t.AssignedTo .ID}}selected{{end}}>{{.Name}}</option> {{end}} </select> <button type="submit">Сохранить</button> </div> </form> <form method="POST" action="/operator/remove-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.RequestID}}" /> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/operator/create-request-task"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <input type="text" name="title" placeholder="Задача" /> <select name="operator_id"> <option value="0">Не назначена</option> {{range $.Operators}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <button type="submit">Добавить задачу</button> </div> </form> {{with .CostTotal}}{{if .Total}} <p><b>Затраты: {{.Total}}</b>, к оплате владельцем: {{.Billable}}</p> {{end}}{{end}} {{range .CostItems}} <form method="POST" action="/operator/remove-cost-item"> <div class="main-root__wrap"> <p>{{if .Labor}}Работы{{else}}Материал{{end}}: {{.Name}}, {{.Quantity}} x {{.UnitPrice}} = {{.Amount}}{{if .Billable}}, к оплате владельцем{{end}}</p> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Удалить</button> </div> </form> {{end}} <form method="POST" action="/operator/create-cost-item"> <div class="main-root__wrap"> <input type="hidden" name="request_id" value="{{.ID}}" /> <select name="kind" required> <option value="material">Материал</option> <option value="labor">Работы, ч</option> </select> <input type="text" name="name" placeholder="Наименование" /> <input type="number" name="quantity" step="0.001" min="0" placeholder="Количество" required /> <input type="number" name="unit_price" step="0.01" min="0" placeholder="Цена" required /> <label><input type="checkbox" name="billable" value="true" /> К оплате владельцем</label> <button type="submit">Добавить затраты</button> </div> </form> {{range .WorkOrders}} <p>Заказ-наряд №{{.ID}}, Подрядчик: {{.ContractorName}}, Статус: {{.Status}}{{if .DueAt}}, Срок: {{.DueAt.Format "2006-01-02"}}{{end}}: {{.Scope}}{{if .Comment}} ({{.Comment}}){{end}}</p> {{end}} {{$id := .ID}} {{if and $.Contractors (or .HasNewStatus .HasInProgressStatus)}} <form method="POST" action="/operator/create-work-order"> <input type="hidden" name="request_id" value="{{.ID}}" /> <select class="main-cell__select" name="contractor_id" required> {{range $.Contractors}} <option value="{{.ID}}">{{.Name}}</option> {{end}} </select> <textarea class="main-cell__text" name="scope" placeholder="Объём работ"></textarea> <label>Срок <input type="date" name="due_at" /></label> <div class="main-root__wrap"> <button type="submit">Выдать заказ-наряд</button> </div> </form> {{end}} {{if .PrimaryRequestID}} <form method="POST" action="/operator/unlink-request"> <p>Дубликат обращения №{{.PrimaryRequestID}}</p> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Отменить объединение</button> </div> </form> {{else if or .HasNewStatus .HasInProgressStatus}} {{range .Duplicates}} <form method="POST" action="/operator/link-request"> <p>Возможный дубликат №{{.DuplicateID}} ({{.SimilarityPercent}}%, статус: {{.Status}}): {{.Text}}</p> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{$id}}" /> <input type="hidden" name="primary_request_id" value="{{.DuplicateID}}" /> <button type="submit">Объединить с №{{.DuplicateID}}</button> </div> </form> {{end}} <form method="POST" action="/operator/link-request"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <input type="number" name="primary_request_id" placeholder="Основное обращение" /> <button type="submit">Объединить</button> </div> </form> {{end}} {{if .HasNewStatus}} <form method="POST" action="/operator/set-request-in-progress"> <div class="main-root__wrap"> <input type="hidden" name="id" value="{{.ID}}" /> <button type="submit">Начать обработку</button> </div> </form> {{else if .HasInProgressStatus}} <form method="POST" action="/operator/set-request-final"> <input type="hidden" name="id" value="{{.ID}}" /> <select class="main-cell__select" name="status" required> <option value="resolved">Разрешён</option> <option value="rejected">Отклонён</option> <option value="irrelevant">Не релевантен</option> </select> {{$cat := .CategoryID}} <select class="main-cell__select" name="response_template_id"> <option value="">Без шаблона</option> {{range $.ResponseTemplates}}{{if .Fits $cat}} <option value="{{.ID}}">{{.Title}}</option>{{end}}{{end}} </select> <textarea class="main-cell__text" name="response" placeholder="Комментарий"></textarea> <div class="main-root__wrap"> <button type="submit">Завершить обработку</button> </div> </form> {{else if .Response}} <p>{{.Response}}</p> {{end}} {{end}} {{if .NextURL}} <p><a href="{{.NextURL}}">Следующая страница</a></p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOperatorRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Priority, status.New,
+		status.InProgress)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.operatorRequests(operatorID, f)
+	if err != nil {
+		return err
+	}
+
+	categories, err := s.storage.Categories()
+	if err != nil {
+		return errors.New("failed to get categories from storage: " +
+			err.Error())
+	}
+
+	cs, err := s.storage.OrganizationContractors(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization contractors from storage: " +
+				err.Error())
+	}
+
+	ops, err := s.storage.OrganizationOperators(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization operators from storage: " +
+				err.Error())
+	}
+
+	rts, err := s.storage.OrganizationResponseTemplates(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization response templates from storage: " +
+				err.Error())
+	}
+
+	ls, err := s.storage.OrganizationLabels(organizationID)
+	if err != nil {
+		return errors.New("failed to get organization labels from storage: " +
+			err.Error())
+	}
+
+	cfs, err := s.storage.OrganizationCustomFields(organizationID)
+	if err != nil {
+		return errors.New(
+			"failed to get organization custom fields from storage: " +
+				err.Error())
+	}
+
+	ts, err := s.storage.OperatorTasks(operatorID)
+	if err != nil {
+		return errors.New("failed to get operator tasks from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "operator_requests", echo.Map{
+		"Login":             login,
+		"Requests":          rs,
+		"Tasks":             ts,
+		"Contractors":       cs,
+		"Operators":         ops,
+		"Categories":        categories,
+		"ResponseTemplates": rts,
+		"Labels":            ls,
+		"CustomFields":      cfs,
+		"Query":             c.QueryParams(),
+		"NextURL":           nextPageURL(c, cursor),
+	})
+}
+
+func (s *Server) postAcknowledgeRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var req entity.Request
+
+	err = c.Bind(&req)
+	if err != nil {
+		return errors.New("failed bind request: " + err.Error())
+	}
+
+	err = s.storage.AcknowledgeOperatorRequest(operatorID, req.ID)
+	if err != nil {
+		return errors.New("failed to acknowledge operator request: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postSetRequestInProgress(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var newReq entity.Request
+
+	err = c.Bind(&newReq)
+	if err != nil {
+		return errors.New("failed bind request: " + err.Error())
+	}
+
+	req, err := s.storage.OperatorRequest(operatorID, newReq.ID)
+	if err != nil {
+		return errors.New("failed to get operator request from storage: " +
+			err.Error())
+	}
+
+	if req.Status != status.New {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request status is not in new state")
+	}
+
+	req.Status = status.InProgress
+
+	_, err = s.storage.SetOperatorRequest(operatorID, req)
+	if err != nil {
+		return errors.New("failed to set operator request: " + err.Error())
+	}
+
+	err = s.storage.AcknowledgeOperatorRequest(operatorID, req.ID)
+	if err != nil {
+		return errors.New("failed to acknowledge operator request: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postSetRequestFinal(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var newReq entity.Request
+
+	err = c.Bind(&newReq)
+	if err != nil {
+		return errors.New("failed bind request: " + err.Error())
+	}
+
+	if !status.Final(newReq.Status) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"status set is not final")
+	}
+
+	req, err := s.storage.OperatorRequest(operatorID, newReq.ID)
+	if err != nil {
+		return errors.New("failed to get operator request from storage: " +
+			err.Error())
+	}
+
+	if status.Final(req.Status) {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"request status is already in final state")
+	}
+
+	req.Status = newReq.Status
+	req.Response = newReq.Response
+
+	// Empty template select is bound as zero template ID.
+	useTemplate := newReq.ResponseTemplateID != nil &&
+		*newReq.ResponseTemplateID != 0
+
+	if useTemplate {
+		req.Response, err = s.operatorTemplateResponse(organizationID,
+			operatorID, *newReq.ResponseTemplateID, req.ID, newReq.Response)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = s.storage.SetOperatorRequest(operatorID, req)
+	if err != nil {
+		return errors.New("failed to set operator request: " + err.Error())
+	}
+
+	if useTemplate {
+		s.addResponseTemplateUse(*newReq.ResponseTemplateID, req.ID,
+			role.Operator, operatorID)
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+const bulkResultsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Обращения / Массовое действие</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> {{if .Organization}} <a class="main-root__link" href="/organization/requests">Обращения</a> <a class="main-root__link" href="/organization/owners">Жильцы</a> <a class="main-root__link" href="/organization/buildings">Дома</a> <a class="main-root__link" href="/organization/meters">Счётчики</a> <a class="main-root__link" href="/organization/announcements">Объявления</a> <a class="main-root__link" href="/organization/polls">Собрания</a> <a class="main-root__link" href="/organization/ledger">Начисления</a> <a class="main-root__link" href="/organization/documents">Документы</a> <a class="main-root__link" href="/organization/operators">Операторы</a> <a class="main-root__link" href="/organization/incidents">Аварии</a> <a class="main-root__link" href="/organization/contractors">Подрядчики</a> <a class="main-root__link" href="/organization/costs">Затраты</a> <a class="main-root__link" href="/organization/response-templates">Шаблоны ответов</a> <a class="main-root__link" href="/organization/fields">Метки и поля</a> <a class="main-root__link" href="/organization/checklists">Чек-листы</a> {{else}} <a class="main-root__link" href="/operator/requests">Обращения</a> <a class="main-root__link" href="/operator/incidents">Аварии</a> <a class="main-root__link" href="/operator/visit-slots">Визиты</a> {{end}} </div> <div class="main-root__ri"> <b class="main-root__title">Массовое действие</b> <div class="main-root__content"> {{range .Results}} {{if .OK}} <p>Обращение №{{.RequestID}}: выполнено</p> {{else}} <p class="main-root__txt--red">Обращение №{{.RequestID}}: {{.Error}}</p> {{end}} {{end}} <p><a href="{{.Path}}/requests">Вернуться к обращениям</a></p> </div> </div> </div></body></html>`
+
+func (s *Server) postBulkRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var ba entity.BulkAction
+
+	err = c.Bind(&ba)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind bulk action: "+err.Error())
+	}
+
+	brs, err := s.bulkAction(rl, actorID, organizationID, ba)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "bulk_results", echo.Map{
+		"Login":        login,
+		"Organization": rl == role.Organization,
+		"Path":         "/" + rl,
+		"Results":      brs,
+	})
+}
+
+func (s *Server) postCreateRequest(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	r, err = s.addStaffRequest(rl, actorID, organizationID, r)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(r.ID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postCreateRequestNote(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var n entity.RequestNote
+
+	err = c.Bind(&n)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request note: "+err.Error())
+	}
+
+	_, err = s.addRequestNote(rl, actorID, organizationID, n)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(n.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postSetRequestAttributes(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var ra entity.RequestAttributes
+
+	err = c.Bind(&ra)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request attributes: "+err.Error())
+	}
+
+	err = s.setRequestAttributes(rl, actorID, organizationID, ra.RequestID,
+		ra)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(ra.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postCreateRequestTask(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var t entity.RequestTask
+
+	err = c.Bind(&t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request task: "+err.Error())
+	}
+
+	_, err = s.addRequestTask(rl, actorID, organizationID, t)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(t.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postSetRequestTask(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var t entity.RequestTask
+
+	err = c.Bind(&t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request task: "+err.Error())
+	}
+
+	err = s.setRequestTask(rl, actorID, organizationID, t)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(t.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postRemoveRequestTask(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var t entity.RequestTask
+
+	err = c.Bind(&t)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request task: "+err.Error())
+	}
+
+	err = s.removeRequestTask(rl, actorID, organizationID, t.RequestID, t.ID)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(t.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postCreateWorkOrder(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var wo entity.WorkOrder
+
+	err = c.Bind(&wo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind work order: "+err.Error())
+	}
+
+	_, err = s.addWorkOrder(operatorID, wo, s.publicBaseURL)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postCreateCostItem(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var ci entity.CostItem
+
+	err = c.Bind(&ci)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind cost item: "+err.Error())
+	}
+
+	_, err = s.addCostItem(rl, actorID, organizationID, ci)
+	if err != nil {
+		return err
+	}
+
+	if rl == role.Organization {
+		return c.Redirect(http.StatusFound,
+			"/organization/requests/"+strconv.Itoa(ci.RequestID))
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postOrganizationRemoveCostItem(c echo.Context) error {
+	rl, actorID, organizationID, err := sessionActor(c)
+	if err != nil {
+		return err
+	}
+
+	var ci entity.CostItem
+
+	err = c.Bind(&ci)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind cost item: "+err.Error())
+	}
+
+	err = s.removeCostItem(rl, actorID, organizationID, ci.RequestID, ci.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound,
+		"/organization/requests/"+strconv.Itoa(ci.RequestID))
+}
+
+func (s *Server) postRemoveCostItem(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var ci entity.CostItem
+
+	err = c.Bind(&ci)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind cost item: "+err.Error())
+	}
+
+	err = s.storage.RemoveOperatorCostItem(operatorID, ci.ID)
+	if err != nil {
+		return errors.New("failed to remove operator cost item from storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postLinkRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var ld linkRequestData
+
+	err = c.Bind(&ld)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind link request data: "+err.Error())
+	}
+
+	err = s.linkRequest(login, ld.ID, ld.PrimaryRequestID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+func (s *Server) postUnlinkRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var req entity.Request
+
+	err = c.Bind(&req)
+	if err != nil {
+		return errors.New("failed bind request: " + err.Error())
+	}
+
+	err = s.unlinkRequest(operatorID, req.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/requests")
+}
+
+const operatorVisitSlotsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Оператор / Визиты</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/operator/requests">Обращения</a> <a class="main-root__link" href="/operator/incidents">Аварии</a> </div> <div class="main-root__ri"> <b class="main-root__title">Визиты</b> <div class="main-root__content"> {{if .CalendarURL}} <p>Календарь визитов: <a href="{{.CalendarURL}}">{{.CalendarURL}}</a></p> <form method="POST" action="/operator/revoke-calendar-token"> <button type="submit">Закрыть доступ к календарю</button> </form> {{else}} <p>Календарь визитов не опубликован.</p> {{end}} <form method="POST" action="/operator/regenerate-calendar-token"> <button type="submit">Создать новую ссылку на календарь</button> </form> <form method="POST" action="/operator/create-visit-slot"> <label>Начало <input type="datetime-local" name="starts_at" required /></label> <label>Окончание <input type="datetime-local" name="ends_at" required /></label> <div class="main-root__wrap"> <button type="submit">Добавить</button> </div> </form> {{range .VisitSlots}} <p><b>{{.StartsAt.Local.Format "2006-01-02 15:04"}} - {{.EndsAt.Local.Format "15:04"}}</b>{{if .Booked}}, обращение №{{.RequestID}}, Владелец: {{.OwnerName}}, Телефон: {{.OwnerPhone}}, Адрес: {{.OwnerAddress}}{{end}}</p> {{if not .Booked}} <form method="POST" action="/operator/remove-visit-slot"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить</button> </div> </form> {{end}} {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOperatorVisitSlots(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	vss, err := s.storage.OperatorVisitSlots(operatorID, time.Now())
+	if err != nil {
+		return errors.New("failed to get operator visit slots from storage: " +
+			err.Error())
+	}
+
+	token, err := s.storage.OperatorCalendarToken(operatorID)
+	if err != nil {
+		return errors.New(
+			"failed to get operator calendar token from storage: " +
+				err.Error())
+	}
+
+	var calendarURL string
+
+	if token != nil {
+		calendarURL = s.publicBaseURL + "/calendars/" + *token + "/visits.ics"
+	}
+
+	return c.Render(http.StatusOK, "operator_visit_slots", echo.Map{
+		"Login":       login,
+		"VisitSlots":  vss,
+		"CalendarURL": calendarURL,
+	})
+}
+
+func (s *Server) postOperatorCreateVisitSlot(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var vs entity.VisitSlot
+
+	err = c.Bind(&vs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind visit slot: "+err.Error())
+	}
+
+	vs.OperatorID = operatorID
+
+	_, err = s.addVisitSlot(vs)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/visit-slots")
+}
+
+func (s *Server) postOperatorRemoveVisitSlot(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	var vs entity.VisitSlot
+
+	err = c.Bind(&vs)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind visit slot: "+err.Error())
+	}
+
+	err = s.storage.RemoveOperatorVisitSlot(operatorID, vs.ID)
+	if err != nil {
+		return errors.New("failed to remove operator visit slot from storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/visit-slots")
+}
+
+// postOperatorRegenerateCalendarToken publishes operator calendar by the new
+// token. Link with the previous token stops working.
+func (s *Server) postOperatorRegenerateCalendarToken(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.storage.SetOperatorCalendarToken(operatorID, &token)
+	if err != nil {
+		return errors.New(
+			"failed to set operator calendar token in storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/visit-slots")
+}
+
+func (s *Server) postOperatorRevokeCalendarToken(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	operatorID, ok := sess.Values["operator_id"].(int)
+	if !ok {
+		return errors.New("failed to get operator ID from session")
+	}
+
+	err = s.storage.SetOperatorCalendarToken(operatorID, nil)
+	if err != nil {
+		return errors.New(
+			"failed to set operator calendar token in storage: " +
+				err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/operator/visit-slots")
+}
+
+// getCalendarVisits serves iCalendar feed of operator booked visits. Feed is
+// authorized by operator calendar token since calendar applications don't
+// keep session.
+func (s *Server) getCalendarVisits(c echo.Context) error {
+	operatorID, err := s.storage.CalendarTokenOperatorID(c.Param("token"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound)
+		}
+		return errors.New(
+			"failed to get calendar token operator ID from storage: " +
+				err.Error())
+	}
+
+	vss, err := s.storage.OperatorVisitSlots(operatorID,
+		time.Now().Add(-calendarHistoryPeriod))
+	if err != nil {
+		return errors.New("failed to get operator visit slots from storage: " +
+			err.Error())
+	}
+
+	return c.Blob(http.StatusOK, "text/calendar; charset=utf-8",
+		[]byte(visitsCalendar(vss)))
+}
+
+const workOrderPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Заказ-наряд</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__input { display: block; border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-bottom: 10px; min-width: 500px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.WorkOrder.ContractorName}}</b> </div> <div class="main-root__ri"> <b class="main-root__title">Заказ-наряд №{{.WorkOrder.ID}}</b> <div class="main-root__content"> {{with .WorkOrder}} <p><b>Статус: {{.Status}}</b>, Выдан: {{.CreatedAt.Format "2006-01-02 15:04"}}{{if .DueAt}}, Срок: {{.DueAt.Format "2006-01-02"}}{{end}}</p> <p><b>Объём работ:</b> {{.Scope}}</p> <p><b>Обращение №{{.RequestID}}:</b> {{.RequestText}}</p> {{if .OwnerAddress}} <p><b>Адрес:</b> {{.OwnerAddress}}</p> {{end}} {{if .Final}} {{if .Comment}} <p>{{.Comment}}</p> {{end}} {{else}} <form method="POST"> <select class="main-cell__select" name="status" required> <option value="accepted">Принят</option> <option value="in_progress">В работе</option> <option value="completed">Выполнен</option> <option value="declined">Отклонён</option> </select> <textarea class="main-cell__text" name="comment" placeholder="Комментарий">{{if .Comment}}{{.Comment}}{{end}}</textarea> <div class="main-root__wrap"> <button type="submit">Сохранить</button> </div> </form> {{end}} {{end}} </div> </div> </div></body></html>`
+
+// getWorkOrder shows work order to the contractor. Contractors have no
+// accounts, so work order is accessed by its token.
+func (s *Server) getWorkOrder(c echo.Context) error {
+	wo, err := s.workOrder(c.Param("token"))
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "work_order", echo.Map{
+		"WorkOrder": wo,
+	})
+}
+
+func (s *Server) postWorkOrder(c echo.Context) error {
+	var wo entity.WorkOrder
+
+	err := c.Bind(&wo)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind work order: "+err.Error())
+	}
+
+	token := c.Param("token")
+
+	woe, err := s.setWorkOrderStatus(token, wo.Status, wo.Comment)
+	if err != nil {
+		return err
+	}
+
+	// Link of the final work order is expired, so it is shown once more
+	// right away.
+	if woe.Final() {
+		return c.Render(http.StatusOK, "work_order", echo.Map{
+			"WorkOrder": woe,
+		})
+	}
+
+	return c.Redirect(http.StatusFound, "/work-orders/"+token)
+}
+
+func (s *Server) getOwner(c echo.Context) error {
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+const ownerRequestsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Обращения</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/household">Жильцы квартиры</a> <a class="main-root__link" href="/owner/meters">Счётчики</a> <a class="main-root__link" href="/owner/ledger">Оплата</a> <a class="main-root__link" href="/owner/polls">Собрания</a> <a class="main-root__link" href="/owner/documents">Документы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Владелец Обращения</b> <div class="main-root__content"> {{range .Announcements}} <p><b>{{if not .ReadAt}}Новое объявление{{else}}Объявление{{end}}: {{.Title}}</b>, {{.PublishAt.Local.Format "2006-01-02 15:04"}}</p> <p>{{.Text}}</p> {{end}} {{range .Incidents}} <p><b class="main-root__txt--red">Авария: {{.Title}}</b>{{if .ExpectedResolutionAt}}, ожидаемое время устранения: {{.ExpectedResolutionAt.Format "2006-01-02 15:04"}}{{end}}</p> <p>{{.Description}}</p> <form method="post" action="/owner/join-incident"> <input type="hidden" name="incident_id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">У меня та же проблема</button> </div> </form> {{end}} {{if .CommonRequests}} <p><b>Обращения по местам общего пользования вашего дома</b></p> {{range .CommonRequests}} <p>№{{.ID}}, Статус: {{.Status}}, {{.CommonArea}}{{if .Entrance}}, подъезд {{.Entrance}}{{end}}{{if .Floor}}, этаж {{.Floor}}{{end}}: {{.Text}}{{if .SupportsCount}} (+{{.SupportsCount}}){{end}}</p> {{if not .Supported}} <form method="post" action="/owner/support-request"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Меня это тоже касается</button> </div> </form> {{end}} {{end}} {{end}} <form method="post" action="/owner/create-request"> <textarea name="text" placeholder="Текст обращения" class="main-cell__text"></textarea> <div class="main-root__wrap"> <select name="common_area"> <option value="">Моя квартира</option> <option value="stairwell">Подъезд, лестница</option> <option value="elevator">Лифт</option> <option value="basement">Подвал</option> <option value="roof">Крыша</option> <option value="yard">Двор</option> <option value="other">Другое</option> </select> <input type="text" name="entrance" placeholder="Подъезд" /> <input type="number" name="floor" placeholder="Этаж" /> </div> <label class="main-cell__check"><input type="checkbox" name="priority" value="urgent" /> Срочно</label> <div class="main-root__wrap"> <button type="submit">Отправить</button> </div> </form> {{if .Confirm}} {{if .DuplicatesCount}} <p><b>Похожих обращений по вашему дому уже подано: {{.DuplicatesCount}}</b></p> {{end}} {{if .Incidents}} <p><b>Возможно, ваша проблема связана с аварией, указанной выше.</b></p> {{end}} <form method="post" action="/owner/create-request"> <input type="hidden" name="text" value="{{.Text}}" /> <input type="hidden" name="priority" value="{{.Priority}}" /> {{with .Request}}{{if .HasCommonArea}} <input type="hidden" name="common_area" value="{{.CommonArea}}" /> {{if .Entrance}}<input type="hidden" name="entrance" value="{{.Entrance}}" />{{end}} {{if .Floor}}<input type="hidden" name="floor" value="{{.Floor}}" />{{end}} {{end}}{{end}} <input type="hidden" name="confirmed" value="true" /> <div class="main-root__wrap"> <button type="submit">Всё равно отправить</button> </div> </form> {{end}} <form method="GET" action="/owner/requests"> <div class="main-root__wrap"> <input type="text" name="q" value="{{.Query.Get "q"}}" placeholder="Поиск по тексту и ответу" /> {{$st := .Query.Get "statuses"}} <select name="statuses"> <option value="">Все статусы</option> <option value="new" {{if eq $st "new"}}selected{{end}}>new</option> <option value="in_progress" {{if eq $st "in_progress"}}selected{{end}}>in_progress</option> <option value="resolved" {{if eq $st "resolved"}}selected{{end}}>resolved</option> <option value="rejected" {{if eq $st "rejected"}}selected{{end}}>rejected</option> <option value="irrelevant" {{if eq $st "irrelevant"}}selected{{end}}>irrelevant</option> <option value="cancelled" {{if eq $st "cancelled"}}selected{{end}}>cancelled</option> </select> </div> <div class="main-root__wrap"> <label>С <input type="date" name="from" value="{{.Query.Get "from"}}" /></label> <label>По <input type="date" name="to" value="{{.Query.Get "to"}}" /></label> <button type="submit">Найти</button> </div> </form> {{range .Requests}} {{$own := .CreatedBy $.OwnerID}} <p><b>{{.ID}}</b> , <b>Статус: {{.Status}}</b>, Дата и время: {{.CreatedAt.Format "2006-01-02 15:04"}}</p> {{if not $own}} <p>Автор: {{.OwnerName}}</p> {{end}} {{if .CategoryName}} <p>Категория: {{.CategoryName}}</p> {{end}} {{if .Progress}} <p>Выполнено работ: {{.Progress}}%</p> {{end}} {{if .HasEmergencyPriority}} <p>Приоритет: аварийное</p> {{else if .HasUrgentPriority}} <p>Приоритет: срочное</p> {{end}} {{if .PrimaryRequestID}} <p>Объединено с обращением №{{.PrimaryRequestID}}</p> {{end}} {{if .IncidentID}} <p>Прикреплено к аварии №{{.IncidentID}}</p> {{end}} {{if .HasCommonArea}} <p><b>Место:</b> {{.Building}}{{if .Entrance}}, подъезд {{.Entrance}}{{end}}{{if .Floor}}, этаж {{.Floor}}{{end}}, {{.CommonArea}}{{if .SupportsCount}}, подтвердили жильцы: {{.SupportsCount}}{{end}}</p> {{end}} {{range .CostItems}} <p>К оплате: {{.Name}}, {{.Quantity}} x {{.UnitPrice}} = {{.Amount}}</p> {{end}} {{range .WorkOrders}} <p>Работы подрядчика {{.ContractorName}}: {{.Scope}}, Статус: {{.Status}}{{if .DueAt}}, Срок: {{.DueAt.Format "2006-01-02"}}{{end}}</p> {{end}} {{if and $own .HasNewStatus}} <form method="post" action="/owner/edit-request"> <input type="hidden" name="id" value="{{.ID}}" /> <textarea name="text" class="main-cell__text">{{.Text}}</textarea> <div class="main-root__wrap"> <button type="submit">Изменить</button> </div> </form> {{else}} <p>{{.Text}}</p> {{end}} {{if .Response}} <p>{{.Response}}</p> {{end}} {{if and $own (or .HasNewStatus .HasInProgressStatus)}} <form method="post" action="/owner/cancel-request"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <input type="text" name="reason" placeholder="Причина отмены" /> <button type="submit">Отменить обращение</button> </div> </form> {{end}} {{$id := .ID}} {{if .Visit}} <p><b>Визит специалиста: {{.Visit.StartsAt.Local.Format "2006-01-02 15:04"}} - {{.Visit.EndsAt.Local.Format "15:04"}}</b></p> <form method="post" action="/owner/cancel-visit"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Отменить визит</button> </div> </form> {{end}} {{if .FreeVisitSlots}} <form method="post" action="/owner/book-visit"> <input type="hidden" name="id" value="{{$id}}" /> <select class="main-cell__select" name="visit_slot_id" required> {{range .FreeVisitSlots}} <option value="{{.ID}}">{{.StartsAt.Local.Format "2006-01-02 15:04"}} - {{.EndsAt.Local.Format "15:04"}}</option> {{end}} </select> <div class="main-root__wrap"> <button type="submit">{{if .Visit}}Перенести визит{{else}}Записаться на визит{{end}}</button> </div> </form> {{end}} {{end}} {{if .NextURL}} <p><a href="{{.NextURL}}">Следующая страница</a></p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerRequests(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	f, err := parseRequestFilter(c, sorting.Newest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse request filter: "+err.Error())
+	}
+
+	rs, cursor, err := s.ownerRequests(ownerID, f)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	is, err := s.buildingIncidents(owner.OrganizationID, owner.Address)
+	if err != nil {
+		return err
+	}
+
+	crs, err := s.buildingCommonRequests(owner)
+	if err != nil {
+		return err
+	}
+
+	as, err := s.ownerAnnouncements(ownerID)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "owner_requests", echo.Map{
+		"Login":          login,
+		"OwnerID":        ownerID,
+		"Requests":       rs,
+		"Incidents":      is,
+		"CommonRequests": crs,
+		"Announcements":  as,
+		"Query":          c.QueryParams(),
+		"NextURL":        nextPageURL(c, cursor),
+	})
+}
+
+func (s *Server) postOwnerCreateRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	organizationID, ok := sess.Values["organization_id"].(int)
+	if !ok {
+		return errors.New("failed to get organization ID from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	r = entity.Request{
+		OrganizationID: organizationID,
+		OwnerID:        &ownerID,
+		CreatorRole:    role.Owner,
+		CreatorID:      ownerID,
+		Entrance:       r.Entrance,
+		Floor:          r.Floor,
+		CommonArea:     r.CommonArea,
+		Text:           r.Text,
+		Status:         status.New,
+		Priority:       priority.Normal,
+		CreatedAt:      time.Now(),
+	}
+
+	if c.FormValue("priority") == priority.Urgent {
+		r.Priority = priority.Urgent
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	r, err = ownerRequestLocation(r, owner)
+	if err != nil {
+		return err
+	}
+
+	r = s.classifyRequest(r)
+
+	rds, err := s.findDuplicates(r, owner.Address)
+	if err != nil {
+		s.log.WithError(err).Error("failed to find request duplicates")
+	}
+
+	is, err := s.buildingIncidents(organizationID, owner.Address)
+	if err != nil {
+		s.log.WithError(err).Error("failed to find building incidents")
+	}
+
+	if (len(rds) > 0 || len(is) > 0) && c.FormValue("confirmed") != "true" {
+		rs, _, err := s.ownerRequests(ownerID, entity.RequestFilter{
+			Limit: requestsDefaultLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		crs, err := s.buildingCommonRequests(owner)
+		if err != nil {
+			return err
+		}
+
+		return c.Render(http.StatusOK, "owner_requests", echo.Map{
+			"Login":           login,
+			"OwnerID":         ownerID,
+			"Requests":        rs,
+			"Incidents":       is,
+			"CommonRequests":  crs,
+			"Confirm":         true,
+			"DuplicatesCount": len(rds),
+			"Request":         r,
+			"Text":            r.Text,
+			"Priority":        r.Priority,
+			"Query":           c.QueryParams(),
+		})
+	}
+
+	r, o := s.routeRequest(r)
+
+	r, err = s.storage.AddRequest(r)
+	if err != nil {
+		return errors.New("failed to add request to storage: " + err.Error())
+	}
+
+	s.addRequestDuplicates(r, rds)
+	s.applyChecklistTemplate(r)
+	s.notifyEmergency(r, o)
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerSupportRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	err = s.supportRequest(owner, r.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerJoinIncident(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	if r.IncidentID == nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"incident ID required")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	r.OrganizationID = owner.OrganizationID
+	r.OwnerID = &owner.ID
+	r.CreatorRole = role.Owner
+	r.CreatorID = owner.ID
+
+	r, err = ownerRequestLocation(r, owner)
+	if err != nil {
+		return err
+	}
+
+	r, err = s.attachRequest(r, owner.Address)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.storage.AddRequest(r)
+	if err != nil {
+		return errors.New("failed to add request to storage: " + err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerEditRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	err = s.editRequest(ownerID, r.ID, r.Text)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerCancelRequest(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	var params struct {
+		ID     int
+		Reason string
+	}
+
+	err = c.Bind(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind params: "+err.Error())
+	}
+
+	err = s.cancelRequest(ownerID, params.ID, params.Reason)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerBookVisit(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	var params struct {
+		ID          int
+		VisitSlotID int `form:"visit_slot_id"`
+	}
+
+	err = c.Bind(&params)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind params: "+err.Error())
+	}
+
+	err = s.bookVisitSlot(ownerID, params.ID, params.VisitSlotID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+func (s *Server) postOwnerCancelVisit(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	ownerID, ok := sess.Values["owner_id"].(int)
+	if !ok {
+		return errors.New("failed to get owner ID from session")
+	}
+
+	var r entity.Request
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind request: "+err.Error())
+	}
+
+	err = s.storage.CancelVisit(ownerID, r.ID)
+	if err != nil {
+		return errors.New("failed to cancel visit in storage: " +
+			err.Error())
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/requests")
+}
+
+const ownerHouseholdPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Жильцы квартиры</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/requests">Обращения</a> <a class="main-root__link" href="/owner/meters">Счётчики</a> <a class="main-root__link" href="/owner/ledger">Оплата</a> <a class="main-root__link" href="/owner/polls">Собрания</a> <a class="main-root__link" href="/owner/documents">Документы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Жильцы квартиры</b> <div class="main-root__content"> {{with .Owner}}{{if .Address}} <p><b>Адрес:</b> {{.Address}}</p> {{else}} <p>Вы не привязаны к квартире, обратитесь в управляющую организацию.</p> {{end}}{{end}} {{range .Members}} <p>{{.Name}}, {{.Phone}}, {{if .HasHouseholdRole "owner"}}владелец{{else if .HasHouseholdRole "co_owner"}}совладелец{{else if .HasHouseholdRole "tenant"}}арендатор{{else}}член семьи{{end}}</p> {{if and $.Owner.PrimaryOwner (ne .ID $.Owner.ID)}} <form method="post" action="/owner/remove-household-member"> <input type="hidden" name="id" value="{{.ID}}" /> <div class="main-root__wrap"> <button type="submit">Удалить</button> </div> </form> {{end}} {{end}} {{if .Owner.PrimaryOwner}} <p><b>Пригласить жильца</b></p> <form method="post" action="/owner/invite-household-member"> <div class="main-root__wrap"> <input type="text" name="phone" placeholder="Телефон" required /> <input type="text" name="name" placeholder="Имя" /> <select name="household_role"> <option value="co_owner">Совладелец</option> <option value="tenant">Арендатор</option> <option value="family_member">Член семьи</option> </select> <button type="submit">Пригласить</button> </div> </form> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerHousehold(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	ms, err := s.storage.HouseholdMembers(owner.ID)
+	if err != nil {
+		return errors.New("failed to get household members from storage: " +
+			err.Error())
+	}
+
+	return c.Render(http.StatusOK, "owner_household", echo.Map{
+		"Login":   login,
+		"Owner":   owner,
+		"Members": ms,
+	})
+}
+
+func (s *Server) postOwnerInviteHouseholdMember(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var m entity.Owner
+
+	err = c.Bind(&m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind owner: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	_, err = s.inviteHouseholdMember(owner, m)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/household")
+}
+
+func (s *Server) postOwnerRemoveHouseholdMember(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var m entity.Owner
+
+	err = c.Bind(&m)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind owner: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	err = s.removeHouseholdMember(owner, m.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/household")
+}
+
+const ownerMetersPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Счётчики</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/requests">Обращения</a> <a class="main-root__link" href="/owner/household">Жильцы квартиры</a> <a class="main-root__link" href="/owner/ledger">Оплата</a> <a class="main-root__link" href="/owner/polls">Собрания</a> <a class="main-root__link" href="/owner/documents">Документы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Счётчики</b> <div class="main-root__content"> {{if .Open}} <p>Передайте показания за {{.Period.Format "01.2006"}} до {{.ToDay}} числа.</p> {{else}} <p>Показания принимаются с {{.FromDay}} по {{.ToDay}} число месяца.</p> {{end}} {{range .Meters}} <p><b>{{if eq .Type "cold_water"}}Холодная вода{{else if eq .Type "hot_water"}}Горячая вода{{else if eq .Type "heating"}}Отопление{{else if eq .Type "electricity"}}Электроэнергия{{else}}Газ{{end}}</b>, № {{.SerialNumber}}{{if .VerificationDate}}, поверка до {{.VerificationDate.Format "02.01.2006"}}{{end}}</p> {{if .LastValue}} <p>Последние показания: {{.LastValue}} за {{.LastPeriod.Format "01.2006"}}{{if .Submitted $.Period}}, переданы{{end}}</p> {{end}} {{if $.Open}} <form method="post" action="/owner/submit-meter-reading"> <input type="hidden" name="meter_id" value="{{.ID}}" /> <div class="main-root__wrap"> <input type="number" step="0.001" min="0" name="value" placeholder="Показания" required /> <button type="submit">{{if .Submitted $.Period}}Исправить{{else}}Передать{{end}}</button> </div> </form> {{end}} {{else}} <p>Счётчики квартиры не зарегистрированы, обратитесь в управляющую организацию.</p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerMeters(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	ms, err := s.ownerMeters(owner)
+	if err != nil {
+		return err
+	}
+
+	period, open := entity.MeterReadingsPeriod(time.Now())
+
+	return c.Render(http.StatusOK, "owner_meters", echo.Map{
+		"Login":   login,
+		"Meters":  ms,
+		"Period":  period,
+		"Open":    open,
+		"FromDay": entity.MeterReadingsFromDay,
+		"ToDay":   entity.MeterReadingsToDay,
+	})
+}
+
+func (s *Server) postOwnerSubmitMeterReading(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	var r entity.MeterReading
+
+	err = c.Bind(&r)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind meter reading: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	_, err = s.submitMeterReading(owner, r)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/meters")
+}
+
+const ownerPollsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Собрания</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/requests">Обращения</a> <a class="main-root__link" href="/owner/household">Жильцы квартиры</a> <a class="main-root__link" href="/owner/meters">Счётчики</a> <a class="main-root__link" href="/owner/ledger">Оплата</a> <a class="main-root__link" href="/owner/documents">Документы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Собрания</b> <div class="main-root__content"> {{range .Polls}} {{$p := .}} <p><b>{{.Title}}</b>, голосование {{.StartsAt.Local.Format "2006-01-02 15:04"}} - {{.EndsAt.Local.Format "2006-01-02 15:04"}}</p> {{if .Description}} <p>{{.Description}}</p> {{end}} {{if .Finished $.Now}} <p>Голосование завершено. Приняли участие {{printf "%.2f" .VotedPercent}}% голосов, кворум {{if .QuorumReached}}имеется{{else}}отсутствует{{end}}.</p> {{else if not (.Started $.Now)}} <p>Голосование ещё не началось.</p> {{end}} {{range $q := .Questions}} {{$v := $q.Vote $.OwnerID}} <p><b>Вопрос {{$q.Number}}. {{$q.Text}}</b>{{if $q.Multiple}} (можно выбрать несколько вариантов){{end}}</p> {{if and $.Voter ($p.Open $.Now)}} <form method="post" action="/owner/vote-poll"> <input type="hidden" name="poll_id" value="{{$p.ID}}" /> <input type="hidden" name="question_id" value="{{$q.ID}}" /> {{range $o, $text := $q.Options}} <label class="main-cell__check"><input type="{{if $q.Multiple}}checkbox{{else}}radio{{end}}" name="options" value="{{$o}}" {{if $v}}{{if $v.Chose $o}}checked{{end}}{{end}} /> {{$text}}</label> {{end}} <div class="main-root__wrap"> <button type="submit">{{if $v}}Переголосовать{{else}}Проголосовать{{end}}</button> </div> </form> {{else}} {{if $v}} <p>Ваш голос: {{range $o, $text := $q.Options}}{{if $v.Chose $o}}{{$text}}. {{end}}{{end}}</p> {{end}} {{end}} {{if $p.Finished $.Now}} {{range $q.Results}} <p>{{.Option}}: {{printf "%.2f" .Percent}}%</p> {{end}} {{end}} {{end}} {{else}} <p>Собраний по вашему дому нет.</p> {{end}} {{if not .Voter}} <p>Голосовать могут только собственники квартир.</p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerPolls(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	prs, err := s.ownerPolls(owner)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "owner_polls", echo.Map{
+		"Login":   login,
+		"Polls":   prs,
+		"OwnerID": owner.ID,
+		"Voter":   pollVoter(owner),
+		"Now":     time.Now(),
+	})
+}
+
+func (s *Server) postOwnerVotePoll(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	pollID, err := strconv.Atoi(c.FormValue("poll_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse poll_id: "+err.Error())
+	}
+
+	var v entity.PollVote
+
+	err = c.Bind(&v)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to bind poll vote: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	_, err = s.votePoll(owner, pollID, v)
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, "/owner/polls")
+}
+
+const ownerLedgerPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Оплата</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/requests">Обращения</a> <a class="main-root__link" href="/owner/household">Жильцы квартиры</a> <a class="main-root__link" href="/owner/meters">Счётчики</a> <a class="main-root__link" href="/owner/polls">Собрания</a> <a class="main-root__link" href="/owner/documents">Документы</a> </div> <div class="main-root__ri"> <b class="main-root__title">Оплата</b> <div class="main-root__content"> {{with .Ledger}} {{if .FlatID}} {{if .Debt}} <p><b class="main-root__txt--red">Задолженность: {{.Debt}} ₽</b></p> {{else if .Overpayment}} <p><b>Переплата: {{.Overpayment}} ₽</b></p> {{else}} <p><b>Задолженности нет</b></p> {{end}} <form method="post" action="/owner/pay"> <div class="main-root__wrap"> <input type="number" step="0.01" min="0.01" name="amount" value="{{if .Debt}}{{.Debt}}{{end}}" placeholder="Сумма, ₽" required /> <button type="submit">Оплатить</button> </div> </form> <p><b>Оплаты</b></p> {{range .Payments}} <p>{{.PaidAt.Local.Format "2006-01-02 15:04"}}: {{.Amount}} ₽</p> {{else}} <p>Оплат нет.</p> {{end}} <p><b>Начисления</b></p> {{range .Charges}} <p>{{.Period.Format "01.2006"}}, {{.Service}}: {{.Amount}} ₽</p> {{else}} <p>Начислений нет.</p> {{end}} {{else}} <p>Квартира не указана, обратитесь в управляющую организацию.</p> {{end}} {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerLedger(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	l, err := s.ownerLedger(owner)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "owner_ledger", echo.Map{
+		"Login":  login,
+		"Ledger": l,
+	})
+}
+
+func (s *Server) postOwnerPay(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	pd, err := bindPaymentData(c)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	u, err := s.ownerPaymentURL(owner, pd.Amount, "/owner/ledger")
+	if err != nil {
+		return err
+	}
+
+	return c.Redirect(http.StatusFound, u)
+}
+
+const ownerDocumentsPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Владелец / Документы</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-root { display: flex; } .main-root__le { height: 100vh; width: 15%; border-right: 1px solid #CCCCCC; } .main-root__user { display: block; font-size: 14px; padding: 15px; box-shadow: -8px -2px 10px rgba(0, 0, 0, 0.2) } .main-root__link { position: relative; display: block; font-size: 15px; padding: 15px 0 15px 60px; color: #4D4D4E; text-decoration: none; } .main-root__link::before { content: ""; position: absolute; top: 50%; left: 20px; display: block; width: 20px; height: 19px; margin-top: -9.5px; background-image: url(https://svgshare.com/i/FDc.svg); } .main-root__link--active, .main-root__link:hover { color: #00B858; } .main-root__ri { width: 84%; } .main-root__content { padding-top: 1%; padding-left: 1%; } .main-root__title { display: block; padding: 15px; background-color: #00B858; color: #fff; } .main-root__wrap { display: flex; margin-bottom: 20px; } .main-root__wrap input { border: 1px solid #E0E0E0; font-size: 16px; padding: 10px 15px; margin-right: 10px; } .main-root__wrap button { cursor: pointer; border-radius: 3px; background-color: #EEEEEE; text-transform: uppercase; border: none; font-size: 14px; color: #1B1B1B; padding: 10px; } .main-root__wrap button:hover { background-color: #00B858; color: #fff; } .main-cell__select { padding: 10px 15px; margin-bottom: 10px; display: block; } .main-cell__text { display: block; border: none; border-bottom: 1px solid #ccc; margin-bottom: 10px; } .main-cell__text:focus { outline: none; } select, button { min-width: 300px; } .main-cell__text { min-width: 500px; min-height: 100px; } .main-cell__check { display: block; margin-bottom: 10px; } .main-root__txt--red { color: #ff0000; } </style></head><body> <div class="main-root"> <div class="main-root__le"> <b class="main-root__user">{{.Login}}</b> <a class="main-root__link" href="/logout">Выход</a> <a class="main-root__link" href="/owner/requests">Обращения</a> <a class="main-root__link" href="/owner/household">Жильцы квартиры</a> <a class="main-root__link" href="/owner/meters">Счётчики</a> <a class="main-root__link" href="/owner/ledger">Оплата</a> <a class="main-root__link" href="/owner/polls">Собрания</a> </div> <div class="main-root__ri"> <b class="main-root__title">Документы</b> <div class="main-root__content"> {{range .Documents}} <p><a href="/owner/documents/{{.ID}}/download"><b>{{.Title}}</b></a>{{if .FolderName}}, {{.FolderName}}{{end}}, версия {{.Version}} от {{.UpdatedAt.Local.Format "2006-01-02"}}, {{.FileName}}</p> {{if .Description}} <p>{{.Description}}</p> {{end}} {{else}} <p>Документов нет.</p> {{end}} </div> </div> </div></body></html>`
+
+func (s *Server) getOwnerDocuments(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	ds, err := s.ownerDocuments(owner.ID)
+	if err != nil {
+		return err
+	}
+
+	return c.Render(http.StatusOK, "owner_documents", echo.Map{
+		"Login":     login,
+		"Documents": ds,
+	})
+}
+
+func (s *Server) getOwnerDocumentDownload(c echo.Context) error {
+	sess, err := session.Get("session", c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to get session")
+	}
+
+	login, ok := sess.Values["login"].(string)
+	if !ok {
+		return errors.New("failed to get login from session")
+	}
+
+	documentID, err := strconv.Atoi(c.Param("document_id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			"failed to parse document_id: "+err.Error())
+	}
+
+	owner, err := s.storage.Owner(login)
+	if err != nil {
+		return errors.New("failed to get owner from storage: " + err.Error())
+	}
+
+	v, err := s.ownerDocumentVersion(owner.ID, documentID)
+	if err != nil {
+		return err
+	}
+
+	return s.downloadDocumentVersion(c, v)
+}
+
+const paymentStubPage = `<!DOCTYPE html><html><head> <title>ЖКХ Пульс / Тестовая оплата</title> <style> * { box-sizing: border-box; } body { margin: 0; font-family: Arial; } .main-cell { height: 100vh; display: flex; align-items: center; justify-content: center; } .main-cell__wrap { width: 520px; padding: 50px 45px; border: 1px solid #F4F4F4; border-radius: 14px; } .main-cell__image { display: flex; justify-content: center; } .main-cell__link { color: #6A6A66; } .main-cell__radio { margin: 15px 0; } .main-cell__radio-cell { display: inline-flex; align-items: center; margin-bottom: 5px; margin-right: 15px; } .main-cell__radio-cell input { -webkit-appearance: none; position: absolute; } .main-cell__radio-cell input+div { position: relative; display: inline-block; width: 16px; height: 16px; border: 2px solid #656565; margin-right: 5px; cursor: pointer; } .main-cell__radio-cell input+div::before { display: none; content: ""; position: absolute; top: 50%; left: 50%; width: 5px; height: 5px; margin-top: -2.5px; margin-left: -2.5px; background-color: #656565; } .main-cell__radio-cell input:checked+div::before { display: block; } .main-cell__radio-cell label { cursor: pointer; } .main-cell__input-cell input { width: 100%; padding: 25px; margin-bottom: 5px; background-color: #EFF0F3; color: #6A6A66; font-size: 16px; border: none; } .main-cell__button button { cursor: pointer; color: #ffffff; background-color: #00B858; width: 100%; padding: 25px; font-size: 26px; border: none; } .main-cell__button button:hover { background-color: #000; } </style></head><body> <div class="main-cell"> <div class="main-cell__wrap"> <div class="main-cell__image"> <div> <img src="https://svgshare.com/i/FDG.svg" width="435" alt="logo"> </div> </div> <p>Тестовый платёжный шлюз. Деньги не списываются.</p> <p><b>К оплате: {{.Amount}} ₽</b></p> <div class="main-cell__form"> <form method="POST" action="/payment/callback"> {{range $k, $v := .Fields}} <input type="hidden" name="{{$k}}" value="{{index $v 0}}" /> {{end}} <div class="main-cell__button"> <button type="submit">Оплатить</button> </div> </form> </div> {{if .ReturnURL}} <a class="main-cell__link" href="{{.ReturnURL}}">Отмена</a> {{end}} </div> </div></body></html>`
+
+func (s *Server) getPaymentStub(c echo.Context) error {
+	returnURL := c.QueryParam("return_url")
+	if !localURL(returnURL) {
+		returnURL = ""
+	}
+
+	return c.Render(http.StatusOK, "payment_stub", echo.Map{
+		"Amount":    c.QueryParam("amount"),
+		"Fields":    c.QueryParams(),
+		"ReturnURL": returnURL,
+	})
+}
+
+func (s *Server) postPaymentCallback(c echo.Context) error {
+	err := s.receivePayment(c.Request())
+	if err != nil {
+		return err
+	}
+
+	returnURL := c.FormValue("return_url")
+	if localURL(returnURL) {
+		return c.Redirect(http.StatusFound, returnURL)
+	}
+
+	return c.NoContent(http.StatusOK)
 }